@@ -0,0 +1,56 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"net/http"
+	"runtime"
+
+	"github.com/graze/golang-service/log"
+)
+
+type allocationHandler struct {
+	threshold uint64
+	handler   http.Handler
+}
+
+// ServeHTTP logs the bytes allocated by the process while the request was handled
+func (h allocationHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	h.handler.ServeHTTP(w, req)
+	runtime.ReadMemStats(&after)
+
+	allocated := after.TotalAlloc - before.TotalAlloc
+	fields := log.KV{"http.alloc_bytes": allocated}
+	if h.threshold > 0 && allocated > h.threshold {
+		fields["http.alloc_warn"] = true
+	}
+	addLogFields(req, fields)
+}
+
+// AllocationGuard returns a middleware that logs the bytes allocated while each request is handled as
+// `http.alloc_bytes`, to find crafted input that causes pathological allocations. If threshold is positive, requests
+// allocating more than threshold bytes also log `http.alloc_warn=true`
+//
+// The allocations are sampled from the runtime before and after the request, so they include anything allocated by
+// other goroutines at the same time and are only a guide. Reading them briefly stops the world, so the middleware
+// should only be used on the handlers for untrusted input that need it
+//
+// Usage:
+//  guard := handlers.AllocationGuard(10 << 20)
+//  r.Handle("/import", guard(importHandler))
+//  http.ListenAndServe(":1123", handlers.StructuredHandler(r))
+func AllocationGuard(threshold uint64) func(h http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return allocationHandler{threshold, h}
+	}
+}
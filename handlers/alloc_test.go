@@ -0,0 +1,56 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Sirupsen/logrus/hooks/test"
+	"github.com/graze/golang-service/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllocationGuard(t *testing.T) {
+	cases := map[string]struct {
+		threshold uint64
+		warn      bool
+	}{
+		"no threshold":        {0, false},
+		"under the threshold": {1 << 30, false},
+		"over the threshold":  {1 << 19, true},
+	}
+
+	allocating := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(make([]byte, 1<<20))
+	})
+
+	for k, tc := range cases {
+		logger := log.New("", "", "")
+		hook := test.NewLocal(logger.Logger)
+
+		handler := StructuredLogHandler(logger, AllocationGuard(tc.threshold)(allocating))
+		handler.ServeHTTP(httptest.NewRecorder(), newRequest("POST", "http://example.com/import"))
+
+		if assert.Equal(t, 1, len(hook.Entries), "test: %s", k) {
+			data := hook.LastEntry().Data
+			if assert.IsType(t, uint64(0), data["http.alloc_bytes"], "test: %s", k) {
+				assert.True(t, data["http.alloc_bytes"].(uint64) >= 1<<20, "test: %s the allocation is recorded", k)
+			}
+			if tc.warn {
+				assert.Equal(t, true, data["http.alloc_warn"], "test: %s", k)
+			} else {
+				assert.NotContains(t, data, "http.alloc_warn", "test: %s", k)
+			}
+		}
+	}
+}
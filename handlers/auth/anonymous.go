@@ -0,0 +1,120 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/graze/golang-service/handlers/failure"
+)
+
+// AnonymousRateLimit lets requests without credentials through under a stricter rate limit for each client ip, and
+// passes requests with credentials to an authentication handler
+//
+// Requests with credentials are authenticated as normal, so an invalid key is still rejected rather than treated as
+// anonymous. Use a KeyRateLimiter as the Finder of the authentication handler to give each key its own rate limit.
+// The bucket that applied is returned by GetRateLimitBucket
+type AnonymousRateLimit struct {
+	// Rate is the number of requests per second allowed for each anonymous client ip
+	Rate float64
+	// Burst is the number of requests each anonymous client ip can make at once
+	Burst int
+	// ClientIP returns the ip the request was made from, the default uses the address of the connection. Use
+	// TrustedProxyIP when the service is behind a proxy or load balancer
+	ClientIP func(r *http.Request) string
+	// HasCredentials returns true if the request should be authenticated. The default checks for an Authorization or
+	// X-Api-Key header, or a client certificate
+	HasCredentials func(r *http.Request) bool
+	// OnError gets called if an anonymous request is rate limited, if nil the default set by SetDefaultOnError is used
+	OnError failure.Handler
+
+	auth    func(http.Handler) http.Handler
+	limiter *KeyRateLimiter
+}
+
+// ThenFunc surrounds an existing handler func and returns a new http.Handler
+//
+// Usage:
+//  anon := auth.NewAnonymousRateLimit(keyAuth.Handler, 1, 5, time.Hour, failure.HandlerFunc(onError))
+//
+//  http.Handle("/thing", anon.ThenFunc(ThingFunc))
+func (a *AnonymousRateLimit) ThenFunc(fn func(http.ResponseWriter, *http.Request)) http.Handler {
+	return a.Handler(http.HandlerFunc(fn))
+}
+
+// Then surrounds an existing http.Handler and returns a new http.Handler
+//
+// Usage:
+//  anon := auth.NewAnonymousRateLimit(keyAuth.Handler, 1, 5, time.Hour, failure.HandlerFunc(onError))
+//
+//  http.Handle("/thing", anon.Then(ThingHandler))
+func (a *AnonymousRateLimit) Then(h http.Handler) http.Handler {
+	return a.Handler(h)
+}
+
+// Handler wraps the Then method to become clearer
+func (a *AnonymousRateLimit) Handler(h http.Handler) http.Handler {
+	authenticated := a.auth(h)
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		req = Track(req)
+		hasCredentials := a.HasCredentials
+		if hasCredentials == nil {
+			hasCredentials = hasAnyCredentials
+		}
+		if hasCredentials(req) {
+			authenticated.ServeHTTP(w, req)
+			return
+		}
+
+		clientIP := a.ClientIP
+		if clientIP == nil {
+			clientIP = remoteIP
+		}
+		wait, remaining := a.limiter.take(fingerprint(clientIP(req)), a.Rate, a.Burst)
+		saveRateLimit(req, "anonymous", remaining, wait > 0)
+		if wait > 0 {
+			orDefault(a.OnError).Handle(w, req, &RateLimitExceededError{wait}, http.StatusTooManyRequests)
+			return
+		}
+
+		h.ServeHTTP(w, req)
+	})
+}
+
+// hasAnyCredentials returns true if the request has an Authorization or X-Api-Key header, or a client certificate
+func hasAnyCredentials(r *http.Request) bool {
+	if r.Header.Get("Authorization") != "" || r.Header.Get("X-Api-Key") != "" {
+		return true
+	}
+	_, ok := peerCert(r)
+	return ok
+}
+
+// NewAnonymousRateLimit returns an AnonymousRateLimit that authenticates requests with credentials using auth (such as
+// the Handler method of an APIKey), and limits each anonymous client ip to rate requests per second with a burst,
+// forgetting ips that have not been seen for expiry
+//
+// Usage:
+//  finder := auth.NewKeyRateLimiter(auth.FinderFunc(finder), time.Hour)
+//  keyAuth := auth.NewAPIKey("Graze", finder, failure.HandlerFunc(onError))
+//  anon := auth.NewAnonymousRateLimit(keyAuth.Handler, 1, 5, time.Hour, failure.HandlerFunc(onError))
+//
+//  http.Handle("/", anon.Then(router))
+func NewAnonymousRateLimit(auth func(http.Handler) http.Handler, rate float64, burst int, expiry time.Duration, onError failure.Handler) *AnonymousRateLimit {
+	return &AnonymousRateLimit{
+		Rate:    rate,
+		Burst:   burst,
+		OnError: onError,
+		auth:    auth,
+		limiter: NewKeyRateLimiter(nil, expiry),
+	}
+}
@@ -0,0 +1,105 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/graze/golang-service/handlers/failure"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnonymousRateLimit(t *testing.T) {
+	inner := &mapFinder{users: map[string]interface{}{"paid": &quotaUser{10, 5}}}
+	keyLimiter := NewKeyRateLimiter(inner, time.Hour)
+	var authErr error
+	onError := failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, status int) {
+		authErr = err
+		w.WriteHeader(status)
+	})
+	keyAuth := NewAPIKey("Graze", keyLimiter, onError)
+	anon := NewAnonymousRateLimit(keyAuth.Handler, 1, 2, time.Hour, onError)
+	now := time.Now()
+	anon.limiter.now = func() time.Time { return now }
+	keyLimiter.now = func() time.Time { return now }
+
+	var bucket string
+	var user interface{}
+	handler := anon.ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+		bucket = GetRateLimitBucket(r)
+		user = GetUser(r)
+	})
+	serve := func(ip string, headers map[string]string) int {
+		bucket, user, authErr = "", nil, nil
+		req := headerRequest(t, "GET", "/stuff", headers)
+		req.RemoteAddr = ip + ":3421"
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	cases := []struct {
+		ip      string
+		headers map[string]string
+		status  int
+		bucket  string
+		user    interface{}
+	}{
+		{"10.0.0.1", map[string]string{}, http.StatusOK, "anonymous", nil},
+		{"10.0.0.1", map[string]string{}, http.StatusOK, "anonymous", nil},
+		{"10.0.0.1", map[string]string{}, http.StatusTooManyRequests, "", nil},
+		{"10.0.0.2", map[string]string{}, http.StatusOK, "anonymous", nil},
+		{"10.0.0.1", map[string]string{"Authorization": "Graze paid"}, http.StatusOK, "key", inner.users["paid"]},
+		{"10.0.0.1", map[string]string{"Authorization": "Graze paid"}, http.StatusOK, "key", inner.users["paid"]},
+		{"10.0.0.1", map[string]string{"Authorization": "Graze paid"}, http.StatusOK, "key", inner.users["paid"]},
+		{"10.0.0.1", map[string]string{"Authorization": "Graze invalid"}, http.StatusUnauthorized, "", nil},
+	}
+
+	for i, tc := range cases {
+		assert.Equal(t, tc.status, serve(tc.ip, tc.headers), "request: %d", i)
+		assert.Equal(t, tc.bucket, bucket, "request: %d", i)
+		assert.Equal(t, tc.user, user, "request: %d", i)
+	}
+
+	serve("10.0.0.1", map[string]string{})
+	assert.IsType(t, &RateLimitExceededError{}, authErr)
+	assert.Equal(t, time.Second, authErr.(*RateLimitExceededError).RetryAfter())
+
+	now = now.Add(time.Second)
+	assert.Equal(t, http.StatusOK, serve("10.0.0.1", map[string]string{}), "the anonymous bucket refills")
+}
+
+func TestAnonymousRateLimitRecordsState(t *testing.T) {
+	keyAuth := NewAPIKey("Graze", &mapFinder{}, nil)
+	anon := NewAnonymousRateLimit(keyAuth.Handler, 1, 1, time.Hour, nil)
+	anon.HasCredentials = func(r *http.Request) bool { return false }
+	now := time.Now()
+	anon.limiter.now = func() time.Time { return now }
+
+	req := Track(ipRequest(t, "10.0.0.1"))
+	req.Header.Set("Authorization", "Graze ignored")
+	anon.Then(okHandler).ServeHTTP(httptest.NewRecorder(), req)
+	remaining, limited, ok := GetRateLimit(req)
+	assert.Equal(t, 0, remaining)
+	assert.False(t, limited)
+	assert.True(t, ok)
+	assert.Equal(t, "anonymous", GetRateLimitBucket(req))
+
+	req = Track(ipRequest(t, "10.0.0.1"))
+	rec := httptest.NewRecorder()
+	anon.Then(okHandler).ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code, "uses the default error handler")
+	_, limited, _ = GetRateLimit(req)
+	assert.True(t, limited)
+}
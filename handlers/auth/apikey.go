@@ -29,8 +29,13 @@ type APIKey struct {
 	Provider string
 	// Validator takes the provided <apiKey> and returns a user object or error if the key is invalid
 	Finder Finder
-	// OnError gets called if the request is unauthorized or forbidden
+	// OnError gets called if the request is unauthorized or forbidden, if nil the default set by SetDefaultOnError is used
 	OnError failure.Handler
+	// RequireTLS rejects requests that are not made over TLS with an *InsecureTransportError and a status of 403
+	RequireTLS bool
+	// TrustForwardedProto treats requests with a `X-Forwarded-Proto: https` header as TLS when RequireTLS is set. Only
+	// use this behind a load balancer that sets the header
+	TrustForwardedProto bool
 }
 
 type (
@@ -45,6 +50,8 @@ type (
 		key string
 		err error
 	}
+	// InsecureTransportError when TLS is required and the request was not made over TLS
+	InsecureTransportError struct{}
 )
 
 func (e *NoHeaderError) Error() string {
@@ -63,6 +70,10 @@ func (e *InvalidKeyError) Error() string {
 	return fmt.Sprintf("provided api key: '%s' is not valid: %s", e.key, e.err.Error())
 }
 
+func (e *InsecureTransportError) Error() string {
+	return "authentication must be made over TLS"
+}
+
 // ThenFunc surrounds an existing handler func and returns a new http.Handler
 //
 // Usage:
@@ -83,7 +94,7 @@ func (e *InvalidKeyError) Error() string {
 // 		fmt.Fprintf(w, err.Error())
 // 	}
 //
-// 	keyAuth := auth.APIKey{"Graze", finder, onError}
+// 	keyAuth := auth.NewAPIKey("Graze", auth.FinderFunc(finder), failure.HandlerFunc(onError))
 //
 // 	http.Handle("/thing", keyAuth.ThenFunc(ThingFunc))
 func (a *APIKey) ThenFunc(fn func(http.ResponseWriter, *http.Request)) http.Handler {
@@ -110,7 +121,7 @@ func (a *APIKey) ThenFunc(fn func(http.ResponseWriter, *http.Request)) http.Hand
 // 		fmt.Fprintf(w, err.Error())
 // 	}
 //
-// 	keyAuth := auth.APIKey{"Graze", finder, onError}
+// 	keyAuth := auth.NewAPIKey("Graze", auth.FinderFunc(finder), failure.HandlerFunc(onError))
 //
 // 	http.Handle("/thing", keyAuth.Then(ThingHandler))
 func (a *APIKey) Then(h http.Handler) http.Handler {
@@ -120,30 +131,36 @@ func (a *APIKey) Then(h http.Handler) http.Handler {
 // Handler wraps the Then method to become clearer
 func (a *APIKey) Handler(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		onError := orDefault(a.OnError)
+		if a.RequireTLS && !isTLS(req, a.TrustForwardedProto) {
+			onError.Handle(w, req, &InsecureTransportError{}, http.StatusForbidden)
+			return
+		}
+
 		header := req.Header["Authorization"]
 		if len(header) == 0 {
-			a.OnError.Handle(w, req, &NoHeaderError{}, http.StatusUnauthorized)
+			onError.Handle(w, req, &NoHeaderError{}, http.StatusUnauthorized)
 			return
 		}
 
 		parts := strings.Split(header[0], " ")
 		if len(parts) != 2 {
-			a.OnError.Handle(w, req, &InvalidFormatError{"<provider> <apiKey>", header[0]}, http.StatusUnauthorized)
+			onError.Handle(w, req, &InvalidFormatError{"<provider> <apiKey>", header[0]}, http.StatusUnauthorized)
 			return
 		}
 
 		provider, value := parts[0], parts[1]
 		if provider != a.Provider {
-			a.OnError.Handle(w, req, &BadProviderError{provider, a.Provider}, http.StatusUnauthorized)
+			onError.Handle(w, req, &BadProviderError{provider, a.Provider}, http.StatusUnauthorized)
 			return
 		}
 
-		user, err := a.Finder.Find(value, req)
+		user, status, err := findUser(a.Finder, value, req)
 		if err != nil {
-			a.OnError.Handle(w, req, &InvalidKeyError{value, err}, http.StatusUnauthorized)
+			onError.Handle(w, req, err, status)
 			return
 		}
-		req = saveUser(req, user)
+		req = saveScheme(saveUser(req, user), "apikey", a.Provider)
 
 		h.ServeHTTP(w, req)
 	})
@@ -151,5 +168,13 @@ func (a *APIKey) Handler(h http.Handler) http.Handler {
 
 // NewAPIKey returns an APIKey struct that has a Handle method to provide authentication to your service
 func NewAPIKey(provider string, finder Finder, onError failure.Handler) *APIKey {
-	return &APIKey{provider, finder, onError}
+	return &APIKey{Provider: provider, Finder: finder, OnError: onError}
+}
+
+// isTLS returns true if req was made over TLS, or has a `X-Forwarded-Proto: https` header if trustForwarded is set
+func isTLS(req *http.Request, trustForwarded bool) bool {
+	if req.TLS != nil {
+		return true
+	}
+	return trustForwarded && strings.EqualFold(req.Header.Get("X-Forwarded-Proto"), "https")
 }
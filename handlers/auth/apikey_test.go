@@ -11,6 +11,7 @@
 package auth
 
 import (
+	"crypto/tls"
 	"errors"
 	"net/http"
 	"net/http/httptest"
@@ -154,3 +155,75 @@ func TestValidAPIKeyAuth(t *testing.T) {
 		handler.ServeHTTP(rec, tc.request)
 	}
 }
+
+func TestAPIKeyRequireTLS(t *testing.T) {
+	finder := FinderFunc(func(key interface{}, r *http.Request) (interface{}, error) {
+		return "user", nil
+	})
+
+	tlsRequest := headerRequest(t, "GET", "/path", map[string]string{"Authorization": "Graze key"})
+	tlsRequest.TLS = &tls.ConnectionState{}
+
+	cases := map[string]struct {
+		requireTLS     bool
+		trustForwarded bool
+		request        *http.Request
+		status         int
+	}{
+		"tls": {
+			true,
+			false,
+			tlsRequest,
+			http.StatusOK,
+		},
+		"plain text": {
+			true,
+			false,
+			headerRequest(t, "GET", "/path", map[string]string{"Authorization": "Graze key"}),
+			http.StatusForbidden,
+		},
+		"plain text without a key": {
+			true,
+			false,
+			headerRequest(t, "GET", "/path", map[string]string{}),
+			http.StatusForbidden,
+		},
+		"trusted forwarded proto": {
+			true,
+			true,
+			headerRequest(t, "GET", "/path", map[string]string{"Authorization": "Graze key", "X-Forwarded-Proto": "https"}),
+			http.StatusOK,
+		},
+		"untrusted forwarded proto": {
+			true,
+			false,
+			headerRequest(t, "GET", "/path", map[string]string{"Authorization": "Graze key", "X-Forwarded-Proto": "https"}),
+			http.StatusForbidden,
+		},
+		"forwarded plain text": {
+			true,
+			true,
+			headerRequest(t, "GET", "/path", map[string]string{"Authorization": "Graze key", "X-Forwarded-Proto": "http"}),
+			http.StatusForbidden,
+		},
+		"not required": {
+			false,
+			false,
+			headerRequest(t, "GET", "/path", map[string]string{"Authorization": "Graze key"}),
+			http.StatusOK,
+		},
+	}
+
+	for k, tc := range cases {
+		auth := NewAPIKey("Graze", finder, failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, status int) {
+			assert.IsType(t, &InsecureTransportError{}, err, "test: %s", k)
+			w.WriteHeader(status)
+		}))
+		auth.RequireTLS = tc.requireTLS
+		auth.TrustForwardedProto = tc.trustForwarded
+
+		rec := httptest.NewRecorder()
+		auth.Then(okHandler).ServeHTTP(rec, tc.request)
+		assert.Equal(t, tc.status, rec.Code, "test: %s", k)
+	}
+}
@@ -0,0 +1,162 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// TooManyAttemptsError is returned when a client has failed to authenticate too many times
+type TooManyAttemptsError struct {
+	ip         string
+	retryAfter time.Duration
+}
+
+func (e *TooManyAttemptsError) Error() string {
+	return fmt.Sprintf("too many failed authentication attempts from: %s, retry after: %s", e.ip, e.retryAfter)
+}
+
+// Status returns 429 (Too Many Requests)
+func (e *TooManyAttemptsError) Status() int {
+	return http.StatusTooManyRequests
+}
+
+// attempts is the number of failures from a single ip since start, and the number of calls still running
+type attempts struct {
+	failures int
+	pending  int
+	start    time.Time
+}
+
+// AttemptLimiter is a Finder that stops calling the wrapped Finder for an ip address after too many failures
+//
+// After maxFailures failed calls within window, each call returns a *TooManyAttemptsError until the window has
+// passed. A successful call resets the count for the ip. Calls that are still running count towards maxFailures, so
+// making calls in parallel does not allow more attempts
+type AttemptLimiter struct {
+	// ClientIP returns the ip to count attempts against, the default uses the address of the connection
+	ClientIP func(r *http.Request) string
+
+	finder      Finder
+	maxFailures int
+	window      time.Duration
+	now         func() time.Time
+
+	mu        sync.Mutex
+	attempts  map[string]*attempts
+	lastSweep time.Time
+}
+
+// Find returns an error without calling the wrapped Finder if the client ip has failed too many times
+func (l *AttemptLimiter) Find(c interface{}, r *http.Request) (interface{}, error) {
+	ip := l.ClientIP(r)
+	a, err := l.reserve(ip)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := l.finder.Find(c, r)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	a.pending--
+	if err == nil {
+		a.failures = 0
+	} else if _, ok := err.(StatusError); !ok {
+		// only count failures for invalid keys, not other errors such as the key store being unavailable
+		l.fail(a)
+	}
+	if a.failures == 0 && a.pending == 0 {
+		delete(l.attempts, ip)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// reserve counts a running call for ip, or returns a *TooManyAttemptsError if its failures and running calls have
+// reached maxFailures
+func (l *AttemptLimiter) reserve(ip string) (*attempts, error) {
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+
+	a, ok := l.attempts[ip]
+	if !ok {
+		a = &attempts{start: now}
+		l.attempts[ip] = a
+	} else if now.Sub(a.start) >= l.window {
+		a.failures = 0
+		a.start = now
+	}
+	if a.failures+a.pending >= l.maxFailures {
+		return nil, &TooManyAttemptsError{ip, a.start.Add(l.window).Sub(now)}
+	}
+	a.pending++
+	return a, nil
+}
+
+// sweep removes the ips whose window has passed without any running calls, the lock must be held
+func (l *AttemptLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) <= l.window {
+		return
+	}
+	for k, a := range l.attempts {
+		if a.pending == 0 && now.Sub(a.start) > l.window {
+			delete(l.attempts, k)
+		}
+	}
+	l.lastSweep = now
+}
+
+// fail records a failed attempt, the lock must be held
+func (l *AttemptLimiter) fail(a *attempts) {
+	now := l.now()
+	if now.Sub(a.start) > l.window {
+		a.failures = 0
+		a.start = now
+	}
+	a.failures++
+}
+
+// NewAttemptLimiter wraps finder to block an ip address after maxFailures failed calls within window
+//
+// Usage:
+//  finder := auth.NewAttemptLimiter(auth.FinderFunc(finder), 5, time.Minute)
+//  keyAuth := auth.NewAPIKey("Graze", finder, failure.HandlerFunc(onError))
+func NewAttemptLimiter(finder Finder, maxFailures int, window time.Duration) *AttemptLimiter {
+	return &AttemptLimiter{
+		ClientIP:    remoteIP,
+		finder:      finder,
+		maxFailures: maxFailures,
+		window:      window,
+		now:         time.Now,
+		attempts:    make(map[string]*attempts),
+	}
+}
+
+// remoteIP returns the ip address of the connection the request was made on
+//
+// Headers such as X-Forwarded-For are ignored as they can be set by the client
+func remoteIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
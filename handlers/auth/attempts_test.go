@@ -0,0 +1,153 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/graze/golang-service/handlers/failure"
+	"github.com/stretchr/testify/assert"
+)
+
+// mapFinder finds users from a map of keys and counts the number of calls
+type mapFinder struct {
+	users map[string]interface{}
+	calls int
+}
+
+func (f *mapFinder) Find(c interface{}, r *http.Request) (interface{}, error) {
+	f.calls++
+	if user, ok := f.users[c.(string)]; ok {
+		return user, nil
+	}
+	return nil, errors.New("no user found")
+}
+
+func ipRequest(t *testing.T, ip string) *http.Request {
+	req := headerRequest(t, "GET", "/path", map[string]string{})
+	req.RemoteAddr = ip + ":3421"
+	return req
+}
+
+func TestAttemptLimiterBlocksAfterTooManyFailures(t *testing.T) {
+	inner := &mapFinder{users: map[string]interface{}{"good": "user"}}
+	limiter := NewAttemptLimiter(inner, 3, time.Minute)
+	now := time.Now()
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		_, err := limiter.Find("bad", ipRequest(t, "10.0.0.1"))
+		assert.IsType(t, errors.New(""), err)
+	}
+	assert.Equal(t, 3, inner.calls)
+
+	_, err := limiter.Find("good", ipRequest(t, "10.0.0.1"))
+	assert.IsType(t, &TooManyAttemptsError{}, err)
+	assert.Equal(t, http.StatusTooManyRequests, err.(StatusError).Status())
+	assert.Equal(t, 3, inner.calls, "the finder is not called when blocked")
+
+	user, err := limiter.Find("good", ipRequest(t, "10.0.0.2"))
+	assert.Nil(t, err, "other ips are not blocked")
+	assert.Equal(t, "user", user)
+
+	now = now.Add(time.Minute + time.Second)
+	user, err = limiter.Find("good", ipRequest(t, "10.0.0.1"))
+	assert.Nil(t, err, "the block expires after the window")
+	assert.Equal(t, "user", user)
+}
+
+func TestAttemptLimiterResetsOnSuccess(t *testing.T) {
+	inner := &mapFinder{users: map[string]interface{}{"good": "user"}}
+	limiter := NewAttemptLimiter(inner, 3, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		limiter.Find("bad", ipRequest(t, "10.0.0.1"))
+	}
+	_, err := limiter.Find("good", ipRequest(t, "10.0.0.1"))
+	assert.Nil(t, err)
+
+	for i := 0; i < 2; i++ {
+		limiter.Find("bad", ipRequest(t, "10.0.0.1"))
+	}
+	_, err = limiter.Find("good", ipRequest(t, "10.0.0.1"))
+	assert.Nil(t, err, "the count was reset by the successful attempt")
+}
+
+func TestAttemptLimiterReturnsTooManyRequests(t *testing.T) {
+	limiter := NewAttemptLimiter(&mapFinder{}, 1, time.Minute)
+
+	var status int
+	var authErr error
+	auth := NewAPIKey("Graze", limiter, failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, s int) {
+		authErr = err
+		status = s
+	}))
+	handler := auth.Then(okHandler)
+
+	req := headerRequest(t, "GET", "/path", map[string]string{"Authorization": "Graze bad"})
+	req.RemoteAddr = "10.0.0.1:3421"
+
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.IsType(t, &InvalidKeyError{}, authErr)
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.IsType(t, &TooManyAttemptsError{}, authErr)
+	assert.Equal(t, http.StatusTooManyRequests, status)
+}
+
+func TestAttemptLimiterConcurrentAttempts(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	limiter := NewAttemptLimiter(FinderFunc(func(c interface{}, r *http.Request) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return nil, errors.New("no user found")
+	}), 3, time.Minute)
+
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		go func() {
+			_, err := limiter.Find("guess", ipRequest(t, "10.0.0.1"))
+			errs <- err
+		}()
+	}
+
+	blocked := 0
+	for i := 0; i < 7; i++ {
+		if _, ok := (<-errs).(*TooManyAttemptsError); ok {
+			blocked++
+		}
+	}
+	close(release)
+	for i := 0; i < 3; i++ {
+		assert.IsType(t, errors.New(""), <-errs, "the reserved attempts reach the finder")
+	}
+
+	assert.Equal(t, 7, blocked, "parallel attempts over the limit are blocked while the others are running")
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	_, err := limiter.Find("guess", ipRequest(t, "10.0.0.1"))
+	assert.IsType(t, &TooManyAttemptsError{}, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
+
+func TestAttemptLimiterForgetsSuccessfulIPs(t *testing.T) {
+	limiter := NewAttemptLimiter(&mapFinder{users: map[string]interface{}{"good": "user"}}, 3, time.Minute)
+
+	limiter.Find("good", ipRequest(t, "10.0.0.1"))
+	limiter.Find("down", ipRequest(t, "10.0.0.2"))
+	assert.Equal(t, 1, len(limiter.attempts), "only ips with failures are kept")
+}
@@ -0,0 +1,124 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/graze/golang-service/log"
+)
+
+// AuditEvent describes a single authentication attempt
+type AuditEvent struct {
+	// Fingerprint is a hash of the key used, so the key itself is not recorded
+	Fingerprint string
+	// UserID identifies the user found for the key, or is empty if the attempt failed
+	UserID string
+	// IP is the ip address the attempt was made from
+	IP string
+	// Time is when the attempt was made
+	Time time.Time
+	// Success is true if a user was found for the key
+	Success bool
+	// Err is the error returned by the Finder if the attempt failed
+	Err error
+}
+
+// AuditSink records audit events
+type AuditSink interface {
+	Audit(event AuditEvent)
+}
+
+// AuditSinkFunc is a function wrapper around the AuditSink interface
+type AuditSinkFunc func(event AuditEvent)
+
+// Audit calls the function with event
+func (f AuditSinkFunc) Audit(event AuditEvent) {
+	f(event)
+}
+
+// LogAuditSink returns an AuditSink that logs each event to logger with the tag `auth_audit`, at info level for
+// successful attempts and warning level for failures
+func LogAuditSink(logger log.FieldLogger) AuditSink {
+	return AuditSinkFunc(func(event AuditEvent) {
+		entry := logger.With(log.KV{
+			"tag":              "auth_audit",
+			"auth.fingerprint": event.Fingerprint,
+			"auth.user":        event.UserID,
+			"auth.ip":          event.IP,
+			"auth.time":        event.Time.UTC().Format(time.RFC3339Nano),
+			"auth.success":     event.Success,
+		})
+		if event.Success {
+			entry.Infof("authenticated user: %s", event.UserID)
+			return
+		}
+		entry.Err(event.Err).Warnf("authentication failed")
+	})
+}
+
+// AuditFinder is a Finder that sends an AuditEvent to a sink for every successful and failed call to the wrapped Finder
+type AuditFinder struct {
+	// ClientIP returns the ip to record for the attempt, the default uses the address of the connection
+	ClientIP func(r *http.Request) string
+	// UserID returns the id to record for a user, the default uses the String method of the user if it has one
+	UserID func(user interface{}) string
+
+	finder Finder
+	sink   AuditSink
+	now    func() time.Time
+}
+
+// Find calls the wrapped Finder and audits the outcome
+func (f *AuditFinder) Find(c interface{}, r *http.Request) (interface{}, error) {
+	user, err := f.finder.Find(c, r)
+
+	event := AuditEvent{
+		IP:      f.ClientIP(r),
+		Time:    f.now(),
+		Success: err == nil,
+		Err:     err,
+	}
+	if key, ok := c.(string); ok {
+		event.Fingerprint = fingerprint(key)
+	}
+	if err == nil {
+		event.UserID = f.UserID(user)
+	}
+	f.sink.Audit(event)
+
+	return user, err
+}
+
+// userString returns the String of user if it is a fmt.Stringer, so other user details are not recorded
+func userString(user interface{}) string {
+	if s, ok := user.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return ""
+}
+
+// NewAuditFinder wraps finder to send an AuditEvent to sink for every authentication attempt
+//
+// Usage:
+//  finder := auth.NewAuditFinder(auth.FinderFunc(finder), auth.LogAuditSink(log.With(log.KV{"module": "audit"})))
+//  keyAuth := auth.NewAPIKey("Graze", finder, failure.HandlerFunc(onError))
+func NewAuditFinder(finder Finder, sink AuditSink) *AuditFinder {
+	return &AuditFinder{
+		ClientIP: remoteIP,
+		UserID:   userString,
+		finder:   finder,
+		sink:     sink,
+		now:      time.Now,
+	}
+}
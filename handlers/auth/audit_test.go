@@ -0,0 +1,76 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus/hooks/test"
+	"github.com/graze/golang-service/log"
+	"github.com/stretchr/testify/assert"
+)
+
+type auditUser struct {
+	id string
+}
+
+func (u *auditUser) String() string {
+	return u.id
+}
+
+func TestAuditFinder(t *testing.T) {
+	var events []AuditEvent
+	finder := NewAuditFinder(
+		&mapFinder{users: map[string]interface{}{"good": &auditUser{"user-1"}}},
+		AuditSinkFunc(func(event AuditEvent) {
+			events = append(events, event)
+		}),
+	)
+	now := time.Now()
+	finder.now = func() time.Time { return now }
+
+	user, err := finder.Find("good", ipRequest(t, "10.0.0.1"))
+	assert.Nil(t, err)
+	assert.Equal(t, &auditUser{"user-1"}, user)
+
+	_, err = finder.Find("bad", ipRequest(t, "10.0.0.2"))
+	assert.NotNil(t, err)
+
+	assert.Equal(t, []AuditEvent{
+		{Fingerprint: fingerprint("good"), UserID: "user-1", IP: "10.0.0.1", Time: now, Success: true},
+		{Fingerprint: fingerprint("bad"), IP: "10.0.0.2", Time: now, Success: false, Err: err},
+	}, events)
+}
+
+func TestLogAuditSink(t *testing.T) {
+	logger := log.New("", "", "")
+	hook := test.NewLocal(logger.Logger)
+	finder := NewAuditFinder(&mapFinder{users: map[string]interface{}{"good": "user"}}, LogAuditSink(logger))
+	finder.UserID = func(user interface{}) string {
+		return user.(string)
+	}
+
+	finder.Find("good", ipRequest(t, "10.0.0.1"))
+	assert.Equal(t, 1, len(hook.Entries))
+	assert.Equal(t, log.InfoLevel, hook.LastEntry().Level)
+	assert.Equal(t, "auth_audit", hook.LastEntry().Data["tag"])
+	assert.Equal(t, "user", hook.LastEntry().Data["auth.user"])
+	assert.Equal(t, fingerprint("good"), hook.LastEntry().Data["auth.fingerprint"])
+	assert.Equal(t, "10.0.0.1", hook.LastEntry().Data["auth.ip"])
+	assert.Equal(t, true, hook.LastEntry().Data["auth.success"])
+
+	finder.Find("bad", ipRequest(t, "10.0.0.1"))
+	assert.Equal(t, 2, len(hook.Entries))
+	assert.Equal(t, log.WarnLevel, hook.LastEntry().Level)
+	assert.Equal(t, false, hook.LastEntry().Data["auth.success"])
+	assert.Equal(t, "", hook.LastEntry().Data["auth.user"])
+}
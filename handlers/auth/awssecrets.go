@@ -0,0 +1,198 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultAWSSecretsTTL is how long the keys fetched from AWS are used before they are fetched again
+	DefaultAWSSecretsTTL = 5 * time.Minute
+	// DefaultAWSSecretsMinRefresh is the shortest time between fetches caused by an unknown key
+	DefaultAWSSecretsMinRefresh = 10 * time.Second
+)
+
+// AWSSecretsClient fetches the keys stored in AWS, such as a wrapper around `GetSecretValue` from Secrets Manager or
+// `GetParametersByPath` from SSM Parameter Store
+type AWSSecretsClient interface {
+	// FetchKeys returns every valid key mapped to the id of its user. An error is returned if the keys could not be
+	// fetched, including when AWS throttles the request. It must stop when ctx is done
+	FetchKeys(ctx context.Context) (map[string]string, error)
+}
+
+// AWSKeyUser is the user returned by the AWSSecretsFinder
+type AWSKeyUser struct {
+	ID string
+}
+
+// String returns the id of the user
+func (u *AWSKeyUser) String() string {
+	return u.ID
+}
+
+// AWSUnavailableError is returned when the keys could not be fetched from AWS
+type AWSUnavailableError struct {
+	err error
+}
+
+func (e *AWSUnavailableError) Error() string {
+	return fmt.Sprintf("failed to fetch the keys from aws: %v", e.err)
+}
+
+// Status returns 503 (Service Unavailable)
+func (e *AWSUnavailableError) Status() int {
+	return http.StatusServiceUnavailable
+}
+
+// AWSSecretsFinder is a Finder that validates keys against the keys stored in AWS Secrets Manager or SSM Parameter
+// Store, returning an *AWSKeyUser
+//
+// All of the keys are fetched at once and kept as fingerprints for TTL. An unknown key fetches them again straight
+// away, at most once every MinRefresh, so new keys can be used without waiting for the TTL. Unknown keys are an invalid
+// key (401). If AWS fails or throttles the request the keys are not fetched again for MinRefresh, and the stale keys are
+// used until they can be fetched. An *AWSUnavailableError (503) is returned if the keys have never been fetched
+type AWSSecretsFinder struct {
+	// TTL is how long the keys are used before they are fetched again (default: DefaultAWSSecretsTTL)
+	TTL time.Duration
+	// MinRefresh is the shortest time between fetches for unknown keys, and after a failed fetch (default:
+	// DefaultAWSSecretsMinRefresh)
+	MinRefresh time.Duration
+	// Timeout limits each call to AWS if set, the request deadline is always honoured
+	Timeout time.Duration
+
+	client AWSSecretsClient
+	now    func() time.Time
+
+	// refresh is held while fetching, so only one request fetches the keys at a time
+	refresh   sync.Mutex
+	mu        sync.Mutex
+	keys      map[string]string
+	fetched   time.Time
+	attempted time.Time
+	err       error
+}
+
+// Find returns the user for the key from the cached keys, fetching them from AWS when they are stale or the key is
+// unknown
+func (f *AWSSecretsFinder) Find(credentials interface{}, r *http.Request) (interface{}, error) {
+	key, ok := credentials.(string)
+	if !ok {
+		return nil, errors.New("the supplied key is in an invalid format")
+	}
+	fp := fingerprint(key)
+
+	id, found, fresh := f.lookup(fp)
+	if found && fresh {
+		return &AWSKeyUser{id}, nil
+	}
+	if f.canRefresh() {
+		f.fetch(r.Context())
+	}
+	if err := f.unavailable(); err != nil {
+		return nil, err
+	}
+	id, found, _ = f.lookup(fp)
+	if !found {
+		return nil, errors.New("the key is not stored in aws")
+	}
+	return &AWSKeyUser{id}, nil
+}
+
+// lookup returns the user id for fp, if it was found, and if the keys are within their TTL
+func (f *AWSSecretsFinder) lookup(fp string) (id string, found, fresh bool) {
+	ttl := f.TTL
+	if ttl <= 0 {
+		ttl = DefaultAWSSecretsTTL
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id, found = f.keys[fp]
+	return id, found, f.keys != nil && f.now().Sub(f.fetched) < ttl
+}
+
+// canRefresh returns true if the last fetch, successful or not, was longer than MinRefresh ago
+func (f *AWSSecretsFinder) canRefresh() bool {
+	minRefresh := f.MinRefresh
+	if minRefresh <= 0 {
+		minRefresh = DefaultAWSSecretsMinRefresh
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now().Sub(f.attempted) >= minRefresh
+}
+
+// unavailable returns the error from the last fetch if the keys have never been fetched
+func (f *AWSSecretsFinder) unavailable() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.keys == nil {
+		return f.err
+	}
+	return nil
+}
+
+// fetch replaces the cached keys with the keys from AWS, unless another request tried to fetch them while this one
+// waited. A failure is recorded and the cached keys are kept
+func (f *AWSSecretsFinder) fetch(ctx context.Context) {
+	start := f.now()
+	f.refresh.Lock()
+	defer f.refresh.Unlock()
+
+	f.mu.Lock()
+	attempted := f.attempted
+	f.mu.Unlock()
+	if attempted.After(start) {
+		return
+	}
+
+	if f.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.Timeout)
+		defer cancel()
+	}
+	keys, err := f.client.FetchKeys(ctx)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.attempted = f.now()
+	if err != nil {
+		f.err = &AWSUnavailableError{err}
+		return
+	}
+	fingerprints := make(map[string]string, len(keys))
+	for key, id := range keys {
+		fingerprints[fingerprint(key)] = id
+	}
+	f.keys = fingerprints
+	f.fetched = f.attempted
+	f.err = nil
+}
+
+// NewAWSSecretsFinder returns an AWSSecretsFinder that fetches the keys with client
+//
+// Usage:
+//  finder := auth.NewAWSSecretsFinder(secretsClient)
+//  finder.TTL = time.Minute
+//  keyAuth := auth.NewAPIKey("Graze", finder, failure.HandlerFunc(onError))
+//  ...
+//  user := auth.GetUser(r).(*auth.AWSKeyUser)
+func NewAWSSecretsFinder(client AWSSecretsClient) *AWSSecretsFinder {
+	return &AWSSecretsFinder{
+		client: client,
+		now:    time.Now,
+	}
+}
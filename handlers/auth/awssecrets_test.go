@@ -0,0 +1,155 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/graze/golang-service/handlers/failure"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSecrets is an AWSSecretsClient with a fixed set of keys that counts the fetches
+type fakeSecrets struct {
+	keys  map[string]string
+	err   error
+	calls int
+}
+
+func (s *fakeSecrets) FetchKeys(ctx context.Context) (map[string]string, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	keys := make(map[string]string, len(s.keys))
+	for k, v := range s.keys {
+		keys[k] = v
+	}
+	return keys, nil
+}
+
+func TestAWSSecretsFinder(t *testing.T) {
+	cases := map[string]struct {
+		keys   map[string]string
+		err    error
+		key    string
+		user   interface{}
+		status int
+	}{
+		"known key":     {map[string]string{"good": "user-1"}, nil, "good", &AWSKeyUser{"user-1"}, http.StatusOK},
+		"unknown key":   {map[string]string{"good": "user-1"}, nil, "bad", nil, http.StatusUnauthorized},
+		"backend error": {nil, errors.New("ThrottlingException: Rate exceeded"), "good", nil, http.StatusServiceUnavailable},
+	}
+
+	for k, tc := range cases {
+		finder := NewAWSSecretsFinder(&fakeSecrets{keys: tc.keys, err: tc.err})
+		var authErr error
+		var user interface{}
+		keyAuth := NewAPIKey("Graze", finder, failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, status int) {
+			authErr = err
+			w.WriteHeader(status)
+		}))
+		rec := httptest.NewRecorder()
+		req := headerRequest(t, "GET", "/stuff", map[string]string{"Authorization": "Graze " + tc.key})
+		keyAuth.ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+			user = GetUser(r)
+		}).ServeHTTP(rec, req)
+
+		assert.Equal(t, tc.status, rec.Code, "test: %s", k)
+		assert.Equal(t, tc.user, user, "test: %s", k)
+		if tc.err != nil {
+			assert.IsType(t, &AWSUnavailableError{}, authErr, "test: %s", k)
+		}
+	}
+}
+
+func TestAWSSecretsFinderCache(t *testing.T) {
+	secrets := &fakeSecrets{keys: map[string]string{"good": "user-1"}}
+	now := time.Now()
+	finder := NewAWSSecretsFinder(secrets)
+	finder.now = func() time.Time { return now }
+	req := headerRequest(t, "GET", "/stuff", map[string]string{})
+
+	user, err := finder.Find("good", req)
+	assert.Nil(t, err)
+	assert.Equal(t, &AWSKeyUser{"user-1"}, user)
+	assert.Equal(t, 1, secrets.calls)
+
+	_, err = finder.Find("good", req)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, secrets.calls, "a known key is served from the cache")
+
+	secrets.keys["new"] = "user-2"
+	_, err = finder.Find("new", req)
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, secrets.calls, "unknown keys do not refresh within MinRefresh")
+
+	now = now.Add(DefaultAWSSecretsMinRefresh)
+	user, err = finder.Find("new", req)
+	assert.Nil(t, err, "an unknown key refreshes the keys")
+	assert.Equal(t, &AWSKeyUser{"user-2"}, user)
+	assert.Equal(t, 2, secrets.calls)
+
+	delete(secrets.keys, "good")
+	now = now.Add(DefaultAWSSecretsTTL)
+	_, err = finder.Find("good", req)
+	assert.NotNil(t, err, "removed keys are not valid once the TTL has passed")
+	assert.Equal(t, 3, secrets.calls)
+
+	secrets.err = errors.New("connection reset")
+	now = now.Add(DefaultAWSSecretsTTL)
+	user, err = finder.Find("new", req)
+	assert.Nil(t, err, "stale keys are used when AWS fails")
+	assert.Equal(t, &AWSKeyUser{"user-2"}, user)
+	assert.Equal(t, 4, secrets.calls)
+}
+
+func TestAWSSecretsFinderFailureBackoff(t *testing.T) {
+	secrets := &fakeSecrets{err: errors.New("ThrottlingException: Rate exceeded")}
+	now := time.Now()
+	finder := NewAWSSecretsFinder(secrets)
+	finder.now = func() time.Time { return now }
+	req := headerRequest(t, "GET", "/stuff", map[string]string{})
+
+	_, err := finder.Find("good", req)
+	assert.IsType(t, &AWSUnavailableError{}, err, "the keys have never been fetched")
+	_, err = finder.Find("good", req)
+	assert.IsType(t, &AWSUnavailableError{}, err)
+	assert.Equal(t, 1, secrets.calls, "a failed fetch is not retried within MinRefresh")
+
+	secrets.err = nil
+	secrets.keys = map[string]string{"good": "user-1", "old": "user-2"}
+	now = now.Add(DefaultAWSSecretsMinRefresh)
+	_, err = finder.Find("good", req)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, secrets.calls)
+
+	secrets.err = errors.New("ThrottlingException: Rate exceeded")
+	now = now.Add(DefaultAWSSecretsTTL)
+	for i := 0; i < 10; i++ {
+		user, err := finder.Find("old", req)
+		assert.Nil(t, err, "stale keys are used during the backoff")
+		assert.Equal(t, &AWSKeyUser{"user-2"}, user)
+		_, err = finder.Find("unknown", req)
+		assert.NotNil(t, err)
+	}
+	assert.Equal(t, 3, secrets.calls, "stale and unknown keys do not fetch again within MinRefresh of a failure")
+
+	now = now.Add(DefaultAWSSecretsMinRefresh)
+	_, err = finder.Find("old", req)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, secrets.calls, "the keys are fetched again once MinRefresh has passed")
+}
@@ -0,0 +1,138 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// CacheMetrics receives a counter for each cache lookup, it is satisfied by *statsd.Client
+type CacheMetrics interface {
+	Incr(name string, tags []string, rate float64) error
+}
+
+// CacheSizeMetrics receives a gauge of the number of cached users, it is satisfied by *statsd.Client
+type CacheSizeMetrics interface {
+	Gauge(name string, value float64, tags []string, rate float64) error
+}
+
+// cachedUser is a user found for a key and when it should be looked up again
+type cachedUser struct {
+	user    interface{}
+	expires time.Time
+}
+
+// CachingFinder is a Finder that caches the users found by the wrapped Finder for a time, for when the lookup is slow
+// (such as a database or HashedKeys)
+//
+// Only successful lookups of string keys are cached, and keys are stored as a fingerprint. If Metrics is set, each
+// lookup sends an `auth.cache.hit` or `auth.cache.miss` counter. If Metrics also implements CacheSizeMetrics, each
+// lookup sends the number of cached users as an `auth.cache.size` gauge
+type CachingFinder struct {
+	// Metrics is sent a counter for each lookup, and the size of the cache if it implements CacheSizeMetrics, if set
+	Metrics CacheMetrics
+
+	finder Finder
+	ttl    time.Duration
+	size   int
+	now    func() time.Time
+
+	mu    sync.Mutex
+	users map[string]cachedUser
+}
+
+// Find returns the cached user for the key, calling the wrapped Finder if it is not cached or has expired
+func (f *CachingFinder) Find(c interface{}, r *http.Request) (interface{}, error) {
+	key, ok := c.(string)
+	if !ok {
+		return f.finder.Find(c, r)
+	}
+	fp := fingerprint(key)
+	now := f.now()
+
+	f.mu.Lock()
+	cached, ok := f.users[fp]
+	f.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		f.incr("auth.cache.hit")
+		f.gaugeSize()
+		return cached.user, nil
+	}
+	f.incr("auth.cache.miss")
+
+	user, err := f.finder.Find(c, r)
+	if err != nil {
+		f.gaugeSize()
+		return user, err
+	}
+	f.store(fp, cachedUser{user, now.Add(f.ttl)}, now)
+	f.gaugeSize()
+	return user, nil
+}
+
+// Len returns the number of users in the cache, including any that have expired but not been removed yet
+func (f *CachingFinder) Len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.users)
+}
+
+// gaugeSize sends the number of cached users to the metrics, if they implement CacheSizeMetrics
+func (f *CachingFinder) gaugeSize() {
+	if metrics, ok := f.Metrics.(CacheSizeMetrics); ok {
+		metrics.Gauge("auth.cache.size", float64(f.Len()), nil, 1)
+	}
+}
+
+// incr sends the counter name to the metrics, if set
+func (f *CachingFinder) incr(name string) {
+	if f.Metrics != nil {
+		f.Metrics.Incr(name, nil, 1)
+	}
+}
+
+// store caches the user for fp, removing expired users (or any user) to make room if the cache is full
+func (f *CachingFinder) store(fp string, user cachedUser, now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.users[fp]; !ok && f.size > 0 && len(f.users) >= f.size {
+		for k, u := range f.users {
+			if !now.Before(u.expires) {
+				delete(f.users, k)
+			}
+		}
+		for k := range f.users {
+			if len(f.users) < f.size {
+				break
+			}
+			delete(f.users, k)
+		}
+	}
+	f.users[fp] = user
+}
+
+// NewCachingFinder wraps finder to cache each user found for ttl, holding at most size users (0 is unlimited)
+//
+// Usage:
+//  finder := auth.NewCachingFinder(auth.FinderFunc(finder), time.Minute, 1000)
+//  finder.Metrics = statsdClient
+//  keyAuth := auth.NewAPIKey("Graze", finder, failure.HandlerFunc(onError))
+func NewCachingFinder(finder Finder, ttl time.Duration, size int) *CachingFinder {
+	return &CachingFinder{
+		finder: finder,
+		ttl:    ttl,
+		size:   size,
+		now:    time.Now,
+		users:  make(map[string]cachedUser),
+	}
+}
@@ -0,0 +1,112 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// countingMetrics counts each counter it is sent
+type countingMetrics map[string]int
+
+func (m countingMetrics) Incr(name string, tags []string, rate float64) error {
+	m[name]++
+	return nil
+}
+
+func TestCachingFinder(t *testing.T) {
+	inner := &mapFinder{users: map[string]interface{}{"good": "user"}}
+	finder := NewCachingFinder(inner, time.Minute, 0)
+	metrics := countingMetrics{}
+	finder.Metrics = metrics
+	now := time.Now()
+	finder.now = func() time.Time { return now }
+
+	user, err := finder.Find("good", ipRequest(t, "10.0.0.1"))
+	assert.Nil(t, err)
+	assert.Equal(t, "user", user)
+	assert.Equal(t, countingMetrics{"auth.cache.miss": 1}, metrics)
+
+	user, err = finder.Find("good", ipRequest(t, "10.0.0.1"))
+	assert.Nil(t, err)
+	assert.Equal(t, "user", user)
+	assert.Equal(t, 1, inner.calls, "the second lookup is cached")
+	assert.Equal(t, countingMetrics{"auth.cache.miss": 1, "auth.cache.hit": 1}, metrics)
+
+	_, err = finder.Find("bad", ipRequest(t, "10.0.0.1"))
+	assert.NotNil(t, err)
+	_, err = finder.Find("bad", ipRequest(t, "10.0.0.1"))
+	assert.NotNil(t, err)
+	assert.Equal(t, 3, inner.calls, "failures are not cached")
+	assert.Equal(t, countingMetrics{"auth.cache.miss": 3, "auth.cache.hit": 1}, metrics)
+
+	now = now.Add(time.Minute)
+	finder.Find("good", ipRequest(t, "10.0.0.1"))
+	assert.Equal(t, 4, inner.calls, "the user is looked up again after the ttl")
+}
+
+func TestCachingFinderSize(t *testing.T) {
+	inner := &mapFinder{users: map[string]interface{}{"one": "1", "two": "2", "three": "3"}}
+	finder := NewCachingFinder(inner, time.Minute, 2)
+
+	for _, key := range []string{"one", "two", "three"} {
+		finder.Find(key, ipRequest(t, "10.0.0.1"))
+	}
+	assert.Equal(t, 2, len(finder.users))
+
+	finder.Metrics = nil
+	user, err := finder.Find("three", ipRequest(t, "10.0.0.1"))
+	assert.Nil(t, err, "metrics are optional")
+	assert.Equal(t, "3", user)
+}
+
+// gaugeMetrics counts each counter and stores the last value of each gauge it is sent
+type gaugeMetrics struct {
+	countingMetrics
+	gauges map[string]float64
+}
+
+func (m *gaugeMetrics) Gauge(name string, value float64, tags []string, rate float64) error {
+	m.gauges[name] = value
+	return nil
+}
+
+func TestCachingFinderSizeGauge(t *testing.T) {
+	inner := &mapFinder{users: map[string]interface{}{"one": "1", "two": "2", "three": "3"}}
+	finder := NewCachingFinder(inner, time.Minute, 2)
+	metrics := &gaugeMetrics{countingMetrics{}, map[string]float64{}}
+	finder.Metrics = metrics
+
+	cases := []struct {
+		key  string
+		size float64
+	}{
+		{"one", 1},
+		{"one", 1},
+		{"two", 2},
+		{"bad", 2},
+		{"three", 2},
+	}
+
+	for _, tc := range cases {
+		finder.Find(tc.key, ipRequest(t, "10.0.0.1"))
+		assert.Equal(t, tc.size, metrics.gauges["auth.cache.size"], "key: %s", tc.key)
+		assert.Equal(t, int(tc.size), finder.Len(), "key: %s", tc.key)
+	}
+
+	counters := countingMetrics{}
+	finder.Metrics = counters
+	finder.Find("one", ipRequest(t, "10.0.0.1"))
+	assert.Equal(t, 1, len(counters), "the gauge is only sent to metrics that support it")
+}
@@ -0,0 +1,70 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ChainFinder is a Finder that queries each of its Finders in order and returns the first user found, for when keys
+// are spread over several stores (such as during a migration)
+//
+// An error that does not implement StatusError, or has a status of 401, means the key was not found and the next Finder
+// is queried. An error with a status of 500 or more means the store is unavailable and is returned straight away,
+// unless SkipUnavailable is set. Any other StatusError (such as a 403) means the key was found but rejected, and is
+// returned straight away
+type ChainFinder struct {
+	// SkipUnavailable queries the next Finder when a Finder is unavailable. If no Finder has the key, the unavailable
+	// error is returned rather than the not found error
+	SkipUnavailable bool
+
+	finders []Finder
+}
+
+// Find returns the user from the first Finder that has the key, or the error from the last Finder if none do
+func (f *ChainFinder) Find(c interface{}, r *http.Request) (interface{}, error) {
+	var notFound, unavailable error
+	for _, finder := range f.finders {
+		user, err := finder.Find(c, r)
+		if err == nil {
+			return user, nil
+		}
+		status := http.StatusUnauthorized
+		if statusErr, ok := err.(StatusError); ok {
+			status = statusErr.Status()
+		}
+		switch {
+		case status == http.StatusUnauthorized:
+			notFound = err
+		case status >= http.StatusInternalServerError && f.SkipUnavailable:
+			unavailable = err
+		default:
+			return nil, err
+		}
+	}
+	if unavailable != nil {
+		return nil, unavailable
+	}
+	if notFound != nil {
+		return nil, notFound
+	}
+	return nil, errors.New("no finders to search for the key")
+}
+
+// NewChainFinder returns a ChainFinder that queries each of finders in order
+//
+// Usage:
+//  finder := auth.NewChainFinder(newStore, legacyStore)
+//  keyAuth := auth.NewAPIKey("Graze", finder, failure.HandlerFunc(onError))
+func NewChainFinder(finders ...Finder) *ChainFinder {
+	return &ChainFinder{finders: finders}
+}
@@ -0,0 +1,97 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// errorFinder always returns err and counts the number of calls
+type errorFinder struct {
+	err   error
+	calls int
+}
+
+func (f *errorFinder) Find(c interface{}, r *http.Request) (interface{}, error) {
+	f.calls++
+	return nil, f.err
+}
+
+func TestChainFinder(t *testing.T) {
+	current := &mapFinder{users: map[string]interface{}{"new-key": "alice"}}
+	legacy := &mapFinder{users: map[string]interface{}{"old-key": "bob", "new-key": "stale"}}
+	finder := NewChainFinder(current, legacy)
+
+	user, err := finder.Find("new-key", ipRequest(t, "10.0.0.1"))
+	assert.Nil(t, err)
+	assert.Equal(t, "alice", user, "the first store wins")
+	assert.Equal(t, 0, legacy.calls, "the second store is not queried when the first has the key")
+
+	user, err = finder.Find("old-key", ipRequest(t, "10.0.0.1"))
+	assert.Nil(t, err)
+	assert.Equal(t, "bob", user, "a key only in the second store is found")
+
+	current.calls, legacy.calls = 0, 0
+	user, err = finder.Find("missing-key", ipRequest(t, "10.0.0.1"))
+	assert.Nil(t, user)
+	assert.Equal(t, errors.New("no user found"), err, "fails when every store misses")
+	assert.Equal(t, 1, current.calls)
+	assert.Equal(t, 1, legacy.calls)
+
+	_, err = NewChainFinder().Find("new-key", ipRequest(t, "10.0.0.1"))
+	assert.NotNil(t, err)
+}
+
+func TestChainFinderErrors(t *testing.T) {
+	cases := map[string]struct {
+		first  error
+		skip   bool
+		err    error
+		status int
+		calls  int
+	}{
+		"not found falls through":            {errors.New("no user found"), false, nil, 0, 1},
+		"invalid key falls through":          {&InvalidKeyError{"key", errors.New("nope")}, false, nil, 0, 1},
+		"unavailable short circuits":         {&DirectoryUnavailableError{errors.New("down")}, false, &DirectoryUnavailableError{}, http.StatusServiceUnavailable, 0},
+		"unavailable is skipped":             {&DirectoryUnavailableError{errors.New("down")}, true, nil, 0, 1},
+		"rejected keys short circuit":        {&NotInGroupError{"alice"}, false, &NotInGroupError{}, http.StatusForbidden, 0},
+		"rejected keys short circuit always": {&NotInGroupError{"alice"}, true, &NotInGroupError{}, http.StatusForbidden, 0},
+	}
+
+	for k, tc := range cases {
+		second := &mapFinder{users: map[string]interface{}{"key": "alice"}}
+		finder := NewChainFinder(&errorFinder{err: tc.first}, second)
+		finder.SkipUnavailable = tc.skip
+
+		user, err := finder.Find("key", ipRequest(t, "10.0.0.1"))
+		assert.Equal(t, tc.calls, second.calls, "test: %s", k)
+		if tc.err == nil {
+			assert.Nil(t, err, "test: %s", k)
+			assert.Equal(t, "alice", user, "test: %s", k)
+			continue
+		}
+		assert.IsType(t, tc.err, err, "test: %s", k)
+		assert.Equal(t, tc.status, err.(StatusError).Status(), "test: %s", k)
+	}
+}
+
+func TestChainFinderAllUnavailable(t *testing.T) {
+	down := &DirectoryUnavailableError{errors.New("down")}
+	finder := NewChainFinder(&errorFinder{err: down}, &mapFinder{users: map[string]interface{}{}})
+	finder.SkipUnavailable = true
+
+	_, err := finder.Find("key", ipRequest(t, "10.0.0.1"))
+	assert.Equal(t, down, err, "the unavailable error is returned when no other store has the key")
+}
@@ -0,0 +1,201 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultChallengeTTL is how long a client has to respond to a challenge
+const DefaultChallengeTTL = 30 * time.Second
+
+// InvalidChallengeError is returned when a challenge response is malformed, for an unknown or expired challenge, or
+// does not match the key of the client
+type InvalidChallengeError struct {
+	reason string
+}
+
+func (e *InvalidChallengeError) Error() string {
+	return fmt.Sprintf("invalid challenge response: %s", e.reason)
+}
+
+// Status returns 401 (Unauthorized)
+func (e *InvalidChallengeError) Status() int {
+	return http.StatusUnauthorized
+}
+
+// ChallengeStore records the challenges that have been issued and not yet answered
+type ChallengeStore interface {
+	// Issue records nonce as a challenge until expires
+	Issue(nonce string, expires time.Time)
+	// Redeem removes nonce, and returns false if it was not issued or has expired. It must be atomic, so only one of
+	// several concurrent calls for the same nonce returns true
+	Redeem(nonce string) bool
+}
+
+// MemoryChallengeStore is a ChallengeStore that keeps the challenges in memory, so it is only suitable for a single
+// instance of a service
+type MemoryChallengeStore struct {
+	now func() time.Time
+
+	mu         sync.Mutex
+	challenges map[string]time.Time
+	lastSweep  time.Time
+}
+
+// Issue records nonce as a challenge until expires, removing expired challenges at most once a minute
+func (s *MemoryChallengeStore) Issue(nonce string, expires time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if now.Sub(s.lastSweep) > time.Minute {
+		for k, e := range s.challenges {
+			if now.After(e) {
+				delete(s.challenges, k)
+			}
+		}
+		s.lastSweep = now
+	}
+	s.challenges[nonce] = expires
+}
+
+// Redeem removes nonce, and returns false if it was not issued or has expired
+func (s *MemoryChallengeStore) Redeem(nonce string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expires, ok := s.challenges[nonce]
+	delete(s.challenges, nonce)
+	return ok && !s.now().After(expires)
+}
+
+// NewMemoryChallengeStore returns an empty MemoryChallengeStore
+func NewMemoryChallengeStore() *MemoryChallengeStore {
+	return &MemoryChallengeStore{
+		now:        time.Now,
+		challenges: make(map[string]time.Time),
+	}
+}
+
+// ChallengeKeyFunc returns the key and user for a client id, or an error if the client is not known
+type ChallengeKeyFunc func(id string, r *http.Request) (key []byte, user interface{}, err error)
+
+// ChallengeFinder is a Finder for clients that prove they have a key without sending it, by answering a challenge
+//
+// The client gets a challenge from the Challenge handler, then sends `<id>:<nonce>:<response>` as the credentials,
+// where the response is the base64 (url encoding without padding) HMAC-SHA256 of the nonce using its key. Each
+// challenge can only be answered once, within TTL of being issued. Responses that are malformed, for an unknown or
+// expired challenge, or that do not match the key of the client are rejected with an *InvalidChallengeError (401)
+type ChallengeFinder struct {
+	// TTL is how long a client has to respond to a challenge (default: DefaultChallengeTTL)
+	TTL time.Duration
+
+	keys  ChallengeKeyFunc
+	store ChallengeStore
+	now   func() time.Time
+}
+
+// challenge is the body written by the Challenge handler
+type challenge struct {
+	Nonce     string `json:"nonce"`
+	ExpiresIn int    `json:"expires_in"`
+}
+
+// Challenge returns a http.Handler that issues a new challenge, writing the nonce and the seconds until it expires as
+// JSON: `{"nonce": "...", "expires_in": 30}`
+func (f *ChallengeFinder) Challenge() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		nonce := base64.RawURLEncoding.EncodeToString(b)
+		ttl := f.ttl()
+		f.store.Issue(nonce, f.now().Add(ttl))
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(challenge{nonce, int(ttl / time.Second)})
+	})
+}
+
+// Find redeems the challenge and checks the response matches the key of the client
+func (f *ChallengeFinder) Find(c interface{}, r *http.Request) (interface{}, error) {
+	credentials, ok := c.(string)
+	if !ok {
+		return nil, &InvalidChallengeError{"the supplied credentials are in an invalid format"}
+	}
+	parts := strings.Split(credentials, ":")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return nil, &InvalidChallengeError{"expected <id>:<nonce>:<response>"}
+	}
+	id, nonce, response := parts[0], parts[1], parts[2]
+
+	// the challenge is redeemed before the response is checked, so each nonce only gets one guess
+	if !f.store.Redeem(nonce) {
+		return nil, &InvalidChallengeError{"the challenge is unknown or has expired"}
+	}
+	key, user, err := f.keys(id, r)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal([]byte(response), []byte(ChallengeResponse(key, nonce))) {
+		return nil, &InvalidChallengeError{"the response does not match"}
+	}
+	return user, nil
+}
+
+// ttl returns how long a challenge can be answered for
+func (f *ChallengeFinder) ttl() time.Duration {
+	if f.TTL <= 0 {
+		return DefaultChallengeTTL
+	}
+	return f.TTL
+}
+
+// ChallengeResponse returns the response to the challenge nonce for key, as the client should calculate it
+func ChallengeResponse(key []byte, nonce string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(nonce))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// NewChallengeFinder returns a ChallengeFinder using keys to get the key of each client, recording the challenges in
+// store. If store is nil the challenges are kept in memory
+//
+// Usage:
+//  finder := auth.NewChallengeFinder(func(id string, r *http.Request) ([]byte, interface{}, error) {
+//      client, err := clients.Get(id)
+//      return client.Key, client, err
+//  }, nil)
+//  keyAuth := auth.NewAPIKey("Challenge", finder, failure.HandlerFunc(onError))
+//
+//  http.Handle("/challenge", finder.Challenge())
+//  http.Handle("/thing", keyAuth.Then(ThingHandler))
+func NewChallengeFinder(keys ChallengeKeyFunc, store ChallengeStore) *ChallengeFinder {
+	if store == nil {
+		store = NewMemoryChallengeStore()
+	}
+	return &ChallengeFinder{
+		keys:  keys,
+		store: store,
+		now:   time.Now,
+	}
+}
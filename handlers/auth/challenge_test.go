@@ -0,0 +1,121 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/graze/golang-service/handlers/failure"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChallengeFinder(t *testing.T) {
+	now := time.Now()
+	store := NewMemoryChallengeStore()
+	store.now = func() time.Time { return now }
+	finder := NewChallengeFinder(func(id string, r *http.Request) ([]byte, interface{}, error) {
+		if id != "client-1" {
+			return nil, nil, errors.New("unknown client")
+		}
+		return []byte("secret"), "user-1", nil
+	}, store)
+	finder.now = store.now
+
+	issue := func() string {
+		rec := httptest.NewRecorder()
+		finder.Challenge().ServeHTTP(rec, headerRequest(t, "POST", "/challenge", map[string]string{}))
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		var body challenge
+		assert.Nil(t, json.NewDecoder(rec.Body).Decode(&body))
+		assert.Equal(t, 30, body.ExpiresIn)
+		return body.Nonce
+	}
+
+	replayed := issue()
+	expired := issue()
+	cases := map[string]struct {
+		credentials func() string
+		at          time.Duration
+		user        interface{}
+		status      int
+	}{
+		"correct response": {func() string {
+			nonce := issue()
+			return "client-1:" + nonce + ":" + ChallengeResponse([]byte("secret"), nonce)
+		}, 0, "user-1", http.StatusOK},
+		"replayed response": {func() string {
+			return "client-1:" + replayed + ":" + ChallengeResponse([]byte("secret"), replayed)
+		}, 0, nil, http.StatusUnauthorized},
+		"expired challenge": {func() string {
+			return "client-1:" + expired + ":" + ChallengeResponse([]byte("secret"), expired)
+		}, DefaultChallengeTTL + time.Second, nil, http.StatusUnauthorized},
+		"wrong hmac": {func() string {
+			nonce := issue()
+			return "client-1:" + nonce + ":" + ChallengeResponse([]byte("guess"), nonce)
+		}, 0, nil, http.StatusUnauthorized},
+		"unknown challenge": {func() string {
+			return "client-1:made-up:" + ChallengeResponse([]byte("secret"), "made-up")
+		}, 0, nil, http.StatusUnauthorized},
+		"unknown client": {func() string {
+			nonce := issue()
+			return "client-2:" + nonce + ":" + ChallengeResponse([]byte("secret"), nonce)
+		}, 0, nil, http.StatusUnauthorized},
+		"malformed": {func() string {
+			return "client-1:secret"
+		}, 0, nil, http.StatusUnauthorized},
+	}
+
+	// the replayed challenge is answered once before the cases run
+	_, err := finder.Find("client-1:"+replayed+":"+ChallengeResponse([]byte("secret"), replayed), headerRequest(t, "GET", "/", map[string]string{}))
+	assert.Nil(t, err)
+
+	start := now
+	for k, tc := range cases {
+		now = start
+		credentials := tc.credentials()
+		now = start.Add(tc.at)
+
+		var user interface{}
+		keyAuth := NewAPIKey("Challenge", finder, failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, status int) {
+			w.WriteHeader(status)
+		}))
+		rec := httptest.NewRecorder()
+		req := headerRequest(t, "GET", "/stuff", map[string]string{"Authorization": "Challenge " + credentials})
+		keyAuth.ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+			user = GetUser(r)
+		}).ServeHTTP(rec, req)
+
+		assert.Equal(t, tc.status, rec.Code, "test: %s", k)
+		assert.Equal(t, tc.user, user, "test: %s", k)
+	}
+}
+
+func TestChallengeFinderErrors(t *testing.T) {
+	finder := NewChallengeFinder(func(id string, r *http.Request) ([]byte, interface{}, error) {
+		return []byte("secret"), "user-1", nil
+	}, nil)
+	req := headerRequest(t, "GET", "/", map[string]string{})
+
+	rec := httptest.NewRecorder()
+	finder.Challenge().ServeHTTP(rec, req)
+	var body challenge
+	json.NewDecoder(rec.Body).Decode(&body)
+
+	_, err := finder.Find("client-1:"+body.Nonce+":wrong", req)
+	assert.IsType(t, &InvalidChallengeError{}, err)
+	_, err = finder.Find("client-1:"+body.Nonce+":"+ChallengeResponse([]byte("secret"), body.Nonce), req)
+	assert.IsType(t, &InvalidChallengeError{}, err, "a challenge only gets one response")
+}
@@ -0,0 +1,214 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/graze/golang-service/handlers/failure"
+)
+
+type (
+	// NoClientCertError when the request was not made with a client certificate
+	NoClientCertError struct{}
+	// CertNotAllowedError when the client certificate does not match any of the allowed certificates
+	CertNotAllowedError struct{ subject string }
+	// InvalidCertError if the client certificate was not verified, or the Finder returned an error for it
+	InvalidCertError struct {
+		subject string
+		err     error
+	}
+)
+
+func (e *NoClientCertError) Error() string {
+	return "no client certificate provided"
+}
+
+// Status returns 401 (Unauthorized)
+func (e *NoClientCertError) Status() int {
+	return http.StatusUnauthorized
+}
+
+func (e *CertNotAllowedError) Error() string {
+	return fmt.Sprintf("client certificate: '%s' is not allowed", e.subject)
+}
+
+// Status returns 403 (Forbidden)
+func (e *CertNotAllowedError) Status() int {
+	return http.StatusForbidden
+}
+
+func (e *InvalidCertError) Error() string {
+	return fmt.Sprintf("client certificate: '%s' is not valid: %s", e.subject, e.err.Error())
+}
+
+// Status returns 401 (Unauthorized)
+func (e *InvalidCertError) Status() int {
+	return http.StatusUnauthorized
+}
+
+// errUnverifiedCert is the error of an *InvalidCertError when the server did not verify the certificate chain
+var errUnverifiedCert = errors.New("the certificate chain was not verified")
+
+// peerCert returns the client certificate the request was made with
+func peerCert(r *http.Request) (*x509.Certificate, bool) {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return nil, false
+	}
+	return r.TLS.PeerCertificates[0], true
+}
+
+// verifiedCert returns the leaf of the first chain the server verified the client certificate with, or false if the
+// certificate was not verified, such as when the server uses tls.RequestClientCert
+func verifiedCert(r *http.Request) (*x509.Certificate, bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil, false
+	}
+	return r.TLS.VerifiedChains[0][0], true
+}
+
+// Fingerprint returns the hex encoded sha256 fingerprint of cert
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// ClientCertFinder is a Finder that allows client certificates matching a common name, DNS subject alternative name or
+// fingerprint
+//
+// Only a certificate the server verified is matched, so the tls.Config of the server should use
+// tls.RequireAndVerifyClientCert (or tls.VerifyClientCertIfGiven). An unverified certificate, such as a self signed
+// certificate sent to a server using tls.RequestClientCert, is rejected with an *InvalidCertError (401). The credentials
+// should be the *x509.Certificate, otherwise the certificate the request was made with is used
+type ClientCertFinder struct {
+	// CommonNames allows certificates with a subject common name in the list
+	CommonNames []string
+	// DNSNames allows certificates with any DNS subject alternative name in the list
+	DNSNames []string
+	// Fingerprints allows certificates with a sha256 fingerprint in the list, in hex with optional colons
+	Fingerprints []string
+	// User returns the user for an allowed certificate, the default returns the certificate subject (pkix.Name)
+	User func(cert *x509.Certificate) interface{}
+}
+
+// allowed returns true if cert matches any of the allowed common names, DNS names or fingerprints
+func (f *ClientCertFinder) allowed(cert *x509.Certificate) bool {
+	for _, cn := range f.CommonNames {
+		if cert.Subject.CommonName == cn {
+			return true
+		}
+	}
+	for _, name := range f.DNSNames {
+		for _, san := range cert.DNSNames {
+			if strings.EqualFold(san, name) {
+				return true
+			}
+		}
+	}
+	fingerprint := Fingerprint(cert)
+	for _, fp := range f.Fingerprints {
+		if strings.ToLower(strings.Replace(fp, ":", "", -1)) == fingerprint {
+			return true
+		}
+	}
+	return false
+}
+
+// Find returns the user for the verified client certificate, or a *NoClientCertError (401), *InvalidCertError (401) or
+// *CertNotAllowedError (403)
+func (f *ClientCertFinder) Find(credentials interface{}, r *http.Request) (interface{}, error) {
+	cert, ok := credentials.(*x509.Certificate)
+	if !ok {
+		if cert, ok = peerCert(r); !ok {
+			return nil, &NoClientCertError{}
+		}
+	}
+	verified, ok := verifiedCert(r)
+	if !ok || !bytes.Equal(verified.Raw, cert.Raw) {
+		return nil, &InvalidCertError{cert.Subject.CommonName, errUnverifiedCert}
+	}
+	cert = verified
+	if !f.allowed(cert) {
+		return nil, &CertNotAllowedError{cert.Subject.CommonName}
+	}
+	if f.User != nil {
+		return f.User(cert), nil
+	}
+	return cert.Subject, nil
+}
+
+// ClientCert contains a wrapper around a handler to provide authentication using mutual TLS client certificates
+//
+// The client certificate (*x509.Certificate) is passed as the credentials to the Finder
+// if anything goes wrong, a callback on onError is called with the error and the http StatusCode to return
+type ClientCert struct {
+	// Finder takes the provided *x509.Certificate and returns a user object or error if the certificate is invalid
+	Finder Finder
+	// OnError gets called if the request is unauthorized or forbidden, if nil the default set by SetDefaultOnError is used
+	OnError failure.Handler
+}
+
+// ThenFunc surrounds an existing handler func and returns a new http.Handler
+//
+// Usage:
+//  certAuth := auth.NewClientCert(&auth.ClientCertFinder{CommonNames: []string{"orders"}}, onError)
+//
+//  http.Handle("/thing", certAuth.ThenFunc(ThingFunc))
+func (c *ClientCert) ThenFunc(fn func(http.ResponseWriter, *http.Request)) http.Handler {
+	return c.Handler(http.HandlerFunc(fn))
+}
+
+// Then surrounds an existing http.Handler and returns a new http.Handler
+//
+// Usage:
+//  certAuth := auth.NewClientCert(&auth.ClientCertFinder{CommonNames: []string{"orders"}}, onError)
+//
+//  http.Handle("/thing", certAuth.Then(ThingHandler))
+func (c *ClientCert) Then(h http.Handler) http.Handler {
+	return c.Handler(h)
+}
+
+// Handler wraps the Then method to become clearer
+func (c *ClientCert) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		onError := orDefault(c.OnError)
+		cert, ok := peerCert(req)
+		if !ok {
+			onError.Handle(w, req, &NoClientCertError{}, http.StatusUnauthorized)
+			return
+		}
+
+		user, err := c.Finder.Find(cert, req)
+		if err != nil {
+			if statusErr, ok := err.(StatusError); ok {
+				onError.Handle(w, req, statusErr, statusErr.Status())
+			} else {
+				onError.Handle(w, req, &InvalidCertError{cert.Subject.CommonName, err}, http.StatusUnauthorized)
+			}
+			return
+		}
+		req = saveScheme(saveUser(req, user), "client_cert", "")
+
+		h.ServeHTTP(w, req)
+	})
+}
+
+// NewClientCert returns a ClientCert struct that has a Handle method to provide authentication to your service
+func NewClientCert(finder Finder, onError failure.Handler) *ClientCert {
+	return &ClientCert{finder, onError}
+}
@@ -0,0 +1,155 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/graze/golang-service/handlers/failure"
+	"github.com/stretchr/testify/assert"
+)
+
+// certRequest returns a request made with certs, which the server verified
+func certRequest(t *testing.T, certs ...*x509.Certificate) *http.Request {
+	req := headerRequest(t, "GET", "/path", map[string]string{})
+	if len(certs) > 0 {
+		req.TLS = &tls.ConnectionState{PeerCertificates: certs, VerifiedChains: [][]*x509.Certificate{certs}}
+	}
+	return req
+}
+
+// unverifiedCertRequest returns a request made with certs that the server did not verify
+func unverifiedCertRequest(t *testing.T, certs ...*x509.Certificate) *http.Request {
+	req := headerRequest(t, "GET", "/path", map[string]string{})
+	req.TLS = &tls.ConnectionState{PeerCertificates: certs}
+	return req
+}
+
+func TestClientCertFinder(t *testing.T) {
+	orders := &x509.Certificate{Subject: pkix.Name{CommonName: "orders"}, Raw: []byte("orders")}
+	billing := &x509.Certificate{Subject: pkix.Name{CommonName: "billing"}, DNSNames: []string{"billing.internal"}, Raw: []byte("billing")}
+	pinned := &x509.Certificate{Subject: pkix.Name{CommonName: "pinned"}, Raw: []byte("pinned")}
+	other := &x509.Certificate{Subject: pkix.Name{CommonName: "other"}, DNSNames: []string{"other.internal"}, Raw: []byte("other")}
+
+	finder := &ClientCertFinder{
+		CommonNames:  []string{"orders"},
+		DNSNames:     []string{"Billing.Internal"},
+		Fingerprints: []string{"3F:AB:5C:18:1B:D2:8A:09:B6:43:97:DF:76:AE:2B:FA:F1:EA:C1:82:97:9B:5F:DB:7A:34:28:58:00:4F:36:AF"},
+	}
+
+	cases := map[string]struct {
+		cert   *x509.Certificate
+		user   interface{}
+		err    error
+		status int
+	}{
+		"common name": {orders, orders.Subject, nil, 0},
+		"dns name":    {billing, billing.Subject, nil, 0},
+		"fingerprint": {pinned, pinned.Subject, nil, 0},
+		"not allowed": {other, nil, &CertNotAllowedError{}, http.StatusForbidden},
+		"no cert":     {nil, nil, &NoClientCertError{}, http.StatusUnauthorized},
+	}
+
+	for k, tc := range cases {
+		req := certRequest(t)
+		if tc.cert != nil {
+			req = certRequest(t, tc.cert)
+		}
+		user, err := finder.Find(nil, req)
+		assert.Equal(t, tc.user, user, "test: %s", k)
+		if tc.err == nil {
+			assert.Nil(t, err, "test: %s", k)
+			continue
+		}
+		assert.IsType(t, tc.err, err, "test: %s", k)
+		assert.Equal(t, tc.status, err.(StatusError).Status(), "test: %s", k)
+	}
+}
+
+func TestClientCertFinderFingerprint(t *testing.T) {
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "anything"}, Raw: []byte("fingerprinted")}
+	finder := &ClientCertFinder{
+		Fingerprints: []string{Fingerprint(cert)},
+		User: func(cert *x509.Certificate) interface{} {
+			return cert.Subject.CommonName
+		},
+	}
+
+	user, err := finder.Find(cert, certRequest(t, cert))
+	assert.Nil(t, err)
+	assert.Equal(t, "anything", user)
+}
+
+func TestClientCertFinderUnverified(t *testing.T) {
+	selfSigned := &x509.Certificate{Subject: pkix.Name{CommonName: "trusted-service"}, Raw: []byte("self signed")}
+	verified := &x509.Certificate{Subject: pkix.Name{CommonName: "trusted-service"}, Raw: []byte("verified")}
+	finder := &ClientCertFinder{CommonNames: []string{"trusted-service"}}
+
+	_, err := finder.Find(nil, unverifiedCertRequest(t, selfSigned))
+	if assert.IsType(t, &InvalidCertError{}, err, "an unverified certificate with an allowed common name is rejected") {
+		assert.Equal(t, http.StatusUnauthorized, err.(StatusError).Status())
+	}
+
+	_, err = finder.Find(selfSigned, certRequest(t, verified))
+	assert.IsType(t, &InvalidCertError{}, err, "credentials that are not the verified certificate are rejected")
+
+	user, err := finder.Find(verified, certRequest(t, verified))
+	assert.Nil(t, err)
+	assert.Equal(t, verified.Subject, user)
+}
+
+func TestClientCert(t *testing.T) {
+	allowed := &x509.Certificate{Subject: pkix.Name{CommonName: "orders"}, Raw: []byte("orders")}
+	other := &x509.Certificate{Subject: pkix.Name{CommonName: "other"}, Raw: []byte("other")}
+
+	cases := map[string]struct {
+		finder Finder
+		req    *http.Request
+		err    error
+		status int
+	}{
+		"allowed":      {&ClientCertFinder{CommonNames: []string{"orders"}}, certRequest(t, allowed), nil, http.StatusOK},
+		"no cert":      {&ClientCertFinder{CommonNames: []string{"orders"}}, certRequest(t), &NoClientCertError{}, http.StatusUnauthorized},
+		"not allowed":  {&ClientCertFinder{CommonNames: []string{"orders"}}, certRequest(t, other), &CertNotAllowedError{}, http.StatusForbidden},
+		"finder error": {noUserFinder, certRequest(t, allowed), &InvalidCertError{}, http.StatusUnauthorized},
+		"unverified":   {&ClientCertFinder{CommonNames: []string{"orders"}}, unverifiedCertRequest(t, allowed), &InvalidCertError{}, http.StatusUnauthorized},
+	}
+
+	for k, tc := range cases {
+		var authErr error
+		var status int
+		var user interface{}
+		certAuth := NewClientCert(tc.finder, failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, s int) {
+			authErr = err
+			status = s
+			w.WriteHeader(s)
+		}))
+		rec := httptest.NewRecorder()
+		certAuth.ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+			user = GetUser(r)
+			w.WriteHeader(http.StatusOK)
+		}).ServeHTTP(rec, tc.req)
+
+		assert.Equal(t, tc.status, rec.Code, "test: %s", k)
+		if tc.err == nil {
+			assert.Nil(t, authErr, "test: %s", k)
+			assert.Equal(t, allowed.Subject, user, "test: %s", k)
+			continue
+		}
+		assert.IsType(t, tc.err, authErr, "test: %s", k)
+		assert.Equal(t, tc.status, status, "test: %s", k)
+	}
+}
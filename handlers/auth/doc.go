@@ -41,6 +41,11 @@ The Finder and FailHandler are common to all authentication types
         fmt.Fprintf(w, err.Error())
     }
 
+If OnError is not set, a package default is used that writes the status text as plain text. It can be changed with
+SetDefaultOnError
+
+    auth.SetDefaultOnError(failure.HandlerFunc(onError))
+
 Authorization Bearer Api Key Auth
 
 For a basic api key based authentication. It directly passes the apiKey as a the credentials to the Finder.Func method
@@ -50,6 +55,10 @@ Usage:
 
     http.Handle("/", keyAuth.Next(router))
 
+Setting RequireTLS rejects requests not made over TLS with an *InsecureTransportError (403) before calling the Finder
+
+    keyAuth.RequireTLS = true
+
 X-Api-Key Authorization
 
 Almost identical to the Authorization header, is using the X-Api-Key header to simply provide just they key to handle.
@@ -60,6 +69,154 @@ Usage:
 
     http.Handle("/", keyAuth.Next(router))
 
+Client Certificate Authorization
+
+For mutual TLS, the client certificate (*x509.Certificate) is passed as the credentials to the Finder. The
+ClientCertFinder allows certificates by common name, DNS name or fingerprint
+
+Usage:
+    finder := &auth.ClientCertFinder{CommonNames: []string{"orders"}}
+    certAuth := auth.NewClientCert(finder, failure.HandlerFunc(onError))
+
+    http.Handle("/", certAuth.Then(router))
+
+gRPC-Web Metadata
+
+FromMetadata copies the credentials from a gRPC metadata header (default: Grpc-Metadata-Authorization) to the
+Authorization header, so requests bridged from gRPC-Web can use the same authentication
+
+    http.Handle("/", auth.FromMetadata("", "")(keyAuth.Then(router)))
+
+Finder Errors
+
+Errors returned by a Finder are passed to the error handler as an *InvalidKeyError with a status of 401, unless they
+implement StatusError in which case they are passed as is with the status they provide
+
+    type StatusError interface {
+        error
+        Status() int
+    }
+
+Limiting Failed Attempts
+
+The AttemptLimiter wraps a Finder and stops calling it for an ip address after too many failures within a window,
+returning a *TooManyAttemptsError (429) instead
+
+    finder := auth.NewAttemptLimiter(auth.FinderFunc(finder), 5, time.Minute)
+    keyAuth := auth.NewAPIKey("Graze", finder, failure.HandlerFunc(onError))
+
+Per Key Rate Limits
+
+The KeyRateLimiter wraps a Finder and limits each key to the rate of its user, if the user implements RateLimited,
+returning a *RateLimitExceededError (429) once the limit is used
+
+    finder := auth.NewKeyRateLimiter(auth.FinderFunc(finder), time.Hour)
+    keyAuth := auth.NewAPIKey("Graze", finder, failure.HandlerFunc(onError))
+
+Anonymous Rate Limits
+
+The AnonymousRateLimit handler lets requests without credentials through under a stricter rate limit for each client
+ip, and passes the rest to an authentication handler
+
+    anon := auth.NewAnonymousRateLimit(keyAuth.Handler, 1, 5, time.Hour, failure.HandlerFunc(onError))
+
+Throttling Key Lookups
+
+The ThrottledFinder wraps a Finder and limits the total rate of lookups across all requests, shedding lookups over the
+rate with a *ThrottledError (429 by default) or queuing them for up to MaxWait
+
+    finder := auth.NewThrottledFinder(auth.FinderFunc(finder), 100, 20)
+    finder.MaxWait = 100 * time.Millisecond
+
+Path Restricted Keys
+
+The PathRestrictedFinder wraps a Finder and rejects users implementing PathRestricted that use a path outside their
+allowed prefixes with a *PathNotAllowedError (403)
+
+    finder := auth.NewPathRestrictedFinder(auth.FinderFunc(finder))
+    keyAuth := auth.NewAPIKey("Graze", finder, failure.HandlerFunc(onError))
+
+Method Restricted Keys
+
+The MethodRestrictedFinder wraps a Finder and rejects users implementing MethodRestricted that use an HTTP method
+they are not allowed to with a *MethodNotAllowedForKeyError (403)
+
+    finder := auth.NewMethodRestrictedFinder(auth.FinderFunc(finder))
+    keyAuth := auth.NewAPIKey("Graze", finder, failure.HandlerFunc(onError))
+
+IP Restricted Keys
+
+The IPRestrictedFinder wraps a Finder and rejects users implementing IPRestricted that are used from an ip outside
+their allowed ranges with an *IPNotAllowedError (403). TrustedProxyIP finds the client ip behind trusted proxies
+
+    finder := auth.NewIPRestrictedFinder(auth.FinderFunc(finder))
+    finder.ClientIP = auth.TrustedProxyIP("10.0.0.0/8")
+    keyAuth := auth.NewAPIKey("Graze", finder, failure.HandlerFunc(onError))
+
+Multiple Keys per User
+
+The MultiKeyFinder allows each user to have several keys, which can be added and removed at runtime
+
+    finder := auth.NewMultiKeyFinder(map[interface{}][]string{user: {primaryKey, ciKey}})
+    finder.RemoveKey(ciKey)
+
+Hierarchical Keys
+
+The HierarchicalFinder returns a *ScopedUser for keys issued by other keys, with the scopes of the key that its parents
+also have. Revoking a key also revokes every key issued below it
+
+    finder := auth.NewHierarchicalFinder()
+    finder.AddKey(accountKey, account, "orders:read", "orders:write")
+    finder.AddChildKey(accountKey, reportingKey, reporting, "orders:read")
+
+Chained Key Stores
+
+The ChainFinder queries each Finder in order and returns the first user found, only failing if none of them find the
+key. Unavailable stores (errors with a status of 500 or more) fail straight away unless SkipUnavailable is set
+
+    finder := auth.NewChainFinder(newStore, legacyStore)
+
+Caching Users
+
+The CachingFinder wraps a slow Finder and caches each user found for a time. If Metrics is set, each lookup sends an
+auth.cache.hit or auth.cache.miss counter, and an auth.cache.size gauge if it also implements CacheSizeMetrics
+
+    finder := auth.NewCachingFinder(auth.FinderFunc(finder), time.Minute, 1000)
+    finder.Metrics = statsdClient
+
+Auditing
+
+The AuditFinder wraps a Finder and sends an AuditEvent to an AuditSink for every successful and failed attempt
+
+    finder := auth.NewAuditFinder(auth.FinderFunc(finder), auth.LogAuditSink(log.With(log.KV{"module": "audit"})))
+
+Shadow Key Stores
+
+The ShadowFinder uses the result of a primary Finder, and looks each key up in a shadow Finder in the background,
+logging a warning with the tag auth_shadow_mismatch when they disagree
+
+    finder := auth.NewShadowFinder(currentStore, newStore, log.With(log.KV{"module": "auth"}))
+
+Hashed Keys
+
+HashedKeys is a Finder that only stores bcrypt hashes of the keys. HashKey returns the fingerprint and hash of a key
+to store at setup time, and NewHashedKeys loads the stored hashes. Each call does at most one bcrypt compare, and
+wrapping it in a CachingFinder skips it for keys seen recently
+
+    hashed, err := auth.HashKey(key, "user-id", bcrypt.DefaultCost)
+    // store hashed.Fingerprint and hashed.Hash, then when the service starts
+    finder := auth.NewCachingFinder(auth.NewHashedKeys(storedKeys), time.Minute, 1000)
+    keyAuth := auth.NewAPIKey("Graze", finder, failure.HandlerFunc(onError))
+
+Last Used Keys
+
+The LastUsedFinder wraps a Finder and records when each valid key was last used in a UsageStore, writing each key at
+most once per resolution. Keys are recorded by their sha256 fingerprint rather than the key itself
+
+    finder := auth.NewLastUsedFinder(auth.FinderFunc(finder), nil, time.Minute)
+    keyAuth := auth.NewAPIKey("Graze", finder, failure.HandlerFunc(onError))
+    lastUsed := finder.LastUsed()
+
 Usage
 
 Authentication can be added to a handler chain too:
@@ -70,6 +227,68 @@ Or using something like `alice`:
 
     chain := alice.New(first, second, keyAuth.Handler, fourth)
 
+One-Time Keys
+
+The OneTimeFinder wraps a Finder so each key can only be used once, later uses fail with a KeyConsumedError (403)
+
+    finder := auth.NewOneTimeFinder(auth.FinderFunc(finder), nil)
+
+Central Auth Service
+
+The GRPCFinder validates keys with a central auth service through the GRPCAuthClient interface, using the request
+context. gRPC status codes are mapped to an invalid key (401) or a *ServiceError (403, 429 or 503)
+
+    finder := auth.NewGRPCFinder(authClient, func(err error) uint32 { return uint32(status.Code(err)) })
+
+Vault Tokens
+
+The VaultFinder validates keys as tokens leased from Vault through a VaultClient, caching each token until its lease
+expires. A VaultUnavailableError (503) is returned if Vault can not be reached
+
+    finder := auth.NewVaultFinder(vaultClient)
+
+AWS Secrets
+
+The AWSSecretsFinder validates keys against the keys fetched from Secrets Manager or Parameter Store by an
+AWSSecretsClient, caching them for a TTL and fetching them again for unknown keys. A failed fetch is not retried for
+MinRefresh, and the stale keys are used until then. An AWSUnavailableError (503) is returned if the keys have never
+been fetched
+
+    finder := auth.NewAWSSecretsFinder(secretsClient)
+
+Directory Groups
+
+The LDAPFinder maps a key to a directory identity, and returns the *DirectoryUser found by a DirectorySearcher if it is
+a member of an allowed group. Other users are rejected with a *NotInGroupError (403), and search failures with a
+*DirectoryUnavailableError (503)
+
+    finder := auth.NewLDAPFinder(searcher, identityForKey, "api-users")
+    keyAuth := auth.NewAPIKey("Graze", finder, failure.HandlerFunc(onError))
+
+Replay Protection
+
+The ReplayProtection handler rejects signed requests with an X-Timestamp header outside of the allowed skew with a
+*StaleRequestError (401), and requests with an X-Nonce header that has already been used with a *ReplayError (401)
+
+    replay := auth.NewReplayProtection(auth.NewMemoryNonceStore(), failure.HandlerFunc(onError))
+    http.Handle("/orders", signatureAuth.Then(replay.Then(ordersHandler)))
+
+Challenge Response
+
+The ChallengeFinder lets clients prove they have a key without sending it, by answering a challenge from its Challenge
+handler with the HMAC of the nonce using the key. Each challenge can only be answered once before it expires
+
+    finder := auth.NewChallengeFinder(clientKeys, nil)
+    http.Handle("/challenge", finder.Challenge())
+
+Identity Propagation
+
+The IdentitySigner signs the authenticated user into a header that its Transport adds to outbound requests, so
+downstream services can verify it with FromRequest using the same key
+
+    signer, err := auth.NewIdentitySigner(key)
+    client := &http.Client{Transport: signer.Transport(nil)}
+
 User Retrieval
 
 The authentication also adds the user field returned by the finder to the
@@ -82,5 +301,9 @@ context object on the request. It can be retireved using the `auth.GetUser` meth
             return
         }
     }
+
+The scheme and provider that authenticated the request are returned by `auth.GetScheme`
+
+    scheme, provider := auth.GetScheme(r)
 */
 package auth
@@ -41,3 +41,23 @@ type FinderFunc func(interface{}, *http.Request) (interface{}, error)
 func (f FinderFunc) Find(c interface{}, r *http.Request) (interface{}, error) {
 	return f(c, r)
 }
+
+// StatusError is an error from a Finder that should be returned with a specific http status
+//
+// Errors that do not implement StatusError are treated as an invalid key with a status of 401
+type StatusError interface {
+	error
+	Status() int
+}
+
+// findUser calls finder with key and returns the user, or the status and error to pass to the error handler
+func findUser(finder Finder, key string, r *http.Request) (interface{}, int, error) {
+	user, err := finder.Find(key, r)
+	if err == nil {
+		return user, 0, nil
+	}
+	if statusErr, ok := err.(StatusError); ok {
+		return nil, statusErr.Status(), statusErr
+	}
+	return nil, http.StatusUnauthorized, &InvalidKeyError{key, err}
+}
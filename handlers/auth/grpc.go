@@ -0,0 +1,117 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// The gRPC status codes (google.golang.org/grpc/codes) that are mapped to authentication errors
+const (
+	grpcInvalidArgument   = 3
+	grpcDeadlineExceeded  = 4
+	grpcNotFound          = 5
+	grpcPermissionDenied  = 7
+	grpcResourceExhausted = 8
+	grpcUnauthenticated   = 16
+)
+
+// GRPCAuthClient validates a key with a central auth service, such as a wrapper around a generated gRPC client
+//
+// It returns the user or claims for a valid key. It must stop when ctx is done
+type GRPCAuthClient interface {
+	ValidateKey(ctx context.Context, key string) (interface{}, error)
+}
+
+// ServiceError is returned when the auth service failed to validate a key
+type ServiceError struct {
+	code uint32
+	err  error
+}
+
+func (e *ServiceError) Error() string {
+	return fmt.Sprintf("auth service failed with code: %d: %v", e.code, e.err)
+}
+
+// Code returns the gRPC status code returned by the auth service
+func (e *ServiceError) Code() uint32 {
+	return e.code
+}
+
+// Status returns 403 (Forbidden) for PermissionDenied, 429 (Too Many Requests) for ResourceExhausted, and 503 (Service
+// Unavailable) for anything else, such as Unavailable or DeadlineExceeded
+func (e *ServiceError) Status() int {
+	switch e.code {
+	case grpcPermissionDenied:
+		return http.StatusForbidden
+	case grpcResourceExhausted:
+		return http.StatusTooManyRequests
+	}
+	return http.StatusServiceUnavailable
+}
+
+// GRPCFinder is a Finder that validates keys with a central auth service
+//
+// The call uses the context of the request, so it stops if the client goes away or the request deadline passes.
+// Errors are mapped using their gRPC status code: NotFound, Unauthenticated and InvalidArgument are an invalid key (401),
+// PermissionDenied is 403, ResourceExhausted is 429, and any other code (such as Unavailable) is a *ServiceError
+// with a status of 503
+type GRPCFinder struct {
+	// Timeout limits each call to the auth service if set, the request deadline is always honoured
+	Timeout time.Duration
+
+	client GRPCAuthClient
+	code   func(err error) uint32
+}
+
+// Find returns the user from the auth service for the key
+func (f *GRPCFinder) Find(credentials interface{}, r *http.Request) (interface{}, error) {
+	key, ok := credentials.(string)
+	if !ok {
+		return nil, errors.New("the supplied key is in an invalid format")
+	}
+	ctx := r.Context()
+	if f.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.Timeout)
+		defer cancel()
+	}
+
+	user, err := f.client.ValidateKey(ctx, key)
+	if err == nil {
+		return user, nil
+	}
+	code := f.code(err)
+	if ctx.Err() == context.DeadlineExceeded {
+		code = grpcDeadlineExceeded
+	}
+	switch code {
+	case grpcNotFound, grpcUnauthenticated, grpcInvalidArgument:
+		return nil, err
+	}
+	return nil, &ServiceError{code, err}
+}
+
+// NewGRPCFinder returns a GRPCFinder that validates keys with client. code returns the gRPC status code of an error
+// from the client, so this package does not depend on gRPC
+//
+// Usage:
+//  finder := auth.NewGRPCFinder(authClient, func(err error) uint32 {
+//      return uint32(status.Code(err))
+//  })
+//  keyAuth := auth.NewAPIKey("Graze", finder, failure.HandlerFunc(onError))
+func NewGRPCFinder(client GRPCAuthClient, code func(err error) uint32) *GRPCFinder {
+	return &GRPCFinder{client: client, code: code}
+}
@@ -0,0 +1,138 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/graze/golang-service/handlers/failure"
+	"github.com/stretchr/testify/assert"
+)
+
+// rpcError is an error with a gRPC status code
+type rpcError struct {
+	code uint32
+}
+
+func (e *rpcError) Error() string {
+	return "rpc error"
+}
+
+func rpcCode(err error) uint32 {
+	if e, ok := err.(*rpcError); ok {
+		return e.code
+	}
+	return 2 // Unknown
+}
+
+// fakeAuthService is a GRPCAuthClient with a fixed set of users, and errors for some keys
+type fakeAuthService struct {
+	users  map[string]interface{}
+	errors map[string]uint32
+	ctx    context.Context
+}
+
+func (s *fakeAuthService) ValidateKey(ctx context.Context, key string) (interface{}, error) {
+	s.ctx = ctx
+	if key == "slow" {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	if code, ok := s.errors[key]; ok {
+		return nil, &rpcError{code}
+	}
+	if user, ok := s.users[key]; ok {
+		return user, nil
+	}
+	return nil, &rpcError{grpcNotFound}
+}
+
+func TestGRPCFinder(t *testing.T) {
+	service := &fakeAuthService{
+		users: map[string]interface{}{"good": "user"},
+		errors: map[string]uint32{
+			"unauthenticated": grpcUnauthenticated,
+			"malformed":       grpcInvalidArgument,
+			"denied":          grpcPermissionDenied,
+			"exhausted":       grpcResourceExhausted,
+			"unavailable":     14,
+			"internal":        13,
+		},
+	}
+	finder := NewGRPCFinder(service, rpcCode)
+
+	cases := map[string]struct {
+		key    string
+		user   interface{}
+		err    error
+		status int
+	}{
+		"valid key":       {"good", "user", nil, http.StatusOK},
+		"not found":       {"unknown", nil, &InvalidKeyError{}, http.StatusUnauthorized},
+		"unauthenticated": {"unauthenticated", nil, &InvalidKeyError{}, http.StatusUnauthorized},
+		"invalid":         {"malformed", nil, &InvalidKeyError{}, http.StatusUnauthorized},
+		"denied":          {"denied", nil, &ServiceError{}, http.StatusForbidden},
+		"exhausted":       {"exhausted", nil, &ServiceError{}, http.StatusTooManyRequests},
+		"unavailable":     {"unavailable", nil, &ServiceError{}, http.StatusServiceUnavailable},
+		"internal":        {"internal", nil, &ServiceError{}, http.StatusServiceUnavailable},
+	}
+
+	for k, tc := range cases {
+		var authErr error
+		var user interface{}
+		keyAuth := NewAPIKey("Graze", finder, failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, status int) {
+			authErr = err
+			w.WriteHeader(status)
+		}))
+		rec := httptest.NewRecorder()
+		req := headerRequest(t, "GET", "/stuff", map[string]string{"Authorization": "Graze " + tc.key})
+		keyAuth.ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+			user = GetUser(r)
+		}).ServeHTTP(rec, req)
+
+		assert.Equal(t, tc.status, rec.Code, "test: %s", k)
+		assert.Equal(t, tc.user, user, "test: %s", k)
+		if tc.err != nil {
+			assert.IsType(t, tc.err, authErr, "test: %s", k)
+		}
+	}
+
+	_, err := finder.Find(1234, ipRequest(t, "10.0.0.1"))
+	assert.NotNil(t, err)
+}
+
+func TestGRPCFinderDeadline(t *testing.T) {
+	service := &fakeAuthService{}
+	finder := NewGRPCFinder(service, rpcCode)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req := ipRequest(t, "10.0.0.1").WithContext(ctx)
+	_, err := finder.Find("slow", req)
+	if assert.IsType(t, &ServiceError{}, err) {
+		assert.Equal(t, uint32(grpcDeadlineExceeded), err.(*ServiceError).Code(), "the request deadline is honoured")
+		assert.Equal(t, http.StatusServiceUnavailable, err.(*ServiceError).Status())
+	}
+	deadline, ok := service.ctx.Deadline()
+	assert.True(t, ok)
+	reqDeadline, _ := ctx.Deadline()
+	assert.Equal(t, reqDeadline, deadline, "the request context is passed to the client")
+
+	finder.Timeout = 10 * time.Millisecond
+	start := time.Now()
+	_, err = finder.Find("slow", ipRequest(t, "10.0.0.1"))
+	assert.IsType(t, &ServiceError{}, err)
+	assert.True(t, time.Since(start) < time.Second, "the timeout limits the call")
+}
@@ -0,0 +1,109 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// HashedKey is the bcrypt hash of an api key and its fingerprint, created by HashKey at setup time so it can be stored
+// instead of the key
+type HashedKey struct {
+	// Fingerprint is the hex encoded sha256 of the key, used to find the hash so each key is only compared to one hash
+	Fingerprint string
+	// Hash is the bcrypt hash of the key
+	Hash string
+	// User is the user the key belongs to
+	User interface{}
+}
+
+// HashKey returns the fingerprint and bcrypt hash of key for user, to be stored and loaded with NewHashedKeys
+//
+// cost is the bcrypt cost, use bcrypt.DefaultCost unless you have a reason not to
+//
+// Usage:
+//  hashed, err := auth.HashKey(key, "user-id", bcrypt.DefaultCost)
+//  db.Exec("INSERT INTO api_keys (fingerprint, hash, user_id) VALUES (?, ?, ?)", hashed.Fingerprint, hashed.Hash, "user-id")
+func HashKey(key string, user interface{}, cost int) (HashedKey, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(key), cost)
+	if err != nil {
+		return HashedKey{}, err
+	}
+	return HashedKey{fingerprint(key), string(hash), user}, nil
+}
+
+// HashedKeys is a Finder that stores bcrypt hashes of api keys rather than the keys themselves
+//
+// The hashes are indexed by the sha256 fingerprint of each key, so each call does at most one bcrypt compare and unknown
+// keys do none. bcrypt is slow by design, so wrap it in a CachingFinder to avoid the compare for keys that have been
+// seen recently
+type HashedKeys struct {
+	keys    map[string]HashedKey
+	compare func(hash, key []byte) error
+}
+
+// Find returns the user whose hashed key matches the supplied key
+func (h *HashedKeys) Find(c interface{}, r *http.Request) (interface{}, error) {
+	key, ok := c.(string)
+	if !ok {
+		return nil, fmt.Errorf("the supplied key is in an invalid format")
+	}
+	hashed, ok := h.keys[fingerprint(key)]
+	if !ok || h.compare([]byte(hashed.Hash), []byte(key)) != nil {
+		return nil, fmt.Errorf("no user found for the supplied key")
+	}
+	return hashed.User, nil
+}
+
+// NewHashedKeys returns a HashedKeys from keys hashed with HashKey, such as those loaded from a database, so the service
+// never holds the plain text keys
+//
+// Usage:
+//  keys := []auth.HashedKey{}
+//  for rows.Next() {
+//      var key auth.HashedKey
+//      rows.Scan(&key.Fingerprint, &key.Hash, &key.User)
+//      keys = append(keys, key)
+//  }
+//  finder := auth.NewCachingFinder(auth.NewHashedKeys(keys), time.Minute, 1000)
+//  keyAuth := auth.NewAPIKey("Graze", finder, failure.HandlerFunc(onError))
+func NewHashedKeys(keys []HashedKey) *HashedKeys {
+	hashed := &HashedKeys{
+		keys:    make(map[string]HashedKey, len(keys)),
+		compare: bcrypt.CompareHashAndPassword,
+	}
+	for _, key := range keys {
+		hashed.keys[strings.ToLower(key.Fingerprint)] = key
+	}
+	return hashed
+}
+
+// HashKeys creates a HashedKeys from a map of plain text keys to users, such as in tests. Services should store the
+// hashes from HashKey and load them with NewHashedKeys so they do not hold the plain text keys
+//
+// Usage:
+//  hashed, err := auth.HashKeys(map[string]interface{}{"some-key": user}, bcrypt.MinCost)
+//  keyAuth := auth.NewAPIKey("Graze", hashed, failure.HandlerFunc(onError))
+func HashKeys(keys map[string]interface{}, cost int) (*HashedKeys, error) {
+	hashed := make([]HashedKey, 0, len(keys))
+	for key, user := range keys {
+		h, err := HashKey(key, user, cost)
+		if err != nil {
+			return nil, err
+		}
+		hashed = append(hashed, h)
+	}
+	return NewHashedKeys(hashed), nil
+}
@@ -0,0 +1,78 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestHashedKeys(t *testing.T) {
+	finder, err := HashKeys(map[string]interface{}{
+		"key-one": "user one",
+		"key-two": "user two",
+	}, bcrypt.MinCost)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(finder.keys))
+	for index, hashed := range finder.keys {
+		assert.NotContains(t, []string{"key-one", "key-two"}, index, "the plain text key is not stored")
+		assert.NotContains(t, []string{"key-one", "key-two"}, hashed.Hash, "the plain text key is not stored")
+	}
+
+	compares := 0
+	finder.compare = func(hash, key []byte) error {
+		compares++
+		return bcrypt.CompareHashAndPassword(hash, key)
+	}
+
+	cases := map[string]struct {
+		key      interface{}
+		user     interface{}
+		err      bool
+		compares int
+	}{
+		"correct key":        {"key-two", "user two", false, 1},
+		"incorrect key":      {"key-three", nil, true, 0},
+		"invalid key format": {1, nil, true, 0},
+	}
+
+	for k, tc := range cases {
+		compares = 0
+		user, err := finder.Find(tc.key, headerRequest(t, "GET", "/path", map[string]string{}))
+		assert.Equal(t, tc.user, user, "test: %s", k)
+		assert.Equal(t, tc.err, err != nil, "test: %s", k)
+		assert.Equal(t, tc.compares, compares, "test: %s bcrypt compares", k)
+	}
+}
+
+func TestNewHashedKeys(t *testing.T) {
+	stored, err := HashKey("key-one", nil, bcrypt.MinCost)
+	assert.Nil(t, err)
+	assert.Equal(t, fingerprint("key-one"), stored.Fingerprint, "the fingerprint does not depend on the process")
+	assert.NotEqual(t, "key-one", stored.Hash)
+
+	// the hashes are loaded as they would be from a database, without the plain text keys
+	loaded := HashedKey{strings.ToUpper(stored.Fingerprint), stored.Hash, "user one"}
+	finder := NewHashedKeys([]HashedKey{loaded})
+
+	user, err := finder.Find("key-one", headerRequest(t, "GET", "/path", map[string]string{}))
+	assert.Nil(t, err)
+	assert.Equal(t, "user one", user)
+
+	_, err = finder.Find("key-two", headerRequest(t, "GET", "/path", map[string]string{}))
+	assert.NotNil(t, err)
+
+	_, err = HashKey("key", nil, bcrypt.MaxCost+1)
+	assert.NotNil(t, err, "an invalid cost is returned")
+}
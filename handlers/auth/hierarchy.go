@@ -0,0 +1,163 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+)
+
+// ScopedUser is the user returned by a HierarchicalFinder, with the scopes it is allowed after narrowing by its parents
+type ScopedUser struct {
+	// User is the user the key was added for
+	User interface{}
+	// Parent is the user of the parent key, or nil for a top level key
+	Parent interface{}
+	// Scopes are the effective scopes of the key, the scopes it was added with that all of its parents also have
+	Scopes []string
+}
+
+// HasScope returns true if scope is one of the effective scopes of the user
+func (u *ScopedUser) HasScope(scope string) bool {
+	for _, s := range u.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// scopedKey is a key in a HierarchicalFinder
+type scopedKey struct {
+	user     interface{}
+	scopes   []string
+	inherit  bool
+	parent   string
+	children map[string]bool
+}
+
+// HierarchicalFinder is a Finder for keys issued by other keys, such as a parent account issuing keys for its child
+// accounts. A child key inherits the scopes of its parent, and can be narrowed to fewer scopes
+//
+// Keys are stored as a fingerprint. Revoking a key also revokes every key issued below it
+type HierarchicalFinder struct {
+	mu   sync.RWMutex
+	keys map[string]*scopedKey
+}
+
+// Find returns a *ScopedUser for the key with its effective scopes
+func (f *HierarchicalFinder) Find(credentials interface{}, r *http.Request) (interface{}, error) {
+	key, ok := credentials.(string)
+	if !ok {
+		return nil, errors.New("the supplied key is in an invalid format")
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	k, ok := f.keys[fingerprint(key)]
+	if !ok {
+		return nil, errors.New("no user found for the key")
+	}
+	user := &ScopedUser{User: k.user, Scopes: f.effectiveScopes(k)}
+	if parent, ok := f.keys[k.parent]; ok {
+		user.Parent = parent.user
+	}
+	return user, nil
+}
+
+// effectiveScopes returns the scopes of k that all of its parents also have, the lock must be held
+func (f *HierarchicalFinder) effectiveScopes(k *scopedKey) []string {
+	if k.parent == "" {
+		return k.scopes
+	}
+	parentScopes := f.effectiveScopes(f.keys[k.parent])
+	if k.inherit {
+		return parentScopes
+	}
+	allowed := make(map[string]bool, len(parentScopes))
+	for _, s := range parentScopes {
+		allowed[s] = true
+	}
+	scopes := make([]string, 0, len(k.scopes))
+	for _, s := range k.scopes {
+		if allowed[s] {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes
+}
+
+// AddKey adds a top level key for user with scopes, replacing any existing key and the keys issued below it
+func (f *HierarchicalFinder) AddKey(key string, user interface{}, scopes ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fp := fingerprint(key)
+	f.revoke(fp)
+	f.keys[fp] = &scopedKey{user: user, scopes: scopes, children: map[string]bool{}}
+}
+
+// AddChildKey adds a key for user issued by parentKey, replacing any existing key and the keys issued below it. With
+// no scopes the key has all the scopes of its parent, otherwise it has the scopes that its parent also has. It returns
+// an error if the parent key does not exist, or is the key or was issued below it
+func (f *HierarchicalFinder) AddChildKey(parentKey, key string, user interface{}, scopes ...string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	parentFp, fp := fingerprint(parentKey), fingerprint(key)
+	parent, ok := f.keys[parentFp]
+	if !ok {
+		return errors.New("no parent found for the key")
+	}
+	for ancestor := parentFp; ancestor != ""; ancestor = f.keys[ancestor].parent {
+		if ancestor == fp {
+			return errors.New("a key can not be issued below itself")
+		}
+	}
+	f.revoke(fp)
+	f.keys[fp] = &scopedKey{user: user, scopes: scopes, inherit: len(scopes) == 0, parent: parentFp, children: map[string]bool{}}
+	parent.children[fp] = true
+	return nil
+}
+
+// RevokeKey removes key and every key issued below it
+func (f *HierarchicalFinder) RevokeKey(key string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.revoke(fingerprint(key))
+}
+
+// revoke removes the key with the fingerprint fp and its children, the lock must be held
+func (f *HierarchicalFinder) revoke(fp string) {
+	k, ok := f.keys[fp]
+	if !ok {
+		return
+	}
+	for child := range k.children {
+		f.revoke(child)
+	}
+	if parent, ok := f.keys[k.parent]; ok {
+		delete(parent.children, fp)
+	}
+	delete(f.keys, fp)
+}
+
+// NewHierarchicalFinder returns an empty HierarchicalFinder
+//
+// Usage:
+//  finder := auth.NewHierarchicalFinder()
+//  finder.AddKey(accountKey, account, "orders:read", "orders:write")
+//  finder.AddChildKey(accountKey, reportingKey, reporting, "orders:read")
+//  keyAuth := auth.NewAPIKey("Graze", finder, failure.HandlerFunc(onError))
+//
+//  user := auth.GetUser(r).(*auth.ScopedUser)
+//  if !user.HasScope("orders:write") { ... }
+func NewHierarchicalFinder() *HierarchicalFinder {
+	return &HierarchicalFinder{keys: make(map[string]*scopedKey)}
+}
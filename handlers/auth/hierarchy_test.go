@@ -0,0 +1,92 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHierarchicalFinderScopes(t *testing.T) {
+	finder := NewHierarchicalFinder()
+	finder.AddKey("account", "account", "orders:read", "orders:write", "reports:read")
+	assert.Nil(t, finder.AddChildKey("account", "reporting", "reporting", "orders:read", "reports:read", "admin"))
+	assert.Nil(t, finder.AddChildKey("account", "inherits", "inherits"))
+	assert.Nil(t, finder.AddChildKey("reporting", "grandchild", "grandchild", "reports:read", "orders:write"))
+
+	cases := map[string]struct {
+		key    string
+		user   interface{}
+		parent interface{}
+		scopes []string
+	}{
+		"top level":           {"account", "account", nil, []string{"orders:read", "orders:write", "reports:read"}},
+		"narrowed":            {"reporting", "reporting", "account", []string{"orders:read", "reports:read"}},
+		"inherits the parent": {"inherits", "inherits", "account", []string{"orders:read", "orders:write", "reports:read"}},
+		"narrowed twice":      {"grandchild", "grandchild", "reporting", []string{"reports:read"}},
+	}
+
+	for k, tc := range cases {
+		found, err := finder.Find(tc.key, ipRequest(t, "10.0.0.1"))
+		assert.Nil(t, err, "test: %s", k)
+		user, ok := found.(*ScopedUser)
+		assert.True(t, ok, "test: %s", k)
+		assert.Equal(t, tc.user, user.User, "test: %s", k)
+		assert.Equal(t, tc.parent, user.Parent, "test: %s", k)
+		assert.Equal(t, tc.scopes, user.Scopes, "test: %s", k)
+	}
+
+	user, _ := finder.Find("reporting", ipRequest(t, "10.0.0.1"))
+	assert.True(t, user.(*ScopedUser).HasScope("orders:read"))
+	assert.False(t, user.(*ScopedUser).HasScope("admin"), "a child can not widen the scopes of its parent")
+
+	_, err := finder.Find("unknown", ipRequest(t, "10.0.0.1"))
+	assert.NotNil(t, err)
+	_, err = finder.Find(1234, ipRequest(t, "10.0.0.1"))
+	assert.NotNil(t, err)
+	assert.NotNil(t, finder.AddChildKey("unknown", "orphan", "orphan"), "the parent must exist")
+	assert.NotNil(t, finder.AddChildKey("account", "account", "account"), "a key can not be its own parent")
+}
+
+func TestHierarchicalFinderRevokeCascades(t *testing.T) {
+	finder := NewHierarchicalFinder()
+	finder.AddKey("account", "account", "orders:read")
+	finder.AddKey("other", "other", "orders:read")
+	finder.AddChildKey("account", "child", "child")
+	finder.AddChildKey("child", "grandchild", "grandchild")
+	finder.AddChildKey("other", "other-child", "other-child")
+
+	finder.RevokeKey("account")
+	for _, key := range []string{"account", "child", "grandchild"} {
+		_, err := finder.Find(key, ipRequest(t, "10.0.0.1"))
+		assert.NotNil(t, err, "key: %s is revoked with its parent", key)
+	}
+	for _, key := range []string{"other", "other-child"} {
+		_, err := finder.Find(key, ipRequest(t, "10.0.0.1"))
+		assert.Nil(t, err, "key: %s is not affected", key)
+	}
+	assert.Equal(t, 2, len(finder.keys), "revoked keys are removed")
+
+	finder.AddKey("account", "account", "orders:read")
+	_, err := finder.Find("child", ipRequest(t, "10.0.0.1"))
+	assert.NotNil(t, err, "re-adding the parent does not restore its children")
+
+	finder.RevokeKey("other-child")
+	_, err = finder.Find("other", ipRequest(t, "10.0.0.1"))
+	assert.Nil(t, err, "revoking a child does not affect its parent")
+	assert.Equal(t, 0, len(finder.keys[fingerprint("other")].children))
+
+	finder.AddChildKey("other", "other-child", "other-child")
+	assert.NotNil(t, finder.AddChildKey("other-child", "other", "other"), "a key can not replace its own parent")
+	_, err = finder.Find("other-child", ipRequest(t, "10.0.0.1"))
+	assert.Nil(t, err, "the keys are not changed")
+}
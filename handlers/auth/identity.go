@@ -0,0 +1,196 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultIdentityHeader is the header the signed identity is sent in
+	DefaultIdentityHeader = "X-Identity"
+	// DefaultIdentityMaxAge is how long a signed identity is accepted for after it was signed
+	DefaultIdentityMaxAge = 5 * time.Minute
+)
+
+// InvalidIdentityError is returned when a signed identity is malformed, has an invalid signature or has expired
+type InvalidIdentityError struct {
+	reason string
+}
+
+func (e *InvalidIdentityError) Error() string {
+	return fmt.Sprintf("invalid signed identity: %s", e.reason)
+}
+
+// Status returns 401 (Unauthorized)
+func (e *InvalidIdentityError) Status() int {
+	return http.StatusUnauthorized
+}
+
+// signedIdentity is the payload of a signed identity
+type signedIdentity struct {
+	User     json.RawMessage `json:"user"`
+	IssuedAt int64           `json:"iat"`
+}
+
+// IdentitySigner signs the user of a request into a header so it can be sent to downstream services, which verify it
+// with the same key instead of authenticating the request again
+//
+// The header value is `<payload>.<signature>`, where the payload is the base64 encoded JSON of the user and the time it
+// was signed, and the signature is a base64 encoded HMAC-SHA256 of the payload. The payload is not encrypted, so the
+// user must not contain any secrets
+type IdentitySigner struct {
+	// Header is the header the signed identity is sent in (default: DefaultIdentityHeader)
+	Header string
+	// MaxAge is how long a signed identity is accepted for by Verify (default: DefaultIdentityMaxAge)
+	MaxAge time.Duration
+
+	key []byte
+	now func() time.Time
+}
+
+// Sign returns the header value for user, which must be able to be encoded as JSON
+func (s *IdentitySigner) Sign(user interface{}) (string, error) {
+	data, err := json.Marshal(user)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode the identity: %v", err)
+	}
+	payload, err := json.Marshal(signedIdentity{data, s.now().Unix()})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode the identity: %v", err)
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + s.signature(encoded), nil
+}
+
+// Verify checks the signature and age of the header value, and decodes the user into user (a pointer, as for
+// json.Unmarshal). A *InvalidIdentityError is returned if the value can not be trusted
+func (s *IdentitySigner) Verify(value string, user interface{}) error {
+	parts := strings.Split(value, ".")
+	if len(parts) != 2 {
+		return &InvalidIdentityError{"the value is malformed"}
+	}
+	if !hmac.Equal([]byte(parts[1]), []byte(s.signature(parts[0]))) {
+		return &InvalidIdentityError{"the signature does not match"}
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return &InvalidIdentityError{"the payload is malformed"}
+	}
+	var identity signedIdentity
+	if err = json.Unmarshal(payload, &identity); err != nil {
+		return &InvalidIdentityError{"the payload is malformed"}
+	}
+	maxAge := s.MaxAge
+	if maxAge <= 0 {
+		maxAge = DefaultIdentityMaxAge
+	}
+	if age := s.now().Sub(time.Unix(identity.IssuedAt, 0)); age > maxAge || age < -maxAge {
+		return &InvalidIdentityError{"the identity has expired"}
+	}
+	if err = json.Unmarshal(identity.User, user); err != nil {
+		return &InvalidIdentityError{fmt.Sprintf("the user can not be decoded: %v", err)}
+	}
+	return nil
+}
+
+// signature returns the base64 encoded HMAC of payload
+func (s *IdentitySigner) signature(payload string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// header returns the header to send the identity in
+func (s *IdentitySigner) header() string {
+	if s.Header == "" {
+		return DefaultIdentityHeader
+	}
+	return s.Header
+}
+
+// identityTransport signs the user in the context of outbound requests into a header
+type identityTransport struct {
+	signer *IdentitySigner
+	next   http.RoundTripper
+}
+
+// RoundTrip sets the signed identity header on a copy of req if its context has an authenticated user
+func (t identityTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	user := GetUser(req)
+	if user == nil {
+		return t.next.RoundTrip(req)
+	}
+	value, err := t.signer.Sign(user)
+	if err != nil {
+		return nil, err
+	}
+
+	// a RoundTripper must not modify the request, so copy it and its headers
+	out := new(http.Request)
+	*out = *req
+	out.Header = make(http.Header, len(req.Header)+1)
+	for k, v := range req.Header {
+		out.Header[k] = v
+	}
+	out.Header.Set(t.signer.header(), value)
+	return t.next.RoundTrip(out)
+}
+
+// Transport returns a http.RoundTripper that sends the signed user from the context of each outbound request, using
+// next (default: http.DefaultTransport) to make the request. The outbound request must use the inbound request's
+// context, after it has been authenticated. Requests without a user are sent unchanged
+//
+// Usage:
+//  signer, err := auth.NewIdentitySigner(key)
+//  client := &http.Client{Transport: signer.Transport(nil)}
+//  http.Handle("/thing", keyAuth.ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+//      out, _ := http.NewRequest("GET", "http://other.service/path", nil)
+//      resp, err := client.Do(out.WithContext(r.Context()))
+//  }))
+func (s *IdentitySigner) Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return identityTransport{s, next}
+}
+
+// FromRequest verifies the signed identity header of an inbound request and decodes the user into user, for use by a
+// downstream service
+//
+// Usage:
+//  var user User
+//  if err := signer.FromRequest(r, &user); err != nil {
+//      w.WriteHeader(http.StatusUnauthorized)
+//  }
+func (s *IdentitySigner) FromRequest(r *http.Request, user interface{}) error {
+	value := r.Header.Get(s.header())
+	if value == "" {
+		return &InvalidIdentityError{"no identity provided"}
+	}
+	return s.Verify(value, user)
+}
+
+// NewIdentitySigner returns an IdentitySigner that signs with key, which must be shared with the downstream services
+func NewIdentitySigner(key []byte) (*IdentitySigner, error) {
+	if len(key) == 0 {
+		return nil, errors.New("an identity signing key is required")
+	}
+	return &IdentitySigner{key: key, now: time.Now}, nil
+}
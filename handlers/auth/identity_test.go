@@ -0,0 +1,135 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/graze/golang-service/handlers/failure"
+	"github.com/stretchr/testify/assert"
+)
+
+type identityUser struct {
+	ID     string   `json:"id"`
+	Scopes []string `json:"scopes"`
+}
+
+// recordingTransport is a http.RoundTripper that records the last request
+type recordingTransport struct {
+	req *http.Request
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.req = req
+	return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader("")), Request: req}, nil
+}
+
+func TestIdentityTransport(t *testing.T) {
+	signer, err := NewIdentitySigner([]byte("secret"))
+	assert.Nil(t, err)
+	downstream := &recordingTransport{}
+	client := &http.Client{Transport: signer.Transport(downstream)}
+
+	finder := &mapFinder{users: map[string]interface{}{"good": &identityUser{"user-1", []string{"read"}}}}
+	keyAuth := NewAPIKey("Graze", finder, failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, status int) {
+		w.WriteHeader(status)
+	}))
+	handler := keyAuth.ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+		out, _ := http.NewRequest("GET", "http://other.service/path", nil)
+		out.Header.Set("Accept", "application/json")
+		resp, err := client.Do(out.WithContext(r.Context()))
+		if assert.Nil(t, err) {
+			resp.Body.Close()
+			assert.Equal(t, "", out.Header.Get(DefaultIdentityHeader), "the outbound request is not modified")
+		}
+	})
+	req := headerRequest(t, "GET", "/stuff", map[string]string{"Authorization": "Graze good"})
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if assert.NotNil(t, downstream.req) {
+		value := downstream.req.Header.Get(DefaultIdentityHeader)
+		assert.NotEqual(t, "", value)
+		assert.Equal(t, "application/json", downstream.req.Header.Get("Accept"))
+
+		var user identityUser
+		assert.Nil(t, signer.FromRequest(downstream.req, &user), "the header can be verified")
+		assert.Equal(t, identityUser{"user-1", []string{"read"}}, user)
+	}
+
+	out, _ := http.NewRequest("GET", "http://other.service/path", nil)
+	resp, err := client.Do(out)
+	if assert.Nil(t, err) {
+		resp.Body.Close()
+	}
+	assert.Equal(t, "", downstream.req.Header.Get(DefaultIdentityHeader), "requests without a user are not signed")
+}
+
+func TestIdentitySignerVerify(t *testing.T) {
+	now := time.Unix(time.Now().Unix(), 0)
+	signer, _ := NewIdentitySigner([]byte("secret"))
+	signer.now = func() time.Time { return now }
+	other, _ := NewIdentitySigner([]byte("other-secret"))
+	other.now = signer.now
+
+	value, err := signer.Sign(&identityUser{"user-1", nil})
+	assert.Nil(t, err)
+	forged, _ := other.Sign(&identityUser{"admin", nil})
+	expired, _ := signer.Sign(&identityUser{"user-1", nil})
+
+	cases := map[string]struct {
+		value string
+		at    time.Time
+		valid bool
+	}{
+		"valid":           {value, now, true},
+		"within max age":  {value, now.Add(DefaultIdentityMaxAge), true},
+		"expired":         {expired, now.Add(DefaultIdentityMaxAge + time.Second), false},
+		"other key":       {forged, now, false},
+		"tampered":        {"x" + value, now, false},
+		"malformed":       {"not-signed", now, false},
+		"empty signature": {value[:len(value)-43], now, false},
+	}
+
+	for k, tc := range cases {
+		at := tc.at
+		signer.now = func() time.Time { return at }
+		var user identityUser
+		err := signer.Verify(tc.value, &user)
+		if tc.valid {
+			assert.Nil(t, err, "test: %s", k)
+			assert.Equal(t, "user-1", user.ID, "test: %s", k)
+		} else {
+			assert.IsType(t, &InvalidIdentityError{}, err, "test: %s", k)
+		}
+	}
+
+	_, err = NewIdentitySigner(nil)
+	assert.NotNil(t, err)
+}
+
+func TestIdentitySignerHeader(t *testing.T) {
+	signer, _ := NewIdentitySigner([]byte("secret"))
+	signer.Header = "X-Mesh-Identity"
+
+	req := headerRequest(t, "GET", "/stuff", map[string]string{})
+	var user identityUser
+	assert.IsType(t, &InvalidIdentityError{}, signer.FromRequest(req, &user))
+
+	value, _ := signer.Sign(&identityUser{"user-1", nil})
+	req.Header.Set("X-Mesh-Identity", value)
+	assert.Nil(t, signer.FromRequest(req, &user))
+	assert.Equal(t, "user-1", user.ID)
+}
@@ -0,0 +1,145 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// IPRestricted is implemented by users returned from a Finder that can only be used from some ip addresses
+type IPRestricted interface {
+	// AllowedCIDRs returns the ip ranges, such as `10.0.0.0/8`, the key can be used from, an empty list allows all ips
+	AllowedCIDRs() []string
+}
+
+// IPNotAllowedError is returned when a valid key is used from an ip address it is not allowed to be used from
+type IPNotAllowedError struct {
+	ip string
+}
+
+func (e *IPNotAllowedError) Error() string {
+	return fmt.Sprintf("key is not allowed to be used from the ip: %s", e.ip)
+}
+
+// Status returns 403 (Forbidden)
+func (e *IPNotAllowedError) Status() int {
+	return http.StatusForbidden
+}
+
+// IPRestrictedFinder is a Finder that rejects keys used from outside of the ip ranges allowed by the user returned from
+// the wrapped Finder
+//
+// Users that do not implement IPRestricted can be used from any ip. Invalid ranges never match, so a user with only
+// invalid ranges can not be used from anywhere
+type IPRestrictedFinder struct {
+	// ClientIP returns the ip the request was made from, the default uses the address of the connection. Use
+	// TrustedProxyIP when the service is behind a proxy or load balancer
+	ClientIP func(r *http.Request) string
+
+	finder Finder
+}
+
+// Find returns an *IPNotAllowedError if the user is not allowed to be used from the client ip of the request
+func (f *IPRestrictedFinder) Find(credentials interface{}, r *http.Request) (interface{}, error) {
+	user, err := f.finder.Find(credentials, r)
+	if err != nil {
+		return user, err
+	}
+	restricted, ok := user.(IPRestricted)
+	if !ok {
+		return user, nil
+	}
+	cidrs := restricted.AllowedCIDRs()
+	if len(cidrs) == 0 {
+		return user, nil
+	}
+	ip := f.ClientIP(r)
+	if parsed := net.ParseIP(ip); parsed != nil {
+		for _, cidr := range cidrs {
+			if _, network, err := net.ParseCIDR(cidr); err == nil && network.Contains(parsed) {
+				return user, nil
+			}
+		}
+	}
+	return nil, &IPNotAllowedError{ip}
+}
+
+// NewIPRestrictedFinder returns a Finder that only allows users implementing IPRestricted to be used from their
+// allowed ip ranges
+//
+// Usage:
+//  func (u *User) AllowedCIDRs() []string {
+//      return u.CIDRs
+//  }
+//
+//  finder := auth.NewIPRestrictedFinder(auth.FinderFunc(finder))
+//  finder.ClientIP = auth.TrustedProxyIP("10.0.0.0/8")
+//  keyAuth := auth.NewAPIKey("Graze", finder, failure.HandlerFunc(onError))
+func NewIPRestrictedFinder(finder Finder) *IPRestrictedFinder {
+	return &IPRestrictedFinder{
+		ClientIP: remoteIP,
+		finder:   finder,
+	}
+}
+
+// TrustedProxyIP returns a function to find the client ip of a request made through the proxies in the trusted ip
+// ranges, to use as a ClientIP
+//
+// If the request was made by a trusted proxy, the last ip in the `X-Forwarded-For` header that is not a trusted proxy
+// is used, as earlier ips can be set by the client. Otherwise the address of the connection is used. It panics if a
+// range is invalid
+//
+// Usage:
+//  limiter := auth.NewAttemptLimiter(auth.FinderFunc(finder), 5, time.Minute)
+//  limiter.ClientIP = auth.TrustedProxyIP("10.0.0.0/8", "172.16.0.0/12")
+func TrustedProxyIP(cidrs ...string) func(r *http.Request) string {
+	trusted := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		trusted = append(trusted, network)
+	}
+	isTrusted := func(ip string) bool {
+		parsed := net.ParseIP(ip)
+		if parsed == nil {
+			return false
+		}
+		for _, network := range trusted {
+			if network.Contains(parsed) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(r *http.Request) string {
+		ip := remoteIP(r)
+		if !isTrusted(ip) {
+			return ip
+		}
+		var forwarded []string
+		for _, header := range r.Header["X-Forwarded-For"] {
+			forwarded = append(forwarded, strings.Split(header, ",")...)
+		}
+		for i := len(forwarded) - 1; i >= 0; i-- {
+			ip = strings.TrimSpace(forwarded[i])
+			if !isTrusted(ip) {
+				return ip
+			}
+		}
+		return ip
+	}
+}
@@ -0,0 +1,87 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/graze/golang-service/handlers/failure"
+	"github.com/stretchr/testify/assert"
+)
+
+type networkUser struct {
+	cidrs []string
+}
+
+func (u *networkUser) AllowedCIDRs() []string {
+	return u.cidrs
+}
+
+func TestIPRestrictedFinder(t *testing.T) {
+	finder := NewIPRestrictedFinder(&mapFinder{users: map[string]interface{}{
+		"office":   &networkUser{[]string{"192.0.2.0/24", "2001:db8::/32"}},
+		"invalid":  &networkUser{[]string{"not a range"}},
+		"anywhere": &networkUser{},
+		"plain":    "user",
+	}})
+	finder.ClientIP = TrustedProxyIP("10.0.0.0/8")
+
+	cases := map[string]struct {
+		key       string
+		remote    string
+		forwarded string
+		err       error
+		status    int
+	}{
+		"allowed ip":               {"office", "192.0.2.10:1234", "", nil, http.StatusOK},
+		"allowed ipv6":             {"office", "[2001:db8::1]:1234", "", nil, http.StatusOK},
+		"disallowed ip":            {"office", "198.51.100.1:1234", "", &IPNotAllowedError{}, http.StatusForbidden},
+		"allowed through proxy":    {"office", "10.0.0.1:1234", "198.51.100.1, 192.0.2.10, 10.0.0.2", nil, http.StatusOK},
+		"disallowed through proxy": {"office", "10.0.0.1:1234", "192.0.2.10, 198.51.100.1", &IPNotAllowedError{}, http.StatusForbidden},
+		"untrusted forwarded for":  {"office", "198.51.100.1:1234", "192.0.2.10", &IPNotAllowedError{}, http.StatusForbidden},
+		"proxy without forwarded":  {"office", "10.0.0.1:1234", "", &IPNotAllowedError{}, http.StatusForbidden},
+		"invalid range":            {"invalid", "192.0.2.10:1234", "", &IPNotAllowedError{}, http.StatusForbidden},
+		"no restrictions":          {"anywhere", "198.51.100.1:1234", "", nil, http.StatusOK},
+		"not restricted user":      {"plain", "198.51.100.1:1234", "", nil, http.StatusOK},
+		"invalid key":              {"missing", "192.0.2.10:1234", "", &InvalidKeyError{}, http.StatusUnauthorized},
+	}
+
+	for k, tc := range cases {
+		var authErr error
+		auth := NewAPIKey("Graze", finder, failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, status int) {
+			authErr = err
+			w.WriteHeader(status)
+		}))
+		rec := httptest.NewRecorder()
+		headers := map[string]string{"Authorization": "Graze " + tc.key}
+		if tc.forwarded != "" {
+			headers["X-Forwarded-For"] = tc.forwarded
+		}
+		req := headerRequest(t, "GET", "/orders", headers)
+		req.RemoteAddr = tc.remote
+		auth.Then(okHandler).ServeHTTP(rec, req)
+
+		assert.Equal(t, tc.status, rec.Code, "test: %s", k)
+		if tc.err == nil {
+			assert.Nil(t, authErr, "test: %s", k)
+		} else {
+			assert.IsType(t, tc.err, authErr, "test: %s", k)
+		}
+	}
+}
+
+func TestTrustedProxyIPPanicsOnInvalidRange(t *testing.T) {
+	assert.Panics(t, func() {
+		TrustedProxyIP("10.0.0.0")
+	})
+}
@@ -0,0 +1,132 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// UsageStore records the time each key was last used
+//
+// Keys are identified by their fingerprint (the hex encoded sha256 of the key), so a store never receives the keys
+type UsageStore interface {
+	// Touch records that the key with fingerprint was used at t
+	Touch(fingerprint string, t time.Time)
+	// LastUsed returns the time each key was last used by fingerprint
+	LastUsed() map[string]time.Time
+}
+
+// MemoryUsageStore is an in memory UsageStore
+type MemoryUsageStore struct {
+	mu   sync.RWMutex
+	used map[string]time.Time
+}
+
+// Touch records that the key with fingerprint was used at t
+func (s *MemoryUsageStore) Touch(fingerprint string, t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.used[fingerprint] = t
+}
+
+// LastUsed returns a copy of the time each key was last used by fingerprint
+func (s *MemoryUsageStore) LastUsed() map[string]time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	used := make(map[string]time.Time, len(s.used))
+	for k, t := range s.used {
+		used[k] = t
+	}
+	return used
+}
+
+// NewMemoryUsageStore returns an empty MemoryUsageStore
+func NewMemoryUsageStore() *MemoryUsageStore {
+	return &MemoryUsageStore{used: make(map[string]time.Time)}
+}
+
+// LastUsedFinder is a Finder that records when each key was last successfully used
+//
+// To keep the cost down on every request, a key is only written to the store if the last time it was written is older
+// than the resolution. Keys are recorded by their fingerprint, so the store and LastUsed never hold the keys
+type LastUsedFinder struct {
+	finder     Finder
+	store      UsageStore
+	resolution time.Duration
+	now        func() time.Time
+
+	mu      sync.RWMutex
+	written map[string]time.Time
+}
+
+// Find calls the wrapped Finder and records the time the key was used if it is valid
+func (f *LastUsedFinder) Find(c interface{}, r *http.Request) (interface{}, error) {
+	user, err := f.finder.Find(c, r)
+	if err != nil {
+		return user, err
+	}
+	if key, ok := c.(string); ok {
+		f.touch(fingerprint(key))
+	}
+	return user, nil
+}
+
+// touch writes the current time for the key with fingerprint to the store, unless it was written within the resolution
+func (f *LastUsedFinder) touch(fingerprint string) {
+	now := f.now()
+
+	f.mu.RLock()
+	last, ok := f.written[fingerprint]
+	f.mu.RUnlock()
+	if ok && now.Sub(last) < f.resolution {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	// another request may have written the key while waiting for the lock
+	if last, ok := f.written[fingerprint]; ok && now.Sub(last) < f.resolution {
+		return
+	}
+	f.written[fingerprint] = now
+	f.store.Touch(fingerprint, now)
+}
+
+// LastUsed returns the time each key was last used from the store, by the fingerprint of the key
+func (f *LastUsedFinder) LastUsed() map[string]time.Time {
+	return f.store.LastUsed()
+}
+
+// NewLastUsedFinder wraps finder to record the time each valid key was used in store, at most once per resolution
+//
+// If store is nil, a MemoryUsageStore is used
+//
+// Usage:
+//  finder := auth.NewLastUsedFinder(auth.FinderFunc(finder), nil, time.Minute)
+//  keyAuth := auth.NewAPIKey("Graze", finder, failure.HandlerFunc(onError))
+//  ...
+//  for fingerprint, t := range finder.LastUsed() {
+//      ...
+//  }
+func NewLastUsedFinder(finder Finder, store UsageStore, resolution time.Duration) *LastUsedFinder {
+	if store == nil {
+		store = NewMemoryUsageStore()
+	}
+	return &LastUsedFinder{
+		finder:     finder,
+		store:      store,
+		resolution: resolution,
+		now:        time.Now,
+		written:    make(map[string]time.Time),
+	}
+}
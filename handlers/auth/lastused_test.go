@@ -0,0 +1,80 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// countingStore counts the number of writes to a MemoryUsageStore
+type countingStore struct {
+	*MemoryUsageStore
+	touches int
+}
+
+func (s *countingStore) Touch(fingerprint string, t time.Time) {
+	s.touches++
+	s.MemoryUsageStore.Touch(fingerprint, t)
+}
+
+func TestLastUsedFinderRecordsUse(t *testing.T) {
+	inner := &mapFinder{users: map[string]interface{}{"key-one": "user one", "key-two": "user two"}}
+	store := &countingStore{MemoryUsageStore: NewMemoryUsageStore()}
+	finder := NewLastUsedFinder(inner, store, time.Minute)
+	now := time.Date(2016, 10, 28, 10, 51, 31, 0, time.UTC)
+	finder.now = func() time.Time { return now }
+
+	user, err := finder.Find("key-one", ipRequest(t, "10.0.0.1"))
+	assert.Nil(t, err)
+	assert.Equal(t, "user one", user)
+	finder.Find("bad-key", ipRequest(t, "10.0.0.1"))
+
+	assert.Equal(t, map[string]time.Time{fingerprint("key-one"): now}, finder.LastUsed(), "invalid keys are not recorded, and keys are recorded by fingerprint")
+
+	first := now
+	now = now.Add(30 * time.Second)
+	finder.Find("key-one", ipRequest(t, "10.0.0.1"))
+	finder.Find("key-two", ipRequest(t, "10.0.0.1"))
+	assert.Equal(t, map[string]time.Time{fingerprint("key-one"): first, fingerprint("key-two"): now}, finder.LastUsed(), "writes within the resolution are coalesced")
+	assert.Equal(t, 2, store.touches)
+
+	now = now.Add(time.Minute)
+	finder.Find("key-one", ipRequest(t, "10.0.0.1"))
+	assert.Equal(t, now, finder.LastUsed()[fingerprint("key-one")], "the time is updated after the resolution")
+	assert.Equal(t, 3, store.touches)
+}
+
+func TestLastUsedFinderIsConcurrencySafe(t *testing.T) {
+	finder := NewLastUsedFinder(FinderFunc(func(c interface{}, r *http.Request) (interface{}, error) {
+		return c, nil
+	}), nil, 0)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				finder.Find(key, ipRequest(t, "10.0.0.1"))
+				finder.LastUsed()
+			}
+		}(fmt.Sprintf("key-%d", i))
+	}
+	wg.Wait()
+
+	assert.Equal(t, 10, len(finder.LastUsed()))
+}
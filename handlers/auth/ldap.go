@@ -0,0 +1,115 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// DirectoryUser is a user found in a directory such as LDAP or Active Directory
+type DirectoryUser struct {
+	// DN is the distinguished name of the user
+	DN string
+	// Identity is the name the user was searched for with, such as the sAMAccountName or uid
+	Identity string
+	// Groups are the names of the groups the user is a member of
+	Groups []string
+}
+
+// DirectorySearcher finds users in a directory, so the LDAPFinder does not depend on an LDAP client
+type DirectorySearcher interface {
+	// Search returns the user with identity and its groups, or nil if there is no user. An error should only be
+	// returned if the directory could not be searched
+	Search(ctx context.Context, identity string) (*DirectoryUser, error)
+}
+
+type (
+	// DirectoryUnavailableError when the directory could not be searched
+	DirectoryUnavailableError struct {
+		err error
+	}
+	// NotInGroupError when the user for a valid key is not a member of any of the allowed groups
+	NotInGroupError struct {
+		identity string
+	}
+)
+
+func (e *DirectoryUnavailableError) Error() string {
+	return fmt.Sprintf("failed to search the directory: %v", e.err)
+}
+
+// Status returns 503 (Service Unavailable)
+func (e *DirectoryUnavailableError) Status() int {
+	return http.StatusServiceUnavailable
+}
+
+func (e *NotInGroupError) Error() string {
+	return fmt.Sprintf("the user: %s is not a member of an allowed group", e.identity)
+}
+
+// Status returns 403 (Forbidden)
+func (e *NotInGroupError) Status() int {
+	return http.StatusForbidden
+}
+
+// LDAPFinder is a Finder that maps a key to a directory identity, and returns the *DirectoryUser for the identity if it
+// is a member of one of the allowed groups
+type LDAPFinder struct {
+	searcher DirectorySearcher
+	identity func(key string) (string, bool)
+	groups   map[string]bool
+}
+
+// Find returns the *DirectoryUser for the key, a *NotInGroupError if the user is not in an allowed group, or a
+// *DirectoryUnavailableError if the directory could not be searched
+func (f *LDAPFinder) Find(c interface{}, r *http.Request) (interface{}, error) {
+	key, ok := c.(string)
+	if !ok {
+		return nil, fmt.Errorf("the credentials are not a key")
+	}
+	identity, ok := f.identity(key)
+	if !ok {
+		return nil, fmt.Errorf("no identity for the key")
+	}
+	user, err := f.searcher.Search(r.Context(), identity)
+	if err != nil {
+		return nil, &DirectoryUnavailableError{err}
+	}
+	if user == nil {
+		return nil, fmt.Errorf("no user found for the identity: %s", identity)
+	}
+	for _, group := range user.Groups {
+		if f.groups[strings.ToLower(group)] {
+			return user, nil
+		}
+	}
+	return nil, &NotInGroupError{identity}
+}
+
+// NewLDAPFinder returns an LDAPFinder that uses identity to map a key to a directory identity, and searcher to find the
+// user. The user must be a member of one of groups, which are compared ignoring case
+//
+// Usage:
+//  finder := auth.NewLDAPFinder(searcher, func(key string) (string, bool) {
+//      identity, ok := serviceAccounts[key]
+//      return identity, ok
+//  }, "api-users", "admins")
+//  keyAuth := auth.NewAPIKey("Graze", auth.NewCachingFinder(finder, time.Minute, 1000), failure.HandlerFunc(onError))
+func NewLDAPFinder(searcher DirectorySearcher, identity func(key string) (string, bool), groups ...string) *LDAPFinder {
+	allowed := make(map[string]bool, len(groups))
+	for _, group := range groups {
+		allowed[strings.ToLower(group)] = true
+	}
+	return &LDAPFinder{searcher: searcher, identity: identity, groups: allowed}
+}
@@ -0,0 +1,88 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/graze/golang-service/handlers/failure"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeDirectory is a DirectorySearcher with a fixed set of users
+type fakeDirectory struct {
+	users map[string]*DirectoryUser
+	err   error
+}
+
+func (d *fakeDirectory) Search(ctx context.Context, identity string) (*DirectoryUser, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	return d.users[identity], nil
+}
+
+func TestLDAPFinder(t *testing.T) {
+	alice := &DirectoryUser{DN: "cn=alice,ou=services,dc=example,dc=com", Identity: "alice", Groups: []string{"Staff", "API-Users"}}
+	bob := &DirectoryUser{DN: "cn=bob,ou=services,dc=example,dc=com", Identity: "bob", Groups: []string{"Staff"}}
+	directory := &fakeDirectory{users: map[string]*DirectoryUser{"alice": alice, "bob": bob}}
+	keys := map[string]string{"alice-key": "alice", "bob-key": "bob", "carol-key": "carol"}
+
+	finder := NewLDAPFinder(directory, func(key string) (string, bool) {
+		identity, ok := keys[key]
+		return identity, ok
+	}, "api-users", "admins")
+
+	cases := map[string]struct {
+		key    string
+		down   bool
+		err    error
+		user   interface{}
+		status int
+	}{
+		"in group":          {"alice-key", false, nil, alice, http.StatusOK},
+		"not in group":      {"bob-key", false, &NotInGroupError{}, nil, http.StatusForbidden},
+		"not in directory":  {"carol-key", false, &InvalidKeyError{}, nil, http.StatusUnauthorized},
+		"unknown key":       {"dave-key", false, &InvalidKeyError{}, nil, http.StatusUnauthorized},
+		"directory is down": {"alice-key", true, &DirectoryUnavailableError{}, nil, http.StatusServiceUnavailable},
+	}
+
+	for k, tc := range cases {
+		directory.err = nil
+		if tc.down {
+			directory.err = fmt.Errorf("connection refused")
+		}
+
+		var authErr error
+		var user interface{}
+		auth := NewAPIKey("Graze", finder, failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, status int) {
+			authErr = err
+			w.WriteHeader(status)
+		}))
+		rec := httptest.NewRecorder()
+		req := headerRequest(t, "GET", "/stuff", map[string]string{"Authorization": "Graze " + tc.key})
+		auth.ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+			user = GetUser(r)
+		}).ServeHTTP(rec, req)
+
+		assert.Equal(t, tc.status, rec.Code, "test: %s", k)
+		assert.Equal(t, tc.user, user, "test: %s", k)
+		if tc.err == nil {
+			assert.Nil(t, authErr, "test: %s", k)
+		} else {
+			assert.IsType(t, tc.err, authErr, "test: %s", k)
+		}
+	}
+}
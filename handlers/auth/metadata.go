@@ -0,0 +1,51 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import "net/http"
+
+// DefaultMetadataHeader is the header gRPC-Web gateways use for the `authorization` gRPC metadata
+const DefaultMetadataHeader = "Grpc-Metadata-Authorization"
+
+// FromMetadata returns a middleware that copies the credentials from the gRPC metadata header to the target header
+// (default: `Authorization`), so requests bridged from gRPC-Web can use the same APIKey or XAPIKey authentication
+//
+// header defaults to DefaultMetadataHeader if empty. Requests that already have the target header, or do not have the
+// metadata header, are passed through unchanged
+//
+// Usage:
+//  keyAuth := auth.NewAPIKey("Graze", auth.FinderFunc(finder), failure.HandlerFunc(onError))
+//  http.Handle("/", auth.FromMetadata("", "")(keyAuth.Then(router)))
+func FromMetadata(header, target string) func(h http.Handler) http.Handler {
+	if header == "" {
+		header = DefaultMetadataHeader
+	}
+	if target == "" {
+		target = "Authorization"
+	}
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			value := req.Header.Get(header)
+			if value == "" || req.Header.Get(target) != "" {
+				h.ServeHTTP(w, req)
+				return
+			}
+			out := new(http.Request)
+			*out = *req
+			out.Header = make(http.Header, len(req.Header)+1)
+			for k, v := range req.Header {
+				out.Header[k] = v
+			}
+			out.Header.Set(target, value)
+			h.ServeHTTP(w, out)
+		})
+	}
+}
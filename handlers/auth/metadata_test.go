@@ -0,0 +1,76 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/graze/golang-service/handlers/failure"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromMetadata(t *testing.T) {
+	finder := &mapFinder{users: map[string]interface{}{"key": "user"}}
+	onError := failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, status int) {
+		w.WriteHeader(status)
+	})
+
+	cases := map[string]struct {
+		header  string
+		target  string
+		handler http.Handler
+		headers map[string]string
+		status  int
+	}{
+		"grpc-web metadata": {
+			"", "",
+			NewAPIKey("Graze", finder, onError).Then(okHandler),
+			map[string]string{"Grpc-Metadata-Authorization": "Graze key"},
+			http.StatusOK,
+		},
+		"invalid metadata key": {
+			"", "",
+			NewAPIKey("Graze", finder, onError).Then(okHandler),
+			map[string]string{"Grpc-Metadata-Authorization": "Graze other"},
+			http.StatusUnauthorized,
+		},
+		"no metadata": {
+			"", "",
+			NewAPIKey("Graze", finder, onError).Then(okHandler),
+			map[string]string{},
+			http.StatusUnauthorized,
+		},
+		"authorization header is used first": {
+			"", "",
+			NewAPIKey("Graze", finder, onError).Then(okHandler),
+			map[string]string{"Authorization": "Graze key", "Grpc-Metadata-Authorization": "Graze other"},
+			http.StatusOK,
+		},
+		"custom headers": {
+			"Grpc-Metadata-X-Api-Key", "X-Api-Key",
+			NewXAPIKey(finder, onError).Then(okHandler),
+			map[string]string{"Grpc-Metadata-X-Api-Key": "key"},
+			http.StatusOK,
+		},
+	}
+
+	for k, tc := range cases {
+		rec := httptest.NewRecorder()
+		req := headerRequest(t, "POST", "/service.Orders/Get", tc.headers)
+		FromMetadata(tc.header, tc.target)(tc.handler).ServeHTTP(rec, req)
+
+		assert.Equal(t, tc.status, rec.Code, "test: %s", k)
+		_, ok := req.Header["Authorization"]
+		assert.Equal(t, tc.headers["Authorization"] != "", ok, "test: %s: the original request is not changed", k)
+	}
+}
@@ -0,0 +1,84 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// MethodRestricted is implemented by users returned from a Finder that can only be used with some HTTP methods
+type MethodRestricted interface {
+	// AllowedMethods returns the HTTP methods the key can be used with, an empty list allows all methods
+	AllowedMethods() []string
+}
+
+// MethodNotAllowedForKeyError is returned when a valid key is used with an HTTP method it is not allowed to use
+type MethodNotAllowedForKeyError struct {
+	method string
+}
+
+func (e *MethodNotAllowedForKeyError) Error() string {
+	return fmt.Sprintf("key is not allowed to use the method: %s", e.method)
+}
+
+// Status returns 403 (Forbidden)
+func (e *MethodNotAllowedForKeyError) Status() int {
+	return http.StatusForbidden
+}
+
+// MethodRestrictedFinder is a Finder that rejects keys used with an HTTP method that is not allowed by the user returned
+// from the wrapped Finder
+//
+// Users that do not implement MethodRestricted can use any method
+type MethodRestrictedFinder struct {
+	finder Finder
+}
+
+// Find returns a *MethodNotAllowedForKeyError if the user is not allowed to use the method of the request
+func (f *MethodRestrictedFinder) Find(credentials interface{}, r *http.Request) (interface{}, error) {
+	user, err := f.finder.Find(credentials, r)
+	if err != nil {
+		return user, err
+	}
+	restricted, ok := user.(MethodRestricted)
+	if !ok {
+		return user, nil
+	}
+	methods := restricted.AllowedMethods()
+	if len(methods) == 0 {
+		return user, nil
+	}
+	for _, method := range methods {
+		if strings.EqualFold(r.Method, method) {
+			return user, nil
+		}
+	}
+	return nil, &MethodNotAllowedForKeyError{r.Method}
+}
+
+// NewMethodRestrictedFinder returns a Finder that only allows users implementing MethodRestricted to use their allowed
+// HTTP methods, such as read only keys that can only use GET and HEAD
+//
+// Usage:
+//  func (u *User) AllowedMethods() []string {
+//      if u.ReadOnly {
+//          return []string{"GET", "HEAD"}
+//      }
+//      return nil
+//  }
+//
+//  finder := auth.NewMethodRestrictedFinder(auth.FinderFunc(finder))
+//  keyAuth := auth.NewAPIKey("Graze", finder, failure.HandlerFunc(onError))
+func NewMethodRestrictedFinder(finder Finder) *MethodRestrictedFinder {
+	return &MethodRestrictedFinder{finder}
+}
@@ -0,0 +1,69 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/graze/golang-service/handlers/failure"
+	"github.com/stretchr/testify/assert"
+)
+
+type methodUser struct {
+	methods []string
+}
+
+func (u *methodUser) AllowedMethods() []string {
+	return u.methods
+}
+
+func TestMethodRestrictedFinder(t *testing.T) {
+	finder := NewMethodRestrictedFinder(&mapFinder{users: map[string]interface{}{
+		"readonly": &methodUser{[]string{"GET", "head"}},
+		"admin":    &methodUser{},
+		"plain":    "user",
+	}})
+
+	cases := map[string]struct {
+		key    string
+		method string
+		err    error
+		status int
+	}{
+		"read only get":       {"readonly", "GET", nil, http.StatusOK},
+		"read only head":      {"readonly", "HEAD", nil, http.StatusOK},
+		"read only post":      {"readonly", "POST", &MethodNotAllowedForKeyError{}, http.StatusForbidden},
+		"read only delete":    {"readonly", "DELETE", &MethodNotAllowedForKeyError{}, http.StatusForbidden},
+		"no restrictions":     {"admin", "POST", nil, http.StatusOK},
+		"not restricted user": {"plain", "POST", nil, http.StatusOK},
+		"invalid key":         {"missing", "GET", &InvalidKeyError{}, http.StatusUnauthorized},
+	}
+
+	for k, tc := range cases {
+		var authErr error
+		auth := NewAPIKey("Graze", finder, failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, status int) {
+			authErr = err
+			w.WriteHeader(status)
+		}))
+		rec := httptest.NewRecorder()
+		req := headerRequest(t, tc.method, "/orders", map[string]string{"Authorization": "Graze " + tc.key})
+		auth.Then(okHandler).ServeHTTP(rec, req)
+
+		assert.Equal(t, tc.status, rec.Code, "test: %s", k)
+		if tc.err == nil {
+			assert.Nil(t, authErr, "test: %s", k)
+		} else {
+			assert.IsType(t, tc.err, authErr, "test: %s", k)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+)
+
+// MultiKeyFinder is a Finder where each user can have several keys (such as primary, secondary and CI keys)
+//
+// Keys are stored as a fingerprint, so they are matched using a map lookup rather than comparing against each key, and
+// the raw keys are not held. Users must be comparable (such as a pointer) to be looked up by user. Keys can be added and
+// removed at runtime
+type MultiKeyFinder struct {
+	mu    sync.RWMutex
+	users map[string]interface{}
+	keys  map[interface{}]map[string]bool
+}
+
+// Find returns the user for the key
+func (f *MultiKeyFinder) Find(credentials interface{}, r *http.Request) (interface{}, error) {
+	key, ok := credentials.(string)
+	if !ok {
+		return nil, errors.New("the supplied key is in an invalid format")
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	user, ok := f.users[fingerprint(key)]
+	if !ok {
+		return nil, errors.New("no user found for the key")
+	}
+	return user, nil
+}
+
+// AddKey adds key for user, replacing any existing user for the key
+func (f *MultiKeyFinder) AddKey(key string, user interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fp := fingerprint(key)
+	f.remove(fp)
+	f.users[fp] = user
+	if f.keys[user] == nil {
+		f.keys[user] = map[string]bool{}
+	}
+	f.keys[user][fp] = true
+}
+
+// RemoveKey revokes key, leaving any other keys for the same user
+func (f *MultiKeyFinder) RemoveKey(key string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.remove(fingerprint(key))
+}
+
+// RemoveUser revokes all the keys for user
+func (f *MultiKeyFinder) RemoveUser(user interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for fp := range f.keys[user] {
+		delete(f.users, fp)
+	}
+	delete(f.keys, user)
+}
+
+// KeyCount returns the number of keys user has
+func (f *MultiKeyFinder) KeyCount(user interface{}) int {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return len(f.keys[user])
+}
+
+// remove deletes the key with the fingerprint fp, the lock must be held
+func (f *MultiKeyFinder) remove(fp string) {
+	user, ok := f.users[fp]
+	if !ok {
+		return
+	}
+	delete(f.users, fp)
+	delete(f.keys[user], fp)
+	if len(f.keys[user]) == 0 {
+		delete(f.keys, user)
+	}
+}
+
+// NewMultiKeyFinder returns a MultiKeyFinder with the keys for each user
+//
+// Usage:
+//  finder := auth.NewMultiKeyFinder(map[interface{}][]string{
+//      user: {primaryKey, ciKey},
+//  })
+//  keyAuth := auth.NewAPIKey("Graze", finder, failure.HandlerFunc(onError))
+//
+//  finder.RemoveKey(ciKey)
+func NewMultiKeyFinder(keys map[interface{}][]string) *MultiKeyFinder {
+	f := &MultiKeyFinder{
+		users: make(map[string]interface{}),
+		keys:  make(map[interface{}]map[string]bool),
+	}
+	for user, userKeys := range keys {
+		for _, key := range userKeys {
+			f.AddKey(key, user)
+		}
+	}
+	return f
+}
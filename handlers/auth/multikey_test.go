@@ -0,0 +1,74 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type keyUser struct {
+	name string
+}
+
+func TestMultiKeyFinder(t *testing.T) {
+	alice := &keyUser{"alice"}
+	bob := &keyUser{"bob"}
+	finder := NewMultiKeyFinder(map[interface{}][]string{
+		alice: {"alice-primary", "alice-secondary", "alice-ci"},
+		bob:   {"bob-primary"},
+	})
+
+	for _, key := range []string{"alice-primary", "alice-secondary", "alice-ci"} {
+		user, err := finder.Find(key, ipRequest(t, "10.0.0.1"))
+		assert.Nil(t, err, "key: %s", key)
+		assert.Equal(t, alice, user, "key: %s", key)
+	}
+	assert.Equal(t, 3, finder.KeyCount(alice))
+
+	finder.RemoveKey("alice-ci")
+	_, err := finder.Find("alice-ci", ipRequest(t, "10.0.0.1"))
+	assert.NotNil(t, err, "the revoked key is not found")
+	user, err := finder.Find("alice-primary", ipRequest(t, "10.0.0.1"))
+	assert.Nil(t, err, "the other keys still work")
+	assert.Equal(t, alice, user)
+	assert.Equal(t, 2, finder.KeyCount(alice))
+
+	finder.AddKey("alice-ci-2", alice)
+	user, err = finder.Find("alice-ci-2", ipRequest(t, "10.0.0.1"))
+	assert.Nil(t, err)
+	assert.Equal(t, alice, user)
+
+	finder.RemoveUser(alice)
+	assert.Equal(t, 0, finder.KeyCount(alice))
+	_, err = finder.Find("alice-primary", ipRequest(t, "10.0.0.1"))
+	assert.NotNil(t, err)
+	user, err = finder.Find("bob-primary", ipRequest(t, "10.0.0.1"))
+	assert.Nil(t, err, "other users are not affected")
+	assert.Equal(t, bob, user)
+
+	_, err = finder.Find(1234, ipRequest(t, "10.0.0.1"))
+	assert.NotNil(t, err)
+}
+
+func TestMultiKeyFinderMovesKey(t *testing.T) {
+	alice := &keyUser{"alice"}
+	bob := &keyUser{"bob"}
+	finder := NewMultiKeyFinder(map[interface{}][]string{alice: {"shared"}})
+
+	finder.AddKey("shared", bob)
+	user, err := finder.Find("shared", ipRequest(t, "10.0.0.1"))
+	assert.Nil(t, err)
+	assert.Equal(t, bob, user)
+	assert.Equal(t, 0, finder.KeyCount(alice))
+	assert.Equal(t, 1, finder.KeyCount(bob))
+}
@@ -0,0 +1,48 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import (
+	"net/http"
+
+	"github.com/graze/golang-service/handlers/failure"
+)
+
+// defaultOnError is used by APIKey and XAPIKey when OnError is not set
+var defaultOnError failure.Handler = failure.HandlerFunc(plainTextError)
+
+// plainTextError writes the text for status as a plain text response
+//
+// The error is not written as it can contain the key supplied by the client
+func plainTextError(w http.ResponseWriter, r *http.Request, err error, status int) {
+	http.Error(w, http.StatusText(status), status)
+}
+
+// SetDefaultOnError changes the failure.Handler used when an APIKey or XAPIKey has no OnError set
+//
+// This should be called during setup, before any requests are handled. Passing nil restores the plain text default
+//
+// Usage:
+//  auth.SetDefaultOnError(failure.HandlerFunc(onError))
+func SetDefaultOnError(h failure.Handler) {
+	if h == nil {
+		h = failure.HandlerFunc(plainTextError)
+	}
+	defaultOnError = h
+}
+
+// orDefault returns h, or the package default if h is nil
+func orDefault(h failure.Handler) failure.Handler {
+	if h == nil {
+		return defaultOnError
+	}
+	return h
+}
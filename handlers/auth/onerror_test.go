@@ -0,0 +1,66 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/graze/golang-service/handlers/failure"
+	"github.com/stretchr/testify/assert"
+)
+
+var noUserFinder = FinderFunc(func(key interface{}, r *http.Request) (interface{}, error) {
+	return nil, errors.New("no user found")
+})
+
+func TestNilOnErrorUsesTheDefault(t *testing.T) {
+	cases := map[string]struct {
+		handler http.Handler
+		request *http.Request
+	}{
+		"api key": {
+			(&APIKey{Provider: "Graze", Finder: noUserFinder}).Then(okHandler),
+			headerRequest(t, "GET", "/path", map[string]string{"Authorization": "Graze secret-key"}),
+		},
+		"x-api-key": {
+			(&XAPIKey{Finder: noUserFinder}).Then(okHandler),
+			headerRequest(t, "GET", "/path", map[string]string{"X-Api-Key": "secret-key"}),
+		},
+	}
+
+	for k, tc := range cases {
+		rec := httptest.NewRecorder()
+		tc.handler.ServeHTTP(rec, tc.request)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code, "test: %s", k)
+		assert.Equal(t, "Unauthorized\n", rec.Body.String(), "test: %s", k)
+		assert.Equal(t, "text/plain; charset=utf-8", rec.Header().Get("Content-Type"), "test: %s", k)
+	}
+}
+
+func TestSetDefaultOnError(t *testing.T) {
+	defer SetDefaultOnError(nil)
+
+	var status int
+	SetDefaultOnError(failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, s int) {
+		status = s
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	rec := httptest.NewRecorder()
+	(&APIKey{Provider: "Graze", Finder: noUserFinder}).Then(okHandler).ServeHTTP(rec, headerRequest(t, "GET", "/path", map[string]string{}))
+
+	assert.Equal(t, http.StatusUnauthorized, status)
+	assert.Equal(t, http.StatusTeapot, rec.Code)
+}
@@ -0,0 +1,102 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// KeyConsumedError is returned when a one-time key has already been used
+type KeyConsumedError struct {
+	fingerprint string
+}
+
+func (e *KeyConsumedError) Error() string {
+	return fmt.Sprintf("the key with fingerprint: %s has already been used", e.fingerprint)
+}
+
+// Status returns 403 (Forbidden)
+func (e *KeyConsumedError) Status() int {
+	return http.StatusForbidden
+}
+
+// ConsumedKeyStore records the one-time keys that have been used
+type ConsumedKeyStore interface {
+	// Consume records key as used, and returns false if it has already been used. It must be atomic, so only one of
+	// several concurrent calls for the same key returns true
+	Consume(key string) bool
+}
+
+// MemoryConsumedKeyStore is a ConsumedKeyStore that keeps the keys in memory, so it is only suitable for a single
+// instance of a service
+type MemoryConsumedKeyStore struct {
+	mu       sync.Mutex
+	consumed map[string]bool
+}
+
+// Consume records key as used, and returns false if it has already been used
+func (s *MemoryConsumedKeyStore) Consume(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.consumed[key] {
+		return false
+	}
+	s.consumed[key] = true
+	return true
+}
+
+// NewMemoryConsumedKeyStore returns an empty MemoryConsumedKeyStore
+func NewMemoryConsumedKeyStore() *MemoryConsumedKeyStore {
+	return &MemoryConsumedKeyStore{consumed: make(map[string]bool)}
+}
+
+// OneTimeFinder is a Finder for single use keys, such as the keys in magic links
+//
+// The first time a key is found it is consumed in the store, every later use fails with a *KeyConsumedError and a
+// status of 403. Only the fingerprint of each key is written to the store. Keys that are not found are not consumed
+type OneTimeFinder struct {
+	finder Finder
+	store  ConsumedKeyStore
+}
+
+// Find calls the wrapped Finder and consumes the key if it is valid
+func (f *OneTimeFinder) Find(c interface{}, r *http.Request) (interface{}, error) {
+	key, ok := c.(string)
+	if !ok {
+		return nil, errors.New("the supplied key is in an invalid format")
+	}
+	user, err := f.finder.Find(key, r)
+	if err != nil {
+		return user, err
+	}
+	id := fingerprint(key)
+	if !f.store.Consume(id) {
+		return nil, &KeyConsumedError{id}
+	}
+	return user, nil
+}
+
+// NewOneTimeFinder wraps finder so each key can only be used once, recording the used keys in store
+//
+// If store is nil, a MemoryConsumedKeyStore is used
+//
+// Usage:
+//  finder := auth.NewOneTimeFinder(auth.FinderFunc(finder), nil)
+//  keyAuth := auth.NewAPIKey("Graze", finder, failure.HandlerFunc(onError))
+func NewOneTimeFinder(finder Finder, store ConsumedKeyStore) *OneTimeFinder {
+	if store == nil {
+		store = NewMemoryConsumedKeyStore()
+	}
+	return &OneTimeFinder{finder, store}
+}
@@ -0,0 +1,94 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/graze/golang-service/handlers/failure"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOneTimeFinder(t *testing.T) {
+	store := NewMemoryConsumedKeyStore()
+	finder := NewOneTimeFinder(&mapFinder{users: map[string]interface{}{"link": "alice"}}, store)
+
+	cases := []struct {
+		name   string
+		key    string
+		status int
+	}{
+		{"unknown key", "other", http.StatusUnauthorized},
+		{"first use", "link", http.StatusOK},
+		{"second use", "link", http.StatusForbidden},
+		{"third use", "link", http.StatusForbidden},
+	}
+
+	for _, tc := range cases {
+		var authErr error
+		keyAuth := NewAPIKey("Graze", finder, failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, status int) {
+			authErr = err
+			w.WriteHeader(status)
+		}))
+		rec := httptest.NewRecorder()
+		req := headerRequest(t, "GET", "/login", map[string]string{"Authorization": "Graze " + tc.key})
+		keyAuth.ThenFunc(okHandler).ServeHTTP(rec, req)
+
+		assert.Equal(t, tc.status, rec.Code, "test: %s", tc.name)
+		if tc.status == http.StatusForbidden {
+			assert.IsType(t, &KeyConsumedError{}, authErr, "test: %s", tc.name)
+		}
+	}
+
+	assert.False(t, store.Consume(fingerprint("link")), "the fingerprint of the key is stored")
+	assert.True(t, store.Consume(fingerprint("other")), "keys that are not found are not consumed")
+
+	_, err := finder.Find(1234, ipRequest(t, "10.0.0.1"))
+	assert.NotNil(t, err)
+}
+
+func TestOneTimeFinderConcurrent(t *testing.T) {
+	finder := NewOneTimeFinder(FinderFunc(func(c interface{}, r *http.Request) (interface{}, error) {
+		if c.(string) == "link" {
+			return "alice", nil
+		}
+		return nil, errors.New("no user found")
+	}), nil)
+
+	var mu sync.Mutex
+	found, consumed := 0, 0
+	wg := &sync.WaitGroup{}
+	start := make(chan struct{})
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			_, err := finder.Find("link", ipRequest(t, "10.0.0.1"))
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				found++
+			} else if _, ok := err.(*KeyConsumedError); ok {
+				consumed++
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	assert.Equal(t, 1, found, "only one request can use the key")
+	assert.Equal(t, 49, consumed)
+}
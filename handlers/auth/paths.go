@@ -0,0 +1,105 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// PathRestricted is implemented by users returned from a Finder that can only be used on some paths
+type PathRestricted interface {
+	// AllowedPaths returns the path prefixes the key can be used on, an empty list allows all paths
+	AllowedPaths() []string
+}
+
+// PathNotAllowedError is returned when a valid key is used on a path it is not allowed to access
+type PathNotAllowedError struct {
+	path string
+}
+
+func (e *PathNotAllowedError) Error() string {
+	return fmt.Sprintf("key is not allowed to access the path: %s", e.path)
+}
+
+// Status returns 403 (Forbidden)
+func (e *PathNotAllowedError) Status() int {
+	return http.StatusForbidden
+}
+
+// pathAllowed returns true if path is prefix, or is below prefix
+func pathAllowed(path, prefix string) bool {
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	return len(path) == len(prefix) || strings.HasSuffix(prefix, "/") || path[len(prefix)] == '/'
+}
+
+// cleanPath returns p without any `.` or `..` segments, so a path can not escape a prefix it starts with. A trailing
+// slash is kept
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	cleaned := path.Clean("/" + p)
+	if strings.HasSuffix(p, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+	return cleaned
+}
+
+// PathRestrictedFinder is a Finder that rejects keys used outside of the paths allowed by the user returned from the
+// wrapped Finder
+//
+// Users that do not implement PathRestricted can be used on any path
+type PathRestrictedFinder struct {
+	finder Finder
+}
+
+// Find returns a *PathNotAllowedError if the user is not allowed to access the path of the request
+func (f *PathRestrictedFinder) Find(credentials interface{}, r *http.Request) (interface{}, error) {
+	user, err := f.finder.Find(credentials, r)
+	if err != nil {
+		return user, err
+	}
+	restricted, ok := user.(PathRestricted)
+	if !ok {
+		return user, nil
+	}
+	prefixes := restricted.AllowedPaths()
+	if len(prefixes) == 0 {
+		return user, nil
+	}
+	requested := cleanPath(r.URL.Path)
+	for _, prefix := range prefixes {
+		if pathAllowed(requested, prefix) {
+			return user, nil
+		}
+	}
+	return nil, &PathNotAllowedError{requested}
+}
+
+// NewPathRestrictedFinder returns a Finder that only allows users implementing PathRestricted to access their allowed
+// paths. A prefix matches the path itself and anything below it, so `/orders` allows `/orders/1` but not `/ordersx`.
+// The path is cleaned first, so `/orders/../admin` is checked as `/admin`
+//
+// Usage:
+//  func (u *User) AllowedPaths() []string {
+//      return u.Paths
+//  }
+//
+//  finder := auth.NewPathRestrictedFinder(auth.FinderFunc(finder))
+//  keyAuth := auth.NewAPIKey("Graze", finder, failure.HandlerFunc(onError))
+func NewPathRestrictedFinder(finder Finder) *PathRestrictedFinder {
+	return &PathRestrictedFinder{finder}
+}
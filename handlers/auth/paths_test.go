@@ -0,0 +1,75 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/graze/golang-service/handlers/failure"
+	"github.com/stretchr/testify/assert"
+)
+
+type scopedUser struct {
+	paths []string
+}
+
+func (u *scopedUser) AllowedPaths() []string {
+	return u.paths
+}
+
+func TestPathRestrictedFinder(t *testing.T) {
+	finder := NewPathRestrictedFinder(&mapFinder{users: map[string]interface{}{
+		"orders":   &scopedUser{[]string{"/orders", "/reports/"}},
+		"anywhere": &scopedUser{},
+		"plain":    "user",
+	}})
+
+	cases := map[string]struct {
+		key    string
+		path   string
+		err    error
+		status int
+	}{
+		"allowed prefix":       {"orders", "/orders", nil, http.StatusOK},
+		"below allowed prefix": {"orders", "/orders/1", nil, http.StatusOK},
+		"below trailing slash": {"orders", "/reports/daily", nil, http.StatusOK},
+		"disallowed path":      {"orders", "/users", &PathNotAllowedError{}, http.StatusForbidden},
+		"partial segment":      {"orders", "/ordersx", &PathNotAllowedError{}, http.StatusForbidden},
+		"no restrictions":      {"anywhere", "/users", nil, http.StatusOK},
+		"not restricted user":  {"plain", "/users", nil, http.StatusOK},
+		"invalid key":          {"missing", "/orders", &InvalidKeyError{}, http.StatusUnauthorized},
+		"traversal":            {"orders", "/orders/../admin", &PathNotAllowedError{}, http.StatusForbidden},
+		"encoded traversal":    {"orders", "/orders/%2e%2e/admin", &PathNotAllowedError{}, http.StatusForbidden},
+		"double traversal":     {"orders", "/reports/daily/../../admin", &PathNotAllowedError{}, http.StatusForbidden},
+		"dot segments":         {"orders", "/users/../orders/./1", nil, http.StatusOK},
+		"trailing slash":       {"orders", "/reports/", nil, http.StatusOK},
+	}
+
+	for k, tc := range cases {
+		var authErr error
+		auth := NewAPIKey("Graze", finder, failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, status int) {
+			authErr = err
+			w.WriteHeader(status)
+		}))
+		rec := httptest.NewRecorder()
+		req := headerRequest(t, "GET", tc.path, map[string]string{"Authorization": "Graze " + tc.key})
+		auth.Then(okHandler).ServeHTTP(rec, req)
+
+		assert.Equal(t, tc.status, rec.Code, "test: %s", k)
+		if tc.err == nil {
+			assert.Nil(t, authErr, "test: %s", k)
+		} else {
+			assert.IsType(t, tc.err, authErr, "test: %s", k)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RateLimited is implemented by users returned from a Finder that have their own rate limit
+type RateLimited interface {
+	// RateLimit returns the number of requests per second allowed, and the number that can be made at once
+	RateLimit() (rate float64, burst int)
+}
+
+// RateLimitExceededError is returned when a key has made more requests than its rate limit allows
+type RateLimitExceededError struct {
+	retryAfter time.Duration
+}
+
+func (e *RateLimitExceededError) Error() string {
+	return fmt.Sprintf("rate limit exceeded, retry after: %s", e.retryAfter)
+}
+
+// Status returns 429 (Too Many Requests)
+func (e *RateLimitExceededError) Status() int {
+	return http.StatusTooManyRequests
+}
+
+// RetryAfter returns how long until the key can make another request
+func (e *RateLimitExceededError) RetryAfter() time.Duration {
+	return e.retryAfter
+}
+
+// bucket is a token bucket for a single key
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// KeyRateLimiter is a Finder that limits the rate of requests for each key, using the rate limit of the user returned
+// by the wrapped Finder
+//
+// Users that do not implement RateLimited are not limited. Keys are stored as a fingerprint rather than the key itself,
+// and are removed once they have not been used for the expiry
+type KeyRateLimiter struct {
+	finder Finder
+	expiry time.Duration
+	now    func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// Find returns a *RateLimitExceededError if the key has used all of its user's rate limit
+func (l *KeyRateLimiter) Find(c interface{}, r *http.Request) (interface{}, error) {
+	user, err := l.finder.Find(c, r)
+	if err != nil {
+		return user, err
+	}
+	limited, ok := user.(RateLimited)
+	key, isString := c.(string)
+	if !ok || !isString {
+		return user, nil
+	}
+
+	rate, burst := limited.RateLimit()
+	wait, remaining := l.take(fingerprint(key), rate, burst)
+	saveRateLimit(r, "key", remaining, wait > 0)
+	if wait > 0 {
+		return nil, &RateLimitExceededError{wait}
+	}
+	return user, nil
+}
+
+// take removes a token from the key's bucket, returning how long to wait if the bucket is empty and the number of
+// whole tokens left
+func (l *KeyRateLimiter) take(key string, rate float64, burst int) (time.Duration, int) {
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(burst), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * rate
+	if b.tokens > float64(burst) {
+		b.tokens = float64(burst)
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		if rate <= 0 {
+			return l.expiry, 0
+		}
+		return time.Duration((1 - b.tokens) / rate * float64(time.Second)), 0
+	}
+	b.tokens--
+	return 0, int(b.tokens)
+}
+
+// sweep removes buckets that have not been used within the expiry, the lock must be held
+func (l *KeyRateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) <= l.expiry {
+		return
+	}
+	for k, b := range l.buckets {
+		if now.Sub(b.last) > l.expiry {
+			delete(l.buckets, k)
+		}
+	}
+	l.lastSweep = now
+}
+
+// fingerprint returns a hash of key so the keys themselves are not held in memory
+func fingerprint(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewKeyRateLimiter wraps finder to limit each key to the rate limit of its user, forgetting keys that have not been
+// used for expiry
+//
+// Usage:
+//  func (u *User) RateLimit() (float64, int) {
+//      return u.RequestsPerSecond, u.Burst
+//  }
+//
+//  finder := auth.NewKeyRateLimiter(auth.FinderFunc(finder), time.Hour)
+//  keyAuth := auth.NewAPIKey("Graze", finder, failure.HandlerFunc(onError))
+func NewKeyRateLimiter(finder Finder, expiry time.Duration) *KeyRateLimiter {
+	return &KeyRateLimiter{
+		finder:  finder,
+		expiry:  expiry,
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+}
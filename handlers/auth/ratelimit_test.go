@@ -0,0 +1,119 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type quotaUser struct {
+	rate  float64
+	burst int
+}
+
+func (u *quotaUser) RateLimit() (float64, int) {
+	return u.rate, u.burst
+}
+
+// allowed returns the number of successful calls to limiter out of n for key
+func allowed(t *testing.T, limiter *KeyRateLimiter, key string, n int) int {
+	count := 0
+	for i := 0; i < n; i++ {
+		_, err := limiter.Find(key, ipRequest(t, "10.0.0.1"))
+		if err == nil {
+			count++
+		} else {
+			assert.IsType(t, &RateLimitExceededError{}, err)
+			assert.Equal(t, http.StatusTooManyRequests, err.(StatusError).Status())
+		}
+	}
+	return count
+}
+
+func TestKeyRateLimiterUsesEachKeysQuota(t *testing.T) {
+	inner := &mapFinder{users: map[string]interface{}{
+		"small": &quotaUser{1, 2},
+		"large": &quotaUser{10, 5},
+		"none":  "unlimited user",
+	}}
+	limiter := NewKeyRateLimiter(inner, time.Hour)
+	now := time.Now()
+	limiter.now = func() time.Time { return now }
+
+	assert.Equal(t, 2, allowed(t, limiter, "small", 10))
+	assert.Equal(t, 5, allowed(t, limiter, "large", 10))
+	assert.Equal(t, 10, allowed(t, limiter, "none", 10))
+
+	_, err := limiter.Find("small", ipRequest(t, "10.0.0.1"))
+	assert.Equal(t, time.Second, err.(*RateLimitExceededError).RetryAfter())
+
+	now = now.Add(time.Second)
+	assert.Equal(t, 1, allowed(t, limiter, "small", 10), "1 token is added per second")
+	assert.Equal(t, 5, allowed(t, limiter, "large", 10), "the bucket is refilled up to the burst")
+
+	_, err = limiter.Find("bad", ipRequest(t, "10.0.0.1"))
+	assert.IsType(t, errors.New(""), err, "errors from the finder are returned")
+}
+
+func TestKeyRateLimiterExpiresKeys(t *testing.T) {
+	inner := &mapFinder{users: map[string]interface{}{"key": &quotaUser{1, 1}}}
+	limiter := NewKeyRateLimiter(inner, time.Minute)
+	now := time.Now()
+	limiter.now = func() time.Time { return now }
+
+	allowed(t, limiter, "key", 1)
+	assert.Equal(t, 1, len(limiter.buckets))
+	assert.NotContains(t, limiter.buckets, "key", "the key is stored as a fingerprint")
+
+	now = now.Add(2 * time.Minute)
+	limiter.take("other", 1, 1)
+	assert.Equal(t, 1, len(limiter.buckets))
+	assert.Contains(t, limiter.buckets, "other")
+}
+
+func TestKeyRateLimiterRecordsState(t *testing.T) {
+	inner := &mapFinder{users: map[string]interface{}{
+		"small": &quotaUser{1, 2},
+		"none":  "unlimited user",
+	}}
+	limiter := NewKeyRateLimiter(inner, time.Hour)
+	now := time.Now()
+	limiter.now = func() time.Time { return now }
+
+	cases := []struct {
+		key       string
+		remaining int
+		limited   bool
+		ok        bool
+	}{
+		{"small", 1, false, true},
+		{"small", 0, false, true},
+		{"small", 0, true, true},
+		{"none", 0, false, false},
+	}
+
+	for i, tc := range cases {
+		req := Track(ipRequest(t, "10.0.0.1"))
+		limiter.Find(tc.key, req)
+		remaining, limited, ok := GetRateLimit(req)
+		assert.Equal(t, tc.remaining, remaining, "request: %d", i)
+		assert.Equal(t, tc.limited, limited, "request: %d", i)
+		assert.Equal(t, tc.ok, ok, "request: %d", i)
+	}
+
+	_, _, ok := GetRateLimit(ipRequest(t, "10.0.0.1"))
+	assert.False(t, ok, "untracked requests have no rate limit state")
+}
@@ -0,0 +1,224 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/graze/golang-service/handlers/failure"
+)
+
+const (
+	// DefaultTimestampHeader is the header containing the time a signed request was made, in unix seconds
+	DefaultTimestampHeader = "X-Timestamp"
+	// DefaultNonceHeader is the header containing a unique value for each signed request
+	DefaultNonceHeader = "X-Nonce"
+	// DefaultMaxSkew is how far the timestamp of a request can be from the current time
+	DefaultMaxSkew = 5 * time.Minute
+	// DefaultMaxNonces is the most nonces a MemoryNonceStore keeps when it has no MaxNonces
+	DefaultMaxNonces = 100000
+)
+
+type (
+	// StaleRequestError when the timestamp of a request is missing, invalid or outside the allowed skew
+	StaleRequestError struct {
+		timestamp string
+		maxSkew   time.Duration
+	}
+	// ReplayError when the nonce of a request is missing or has already been used
+	ReplayError struct {
+		nonce string
+	}
+)
+
+func (e *StaleRequestError) Error() string {
+	if e.timestamp == "" {
+		return "no request timestamp provided"
+	}
+	return fmt.Sprintf("request timestamp: %s is invalid or not within %s of the current time", e.timestamp, e.maxSkew)
+}
+
+// Status returns 401 (Unauthorized)
+func (e *StaleRequestError) Status() int {
+	return http.StatusUnauthorized
+}
+
+func (e *ReplayError) Error() string {
+	if e.nonce == "" {
+		return "no request nonce provided"
+	}
+	return fmt.Sprintf("request nonce: %s has already been used", e.nonce)
+}
+
+// Status returns 401 (Unauthorized)
+func (e *ReplayError) Status() int {
+	return http.StatusUnauthorized
+}
+
+// NonceStore records the nonces that have been used
+type NonceStore interface {
+	// Use records nonce as used until expires, and returns false if it has already been used
+	Use(nonce string, expires time.Time) bool
+}
+
+// MemoryNonceStore is a NonceStore that keeps the nonces in memory, so it is only suitable for a single instance of a
+// service
+//
+// At most MaxNonces unexpired nonces are kept, when it is full new nonces are rejected until the old ones expire, as
+// forgetting a nonce early would allow it to be replayed
+type MemoryNonceStore struct {
+	// MaxNonces is the most nonces that are kept (default: DefaultMaxNonces)
+	MaxNonces int
+
+	now func() time.Time
+
+	mu        sync.Mutex
+	nonces    map[string]time.Time
+	lastSweep time.Time
+}
+
+// Use records nonce as used until expires, and returns false if it has already been used or the store is full
+func (s *MemoryNonceStore) Use(nonce string, expires time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	maxNonces := s.MaxNonces
+	if maxNonces <= 0 {
+		maxNonces = DefaultMaxNonces
+	}
+	if now.Sub(s.lastSweep) > time.Minute || len(s.nonces) >= maxNonces {
+		s.sweep(now)
+	}
+	if e, ok := s.nonces[nonce]; ok && !now.After(e) {
+		return false
+	}
+	if len(s.nonces) >= maxNonces {
+		return false
+	}
+	s.nonces[nonce] = expires
+	return true
+}
+
+// sweep removes the expired nonces, the lock must be held
+func (s *MemoryNonceStore) sweep(now time.Time) {
+	for k, e := range s.nonces {
+		if now.After(e) {
+			delete(s.nonces, k)
+		}
+	}
+	s.lastSweep = now
+}
+
+// NewMemoryNonceStore returns an empty MemoryNonceStore
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{
+		now:    time.Now,
+		nonces: make(map[string]time.Time),
+	}
+}
+
+// ReplayProtection rejects signed requests with a timestamp that is outside the allowed skew, and optionally requests
+// with a nonce that has already been used
+//
+// The timestamp and nonce headers must be included in the request signature, otherwise they can be changed by an
+// attacker replaying the request. It should be used after the request has been authenticated, so unauthenticated
+// requests can not use up the nonces of legitimate clients or fill the NonceStore
+type ReplayProtection struct {
+	// TimestampHeader is the header containing the time the request was made in unix seconds (default:
+	// DefaultTimestampHeader)
+	TimestampHeader string
+	// MaxSkew is how far the timestamp can be from the current time (default: DefaultMaxSkew)
+	MaxSkew time.Duration
+	// NonceHeader is the header containing a unique value for each request (default: DefaultNonceHeader)
+	NonceHeader string
+	// Nonces records the nonces that have been used, if nil the nonce is not checked
+	Nonces NonceStore
+	// OnError gets called if the request is stale or replayed, if nil the default set by SetDefaultOnError is used
+	OnError failure.Handler
+
+	now func() time.Time
+}
+
+// ThenFunc surrounds an existing handler func and returns a new http.Handler
+//
+// Usage:
+//  replay := auth.NewReplayProtection(auth.NewMemoryNonceStore(), onError)
+//
+//  http.Handle("/thing", replay.ThenFunc(ThingFunc))
+func (p *ReplayProtection) ThenFunc(fn func(http.ResponseWriter, *http.Request)) http.Handler {
+	return p.Handler(http.HandlerFunc(fn))
+}
+
+// Then surrounds an existing http.Handler and returns a new http.Handler
+//
+// Usage:
+//  replay := auth.NewReplayProtection(auth.NewMemoryNonceStore(), onError)
+//
+//  http.Handle("/thing", signatureAuth.Then(replay.Then(ThingHandler)))
+func (p *ReplayProtection) Then(h http.Handler) http.Handler {
+	return p.Handler(h)
+}
+
+// Handler wraps the Then method to become clearer
+func (p *ReplayProtection) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		onError := orDefault(p.OnError)
+		maxSkew := p.MaxSkew
+		if maxSkew <= 0 {
+			maxSkew = DefaultMaxSkew
+		}
+		now := time.Now
+		if p.now != nil {
+			now = p.now
+		}
+
+		timestamp := req.Header.Get(orDefaultHeader(p.TimestampHeader, DefaultTimestampHeader))
+		seconds, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			onError.Handle(w, req, &StaleRequestError{timestamp, maxSkew}, http.StatusUnauthorized)
+			return
+		}
+		sent := time.Unix(seconds, 0)
+		if skew := now().Sub(sent); skew > maxSkew || skew < -maxSkew {
+			onError.Handle(w, req, &StaleRequestError{timestamp, maxSkew}, http.StatusUnauthorized)
+			return
+		}
+
+		if p.Nonces != nil {
+			nonce := req.Header.Get(orDefaultHeader(p.NonceHeader, DefaultNonceHeader))
+			// a nonce only needs to be kept while its timestamp would be accepted
+			if nonce == "" || !p.Nonces.Use(nonce, sent.Add(maxSkew)) {
+				onError.Handle(w, req, &ReplayError{nonce}, http.StatusUnauthorized)
+				return
+			}
+		}
+
+		h.ServeHTTP(w, req)
+	})
+}
+
+// orDefaultHeader returns header, or def if header is empty
+func orDefaultHeader(header, def string) string {
+	if header == "" {
+		return def
+	}
+	return header
+}
+
+// NewReplayProtection returns a ReplayProtection using the default headers and skew, recording nonces in nonces. If
+// nonces is nil only the timestamp is checked
+func NewReplayProtection(nonces NonceStore, onError failure.Handler) *ReplayProtection {
+	return &ReplayProtection{Nonces: nonces, OnError: onError}
+}
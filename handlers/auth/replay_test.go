@@ -0,0 +1,154 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/graze/golang-service/handlers/failure"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplayProtection(t *testing.T) {
+	now := time.Date(2016, 10, 28, 10, 51, 31, 0, time.UTC)
+	unix := func(d time.Duration) string {
+		return strconv.FormatInt(now.Add(d).Unix(), 10)
+	}
+
+	cases := map[string]struct {
+		nonces    bool
+		timestamp string
+		nonce     string
+		err       error
+		status    int
+	}{
+		"fresh":              {true, unix(0), "fresh", nil, http.StatusOK},
+		"within skew":        {true, unix(-4 * time.Minute), "within", nil, http.StatusOK},
+		"within future skew": {true, unix(4 * time.Minute), "future", nil, http.StatusOK},
+		"stale":              {true, unix(-6 * time.Minute), "stale", &StaleRequestError{}, http.StatusUnauthorized},
+		"too far in future":  {true, unix(6 * time.Minute), "far", &StaleRequestError{}, http.StatusUnauthorized},
+		"no timestamp":       {true, "", "none", &StaleRequestError{}, http.StatusUnauthorized},
+		"invalid timestamp":  {true, now.Format(time.RFC3339), "invalid", &StaleRequestError{}, http.StatusUnauthorized},
+		"no nonce":           {true, unix(0), "", &ReplayError{}, http.StatusUnauthorized},
+		"nonce not checked":  {false, unix(0), "", nil, http.StatusOK},
+	}
+
+	for k, tc := range cases {
+		var authErr error
+		store := NewMemoryNonceStore()
+		store.now = func() time.Time { return now }
+		var nonces NonceStore
+		if tc.nonces {
+			nonces = store
+		}
+		replay := NewReplayProtection(nonces, failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, status int) {
+			authErr = err
+			w.WriteHeader(status)
+		}))
+		replay.now = func() time.Time { return now }
+
+		rec := httptest.NewRecorder()
+		req := headerRequest(t, "GET", "/stuff", map[string]string{"X-Timestamp": tc.timestamp, "X-Nonce": tc.nonce})
+		replay.Then(okHandler).ServeHTTP(rec, req)
+
+		assert.Equal(t, tc.status, rec.Code, "test: %s", k)
+		if tc.err == nil {
+			assert.Nil(t, authErr, "test: %s", k)
+		} else {
+			assert.IsType(t, tc.err, authErr, "test: %s", k)
+		}
+	}
+}
+
+func TestReplayProtectionRejectsReplays(t *testing.T) {
+	now := time.Date(2016, 10, 28, 10, 51, 31, 0, time.UTC)
+	store := NewMemoryNonceStore()
+	store.now = func() time.Time { return now }
+
+	var authErr error
+	replay := NewReplayProtection(store, failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, status int) {
+		authErr = err
+		w.WriteHeader(status)
+	}))
+	replay.MaxSkew = time.Minute
+	replay.NonceHeader = "X-Request-Nonce"
+	replay.now = func() time.Time { return now }
+	handler := replay.Then(okHandler)
+
+	request := func(nonce string) int {
+		authErr = nil
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, headerRequest(t, "POST", "/orders", map[string]string{
+			"X-Timestamp":     strconv.FormatInt(now.Unix(), 10),
+			"X-Request-Nonce": nonce,
+		}))
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, request("abc"))
+	assert.Equal(t, http.StatusUnauthorized, request("abc"), "the same nonce is rejected")
+	assert.IsType(t, &ReplayError{}, authErr)
+	assert.Equal(t, http.StatusOK, request("def"), "other nonces are allowed")
+
+	// once the timestamp would be stale the nonce is forgotten
+	now = now.Add(2 * time.Minute)
+	assert.True(t, store.Use("other", now.Add(time.Minute)))
+	assert.Empty(t, store.nonces["abc"])
+	assert.Equal(t, 1, len(store.nonces))
+}
+
+func TestReplayProtectionAfterAuthentication(t *testing.T) {
+	now := time.Date(2016, 10, 28, 10, 51, 31, 0, time.UTC)
+	store := NewMemoryNonceStore()
+	store.now = func() time.Time { return now }
+	onError := failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, status int) {
+		w.WriteHeader(status)
+	})
+	replay := NewReplayProtection(store, onError)
+	replay.now = func() time.Time { return now }
+	keyAuth := NewAPIKey("Graze", &mapFinder{users: map[string]interface{}{"good": "user"}}, onError)
+	handler := keyAuth.Then(replay.Then(okHandler))
+
+	request := func(key string) int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, headerRequest(t, "POST", "/orders", map[string]string{
+			"Authorization": "Graze " + key,
+			"X-Timestamp":   strconv.FormatInt(now.Unix(), 10),
+			"X-Nonce":       "abc",
+		}))
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, request("bad"))
+	assert.Empty(t, store.nonces, "unauthenticated requests do not use the nonce")
+	assert.Equal(t, http.StatusOK, request("good"), "the nonce can still be used by the client")
+	assert.Equal(t, http.StatusUnauthorized, request("good"))
+}
+
+func TestMemoryNonceStoreMaxNonces(t *testing.T) {
+	now := time.Date(2016, 10, 28, 10, 51, 31, 0, time.UTC)
+	store := NewMemoryNonceStore()
+	store.MaxNonces = 2
+	store.now = func() time.Time { return now }
+
+	assert.True(t, store.Use("a", now.Add(time.Minute)))
+	assert.True(t, store.Use("b", now.Add(2*time.Minute)))
+	assert.False(t, store.Use("c", now.Add(time.Minute)), "new nonces are rejected when the store is full")
+	assert.Equal(t, 2, len(store.nonces))
+
+	now = now.Add(time.Minute + time.Second)
+	assert.True(t, store.Use("c", now.Add(time.Minute)), "expired nonces make room")
+	assert.False(t, store.Use("b", now.Add(time.Minute)), "unexpired nonces are kept")
+}
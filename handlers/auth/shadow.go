@@ -0,0 +1,133 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/graze/golang-service/log"
+)
+
+// shadowConcurrency is the number of shadow lookups that can be running at once, more are skipped
+const shadowConcurrency = 100
+
+// DefaultShadowTimeout is how long a shadow lookup can take when the ShadowFinder has no Timeout
+const DefaultShadowTimeout = 5 * time.Second
+
+// ShadowFinder is a Finder that returns the result of a primary Finder, and also looks the key up in a shadow Finder
+// (such as a new key store) in the background, logging when the shadow disagrees
+//
+// A disagreement is when only one of the Finders finds a user, or they find users that are not Equal. It is logged at
+// warning level with the tag `auth_shadow_mismatch` and the fingerprint of the key. The shadow lookup does not add any
+// latency, and is skipped if too many are already running. It is given its own context, cancelled after Timeout, as the
+// request's context is cancelled when the response is written
+type ShadowFinder struct {
+	// Equal returns true if the users found by the primary and shadow Finders are the same, the default uses
+	// reflect.DeepEqual
+	Equal func(primary, shadow interface{}) bool
+	// UserID returns the id to log for a user, the default uses the String method of the user if it has one
+	UserID func(user interface{}) string
+	// Timeout is how long a shadow lookup can take before its context is cancelled (default: DefaultShadowTimeout)
+	Timeout time.Duration
+
+	primary Finder
+	shadow  Finder
+	logger  log.FieldLogger
+	running chan struct{}
+	wg      sync.WaitGroup
+}
+
+// Find returns the result of the primary Finder, and compares it to the shadow Finder in the background
+func (f *ShadowFinder) Find(c interface{}, r *http.Request) (interface{}, error) {
+	user, err := f.primary.Find(c, r)
+
+	select {
+	case f.running <- struct{}{}:
+		f.wg.Add(1)
+		go f.compare(c, r, user, err)
+	default:
+	}
+
+	return user, err
+}
+
+// compare looks the credentials up in the shadow Finder, and logs if it disagrees with the primary result
+func (f *ShadowFinder) compare(c interface{}, r *http.Request, user interface{}, err error) {
+	defer func() {
+		<-f.running
+		f.wg.Done()
+	}()
+	timeout := f.Timeout
+	if timeout <= 0 {
+		timeout = DefaultShadowTimeout
+	}
+	// the request context is cancelled once the response is written, which can be before the shadow is done
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	shadowUser, shadowErr := f.shadow.Find(c, r.WithContext(ctx))
+
+	primaryFound, shadowFound := err == nil, shadowErr == nil
+	if primaryFound == shadowFound && (!primaryFound || f.Equal(user, shadowUser)) {
+		return
+	}
+
+	fields := log.KV{
+		"tag":                "auth_shadow_mismatch",
+		"auth.primary_found": primaryFound,
+		"auth.shadow_found":  shadowFound,
+		"auth.primary_user":  "",
+		"auth.shadow_user":   "",
+		"auth.fingerprint":   "",
+		"auth.primary_error": "",
+		"auth.shadow_error":  "",
+	}
+	if key, ok := c.(string); ok {
+		fields["auth.fingerprint"] = fingerprint(key)
+	}
+	if primaryFound {
+		fields["auth.primary_user"] = f.UserID(user)
+	} else {
+		fields["auth.primary_error"] = err.Error()
+	}
+	if shadowFound {
+		fields["auth.shadow_user"] = f.UserID(shadowUser)
+	} else {
+		fields["auth.shadow_error"] = shadowErr.Error()
+	}
+	f.logger.With(fields).Warnf("the shadow finder disagrees with the primary finder")
+}
+
+// Wait blocks until the running shadow lookups are done, such as when the service shuts down
+func (f *ShadowFinder) Wait() {
+	f.wg.Wait()
+}
+
+// NewShadowFinder wraps primary to also look each key up in shadow, logging disagreements to logger
+//
+// Usage:
+//  finder := auth.NewShadowFinder(currentStore, newStore, log.With(log.KV{"module": "auth"}))
+//  finder.Timeout = time.Second
+//  keyAuth := auth.NewAPIKey("Graze", finder, failure.HandlerFunc(onError))
+func NewShadowFinder(primary, shadow Finder, logger log.FieldLogger) *ShadowFinder {
+	return &ShadowFinder{
+		Equal:   reflect.DeepEqual,
+		UserID:  userString,
+		Timeout: DefaultShadowTimeout,
+		primary: primary,
+		shadow:  shadow,
+		logger:  logger,
+		running: make(chan struct{}, shadowConcurrency),
+	}
+}
@@ -0,0 +1,133 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus/hooks/test"
+	"github.com/graze/golang-service/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShadowFinder(t *testing.T) {
+	cases := map[string]struct {
+		key      string
+		user     interface{}
+		mismatch bool
+		primary  string
+		shadow   string
+	}{
+		"both agree":          {"same", "alice", false, "", ""},
+		"both not found":      {"unknown", nil, false, "", ""},
+		"different user":      {"changed", "bob", true, "bob", "robert"},
+		"missing from shadow": {"old", "carol", true, "carol", ""},
+		"only in shadow":      {"new", nil, true, "", "dave"},
+	}
+
+	for k, tc := range cases {
+		logger := log.New("", "", "")
+		hook := test.NewLocal(logger.Logger)
+		primary := &mapFinder{users: map[string]interface{}{"same": "alice", "changed": "bob", "old": "carol"}}
+		shadow := &mapFinder{users: map[string]interface{}{"same": "alice", "changed": "robert", "new": "dave"}}
+		finder := NewShadowFinder(primary, shadow, logger)
+		finder.UserID = func(user interface{}) string {
+			return user.(string)
+		}
+
+		user, err := finder.Find(tc.key, ipRequest(t, "10.0.0.1"))
+		assert.Equal(t, tc.user, user, "test: %s the primary result is used", k)
+		assert.Equal(t, tc.user == nil, err != nil, "test: %s", k)
+
+		finder.Wait()
+		assert.Equal(t, 1, shadow.calls, "test: %s", k)
+		if !tc.mismatch {
+			assert.Equal(t, 0, len(hook.Entries), "test: %s", k)
+			continue
+		}
+		if assert.Equal(t, 1, len(hook.Entries), "test: %s", k) {
+			entry := hook.LastEntry()
+			assert.Equal(t, log.WarnLevel, entry.Level, "test: %s", k)
+			assert.Equal(t, "auth_shadow_mismatch", entry.Data["tag"], "test: %s", k)
+			assert.Equal(t, fingerprint(tc.key), entry.Data["auth.fingerprint"], "test: %s", k)
+			assert.Equal(t, tc.primary, entry.Data["auth.primary_user"], "test: %s", k)
+			assert.Equal(t, tc.shadow, entry.Data["auth.shadow_user"], "test: %s", k)
+			assert.Equal(t, tc.primary != "", entry.Data["auth.primary_found"], "test: %s", k)
+			assert.Equal(t, tc.shadow != "", entry.Data["auth.shadow_found"], "test: %s", k)
+		}
+	}
+}
+
+func TestShadowFinderEqual(t *testing.T) {
+	logger := log.New("", "", "")
+	hook := test.NewLocal(logger.Logger)
+	finder := NewShadowFinder(
+		&mapFinder{users: map[string]interface{}{"key": "Alice"}},
+		&mapFinder{users: map[string]interface{}{"key": "alice"}},
+		logger,
+	)
+
+	finder.Find("key", ipRequest(t, "10.0.0.1"))
+	finder.Wait()
+	assert.Equal(t, 1, len(hook.Entries))
+
+	finder.Equal = func(primary, shadow interface{}) bool {
+		return len(primary.(string)) == len(shadow.(string))
+	}
+	finder.Find("key", ipRequest(t, "10.0.0.1"))
+	finder.Wait()
+	assert.Equal(t, 1, len(hook.Entries), "a custom Equal is used to compare users")
+}
+
+func TestShadowFinderDoesNotWait(t *testing.T) {
+	logger := log.New("", "", "")
+	release := make(chan struct{})
+	shadow := FinderFunc(func(c interface{}, r *http.Request) (interface{}, error) {
+		<-release
+		return "alice", r.Context().Err()
+	})
+	finder := NewShadowFinder(&mapFinder{users: map[string]interface{}{"key": "alice"}}, shadow, logger)
+	hook := test.NewLocal(logger.Logger)
+
+	req := ipRequest(t, "10.0.0.1")
+	ctx, cancel := context.WithCancel(req.Context())
+	user, err := finder.Find("key", req.WithContext(ctx))
+	assert.Nil(t, err)
+	assert.Equal(t, "alice", user, "the primary result is returned before the shadow is done")
+	cancel()
+
+	close(release)
+	finder.Wait()
+	assert.Equal(t, 0, len(hook.Entries), "the shadow is not cancelled with the request")
+}
+
+func TestShadowFinderTimeout(t *testing.T) {
+	logger := log.New("", "", "")
+	hook := test.NewLocal(logger.Logger)
+	shadow := FinderFunc(func(c interface{}, r *http.Request) (interface{}, error) {
+		<-r.Context().Done()
+		return nil, r.Context().Err()
+	})
+	finder := NewShadowFinder(&mapFinder{users: map[string]interface{}{"key": "alice"}}, shadow, logger)
+	assert.Equal(t, DefaultShadowTimeout, finder.Timeout)
+	finder.Timeout = 20 * time.Millisecond
+
+	start := time.Now()
+	finder.Find("key", ipRequest(t, "10.0.0.1"))
+	finder.Wait()
+	assert.True(t, time.Since(start) < time.Second, "the shadow lookup is cancelled after the timeout")
+	if assert.Equal(t, 1, len(hook.Entries)) {
+		assert.Equal(t, context.DeadlineExceeded.Error(), hook.LastEntry().Data["auth.shadow_error"])
+	}
+}
@@ -0,0 +1,151 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ThrottledError is returned when the key store is being called more often than its maximum rate
+type ThrottledError struct {
+	retryAfter time.Duration
+	status     int
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("key validation is throttled, retry after: %s", e.retryAfter)
+}
+
+// Status returns the ShedStatus of the ThrottledFinder, 429 (Too Many Requests) by default
+func (e *ThrottledError) Status() int {
+	return e.status
+}
+
+// RetryAfter returns how long until the key store can be called again
+func (e *ThrottledError) RetryAfter() time.Duration {
+	return e.retryAfter
+}
+
+// ThrottledFinder is a Finder that limits the rate of calls to the wrapped Finder across all requests, to protect a
+// fragile key store. It is a single token bucket allowing rate calls per second and burst calls at once
+//
+// When the rate is exceeded a call waits for its turn if that is within MaxWait and before the request's deadline,
+// otherwise it is shed with a *ThrottledError. A waiting call stops if the request's context is done. Unlike the
+// KeyRateLimiter, the limit is shared by every key
+type ThrottledFinder struct {
+	// MaxWait is how long a call can queue for its turn, 0 sheds calls over the rate straight away
+	MaxWait time.Duration
+	// ShedStatus is the status of a shed call, such as 503 (Service Unavailable) (default: 429 Too Many Requests)
+	ShedStatus int
+
+	finder Finder
+	rate   float64
+	burst  int
+	now    func() time.Time
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// Find calls the wrapped Finder when there is capacity, waiting up to MaxWait for it
+func (f *ThrottledFinder) Find(c interface{}, r *http.Request) (interface{}, error) {
+	maxWait := f.MaxWait
+	if deadline, ok := r.Context().Deadline(); ok {
+		if untilDeadline := deadline.Sub(f.now()); untilDeadline < maxWait {
+			maxWait = untilDeadline
+		}
+	}
+
+	wait, ok := f.reserve(maxWait)
+	if !ok {
+		return nil, f.shed(wait)
+	}
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			timer.Stop()
+			f.cancel()
+			return nil, f.shed(wait)
+		}
+	}
+	return f.finder.Find(c, r)
+}
+
+// reserve takes a token from the bucket, returning how long to wait for it. If the wait is longer than maxWait no
+// token is taken and false is returned
+func (f *ThrottledFinder) reserve(maxWait time.Duration) (time.Duration, bool) {
+	now := f.now()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.tokens += now.Sub(f.last).Seconds() * f.rate
+	if f.tokens > float64(f.burst) {
+		f.tokens = float64(f.burst)
+	}
+	f.last = now
+
+	var wait time.Duration
+	if f.tokens < 1 {
+		if f.rate <= 0 {
+			return time.Second, false
+		}
+		wait = time.Duration((1 - f.tokens) / f.rate * float64(time.Second))
+	}
+	if wait > maxWait {
+		return wait, false
+	}
+	// the bucket can go negative, so later calls queue behind this one
+	f.tokens--
+	return wait, true
+}
+
+// cancel returns a reserved token that was not used
+func (f *ThrottledFinder) cancel() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.tokens++
+}
+
+// shed returns the error for a call that was shed
+func (f *ThrottledFinder) shed(retryAfter time.Duration) error {
+	status := f.ShedStatus
+	if status == 0 {
+		status = http.StatusTooManyRequests
+	}
+	return &ThrottledError{retryAfter, status}
+}
+
+// NewThrottledFinder wraps finder to allow at most rate calls per second, with up to burst calls at once
+//
+// Usage:
+//  finder := auth.NewThrottledFinder(auth.FinderFunc(finder), 100, 20)
+//  finder.MaxWait = 100 * time.Millisecond
+//  finder.ShedStatus = http.StatusServiceUnavailable
+//  keyAuth := auth.NewAPIKey("Graze", finder, failure.HandlerFunc(onError))
+func NewThrottledFinder(finder Finder, rate float64, burst int) *ThrottledFinder {
+	if burst < 1 {
+		burst = 1
+	}
+	return &ThrottledFinder{
+		finder: finder,
+		rate:   rate,
+		burst:  burst,
+		now:    time.Now,
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
@@ -0,0 +1,117 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/graze/golang-service/handlers/failure"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThrottledFinderShed(t *testing.T) {
+	cases := map[string]struct {
+		shedStatus int
+		status     int
+	}{
+		"default":             {0, http.StatusTooManyRequests},
+		"service unavailable": {http.StatusServiceUnavailable, http.StatusServiceUnavailable},
+	}
+
+	for k, tc := range cases {
+		store := &mapFinder{users: map[string]interface{}{"good": "user"}}
+		finder := NewThrottledFinder(store, 1, 3)
+		finder.ShedStatus = tc.shedStatus
+		var authErr error
+		keyAuth := NewAPIKey("Graze", finder, failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, status int) {
+			authErr = err
+			w.WriteHeader(status)
+		}))
+
+		codes := []int{}
+		for i := 0; i < 5; i++ {
+			rec := httptest.NewRecorder()
+			req := headerRequest(t, "GET", "/stuff", map[string]string{"Authorization": "Graze good"})
+			keyAuth.Then(okHandler).ServeHTTP(rec, req)
+			codes = append(codes, rec.Code)
+		}
+
+		assert.Equal(t, []int{200, 200, 200, tc.status, tc.status}, codes, "test: %s the burst is allowed", k)
+		assert.Equal(t, 3, store.calls, "test: %s shed calls do not reach the key store", k)
+		if assert.IsType(t, &ThrottledError{}, authErr, "test: %s", k) {
+			assert.True(t, authErr.(*ThrottledError).RetryAfter() > 0, "test: %s", k)
+		}
+	}
+}
+
+func TestThrottledFinderSharedByKeys(t *testing.T) {
+	finder := NewThrottledFinder(&mapFinder{users: map[string]interface{}{"a": "alice", "b": "bob"}}, 1, 1)
+
+	_, err := finder.Find("a", ipRequest(t, "10.0.0.1"))
+	assert.Nil(t, err)
+	_, err = finder.Find("b", ipRequest(t, "10.0.0.2"))
+	assert.IsType(t, &ThrottledError{}, err, "the limit is global")
+}
+
+func TestThrottledFinderQueue(t *testing.T) {
+	finder := NewThrottledFinder(FinderFunc(func(c interface{}, r *http.Request) (interface{}, error) {
+		return "user", nil
+	}), 50, 1)
+	finder.MaxWait = time.Second
+
+	start := time.Now()
+	wg := &sync.WaitGroup{}
+	errs := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := finder.Find("key", ipRequest(t, "10.0.0.1"))
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.Nil(t, err, "calls over the rate are queued")
+	}
+	elapsed := time.Since(start)
+	assert.True(t, elapsed >= 70*time.Millisecond, "5 calls at 50 per second take at least 80ms: %s", elapsed)
+}
+
+func TestThrottledFinderQueueContext(t *testing.T) {
+	finder := NewThrottledFinder(&mapFinder{users: map[string]interface{}{"good": "user"}}, 1, 1)
+	finder.MaxWait = 10 * time.Second
+
+	_, err := finder.Find("good", ipRequest(t, "10.0.0.1"))
+	assert.Nil(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = finder.Find("good", ipRequest(t, "10.0.0.1").WithContext(ctx))
+	assert.IsType(t, &ThrottledError{}, err, "a call that can not be made before the deadline is shed")
+	assert.True(t, time.Since(start) < 40*time.Millisecond, "it does not wait")
+
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+	_, err = finder.Find("good", ipRequest(t, "10.0.0.1").WithContext(ctx))
+	assert.IsType(t, &ThrottledError{}, err, "a waiting call stops when the request is cancelled")
+}
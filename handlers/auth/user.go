@@ -13,13 +13,108 @@ package auth
 import (
 	"context"
 	"net/http"
+	"sync"
 )
 
 // contextKey is a custom type to only allow this to access the key in the context
 type contextKey int
 
-// userKey is a private key to store the user information in the context in
-const userKey contextKey = iota
+const (
+	// userKey is a private key to store the user information in the context in
+	userKey contextKey = iota
+	// trackKey is a private key to store the tracked state of the request in the context in
+	trackKey
+)
+
+// tracked is the state of the auth handlers for a request, such as the scheme that authenticated it
+//
+// It is stored as a pointer so handlers wrapping the auth handler can see it once the request has been handled
+type tracked struct {
+	mu        sync.Mutex
+	scheme    string
+	provider  string
+	rateLimit bool
+	bucket    string
+	remaining int
+	limited   bool
+}
+
+// Track returns a copy of req that records the state of the auth handlers and finders for it, so a handler wrapping
+// the auth handler (such as a request logger) can read it with GetScheme and GetRateLimit after the request has been
+// handled
+func Track(r *http.Request) *http.Request {
+	if _, ok := r.Context().Value(trackKey).(*tracked); ok {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), trackKey, &tracked{}))
+}
+
+// getTracked returns the tracked state of r, or nil if r is not tracked
+func getTracked(r *http.Request) *tracked {
+	t, _ := r.Context().Value(trackKey).(*tracked)
+	return t
+}
+
+// GetScheme returns the scheme (such as `apikey`, `x-api-key` or `client_cert`) and provider (the APIKey provider, such
+// as `Bearer`) that authenticated the request, or empty strings if the request was not authenticated
+//
+// Outside the auth handler, the request must have been passed to Track before being handled
+func GetScheme(r *http.Request) (scheme, provider string) {
+	t := getTracked(r)
+	if t == nil {
+		return "", ""
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.scheme, t.provider
+}
+
+// saveScheme records the scheme and provider that authenticated the request
+func saveScheme(r *http.Request, scheme, provider string) *http.Request {
+	r = Track(r)
+	t := getTracked(r)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.scheme, t.provider = scheme, provider
+	return r
+}
+
+// GetRateLimit returns the number of requests remaining for the key after this request and whether the request was
+// limited by a KeyRateLimiter or AnonymousRateLimit. ok is false if the request was not checked by a rate limiter
+//
+// The request must have been passed to Track before being handled, as the Finder can not change the request
+func GetRateLimit(r *http.Request) (remaining int, limited bool, ok bool) {
+	t := getTracked(r)
+	if t == nil {
+		return 0, false, false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.remaining, t.limited, t.rateLimit
+}
+
+// GetRateLimitBucket returns the rate limit bucket that applied to the request: `key` for a KeyRateLimiter, `anonymous`
+// for an AnonymousRateLimit, or an empty string if the request was not checked by a rate limiter
+func GetRateLimitBucket(r *http.Request) string {
+	t := getTracked(r)
+	if t == nil {
+		return ""
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.bucket
+}
+
+// saveRateLimit records the bucket and rate limit state of the request if it is tracked
+func saveRateLimit(r *http.Request, bucket string, remaining int, limited bool) {
+	t := getTracked(r)
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rateLimit, t.bucket, t.remaining, t.limited = true, bucket, remaining, limited
+}
 
 // saveUser takes a nominal user and stores it in a new context for the provided request
 func saveUser(r *http.Request, user interface{}) *http.Request {
@@ -53,7 +53,7 @@ func TestUserStorage(t *testing.T) {
 	rec := httptest.NewRecorder()
 
 	for k, tc := range cases {
-		auth := &APIKey{tc.provider, tc.finder, failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, status int) {
+		auth := &APIKey{Provider: tc.provider, Finder: tc.finder, OnError: failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, status int) {
 			t.Errorf("onError handler called. Err: %s, Status: %d, Test: %s", err, status, k)
 		})}
 
@@ -66,3 +66,41 @@ func TestUserStorage(t *testing.T) {
 		handler.ServeHTTP(rec, tc.request)
 	}
 }
+
+func TestSchemeStorage(t *testing.T) {
+	finder := FinderFunc(func(key interface{}, r *http.Request) (interface{}, error) {
+		return "user", nil
+	})
+	onError := failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, status int) {
+		w.WriteHeader(status)
+	})
+
+	cases := map[string]struct {
+		auth     func(h http.Handler) http.Handler
+		headers  map[string]string
+		scheme   string
+		provider string
+	}{
+		"api key":   {NewAPIKey("Bearer", finder, onError).Then, map[string]string{"Authorization": "Bearer token"}, "apikey", "Bearer"},
+		"x api key": {NewXAPIKey(finder, onError).Then, map[string]string{"X-Api-Key": "key"}, "x-api-key", ""},
+		"rejected":  {NewAPIKey("Graze", finder, onError).Then, map[string]string{"Authorization": "Bearer token"}, "", ""},
+	}
+
+	for k, tc := range cases {
+		req := Track(headerRequest(t, "GET", "/stuff", tc.headers))
+		var innerScheme, innerProvider string
+		tc.auth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			innerScheme, innerProvider = GetScheme(r)
+		})).ServeHTTP(httptest.NewRecorder(), req)
+
+		scheme, provider := GetScheme(req)
+		assert.Equal(t, tc.scheme, scheme, "test: %s: the scheme is visible to the wrapping handler", k)
+		assert.Equal(t, tc.provider, provider, "test: %s", k)
+		assert.Equal(t, tc.scheme, innerScheme, "test: %s: the scheme is visible to the handler", k)
+		assert.Equal(t, tc.provider, innerProvider, "test: %s", k)
+	}
+
+	scheme, provider := GetScheme(headerRequest(t, "GET", "/stuff", nil))
+	assert.Equal(t, "", scheme, "untracked requests have no scheme")
+	assert.Equal(t, "", provider)
+}
@@ -0,0 +1,157 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// VaultLease is the identity and policies that a Vault token is leased for
+type VaultLease struct {
+	// ID identifies the entity the token was issued to
+	ID string
+	// Policies are the Vault policies attached to the token
+	Policies []string
+	// TTL is how long is left on the lease
+	TTL time.Duration
+}
+
+// VaultClient looks up tokens in Vault, such as a wrapper around the `auth/token/lookup` endpoint of the Vault api
+type VaultClient interface {
+	// LookupToken returns the lease for token, or a nil lease if the token is not valid. An error is only returned if
+	// Vault could not be reached. It must stop when ctx is done
+	LookupToken(ctx context.Context, token string) (*VaultLease, error)
+}
+
+// VaultUser is the user returned by the VaultFinder
+type VaultUser struct {
+	ID       string
+	Policies []string
+	// Expires is when the lease of the token ends
+	Expires time.Time
+}
+
+// String returns the id of the user
+func (u *VaultUser) String() string {
+	return u.ID
+}
+
+// VaultUnavailableError is returned when Vault could not be reached to look up a token
+type VaultUnavailableError struct {
+	err error
+}
+
+func (e *VaultUnavailableError) Error() string {
+	return fmt.Sprintf("failed to look up the token in vault: %v", e.err)
+}
+
+// Status returns 503 (Service Unavailable)
+func (e *VaultUnavailableError) Status() int {
+	return http.StatusServiceUnavailable
+}
+
+// VaultFinder is a Finder that validates keys as tokens leased from Vault, returning a *VaultUser
+//
+// Each valid token is cached until its lease expires, or for MaxTTL if that is shorter, so a revoked token can still
+// be used until then. Tokens are cached as a fingerprint. Tokens that are not valid or have an expired lease are an
+// invalid key (401), and a *VaultUnavailableError (503) is returned if Vault could not be reached
+type VaultFinder struct {
+	// MaxTTL limits how long a token is cached, 0 caches for the whole lease
+	MaxTTL time.Duration
+	// Timeout limits each call to Vault if set, the request deadline is always honoured
+	Timeout time.Duration
+
+	client VaultClient
+	now    func() time.Time
+
+	mu        sync.Mutex
+	users     map[string]cachedUser
+	lastSweep time.Time
+}
+
+// Find returns the user for the token from the cache, or looks it up in Vault
+func (f *VaultFinder) Find(credentials interface{}, r *http.Request) (interface{}, error) {
+	token, ok := credentials.(string)
+	if !ok {
+		return nil, errors.New("the supplied key is in an invalid format")
+	}
+	fp := fingerprint(token)
+	now := f.now()
+
+	f.mu.Lock()
+	cached, ok := f.users[fp]
+	if ok && !now.Before(cached.expires) {
+		delete(f.users, fp)
+		ok = false
+	}
+	f.mu.Unlock()
+	if ok {
+		return cached.user, nil
+	}
+
+	ctx := r.Context()
+	if f.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.Timeout)
+		defer cancel()
+	}
+	lease, err := f.client.LookupToken(ctx, token)
+	if err != nil {
+		return nil, &VaultUnavailableError{err}
+	}
+	if lease == nil || lease.TTL <= 0 {
+		return nil, errors.New("the token is not valid or its lease has expired")
+	}
+
+	user := &VaultUser{ID: lease.ID, Policies: lease.Policies, Expires: now.Add(lease.TTL)}
+	ttl := lease.TTL
+	if f.MaxTTL > 0 && f.MaxTTL < ttl {
+		ttl = f.MaxTTL
+	}
+	f.store(fp, cachedUser{user, now.Add(ttl)}, now)
+	return user, nil
+}
+
+// store caches the user for fp, removing any expired users at most once a minute
+func (f *VaultFinder) store(fp string, user cachedUser, now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if now.Sub(f.lastSweep) > time.Minute {
+		for k, u := range f.users {
+			if !now.Before(u.expires) {
+				delete(f.users, k)
+			}
+		}
+		f.lastSweep = now
+	}
+	f.users[fp] = user
+}
+
+// NewVaultFinder returns a VaultFinder that looks tokens up with client
+//
+// Usage:
+//  finder := auth.NewVaultFinder(vaultClient)
+//  finder.MaxTTL = 5 * time.Minute
+//  keyAuth := auth.NewAPIKey("Bearer", finder, failure.HandlerFunc(onError))
+//  ...
+//  user := auth.GetUser(r).(*auth.VaultUser)
+func NewVaultFinder(client VaultClient) *VaultFinder {
+	return &VaultFinder{
+		client: client,
+		now:    time.Now,
+		users:  make(map[string]cachedUser),
+	}
+}
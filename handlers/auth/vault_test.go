@@ -0,0 +1,138 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/graze/golang-service/handlers/failure"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeVault is a VaultClient with a fixed set of leases that counts the lookups
+type fakeVault struct {
+	leases map[string]*VaultLease
+	down   bool
+	calls  int
+}
+
+func (v *fakeVault) LookupToken(ctx context.Context, token string) (*VaultLease, error) {
+	v.calls++
+	if v.down {
+		return nil, errors.New("connection refused")
+	}
+	return v.leases[token], nil
+}
+
+func TestVaultFinder(t *testing.T) {
+	vault := &fakeVault{leases: map[string]*VaultLease{
+		"valid":   {ID: "entity-1", Policies: []string{"default", "orders"}, TTL: time.Hour},
+		"expired": {ID: "entity-2", Policies: []string{"default"}, TTL: 0},
+	}}
+	now := time.Now()
+	finder := NewVaultFinder(vault)
+	finder.now = func() time.Time { return now }
+
+	cases := map[string]struct {
+		token  string
+		user   interface{}
+		err    error
+		status int
+	}{
+		"valid lease": {
+			"valid", &VaultUser{ID: "entity-1", Policies: []string{"default", "orders"}, Expires: now.Add(time.Hour)},
+			nil, http.StatusOK,
+		},
+		"expired lease": {"expired", nil, &InvalidKeyError{}, http.StatusUnauthorized},
+		"unknown token": {"unknown", nil, &InvalidKeyError{}, http.StatusUnauthorized},
+	}
+
+	for k, tc := range cases {
+		var authErr error
+		var user interface{}
+		keyAuth := NewAPIKey("Bearer", finder, failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, status int) {
+			authErr = err
+			w.WriteHeader(status)
+		}))
+		rec := httptest.NewRecorder()
+		req := headerRequest(t, "GET", "/stuff", map[string]string{"Authorization": "Bearer " + tc.token})
+		keyAuth.ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+			user = GetUser(r)
+		}).ServeHTTP(rec, req)
+
+		assert.Equal(t, tc.status, rec.Code, "test: %s", k)
+		assert.Equal(t, tc.user, user, "test: %s", k)
+		if tc.err != nil {
+			assert.IsType(t, tc.err, authErr, "test: %s", k)
+		}
+	}
+
+	_, err := finder.Find(1234, ipRequest(t, "10.0.0.1"))
+	assert.NotNil(t, err)
+}
+
+func TestVaultFinderCache(t *testing.T) {
+	vault := &fakeVault{leases: map[string]*VaultLease{
+		"short": {ID: "entity-1", TTL: time.Minute},
+		"long":  {ID: "entity-2", TTL: time.Hour},
+	}}
+	now := time.Now()
+	finder := NewVaultFinder(vault)
+	finder.now = func() time.Time { return now }
+	finder.MaxTTL = 10 * time.Minute
+
+	finder.Find("short", ipRequest(t, "10.0.0.1"))
+	finder.Find("short", ipRequest(t, "10.0.0.1"))
+	assert.Equal(t, 1, vault.calls, "the lease is cached")
+
+	now = now.Add(time.Minute)
+	delete(vault.leases, "short")
+	_, err := finder.Find("short", ipRequest(t, "10.0.0.1"))
+	assert.NotNil(t, err, "the cache entry expires with the lease")
+	assert.Equal(t, 2, vault.calls)
+
+	finder.Find("long", ipRequest(t, "10.0.0.1"))
+	now = now.Add(9 * time.Minute)
+	finder.Find("long", ipRequest(t, "10.0.0.1"))
+	assert.Equal(t, 3, vault.calls)
+	now = now.Add(time.Minute)
+	finder.Find("long", ipRequest(t, "10.0.0.1"))
+	assert.Equal(t, 4, vault.calls, "the cache is limited to MaxTTL")
+}
+
+func TestVaultFinderUnavailable(t *testing.T) {
+	vault := &fakeVault{leases: map[string]*VaultLease{"valid": {ID: "entity-1", TTL: time.Minute}}}
+	now := time.Now()
+	finder := NewVaultFinder(vault)
+	finder.now = func() time.Time { return now }
+
+	_, err := finder.Find("valid", ipRequest(t, "10.0.0.1"))
+	assert.Nil(t, err)
+
+	vault.down = true
+	user, err := finder.Find("valid", ipRequest(t, "10.0.0.1"))
+	assert.Nil(t, err, "cached tokens can be used while vault is down")
+	assert.Equal(t, "entity-1", user.(*VaultUser).String())
+
+	var status int
+	keyAuth := NewAPIKey("Bearer", finder, failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, s int) {
+		assert.IsType(t, &VaultUnavailableError{}, err)
+		status = s
+	}))
+	req := headerRequest(t, "GET", "/stuff", map[string]string{"Authorization": "Bearer other"})
+	keyAuth.Then(okHandler).ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+}
@@ -23,7 +23,7 @@ import (
 type XAPIKey struct {
 	// Validator takes the provided <apiKey> and returns a user object or error if the key is invalid
 	Finder Finder
-	// OnError gets called if the request is unauthorized or forbidden
+	// OnError gets called if the request is unauthorized or forbidden, if nil the default set by SetDefaultOnError is used
 	OnError failure.Handler
 }
 
@@ -47,7 +47,7 @@ type XAPIKey struct {
 // 		fmt.Fprintf(w, err.Error())
 // 	}
 //
-// 	keyAuth := auth.APIKey{"Graze", finder, onError}
+// 	keyAuth := auth.NewXAPIKey(auth.FinderFunc(finder), failure.HandlerFunc(onError))
 //
 // 	http.Handle("/thing", keyAuth.ThenFunc(ThingFunc))
 func (x *XAPIKey) ThenFunc(fn func(http.ResponseWriter, *http.Request)) http.Handler {
@@ -74,7 +74,7 @@ func (x *XAPIKey) ThenFunc(fn func(http.ResponseWriter, *http.Request)) http.Han
 // 		fmt.Fprintf(w, err.Error())
 // 	}
 //
-// 	keyAuth := auth.APIKey{"Graze", finder, onError}
+// 	keyAuth := auth.NewXAPIKey(auth.FinderFunc(finder), failure.HandlerFunc(onError))
 //
 // 	http.Handle("/thing", keyAuth.Then(ThingHandler))
 func (x *XAPIKey) Then(h http.Handler) http.Handler {
@@ -84,18 +84,19 @@ func (x *XAPIKey) Then(h http.Handler) http.Handler {
 // Handler wraps the Then method to become clearer
 func (x *XAPIKey) Handler(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		onError := orDefault(x.OnError)
 		header := req.Header["X-Api-Key"]
 		if len(header) == 0 {
-			x.OnError.Handle(w, req, &NoHeaderError{}, http.StatusUnauthorized)
+			onError.Handle(w, req, &NoHeaderError{}, http.StatusUnauthorized)
 			return
 		}
 
-		user, err := x.Finder.Find(header[0], req)
+		user, status, err := findUser(x.Finder, header[0], req)
 		if err != nil {
-			x.OnError.Handle(w, req, &InvalidKeyError{header[0], err}, http.StatusUnauthorized)
+			onError.Handle(w, req, err, status)
 			return
 		}
-		req = saveUser(req, user)
+		req = saveScheme(saveUser(req, user), "x-api-key", "")
 
 		h.ServeHTTP(w, req)
 	})
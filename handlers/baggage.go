@@ -0,0 +1,68 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/graze/golang-service/log"
+)
+
+// WithBaggage logs the values of the allowed keys from the W3C `baggage` header as `baggage.<key>`
+//
+// Only the listed keys are logged so arbitrary client data does not end up in the logs. Malformed entries are ignored
+//
+// Usage:
+//  loggedRouter := handlers.StructuredHandler(r, handlers.WithBaggage("tenant", "experiment"))
+func WithBaggage(keys ...string) StructuredOption {
+	allowed := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		allowed[k] = true
+	}
+	return func(h *structuredHandler) {
+		h.fields = append(h.fields, func(req *http.Request) log.KV {
+			fields := log.KV{}
+			for k, v := range parseBaggage(req) {
+				if allowed[k] {
+					fields["baggage."+k] = v
+				}
+			}
+			return fields
+		})
+	}
+}
+
+// parseBaggage returns the key/values from all the `baggage` headers on req, skipping any malformed members
+//
+// See: https://www.w3.org/TR/baggage/
+func parseBaggage(req *http.Request) map[string]string {
+	baggage := map[string]string{}
+	for _, header := range req.Header["Baggage"] {
+		for _, member := range strings.Split(header, ",") {
+			// properties after the value are not logged
+			member = strings.SplitN(member, ";", 2)[0]
+			parts := strings.SplitN(member, "=", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			key := strings.TrimSpace(parts[0])
+			// values are percent encoded, where `+` is not a space
+			value, err := url.QueryUnescape(strings.Replace(strings.TrimSpace(parts[1]), "+", "%2B", -1))
+			if key == "" || err != nil {
+				continue
+			}
+			baggage[key] = value
+		}
+	}
+	return baggage
+}
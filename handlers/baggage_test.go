@@ -0,0 +1,67 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Sirupsen/logrus/hooks/test"
+	"github.com/graze/golang-service/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStructuredLoggingWithBaggage(t *testing.T) {
+	cases := map[string]struct {
+		baggage  []string
+		expected log.KV
+	}{
+		"allowed keys": {
+			[]string{"tenant=acme,experiment=blue;ttl=10,secret=hunter2"},
+			log.KV{"baggage.tenant": "acme", "baggage.experiment": "blue"},
+		},
+		"multiple headers": {
+			[]string{"tenant=acme", "experiment=red"},
+			log.KV{"baggage.tenant": "acme", "baggage.experiment": "red"},
+		},
+		"percent encoded": {
+			[]string{"tenant=acme%20corp+1"},
+			log.KV{"baggage.tenant": "acme corp+1"},
+		},
+		"malformed": {
+			[]string{"tenant,=blue,experiment=%zz, experiment"},
+			log.KV{},
+		},
+	}
+
+	logger := log.New("", "", "")
+	hook := test.NewLocal(logger.Logger)
+	handler := StructuredLogHandler(logger, okHandler, WithBaggage("tenant", "experiment"))
+
+	for k, tc := range cases {
+		hook.Reset()
+		req := newRequest("GET", "http://example.com")
+		for _, b := range tc.baggage {
+			req.Header.Add("Baggage", b)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		assert.Equal(t, 1, len(hook.Entries), "test: %s", k)
+		fields := log.KV{}
+		for f, v := range hook.LastEntry().Data {
+			if strings.HasPrefix(f, "baggage.") {
+				fields[f] = v
+			}
+		}
+		assert.Equal(t, tc.expected, fields, "test: %s", k)
+	}
+}
@@ -0,0 +1,86 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/graze/golang-service/handlers/failure"
+	"github.com/graze/golang-service/log"
+)
+
+// BudgetExceededError is returned when a request takes longer than its time budget
+type BudgetExceededError struct {
+	Budget time.Duration
+}
+
+func (e *BudgetExceededError) Error() string {
+	return fmt.Sprintf("request exceeded its time budget of %s", e.Budget)
+}
+
+type budgetHandler struct {
+	budget  time.Duration
+	onError failure.Handler
+	handler http.Handler
+}
+
+// ServeHTTP sets a deadline on the request context and calls onError if the handler runs out of time without writing
+// a response
+func (h budgetHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	ctx, cancel := context.WithTimeout(req.Context(), h.budget)
+	defer cancel()
+
+	req = withRequestFields(req.WithContext(ctx))
+	addLogFields(req, log.KV{"http.budget_ms": int64(h.budget / time.Millisecond)})
+
+	logger := MakeLogger(w)
+	h.handler.ServeHTTP(logger, req)
+
+	if ctx.Err() == context.DeadlineExceeded && logger.Status() == 0 {
+		h.onError.Handle(logger, req, &BudgetExceededError{h.budget}, http.StatusServiceUnavailable)
+	}
+}
+
+// TimeoutBudget returns a middleware that gives each request a time budget of `budget`
+//
+// The deadline is set on the request context so any downstream calls using the context are cancelled once the
+// budget is used. If the handler returns after the deadline without writing a response, onError is called with a
+// *BudgetExceededError and a status of 503. The budget is logged as `http.budget_ms`
+//
+// Usage:
+//  r := mux.NewRouter()
+//  r.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+//      remaining, _ := handlers.RemainingBudget(r)
+//      client.Timeout = remaining
+//  })
+//  budget := handlers.TimeoutBudget(2*time.Second, failure.HandlerFunc(onError))
+//  http.ListenAndServe(":1123", handlers.StructuredHandler(budget(r)))
+func TimeoutBudget(budget time.Duration, onError failure.Handler) func(h http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return budgetHandler{budget, onError, h}
+	}
+}
+
+// RemainingBudget returns the time left before the request's deadline, and false if the request has no deadline
+func RemainingBudget(req *http.Request) (time.Duration, bool) {
+	deadline, ok := req.Context().Deadline()
+	if !ok {
+		return 0, false
+	}
+	remaining := deadline.Sub(time.Now())
+	if remaining < 0 {
+		remaining = 0
+	}
+	return remaining, true
+}
@@ -0,0 +1,80 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus/hooks/test"
+	"github.com/graze/golang-service/handlers/failure"
+	"github.com/graze/golang-service/log"
+	"github.com/stretchr/testify/assert"
+)
+
+var statusRecoverer = failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, status int) {
+	w.WriteHeader(status)
+})
+
+func TestTimeoutBudgetPropagatesToTheHandler(t *testing.T) {
+	logger := log.New("", "", "")
+	hook := test.NewLocal(logger.Logger)
+
+	var remaining time.Duration
+	var ok bool
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remaining, ok = RemainingBudget(r)
+		w.Write([]byte("ok\n"))
+	})
+
+	handler := StructuredLogHandler(logger, TimeoutBudget(time.Second, statusRecoverer)(h))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, newRequest("GET", "http://example.com"))
+
+	assert.True(t, ok)
+	assert.True(t, remaining > 0 && remaining <= time.Second)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, 1, len(hook.Entries))
+	assert.Equal(t, int64(1000), hook.LastEntry().Data["http.budget_ms"])
+	assert.Equal(t, http.StatusOK, hook.LastEntry().Data["http.status"])
+}
+
+func TestTimeoutBudgetExceeded(t *testing.T) {
+	logger := log.New("", "", "")
+	hook := test.NewLocal(logger.Logger)
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+
+	var budgetErr error
+	onError := failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, status int) {
+		budgetErr = err
+		w.WriteHeader(status)
+	})
+
+	handler := StructuredLogHandler(logger, TimeoutBudget(10*time.Millisecond, onError)(h))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, newRequest("GET", "http://example.com"))
+
+	assert.IsType(t, &BudgetExceededError{}, budgetErr)
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, 1, len(hook.Entries))
+	assert.Equal(t, int64(10), hook.LastEntry().Data["http.budget_ms"])
+	assert.Equal(t, http.StatusServiceUnavailable, hook.LastEntry().Data["http.status"])
+}
+
+func TestRemainingBudgetWithoutADeadline(t *testing.T) {
+	_, ok := RemainingBudget(newRequest("GET", "http://example.com"))
+	assert.False(t, ok)
+}
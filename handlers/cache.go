@@ -0,0 +1,35 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/graze/golang-service/log"
+)
+
+// SetCacheStatus records if the response to req was served from a cache (e.g. hit, miss or bypass)
+//
+// The status is logged by the structured log handler as `http.cache`
+//
+// Usage:
+//  r.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+//      if body, ok := cache.Get(r.URL.Path); ok {
+//          handlers.SetCacheStatus(r, "hit")
+//          w.Write(body)
+//          return
+//      }
+//      handlers.SetCacheStatus(r, "miss")
+//      ...
+//  })
+func SetCacheStatus(req *http.Request, status string) {
+	addLogFields(req, log.KV{"http.cache": status})
+}
@@ -0,0 +1,40 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Sirupsen/logrus/hooks/test"
+	"github.com/graze/golang-service/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetCacheStatus(t *testing.T) {
+	logger := log.New("", "", "")
+	hook := test.NewLocal(logger.Logger)
+
+	cached := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		SetCacheStatus(r, "hit")
+		w.Write([]byte("ok\n"))
+	})
+
+	StructuredLogHandler(logger, cached).ServeHTTP(httptest.NewRecorder(), newRequest("GET", "http://example.com"))
+	assert.Equal(t, 1, len(hook.Entries))
+	assert.Equal(t, "hit", hook.LastEntry().Data["http.cache"])
+
+	hook.Reset()
+	StructuredLogHandler(logger, okHandler).ServeHTTP(httptest.NewRecorder(), newRequest("GET", "http://example.com"))
+	assert.Equal(t, 1, len(hook.Entries))
+	assert.NotContains(t, hook.LastEntry().Data, "http.cache")
+}
@@ -0,0 +1,39 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/graze/golang-service/log"
+)
+
+// WithClientCert logs the subject common name and serial number (in hex) of the client certificate presented over
+// mutual TLS as `tls.client_cn` and `tls.client_serial`, whether or not it is used for authentication, to audit which
+// certificates are in use. Nothing is logged for requests without a client certificate
+//
+// Usage:
+//  loggedRouter := handlers.StructuredHandler(r, handlers.WithClientCert())
+func WithClientCert() StructuredOption {
+	return func(h *structuredHandler) {
+		h.fields = append(h.fields, func(req *http.Request) log.KV {
+			if req.TLS == nil || len(req.TLS.PeerCertificates) == 0 {
+				return log.KV{}
+			}
+			cert := req.TLS.PeerCertificates[0]
+			fields := log.KV{"tls.client_cn": cert.Subject.CommonName}
+			if cert.SerialNumber != nil {
+				fields["tls.client_serial"] = cert.SerialNumber.Text(16)
+			}
+			return fields
+		})
+	}
+}
@@ -0,0 +1,60 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Sirupsen/logrus/hooks/test"
+	"github.com/graze/golang-service/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithClientCert(t *testing.T) {
+	client := &x509.Certificate{Subject: pkix.Name{CommonName: "orders.internal"}, SerialNumber: big.NewInt(0x1f2e3d)}
+	issuer := &x509.Certificate{Subject: pkix.Name{CommonName: "Internal CA"}, SerialNumber: big.NewInt(1)}
+
+	cases := map[string]struct {
+		tls    *tls.ConnectionState
+		cn     interface{}
+		serial interface{}
+	}{
+		"client cert": {
+			&tls.ConnectionState{PeerCertificates: []*x509.Certificate{client, issuer}},
+			"orders.internal", "1f2e3d",
+		},
+		"no client cert": {&tls.ConnectionState{}, nil, nil},
+		"no tls":         {nil, nil, nil},
+	}
+
+	for k, tc := range cases {
+		logger := log.New("", "", "")
+		hook := test.NewLocal(logger.Logger)
+
+		req := newRequest("GET", "https://example.com")
+		req.TLS = tc.tls
+		StructuredLogHandler(logger, okHandler, WithClientCert()).ServeHTTP(httptest.NewRecorder(), req)
+
+		assert.Equal(t, 1, len(hook.Entries), "test: %s", k)
+		for field, expected := range map[string]interface{}{"tls.client_cn": tc.cn, "tls.client_serial": tc.serial} {
+			value, ok := hook.LastEntry().Data[field]
+			assert.Equal(t, expected != nil, ok, "test: %s field: %s", k, field)
+			if ok {
+				assert.Equal(t, expected, value, "test: %s field: %s", k, field)
+			}
+		}
+	}
+}
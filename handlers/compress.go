@@ -0,0 +1,171 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/graze/golang-service/log"
+)
+
+// countingWriter counts the bytes written to a writer
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	return n, err
+}
+
+// gzipWriter compresses the response body if the response can be compressed. The status is held until the first
+// bytes of the body are written, so the content type can be detected from the uncompressed body and empty bodies are
+// not compressed
+type gzipWriter struct {
+	http.ResponseWriter
+	status int
+	sent   bool
+	gz     *gzip.Writer
+	out    *countingWriter
+	in     int64
+}
+
+func (w *gzipWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+// sendHeader writes the status to the underlying writer, compressing the body from now on if compress is set and the
+// response can have a body. If the handler did not set a content type it is detected from first
+func (w *gzipWriter) sendHeader(compress bool, first []byte) {
+	if w.sent {
+		return
+	}
+	w.sent = true
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	header := w.Header()
+	header.Add("Vary", "Accept-Encoding")
+	if _, ok := header["Content-Type"]; !ok && len(first) > 0 {
+		header.Set("Content-Type", http.DetectContentType(first))
+	}
+	if compress && compressible(w.status) && header.Get("Content-Encoding") == "" {
+		header.Set("Content-Encoding", "gzip")
+		header.Del("Content-Length")
+		w.out = &countingWriter{w: w.ResponseWriter}
+		w.gz = gzip.NewWriter(w.out)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+}
+
+func (w *gzipWriter) Write(b []byte) (int, error) {
+	if !w.sent {
+		if len(b) == 0 {
+			return 0, nil
+		}
+		w.sendHeader(true, b)
+	}
+	if w.gz == nil {
+		return w.ResponseWriter.Write(b)
+	}
+	n, err := w.gz.Write(b)
+	w.in += int64(n)
+	return n, err
+}
+
+func (w *gzipWriter) Flush() {
+	w.sendHeader(true, nil)
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// compressible returns true if a response with status can have a body
+func compressible(status int) bool {
+	return status >= http.StatusOK && status != http.StatusNoContent && status != http.StatusNotModified
+}
+
+// acceptsGzip returns true if the Accept-Encoding header of req allows gzip
+func acceptsGzip(req *http.Request) bool {
+	for _, value := range req.Header["Accept-Encoding"] {
+		for _, encoding := range strings.Split(value, ",") {
+			parts := strings.Split(encoding, ";")
+			if !strings.EqualFold(strings.TrimSpace(parts[0]), "gzip") {
+				continue
+			}
+			q := 1.0
+			for _, param := range parts[1:] {
+				param = strings.TrimSpace(param)
+				if strings.HasPrefix(param, "q=") {
+					if value, err := strconv.ParseFloat(param[2:], 64); err == nil {
+						q = value
+					}
+				}
+			}
+			return q > 0
+		}
+	}
+	return false
+}
+
+type compressHandler struct {
+	handler http.Handler
+}
+
+// ServeHTTP compresses the response if the client accepts gzip and records if it was compressed
+func (h compressHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method == "HEAD" || !acceptsGzip(req) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		addLogFields(req, log.KV{"http.compressed": false})
+		h.handler.ServeHTTP(w, req)
+		return
+	}
+
+	writer := &gzipWriter{ResponseWriter: w}
+	h.handler.ServeHTTP(writer, req)
+	// a response without a body is sent as it is
+	writer.sendHeader(false, nil)
+	if writer.gz == nil {
+		addLogFields(req, log.KV{"http.compressed": false})
+		return
+	}
+
+	writer.gz.Close()
+	fields := log.KV{"http.compressed": true}
+	if writer.out.n > 0 {
+		fields["http.compression_ratio"] = float64(writer.in) / float64(writer.out.n)
+	}
+	addLogFields(req, fields)
+}
+
+// Compress returns a http.Handler that compresses the response of h with gzip when the client accepts it
+//
+// Responses that already have a `Content-Encoding`, and responses without a body, are not compressed. As with
+// net/http, the `Content-Type` is detected from the uncompressed body if the handler does not set one. It records if
+// the response was compressed as `http.compressed` in the structured request log, and for compressed responses the
+// uncompressed size divided by the compressed size as `http.compression_ratio`
+//
+// Usage:
+//  http.ListenAndServe(":1123", handlers.StructuredHandler(handlers.Compress(r)))
+func Compress(h http.Handler) http.Handler {
+	return compressHandler{h}
+}
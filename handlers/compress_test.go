@@ -0,0 +1,135 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Sirupsen/logrus/hooks/test"
+	"github.com/graze/golang-service/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompress(t *testing.T) {
+	body := strings.Repeat("a compressible response body\n", 100)
+	bodyHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "2900")
+		w.Write([]byte(body))
+	})
+
+	cases := map[string]struct {
+		method         string
+		acceptEncoding string
+		handler        http.Handler
+		compressed     bool
+	}{
+		"gzip":               {"GET", "gzip, deflate", bodyHandler, true},
+		"gzip with q":        {"GET", "deflate;q=1.0, gzip;q=0.5", bodyHandler, true},
+		"gzip not accepted":  {"GET", "gzip;q=0", bodyHandler, false},
+		"no accept encoding": {"GET", "", bodyHandler, false},
+		"head":               {"HEAD", "gzip", bodyHandler, false},
+		"no content":         {"GET", "gzip", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) }), false},
+		"already encoded": {"GET", "gzip", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Encoding", "br")
+			w.Write([]byte(body))
+		}), false},
+	}
+
+	for k, tc := range cases {
+		logger := log.New("", "", "")
+		hook := test.NewLocal(logger.Logger)
+
+		req := newRequest(tc.method, "http://example.com")
+		if tc.acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+		}
+		rec := httptest.NewRecorder()
+		StructuredLogHandler(logger, Compress(tc.handler)).ServeHTTP(rec, req)
+
+		assert.Equal(t, "Accept-Encoding", rec.Header().Get("Vary"), "test: %s", k)
+		assert.Equal(t, 1, len(hook.Entries), "test: %s", k)
+		assert.Equal(t, tc.compressed, hook.LastEntry().Data["http.compressed"], "test: %s", k)
+		ratio, ok := hook.LastEntry().Data["http.compression_ratio"]
+		assert.Equal(t, tc.compressed, ok, "test: %s", k)
+
+		if !tc.compressed {
+			assert.NotEqual(t, "gzip", rec.Header().Get("Content-Encoding"), "test: %s", k)
+			continue
+		}
+		assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"), "test: %s", k)
+		assert.Equal(t, "", rec.Header().Get("Content-Length"), "test: %s", k)
+		compressedSize := rec.Body.Len()
+		reader, err := gzip.NewReader(rec.Body)
+		if !assert.Nil(t, err, "test: %s", k) {
+			continue
+		}
+		decompressed, err := ioutil.ReadAll(reader)
+		assert.Nil(t, err, "test: %s", k)
+		assert.Equal(t, body, string(decompressed), "test: %s", k)
+		assert.Equal(t, float64(len(body))/float64(compressedSize), ratio, "test: %s", k)
+		assert.Equal(t, compressedSize, hook.LastEntry().Data["http.bytes"], "test: %s: the compressed size is logged", k)
+	}
+}
+
+func TestCompressContentType(t *testing.T) {
+	cases := map[string]struct {
+		handler     http.Handler
+		contentType string
+		encoding    string
+		body        string
+	}{
+		"detected from the uncompressed body": {
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("<html><body>hello</body></html>"))
+			}),
+			"text/html; charset=utf-8", "gzip", "<html><body>hello</body></html>",
+		},
+		"set by the handler": {
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte(`{"id":1}`))
+			}),
+			"application/json", "gzip", `{"id":1}`,
+		},
+		"empty body": {
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte{})
+			}),
+			"", "", "",
+		},
+	}
+
+	for k, tc := range cases {
+		req := newRequest("GET", "http://example.com")
+		req.Header.Set("Accept-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+		Compress(tc.handler).ServeHTTP(rec, req)
+
+		assert.Equal(t, tc.contentType, rec.Header().Get("Content-Type"), "test: %s", k)
+		assert.Equal(t, tc.encoding, rec.Header().Get("Content-Encoding"), "test: %s", k)
+		if tc.encoding == "" {
+			assert.Equal(t, 0, rec.Body.Len(), "test: %s an empty body is not wrapped in gzip", k)
+			continue
+		}
+		reader, err := gzip.NewReader(rec.Body)
+		if assert.Nil(t, err, "test: %s", k) {
+			decompressed, _ := ioutil.ReadAll(reader)
+			assert.Equal(t, tc.body, string(decompressed), "test: %s", k)
+		}
+	}
+}
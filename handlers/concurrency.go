@@ -0,0 +1,70 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/graze/golang-service/handlers/failure"
+	"github.com/graze/golang-service/log"
+)
+
+// shedRetryAfter is the number of seconds a client is asked to wait before retrying a shed request
+const shedRetryAfter = "1"
+
+// ConcurrencyLimitError is returned when a request is shed because too many requests are already being handled
+type ConcurrencyLimitError struct {
+	Max int
+}
+
+func (e *ConcurrencyLimitError) Error() string {
+	return fmt.Sprintf("the maximum of %d concurrent requests are already being handled", e.Max)
+}
+
+type concurrencyHandler struct {
+	running chan struct{}
+	onError failure.Handler
+	handler http.Handler
+}
+
+// ServeHTTP handles the request if there is a free slot, and sheds it otherwise
+func (h concurrencyHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	select {
+	case h.running <- struct{}{}:
+		defer func() { <-h.running }()
+		h.handler.ServeHTTP(w, req)
+	default:
+		addLogFields(req, log.KV{"http.shed": true})
+		w.Header().Set("Retry-After", shedRetryAfter)
+		h.onError.Handle(w, req, &ConcurrencyLimitError{cap(h.running)}, http.StatusServiceUnavailable)
+	}
+}
+
+// Concurrency returns a middleware that handles at most n requests at once
+//
+// Requests over the limit are not queued, onError is called with a *ConcurrencyLimitError and a status of 503, a
+// `Retry-After` header is set and `http.shed=true` is logged. Each handler wrapped by the middleware has its own limit,
+// so it can be applied to each route that needs protecting. The limit is for this instance only
+//
+// Usage:
+//  limit := handlers.Concurrency(10, failure.HandlerFunc(onError))
+//  r := mux.NewRouter()
+//  r.Handle("/report", limit(reportHandler))
+//  http.ListenAndServe(":1123", handlers.StructuredHandler(r))
+func Concurrency(n int, onError failure.Handler) func(h http.Handler) http.Handler {
+	if n < 1 {
+		n = 1
+	}
+	return func(h http.Handler) http.Handler {
+		return concurrencyHandler{make(chan struct{}, n), onError, h}
+	}
+}
@@ -0,0 +1,130 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/Sirupsen/logrus/hooks/test"
+	"github.com/graze/golang-service/handlers/failure"
+	"github.com/graze/golang-service/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConcurrency(t *testing.T) {
+	var handled error
+	onError := failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, status int) {
+		handled = err
+		w.WriteHeader(status)
+	})
+	started := &sync.WaitGroup{}
+	release := make(chan struct{})
+	handler := Concurrency(3, onError)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started.Done()
+		<-release
+	}))
+
+	done := &sync.WaitGroup{}
+	codes := make(chan int, 3)
+	for i := 0; i < 3; i++ {
+		started.Add(1)
+		done.Add(1)
+		go func() {
+			defer done.Done()
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, newRequest("GET", "http://example.com/report"))
+			codes <- rec.Code
+		}()
+	}
+	started.Wait()
+
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, newRequest("GET", "http://example.com/report"))
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code, "requests over the limit are shed")
+		assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+	}
+	if assert.IsType(t, &ConcurrencyLimitError{}, handled) {
+		assert.Equal(t, 3, handled.(*ConcurrencyLimitError).Max)
+	}
+
+	close(release)
+	done.Wait()
+	close(codes)
+	for code := range codes {
+		assert.Equal(t, http.StatusOK, code)
+	}
+
+	started.Add(1)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, newRequest("GET", "http://example.com/report"))
+	assert.Equal(t, http.StatusOK, rec.Code, "slots are freed once requests are done")
+}
+
+func TestConcurrencyPerHandler(t *testing.T) {
+	onError := failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, status int) {
+		w.WriteHeader(status)
+	})
+	limit := Concurrency(1, onError)
+	release := make(chan struct{})
+	started := make(chan struct{})
+	blocked := limit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))
+	other := limit(okHandler)
+
+	go blocked.ServeHTTP(httptest.NewRecorder(), newRequest("GET", "http://example.com/slow"))
+	<-started
+
+	rec := httptest.NewRecorder()
+	other.ServeHTTP(rec, newRequest("GET", "http://example.com/fast"))
+	assert.Equal(t, http.StatusOK, rec.Code, "each wrapped handler has its own limit")
+
+	rec = httptest.NewRecorder()
+	blocked.ServeHTTP(rec, newRequest("GET", "http://example.com/slow"))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	close(release)
+}
+
+func TestConcurrencyLogging(t *testing.T) {
+	logger := log.New("", "", "")
+	hook := test.NewLocal(logger.Logger)
+	onError := failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, status int) {
+		w.WriteHeader(status)
+	})
+	release := make(chan struct{})
+	started := make(chan struct{})
+	handler := StructuredLogHandler(logger, Concurrency(1, onError)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})))
+
+	done := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(httptest.NewRecorder(), newRequest("GET", "http://example.com/report"))
+		close(done)
+	}()
+	<-started
+
+	handler.ServeHTTP(httptest.NewRecorder(), newRequest("GET", "http://example.com/report"))
+	assert.Equal(t, 1, len(hook.Entries))
+	assert.Equal(t, http.StatusServiceUnavailable, hook.LastEntry().Data["http.status"])
+	assert.Equal(t, true, hook.LastEntry().Data["http.shed"])
+
+	close(release)
+	<-done
+	assert.Equal(t, 2, len(hook.Entries))
+	assert.NotContains(t, hook.LastEntry().Data, "http.shed")
+}
@@ -0,0 +1,81 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/graze/golang-service/handlers/failure"
+	"github.com/graze/golang-service/log"
+)
+
+// UnsupportedContentTypeError is returned when the body of a request has a content type that is not allowed
+type UnsupportedContentTypeError struct {
+	ContentType string
+}
+
+func (e *UnsupportedContentTypeError) Error() string {
+	return fmt.Sprintf("content type: %q is not supported", e.ContentType)
+}
+
+type contentTypeHandler struct {
+	types   map[string]bool
+	onError failure.Handler
+	handler http.Handler
+}
+
+// hasBody returns true if the request has a body, or may have one when the length is unknown
+func hasBody(req *http.Request) bool {
+	return req.Body != nil && req.ContentLength != 0
+}
+
+// ServeHTTP rejects requests with a body that is not one of the allowed content types
+func (h contentTypeHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if !hasBody(req) {
+		h.handler.ServeHTTP(w, req)
+		return
+	}
+	contentType := req.Header.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || !h.types[mediaType] {
+		log.Ctx(req.Context()).With(log.KV{
+			"tag":               "unsupported_content_type",
+			"http.method":       req.Method,
+			"http.content_type": contentType,
+			"http.status":       http.StatusUnsupportedMediaType,
+		}).Warnf("content type: %q is not supported", contentType)
+		h.onError.Handle(w, req, &UnsupportedContentTypeError{contentType}, http.StatusUnsupportedMediaType)
+		return
+	}
+	h.handler.ServeHTTP(w, req)
+}
+
+// RequireContentType returns a middleware that only allows request bodies with a `Content-Type` in types
+//
+// The media type is compared ignoring case and any parameters such as the charset. Requests without a body are passed
+// straight through. Other requests are logged at warning level using the global logger and onError is called with an
+// *UnsupportedContentTypeError and a status of 415
+//
+// Usage:
+//  requireType := handlers.RequireContentType(failure.HandlerFunc(onError), "application/json")
+//  http.ListenAndServe(":1123", handlers.StructuredHandler(requireType(r)))
+func RequireContentType(onError failure.Handler, types ...string) func(h http.Handler) http.Handler {
+	allowed := make(map[string]bool, len(types))
+	for _, t := range types {
+		allowed[strings.ToLower(t)] = true
+	}
+	return func(h http.Handler) http.Handler {
+		return contentTypeHandler{allowed, onError, h}
+	}
+}
@@ -0,0 +1,63 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/graze/golang-service/handlers/failure"
+	"github.com/graze/golang-service/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireContentType(t *testing.T) {
+	cases := map[string]struct {
+		request *http.Request
+		status  int
+	}{
+		"matching":            {bodyRequest("POST", "http://example.com", "application/json", `{}`), http.StatusOK},
+		"matching charset":    {bodyRequest("PUT", "http://example.com", "application/json; charset=utf-8", `{}`), http.StatusOK},
+		"matching case":       {bodyRequest("POST", "http://example.com", "Application/JSON", `{}`), http.StatusOK},
+		"second type":         {bodyRequest("POST", "http://example.com", "application/x-www-form-urlencoded", `a=b`), http.StatusOK},
+		"mismatching":         {bodyRequest("POST", "http://example.com", "text/plain", `{}`), http.StatusUnsupportedMediaType},
+		"missing":             {bodyRequest("POST", "http://example.com", "", `{}`), http.StatusUnsupportedMediaType},
+		"malformed":           {bodyRequest("POST", "http://example.com", "application/", `{}`), http.StatusUnsupportedMediaType},
+		"bodyless get":        {newRequest("GET", "http://example.com"), http.StatusOK},
+		"bodyless head":       {newRequest("HEAD", "http://example.com"), http.StatusOK},
+		"empty post":          {bodyRequest("POST", "http://example.com", "text/plain", ``), http.StatusOK},
+		"get with wrong body": {bodyRequest("GET", "http://example.com", "text/plain", `{}`), http.StatusUnsupportedMediaType},
+	}
+
+	hook, restore := globalHook()
+	defer restore()
+	onError := failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, status int) {
+		assert.IsType(t, &UnsupportedContentTypeError{}, err)
+		w.WriteHeader(status)
+	})
+	handler := RequireContentType(onError, "application/json", "application/x-www-form-urlencoded")(okHandler)
+
+	for k, tc := range cases {
+		hook.Reset()
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, tc.request)
+
+		assert.Equal(t, tc.status, rec.Code, "test: %s", k)
+		if tc.status == http.StatusOK {
+			assert.Equal(t, 0, len(hook.Entries), "test: %s", k)
+		} else {
+			assert.Equal(t, 1, len(hook.Entries), "test: %s", k)
+			assert.Equal(t, log.WarnLevel, hook.LastEntry().Level, "test: %s", k)
+			assert.Equal(t, http.StatusUnsupportedMediaType, hook.LastEntry().Data["http.status"], "test: %s", k)
+		}
+	}
+}
@@ -30,8 +30,9 @@ func (h logContextHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	if userIP, err := getUserIP(req); err == nil {
 		ip = userIP.String()
 	}
+	id := uuid.NewV4().String()
 	ctx := h.logger.Ctx(req.Context()).With(log.KV{
-		"transaction":     uuid.NewV4().String(),
+		"transaction":     id,
 		"http.method":     req.Method,
 		"http.protocol":   req.Proto,
 		"http.uri":        parseURI(req, url),
@@ -40,7 +41,7 @@ func (h logContextHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		"http.user":       ip,
 		"http.ref":        req.Referer(),
 		"http.user-agent": req.Header.Get("User-Agent"),
-	}).NewContext(req.Context())
+	}).NewContext(WithRequestID(req.Context(), id))
 	h.handler.ServeHTTP(w, req.WithContext(ctx))
 }
 
@@ -56,6 +57,8 @@ func (h logContextHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 //	http.ref		- http://google.com - referrer
 //	http.user-agent - The user agent of the user
 //  transaction     - unique uuid4 for this request
+//
+// The transaction is also stored as the request id, see: RequestID and RequestIDTransport
 func LoggingContextHandler(logger log.FieldLogger, h http.Handler) http.Handler {
 	return logContextHandler{logger.With(log.KV{}), h}
 }
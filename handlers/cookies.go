@@ -0,0 +1,49 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/graze/golang-service/log"
+)
+
+// WithCookieNames logs the number of cookies on the request as `http.cookie_count` and their names, in the order they
+// were sent and joined with `,`, as `http.cookie_names`, to debug sessions with missing or extra cookies
+//
+// The values of the cookies are never logged. Cookies named in denied are counted, but their names are not logged.
+// `http.cookie_names` is not logged if there are no names to log
+//
+// Usage:
+//  loggedRouter := handlers.StructuredHandler(r, handlers.WithCookieNames("internal_session"))
+func WithCookieNames(denied ...string) StructuredOption {
+	deny := make(map[string]bool, len(denied))
+	for _, name := range denied {
+		deny[name] = true
+	}
+	return func(h *structuredHandler) {
+		h.fields = append(h.fields, func(req *http.Request) log.KV {
+			cookies := req.Cookies()
+			fields := log.KV{"http.cookie_count": len(cookies)}
+			names := make([]string, 0, len(cookies))
+			for _, cookie := range cookies {
+				if !deny[cookie.Name] {
+					names = append(names, cookie.Name)
+				}
+			}
+			if len(names) > 0 {
+				fields["http.cookie_names"] = strings.Join(names, ",")
+			}
+			return fields
+		})
+	}
+}
@@ -0,0 +1,60 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Sirupsen/logrus/hooks/test"
+	"github.com/graze/golang-service/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithCookieNames(t *testing.T) {
+	cases := map[string]struct {
+		cookie string
+		denied []string
+		count  int
+		names  string
+	}{
+		"cookies":    {"session=s3cret; theme=dark", nil, 2, "session,theme"},
+		"denied":     {"session=s3cret; theme=dark", []string{"session"}, 2, "theme"},
+		"all denied": {"session=s3cret", []string{"session"}, 1, ""},
+		"no cookies": {"", nil, 0, ""},
+	}
+
+	for k, tc := range cases {
+		logger := log.New("", "", "")
+		hook := test.NewLocal(logger.Logger)
+
+		req := newRequest("GET", "http://example.com")
+		if tc.cookie != "" {
+			req.Header.Set("Cookie", tc.cookie)
+		}
+		StructuredLogHandler(logger, okHandler, WithCookieNames(tc.denied...)).ServeHTTP(httptest.NewRecorder(), req)
+
+		if assert.Equal(t, 1, len(hook.Entries), "test: %s", k) {
+			data := hook.LastEntry().Data
+			assert.Equal(t, tc.count, data["http.cookie_count"], "test: %s", k)
+			if tc.names == "" {
+				assert.NotContains(t, data, "http.cookie_names", "test: %s", k)
+			} else {
+				assert.Equal(t, tc.names, data["http.cookie_names"], "test: %s", k)
+			}
+			for field, value := range data {
+				assert.NotContains(t, fmt.Sprint(value), "s3cret", "test: %s the value of %s contains a cookie value", k, field)
+				assert.NotContains(t, fmt.Sprint(value), "dark", "test: %s the value of %s contains a cookie value", k, field)
+			}
+		}
+	}
+}
@@ -0,0 +1,170 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-go/statsd"
+)
+
+// tagCount is the number of requests with a set of tags
+type tagCount struct {
+	tags []string
+	n    int64
+}
+
+// DefaultCountGaugeInterval is the interval used by WithCountGauge when the interval is not positive, matching the
+// flush interval of the Datadog agent
+const DefaultCountGaugeInterval = 10 * time.Second
+
+// countAggregator counts requests for each set of tags, sending the counts as gauges once per interval
+type countAggregator struct {
+	interval time.Duration
+	stop     chan struct{}
+	stopOnce sync.Once
+
+	mu     sync.Mutex
+	counts map[string]*tagCount
+}
+
+// add counts a request with tags
+func (a *countAggregator) add(tags []string) {
+	key := strings.Join(tags, ",")
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	c, ok := a.counts[key]
+	if !ok {
+		c = &tagCount{tags: tags}
+		a.counts[key] = c
+	}
+	c.n++
+}
+
+// flush sends the count for each set of tags since the last flush as the `request.count` gauge to each client
+//
+// Tags without any requests since the last flush are sent as 0 once and then forgotten
+func (a *countAggregator) flush(clients []*statsd.Client) {
+	a.mu.Lock()
+	counts := make([]tagCount, 0, len(a.counts))
+	for key, c := range a.counts {
+		counts = append(counts, *c)
+		if c.n == 0 {
+			delete(a.counts, key)
+		}
+		c.n = 0
+	}
+	a.mu.Unlock()
+
+	for _, c := range counts {
+		for _, client := range clients {
+			client.Gauge("request.count", float64(c.n), c.tags, 1)
+		}
+	}
+}
+
+// start flushes the counts to clients every interval until close is called
+func (a *countAggregator) start(clients []*statsd.Client) {
+	ticker := time.NewTicker(a.interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				a.flush(clients)
+			case <-a.stop:
+				a.flush(clients)
+				return
+			}
+		}
+	}()
+}
+
+// close stops flushing the counts, after sending the counts for the current interval
+func (a *countAggregator) close() {
+	a.stopOnce.Do(func() {
+		close(a.stop)
+	})
+}
+
+// WithCountSampleRate sends the `request.count` counter with a sample rate, so only that fraction of requests are sent
+// and Datadog scales the count back up using the rate. This reduces the number of packets sent for busy services at
+// the cost of accuracy at low volumes. rate should be between 0 and 1
+//
+// Usage:
+//  loggedRouter := handlers.StatsdIoHandler(client, r, handlers.WithCountSampleRate(0.1))
+func WithCountSampleRate(rate float64) StatsdOption {
+	return func(h *statsdHandler) {
+		if rate > 0 && rate <= 1 {
+			h.countRate = rate
+		}
+	}
+}
+
+// WithCountGauge sends `request.count` as a gauge of the number of requests in each interval for each set of tags,
+// rather than a counter for each request. Only one packet is sent per interval for each set of tags, but the gauge is a
+// requests-per-interval value rather than a count, so interval should match the flush interval of the statsd agent
+// (DefaultCountGaugeInterval is used if it is not positive)
+//
+// The counts are sent from a background goroutine, which is stopped by closing the handler (it is an io.Closer). The
+// counts for the current interval are sent when it is closed, so it should be closed when the server shuts down
+//
+// Usage:
+//  loggedRouter := handlers.StatsdIoHandler(client, r, handlers.WithCountGauge(10*time.Second))
+//  defer loggedRouter.(io.Closer).Close()
+func WithCountGauge(interval time.Duration) StatsdOption {
+	if interval <= 0 {
+		interval = DefaultCountGaugeInterval
+	}
+	return func(h *statsdHandler) {
+		h.counts = &countAggregator{interval: interval, stop: make(chan struct{}), counts: map[string]*tagCount{}}
+	}
+}
+
+// WithStatusClassCounts also sends a counter without any tags for the class of each response status, as
+// `request.1xx` to `request.5xx`, for backends where metric names are cheaper than tags. The tagged `request.count` is
+// still sent unless WithoutTaggedCount is also used
+//
+// Usage:
+//  loggedRouter := handlers.StatsdIoHandler(client, r, handlers.WithStatusClassCounts(), handlers.WithoutTaggedCount())
+func WithStatusClassCounts() StatsdOption {
+	return func(h *statsdHandler) {
+		h.classes = true
+	}
+}
+
+// WithoutTaggedCount stops the `request.count` counter being sent, such as when WithStatusClassCounts is used instead.
+// It replaces any earlier WithCountSampleRate or WithCountGauge option
+//
+// Usage:
+//  loggedRouter := handlers.StatsdIoHandler(client, r, handlers.WithStatusClassCounts(), handlers.WithoutTaggedCount())
+func WithoutTaggedCount() StatsdOption {
+	return func(h *statsdHandler) {
+		h.countRate = 0
+		h.counts = nil
+	}
+}
+
+// statusClass returns the class of status, such as `2xx`, or an empty string if it is not a valid status. A status of 0
+// is a 200, as that is sent when the handler did not write anything
+func statusClass(status int) string {
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if status < 100 || status > 599 {
+		return ""
+	}
+	return strconv.Itoa(status/100) + "xx"
+}
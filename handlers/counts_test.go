@@ -0,0 +1,160 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-go/statsd"
+	"github.com/graze/golang-service/nettest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatsdCountModes(t *testing.T) {
+	tags := "#endpoint:/,statusCode:200,method:GET,protocol:HTTP/1.1"
+
+	cases := map[string]struct {
+		opts     []StatsdOption
+		expected string
+	}{
+		"counter":      {[]StatsdOption{}, "request.count:1|c|" + tags},
+		"sample rate":  {[]StatsdOption{WithCountSampleRate(0.999999)}, "request.count:1|c|@0.999999|" + tags},
+		"invalid rate": {[]StatsdOption{WithCountSampleRate(2)}, "request.count:1|c|" + tags},
+	}
+
+	done := make(chan string)
+	addr, sock, srvWg := nettest.CreateServer(t, "udp", "localhost:", done)
+	defer srvWg.Wait()
+	defer os.Remove(addr.String())
+	defer sock.Close()
+
+	client, err := statsd.New(addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for k, tc := range cases {
+		StatsdIoHandler(client, okHandler, tc.opts...).ServeHTTP(httptest.NewRecorder(), newRequest("GET", "http://example.com"))
+
+		assert.Regexp(t, `^request\.response_time:[0-9.]+\|ms\|`+regexp.QuoteMeta(tags)+`$`, <-done, "test: %s", k)
+		assert.Equal(t, tc.expected, <-done, "test: %s", k)
+	}
+}
+
+func TestStatsdCountGauge(t *testing.T) {
+	tags := "#endpoint:/,statusCode:200,method:GET,protocol:HTTP/1.1"
+
+	done := make(chan string)
+	addr, sock, srvWg := nettest.CreateServer(t, "udp", "localhost:", done)
+	defer srvWg.Wait()
+	defer os.Remove(addr.String())
+	defer sock.Close()
+
+	client, err := statsd.New(addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := StatsdIoHandler(client, okHandler, WithCountGauge(time.Hour))
+	defer handler.(io.Closer).Close()
+	for i := 0; i < 3; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), newRequest("GET", "http://example.com"))
+		assert.Regexp(t, `^request\.response_time:[0-9.]+\|ms\|`+regexp.QuoteMeta(tags)+`$`, <-done, "the count is not sent per request")
+	}
+
+	counts := handler.(statsdHandler).counts
+	counts.flush([]*statsd.Client{client})
+	assert.Equal(t, "request.count:3.000000|g|"+tags, <-done)
+
+	counts.flush([]*statsd.Client{client})
+	assert.Equal(t, "request.count:0.000000|g|"+tags, <-done, "an interval without requests is sent as 0")
+
+	assert.Empty(t, counts.counts, "idle tags are forgotten after sending 0")
+}
+
+func TestStatsdCountGaugeClose(t *testing.T) {
+	done := make(chan string)
+	addr, sock, srvWg := nettest.CreateServer(t, "udp", "localhost:", done)
+	defer srvWg.Wait()
+	defer os.Remove(addr.String())
+	defer sock.Close()
+
+	client, err := statsd.New(addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags := "#endpoint:/,statusCode:200,method:GET,protocol:HTTP/1.1"
+
+	handler := StatsdIoHandler(client, okHandler, WithCountGauge(time.Hour))
+	for i := 0; i < 2; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), newRequest("GET", "http://example.com"))
+		<-done
+	}
+
+	assert.Nil(t, handler.(io.Closer).Close())
+	assert.Equal(t, "request.count:2.000000|g|"+tags, <-done, "the current interval is sent when closed")
+	assert.Nil(t, handler.(io.Closer).Close(), "closing twice does nothing")
+
+	defaulted := StatsdIoHandler(client, okHandler, WithCountGauge(0))
+	assert.Equal(t, DefaultCountGaugeInterval, defaulted.(statsdHandler).counts.interval)
+	assert.Nil(t, defaulted.(io.Closer).Close())
+	assert.Nil(t, StatsdIoHandler(client, okHandler).(io.Closer).Close(), "a handler without a gauge can be closed")
+}
+
+func TestStatsdStatusClassCounts(t *testing.T) {
+	done := make(chan string)
+	addr, sock, srvWg := nettest.CreateServer(t, "udp", "localhost:", done)
+	defer srvWg.Wait()
+	defer os.Remove(addr.String())
+	defer sock.Close()
+
+	client, err := statsd.New(addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		status  int
+		counter string
+	}{
+		"nothing written": {0, "request.2xx:1|c"},
+		"ok":              {http.StatusOK, "request.2xx:1|c"},
+		"redirect":        {http.StatusMovedPermanently, "request.3xx:1|c"},
+		"not found":       {http.StatusNotFound, "request.4xx:1|c"},
+		"error":           {http.StatusInternalServerError, "request.5xx:1|c"},
+	}
+
+	for k, tc := range cases {
+		status := tc.status
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if status != 0 {
+				w.WriteHeader(status)
+			}
+		})
+		tags := fmt.Sprintf("#endpoint:/,statusCode:%d,method:GET,protocol:HTTP/1.1", status)
+
+		StatsdIoHandler(client, h, WithStatusClassCounts()).ServeHTTP(httptest.NewRecorder(), newRequest("GET", "http://example.com"))
+		assert.Regexp(t, `^request\.response_time:`, <-done, "test: %s", k)
+		assert.Equal(t, "request.count:1|c|"+tags, <-done, "test: %s the tagged count is still sent", k)
+		assert.Equal(t, tc.counter, <-done, "test: %s", k)
+
+		StatsdIoHandler(client, h, WithStatusClassCounts(), WithoutTaggedCount()).ServeHTTP(httptest.NewRecorder(), newRequest("GET", "http://example.com"))
+		assert.Regexp(t, `^request\.response_time:`, <-done, "test: %s", k)
+		assert.Equal(t, tc.counter, <-done, "test: %s instead of the tagged count", k)
+	}
+}
@@ -0,0 +1,161 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/graze/golang-service/handlers/failure"
+	"github.com/graze/golang-service/log"
+)
+
+// DefaultMaxDecompressedBytes is the largest decompressed request body read by Decompress when no size is given
+const DefaultMaxDecompressedBytes = 10 << 20
+
+// DecompressError is returned when a request body can not be decompressed using its Content-Encoding
+type DecompressError struct {
+	encoding string
+	err      error
+}
+
+func (e *DecompressError) Error() string {
+	return fmt.Sprintf("request body is not valid %s: %s", e.encoding, e.err.Error())
+}
+
+// DecompressTooLargeError is returned when reading a request body that decompresses to more than the maximum size
+type DecompressTooLargeError struct {
+	max int64
+}
+
+func (e *DecompressTooLargeError) Error() string {
+	return fmt.Sprintf("decompressed request body is larger than %d bytes", e.max)
+}
+
+// MaxBytes returns the largest decompressed body that is allowed
+func (e *DecompressTooLargeError) MaxBytes() int64 {
+	return e.max
+}
+
+// decompressReader reads the decompressed body, and closes both the decompressor and the original body
+type decompressReader struct {
+	io.Reader
+	decompressor io.Closer
+	body         io.Closer
+}
+
+func (r decompressReader) Close() error {
+	r.decompressor.Close()
+	return r.body.Close()
+}
+
+// newDecompressor returns a reader of the decompressed body for the encoding, or false if it is not supported
+func newDecompressor(encoding string, body io.Reader) (io.ReadCloser, bool, error) {
+	switch encoding {
+	case "gzip", "x-gzip":
+		r, err := gzip.NewReader(body)
+		return r, true, err
+	case "deflate":
+		r, err := zlib.NewReader(body)
+		return r, true, err
+	}
+	return nil, false, nil
+}
+
+type decompressHandler struct {
+	maxBytes int64
+	onError  failure.Handler
+	handler  http.Handler
+}
+
+// DecompressOption changes the behaviour of the Decompress middleware
+type DecompressOption func(h *decompressHandler)
+
+// WithMaxDecompressedBytes sets the largest decompressed body that can be read (default: DefaultMaxDecompressedBytes)
+func WithMaxDecompressedBytes(n int64) DecompressOption {
+	return func(h *decompressHandler) {
+		if n > 0 {
+			h.maxBytes = n
+		}
+	}
+}
+
+// ServeHTTP replaces a gzip or deflate encoded request body with the decompressed body
+func (h decompressHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	encoding := strings.ToLower(strings.TrimSpace(req.Header.Get("Content-Encoding")))
+	if req.Body == nil || encoding == "" || encoding == "identity" {
+		h.handler.ServeHTTP(w, req)
+		return
+	}
+
+	reader, ok, err := newDecompressor(encoding, req.Body)
+	if !ok {
+		h.handler.ServeHTTP(w, req)
+		return
+	}
+	if err != nil {
+		addLogFields(req, log.KV{"http.decompress_invalid": true})
+		h.onError.Handle(w, req, &DecompressError{encoding, err}, http.StatusBadRequest)
+		return
+	}
+
+	tooLarge := &DecompressTooLargeError{h.maxBytes}
+	limited := &limitedReader{Reader: reader, n: h.maxBytes, err: tooLarge}
+	body := &countingReader{ReadCloser: decompressReader{limited, reader, req.Body}}
+	setDecompressedBody(req, body)
+
+	out := new(http.Request)
+	*out = *req
+	out.Body = body
+	out.ContentLength = -1
+	out.Header = make(http.Header, len(req.Header))
+	for k, v := range req.Header {
+		out.Header[k] = v
+	}
+	out.Header.Del("Content-Encoding")
+	out.Header.Del("Content-Length")
+	logger := MakeLogger(w)
+	h.handler.ServeHTTP(logger, out)
+
+	if limited.over {
+		addLogFields(req, log.KV{"http.decompress_too_large": true})
+		// the handler sees the error reading the body, if it did not respond the error is handled here
+		if logger.Status() == 0 {
+			h.onError.Handle(logger, req, tooLarge, http.StatusRequestEntityTooLarge)
+		}
+	}
+}
+
+// Decompress returns a handler that decompresses request bodies with a `Content-Encoding` of gzip or deflate, so
+// the handler reads the decompressed body. Bodies that can not be decompressed call onError with a *DecompressError
+// and a status of 400. Other encodings are passed through unchanged
+//
+// To protect against decompression bombs, reading more than DefaultMaxDecompressedBytes (or WithMaxDecompressedBytes)
+// from the decompressed body returns a *DecompressTooLargeError to the handler and logs
+// `http.decompress_too_large=true`. If the handler does not write a response, onError is called with the error and a
+// status of 413
+//
+// Usage:
+//  decompress := handlers.Decompress(failure.HandlerFunc(onError), handlers.WithMaxDecompressedBytes(1<<20))
+//  http.ListenAndServe(":1123", handlers.StructuredHandler(decompress(r), handlers.WithRequestBodySizes()))
+func Decompress(onError failure.Handler, opts ...DecompressOption) func(h http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		handler := &decompressHandler{maxBytes: DefaultMaxDecompressedBytes, onError: onError, handler: h}
+		for _, opt := range opts {
+			opt(handler)
+		}
+		return handler
+	}
+}
@@ -0,0 +1,178 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Sirupsen/logrus/hooks/test"
+	"github.com/graze/golang-service/handlers/failure"
+	"github.com/graze/golang-service/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func gzipBody(t *testing.T, body string) []byte {
+	buf := &bytes.Buffer{}
+	w := gzip.NewWriter(buf)
+	if _, err := w.Write([]byte(body)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecompress(t *testing.T) {
+	body := strings.Repeat(`{"some":"json"}`, 100)
+	compressed := gzipBody(t, body)
+
+	cases := map[string]struct {
+		encoding string
+		body     []byte
+		status   int
+		expected string
+	}{
+		"gzip":           {"gzip", compressed, http.StatusOK, body},
+		"not encoded":    {"", []byte(body), http.StatusOK, body},
+		"unknown":        {"br", []byte("brotli"), http.StatusOK, "brotli"},
+		"malformed gzip": {"gzip", []byte("not gzip"), http.StatusBadRequest, ""},
+	}
+
+	for k, tc := range cases {
+		var read string
+		var encoding string
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, err := ioutil.ReadAll(r.Body)
+			assert.Nil(t, err, "test: %s", k)
+			read = string(b)
+			encoding = r.Header.Get("Content-Encoding")
+		})
+		decompress := Decompress(failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, status int) {
+			assert.IsType(t, &DecompressError{}, err, "test: %s", k)
+			w.WriteHeader(status)
+		}))
+
+		req := bodyRequest("POST", "http://example.com", "application/json", string(tc.body))
+		if tc.encoding != "" {
+			req.Header.Set("Content-Encoding", tc.encoding)
+		}
+		rec := httptest.NewRecorder()
+		decompress(handler).ServeHTTP(rec, req)
+
+		assert.Equal(t, tc.status, rec.Code, "test: %s", k)
+		assert.Equal(t, tc.expected, read, "test: %s", k)
+		if tc.status == http.StatusOK && tc.encoding == "gzip" {
+			assert.Equal(t, "", encoding, "test: %s", k)
+		}
+	}
+}
+
+func TestWithRequestBodySizes(t *testing.T) {
+	body := strings.Repeat(`{"some":"json"}`, 100)
+	compressed := gzipBody(t, body)
+
+	cases := map[string]struct {
+		encoding string
+		body     []byte
+		wire     int64
+		bytes    int64
+	}{
+		"gzip":        {"gzip", compressed, int64(len(compressed)), int64(len(body))},
+		"not encoded": {"", []byte(body), int64(len(body)), int64(len(body))},
+	}
+
+	for k, tc := range cases {
+		logger := log.New("", "", "")
+		hook := test.NewLocal(logger.Logger)
+
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ioutil.ReadAll(r.Body)
+		})
+		decompress := Decompress(failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, status int) {
+			t.Errorf("onError handler called. Err: %s", err)
+		}))
+
+		req := bodyRequest("POST", "http://example.com", "application/json", string(tc.body))
+		if tc.encoding != "" {
+			req.Header.Set("Content-Encoding", tc.encoding)
+		}
+		StructuredLogHandler(logger, decompress(handler), WithRequestBodySizes()).ServeHTTP(httptest.NewRecorder(), req)
+
+		assert.Equal(t, 1, len(hook.Entries), "test: %s", k)
+		assert.Equal(t, tc.wire, hook.LastEntry().Data["http.request_wire_bytes"], "test: %s", k)
+		assert.Equal(t, tc.bytes, hook.LastEntry().Data["http.request_bytes"], "test: %s", k)
+	}
+}
+
+func TestDecompressMaxBytes(t *testing.T) {
+	bomb := gzipBody(t, strings.Repeat("0", 100000))
+
+	cases := map[string]struct {
+		max      int64
+		respond  bool
+		status   int
+		tooLarge bool
+	}{
+		"under the limit":          {100000, false, http.StatusOK, false},
+		"over the limit":           {1000, false, http.StatusRequestEntityTooLarge, true},
+		"handler responds":         {1000, true, http.StatusBadRequest, true},
+		"default limit is not hit": {0, false, http.StatusOK, false},
+	}
+
+	logger := log.New("", "", "")
+	hook := test.NewLocal(logger.Logger)
+
+	for k, tc := range cases {
+		hook.Reset()
+		var readErr, handled error
+		var read int
+		respond := tc.respond
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, err := ioutil.ReadAll(r.Body)
+			read, readErr = len(b), err
+			if err != nil && respond {
+				w.WriteHeader(http.StatusBadRequest)
+			}
+		})
+		onError := failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, status int) {
+			handled = err
+			w.WriteHeader(status)
+		})
+
+		req := bodyRequest("POST", "http://example.com", "text/plain", string(bomb))
+		req.Header.Set("Content-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+		StructuredLogHandler(logger, Decompress(onError, WithMaxDecompressedBytes(tc.max))(handler)).ServeHTTP(rec, req)
+
+		assert.Equal(t, tc.status, rec.Code, "test: %s", k)
+		if !tc.tooLarge {
+			assert.Nil(t, readErr, "test: %s", k)
+			assert.Equal(t, 100000, read, "test: %s", k)
+			assert.NotContains(t, hook.LastEntry().Data, "http.decompress_too_large", "test: %s", k)
+			continue
+		}
+		assert.IsType(t, &DecompressTooLargeError{}, readErr, "test: %s the handler sees the error", k)
+		assert.Equal(t, int(tc.max), read, "test: %s the body stops at the limit", k)
+		assert.Equal(t, true, hook.LastEntry().Data["http.decompress_too_large"], "test: %s", k)
+		if tc.respond {
+			assert.Nil(t, handled, "test: %s onError is not called when the handler responded", k)
+		} else if assert.IsType(t, &DecompressTooLargeError{}, handled, "test: %s", k) {
+			assert.Equal(t, tc.max, handled.(*DecompressTooLargeError).MaxBytes(), "test: %s", k)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/graze/golang-service/handlers/failure"
+	"github.com/graze/golang-service/log"
+)
+
+// statusError is an error that should be returned with a specific http status
+type statusError interface {
+	error
+	Status() int
+}
+
+type errorHandler struct {
+	onError failure.Handler
+	fn      func(w http.ResponseWriter, req *http.Request) error
+}
+
+// ServeHTTP calls fn, logging any returned error and passing it to onError
+func (h errorHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	logger := MakeLogger(w)
+	err := h.fn(logger, req)
+	if err == nil {
+		return
+	}
+	addLogFields(req, log.KV{"http.error": err.Error()})
+	if logger.Status() != 0 {
+		// the response has already been started, so it is too late to respond with the error
+		return
+	}
+	status := http.StatusInternalServerError
+	if statusErr, ok := err.(statusError); ok {
+		status = statusErr.Status()
+	}
+	h.onError.Handle(logger, req, err, status)
+}
+
+// ErrorHandlerFunc returns a http.Handler for a handler func that returns an error
+//
+// A returned error is logged by the structured log handler as `http.error` and onError is called with it and a status
+// of 500, or the status of the error if it has a `Status() int` method. If fn has already written a response, the error
+// is only logged
+//
+// Usage:
+//  r.Handle("/users/{id}", handlers.ErrorHandlerFunc(failure.HandlerFunc(onError), func(w http.ResponseWriter, r *http.Request) error {
+//      user, err := users.Get(mux.Vars(r)["id"])
+//      if err != nil {
+//          return err
+//      }
+//      return json.NewEncoder(w).Encode(user)
+//  }))
+//  http.ListenAndServe(":1123", handlers.StructuredHandler(r))
+func ErrorHandlerFunc(onError failure.Handler, fn func(w http.ResponseWriter, req *http.Request) error) http.Handler {
+	return errorHandler{onError, fn}
+}
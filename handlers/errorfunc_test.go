@@ -0,0 +1,101 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Sirupsen/logrus/hooks/test"
+	"github.com/graze/golang-service/handlers/failure"
+	"github.com/graze/golang-service/log"
+	"github.com/stretchr/testify/assert"
+)
+
+type notFoundError struct{}
+
+func (e *notFoundError) Error() string {
+	return "not found"
+}
+
+func (e *notFoundError) Status() int {
+	return http.StatusNotFound
+}
+
+func TestErrorHandlerFunc(t *testing.T) {
+	cases := map[string]struct {
+		fn     func(w http.ResponseWriter, r *http.Request) error
+		status int
+		body   string
+		err    interface{}
+	}{
+		"nil": {
+			func(w http.ResponseWriter, r *http.Request) error {
+				fmt.Fprintln(w, "ok")
+				return nil
+			},
+			http.StatusOK,
+			"ok\n",
+			nil,
+		},
+		"error": {
+			func(w http.ResponseWriter, r *http.Request) error {
+				return errors.New("database unavailable")
+			},
+			http.StatusInternalServerError,
+			"error: database unavailable\n",
+			"database unavailable",
+		},
+		"status error": {
+			func(w http.ResponseWriter, r *http.Request) error {
+				return &notFoundError{}
+			},
+			http.StatusNotFound,
+			"error: not found\n",
+			"not found",
+		},
+		"already written": {
+			func(w http.ResponseWriter, r *http.Request) error {
+				fmt.Fprintln(w, "partial")
+				return errors.New("write failed")
+			},
+			http.StatusOK,
+			"partial\n",
+			"write failed",
+		},
+	}
+
+	onError := failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, status int) {
+		w.WriteHeader(status)
+		fmt.Fprintf(w, "error: %s\n", err)
+	})
+
+	for k, tc := range cases {
+		logger := log.New("", "", "")
+		hook := test.NewLocal(logger.Logger)
+
+		rec := httptest.NewRecorder()
+		StructuredLogHandler(logger, ErrorHandlerFunc(onError, tc.fn)).ServeHTTP(rec, newRequest("GET", "http://example.com"))
+
+		assert.Equal(t, tc.status, rec.Code, "test: %s", k)
+		assert.Equal(t, tc.body, rec.Body.String(), "test: %s", k)
+		assert.Equal(t, 1, len(hook.Entries), "test: %s", k)
+		assert.Equal(t, tc.status, hook.LastEntry().Data["http.status"], "test: %s", k)
+		if tc.err == nil {
+			assert.NotContains(t, hook.LastEntry().Data, "http.error", "test: %s", k)
+		} else {
+			assert.Equal(t, tc.err, hook.LastEntry().Data["http.error"], "test: %s", k)
+		}
+	}
+}
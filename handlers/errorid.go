@@ -0,0 +1,77 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/graze/golang-service/handlers/failure"
+	"github.com/graze/golang-service/log"
+)
+
+// InternalError is passed to the error handler in place of a server error, so the client only sees a generic message
+// and an id that can be found in the logs
+type InternalError struct {
+	ID string
+}
+
+func (e *InternalError) Error() string {
+	return fmt.Sprintf("internal server error, error id: %s", e.ID)
+}
+
+// newErrorID returns a short random id for an error
+func newErrorID() string {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// ErrorIDs returns a failure.Handler that gives each server error (a status of 500 or above) a short id before calling
+// onError with an *InternalError containing only the id
+//
+// The original error, id and stack trace are logged at the error level using the global logger with the tag
+// `internal_error`, and the id is added to the structured request log as `http.error_id`, so support can find the
+// error from the id given to the client. Other errors are passed to onError unchanged
+//
+// Usage:
+//  onError := handlers.ErrorIDs(failure.HandlerFunc(writeError))
+//  recoverer := handlers.Recover(onError)
+//  r.Handle("/users", handlers.ErrorHandlerFunc(onError, listUsers))
+//  http.ListenAndServe(":1123", handlers.StructuredHandler(recoverer(r)))
+func ErrorIDs(onError failure.Handler) failure.Handler {
+	return failure.HandlerFunc(func(w http.ResponseWriter, req *http.Request, err error, status int) {
+		if status < http.StatusInternalServerError {
+			onError.Handle(w, req, err, status)
+			return
+		}
+
+		id := newErrorID()
+		addLogFields(req, log.KV{"http.error_id": id})
+		fields := log.KV{
+			"tag":      "internal_error",
+			"error_id": id,
+			"stack":    string(debug.Stack()),
+			"status":   status,
+		}
+		if panicErr, ok := err.(*PanicError); ok {
+			fields["panic"] = fmt.Sprintf("%v", panicErr.value)
+		}
+		log.Ctx(req.Context()).With(fields).Err(err).Errorf("internal error: %s", id)
+
+		onError.Handle(w, req, &InternalError{id}, status)
+	})
+}
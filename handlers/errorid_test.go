@@ -0,0 +1,93 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/Sirupsen/logrus/hooks/test"
+	"github.com/graze/golang-service/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorIDs(t *testing.T) {
+	onError := ErrorIDs(echoRecoverer)
+
+	cases := map[string]struct {
+		handler http.Handler
+		panic   interface{}
+	}{
+		"panic": {
+			Recover(onError)(panicHandler),
+			"secret database password",
+		},
+		"returned error": {
+			ErrorHandlerFunc(onError, func(w http.ResponseWriter, r *http.Request) error {
+				return errors.New("secret database password")
+			}),
+			nil,
+		},
+	}
+
+	hook, restore := globalHook()
+	defer restore()
+	for k, tc := range cases {
+		hook.Reset()
+		rec := httptest.NewRecorder()
+		tc.handler.ServeHTTP(rec, newRequest("GET", "http://example.com"))
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code, "test: %s", k)
+		matches := regexp.MustCompile(`^internal server error, error id: ([0-9a-f]{12})$`).FindStringSubmatch(rec.Body.String())
+		if !assert.Equal(t, 2, len(matches), "test: %s body: %s", k, rec.Body.String()) {
+			continue
+		}
+		id := matches[1]
+
+		entry := hook.LastEntry()
+		assert.Equal(t, log.ErrorLevel, entry.Level, "test: %s", k)
+		assert.Equal(t, "internal_error", entry.Data["tag"], "test: %s", k)
+		assert.Equal(t, id, entry.Data["error_id"], "test: %s: the same id is logged", k)
+		assert.Contains(t, entry.Message, id, "test: %s", k)
+		assert.Contains(t, entry.Data["stack"], "errorid.go", "test: %s", k)
+		if tc.panic != nil {
+			assert.Equal(t, tc.panic, entry.Data["panic"], "test: %s", k)
+		} else {
+			assert.Equal(t, "secret database password", entry.Data["error"].(error).Error(), "test: %s", k)
+		}
+	}
+}
+
+func TestErrorIDsPassesClientErrors(t *testing.T) {
+	hook, restore := globalHook()
+	defer restore()
+	hook.Reset()
+	rec := httptest.NewRecorder()
+	ErrorIDs(echoRecoverer).Handle(rec, newRequest("GET", "http://example.com"), errors.New("bad request"), http.StatusBadRequest)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "bad request", rec.Body.String())
+	assert.Equal(t, 0, len(hook.Entries))
+}
+
+func TestErrorIDsAreLoggedWithTheRequest(t *testing.T) {
+	logger := log.New("", "", "")
+	hook := test.NewLocal(logger.Logger)
+
+	rec := httptest.NewRecorder()
+	StructuredLogHandler(logger, Recover(ErrorIDs(echoRecoverer))(panicHandler)).ServeHTTP(rec, newRequest("GET", "http://example.com"))
+
+	id := regexp.MustCompile(`[0-9a-f]{12}$`).FindString(rec.Body.String())
+	assert.Equal(t, id, hook.LastEntry().Data["http.error_id"])
+}
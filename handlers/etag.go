@@ -0,0 +1,160 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// DefaultETagMaxSize is the largest response body buffered by ETag when no size is given
+const DefaultETagMaxSize = 1 << 20
+
+// etagWriter buffers a successful response so an ETag can be generated from the body
+//
+// Once the body is larger than max, or the handler flushes, the buffer is written and the rest of the response is
+// passed straight through without an ETag
+type etagWriter struct {
+	w           http.ResponseWriter
+	max         int
+	status      int
+	buf         bytes.Buffer
+	passthrough bool
+}
+
+func (e *etagWriter) Header() http.Header {
+	return e.w.Header()
+}
+
+func (e *etagWriter) WriteHeader(status int) {
+	if e.passthrough || e.status != 0 {
+		return
+	}
+	if status != http.StatusOK {
+		e.passthrough = true
+		e.w.WriteHeader(status)
+		return
+	}
+	e.status = status
+}
+
+func (e *etagWriter) Write(b []byte) (int, error) {
+	if e.passthrough {
+		return e.w.Write(b)
+	}
+	if e.status == 0 {
+		e.status = http.StatusOK
+	}
+	if e.buf.Len()+len(b) > e.max {
+		if err := e.flushBuffer(); err != nil {
+			return 0, err
+		}
+		return e.w.Write(b)
+	}
+	return e.buf.Write(b)
+}
+
+// Flush writes anything buffered and passes the rest of the response through, as the handler is streaming
+func (e *etagWriter) Flush() {
+	if !e.passthrough {
+		e.flushBuffer()
+	}
+	if f, ok := e.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// flushBuffer writes the status and buffered body without an ETag and switches to passing writes through
+func (e *etagWriter) flushBuffer() error {
+	e.passthrough = true
+	if e.status != 0 {
+		e.w.WriteHeader(e.status)
+	}
+	_, err := e.w.Write(e.buf.Bytes())
+	e.buf.Reset()
+	return err
+}
+
+// finish writes the buffered response with an ETag, or a 304 if the request's If-None-Match matches
+func (e *etagWriter) finish(req *http.Request) {
+	if e.passthrough || e.status == 0 {
+		return
+	}
+
+	etag := e.w.Header().Get("ETag")
+	if etag == "" {
+		sum := sha1.Sum(e.buf.Bytes())
+		etag = `"` + hex.EncodeToString(sum[:]) + `"`
+		e.w.Header().Set("ETag", etag)
+	}
+
+	if etagMatches(req.Header.Get("If-None-Match"), etag) {
+		e.w.Header().Del("Content-Length")
+		e.w.Header().Del("Content-Type")
+		e.w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	e.w.WriteHeader(e.status)
+	e.w.Write(e.buf.Bytes())
+}
+
+// etagMatches returns true if the If-None-Match header contains etag or `*`, using a weak comparison
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+type etagHandler struct {
+	max     int
+	handler http.Handler
+}
+
+// ServeHTTP buffers the response of GET and HEAD requests to add an ETag
+func (h etagHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" && req.Method != "HEAD" {
+		h.handler.ServeHTTP(w, req)
+		return
+	}
+	writer := &etagWriter{w: w, max: h.max}
+	h.handler.ServeHTTP(writer, req)
+	writer.finish(req)
+}
+
+// ETag returns a middleware that adds an ETag header to successful GET and HEAD responses and responds with 304 (Not
+// Modified) when the request's If-None-Match header matches
+//
+// The response body is buffered to generate the ETag, up to maxSize bytes (default: DefaultETagMaxSize). Larger or
+// flushed responses are sent without an ETag. An ETag set by the handler is used instead of generating one. Place
+// it inside the logging handlers so the 304 status and size are logged
+//
+// Usage:
+//  etag := handlers.ETag(0)
+//  http.ListenAndServe(":1123", handlers.StructuredHandler(etag(r)))
+func ETag(maxSize int) func(h http.Handler) http.Handler {
+	if maxSize <= 0 {
+		maxSize = DefaultETagMaxSize
+	}
+	return func(h http.Handler) http.Handler {
+		return etagHandler{maxSize, h}
+	}
+}
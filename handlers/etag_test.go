@@ -0,0 +1,90 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Sirupsen/logrus/hooks/test"
+	"github.com/graze/golang-service/log"
+	"github.com/stretchr/testify/assert"
+)
+
+// okETag is the ETag of the okHandler's body
+const okETag = `"92a949fd41844e1bb8c6812cdea102708fde23a4"`
+
+func TestETag(t *testing.T) {
+	cases := map[string]struct {
+		method      string
+		ifNoneMatch string
+		max         int
+		handler     http.Handler
+		status      int
+		etag        string
+		body        string
+	}{
+		"no match": {
+			"GET", `"other"`, 0, okHandler, http.StatusOK, okETag, "ok\n",
+		},
+		"no header": {
+			"GET", "", 0, okHandler, http.StatusOK, okETag, "ok\n",
+		},
+		"match": {
+			"GET", okETag, 0, okHandler, http.StatusNotModified, okETag, "",
+		},
+		"match in list": {
+			"GET", `"other", W/` + okETag, 0, okHandler, http.StatusNotModified, okETag, "",
+		},
+		"match any": {
+			"GET", "*", 0, okHandler, http.StatusNotModified, okETag, "",
+		},
+		"not a GET": {
+			"POST", okETag, 0, okHandler, http.StatusOK, "", "ok\n",
+		},
+		"over the size cap": {
+			"GET", okETag, 2, okHandler, http.StatusOK, "", "ok\n",
+		},
+		"error response": {
+			"GET", "*", 0, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte("not found"))
+			}), http.StatusNotFound, "", "not found",
+		},
+		"handler etag": {
+			"GET", `"v1"`, 0, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("ETag", `"v1"`)
+				w.Write([]byte("ok\n"))
+			}), http.StatusNotModified, `"v1"`, "",
+		},
+	}
+
+	logger := log.New("", "", "")
+	hook := test.NewLocal(logger.Logger)
+
+	for k, tc := range cases {
+		hook.Reset()
+		req := newRequest(tc.method, "http://example.com")
+		if tc.ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", tc.ifNoneMatch)
+		}
+		rec := httptest.NewRecorder()
+		StructuredLogHandler(logger, ETag(tc.max)(tc.handler)).ServeHTTP(rec, req)
+
+		assert.Equal(t, tc.status, rec.Code, "test: %s", k)
+		assert.Equal(t, tc.etag, rec.Header().Get("ETag"), "test: %s", k)
+		assert.Equal(t, tc.body, rec.Body.String(), "test: %s", k)
+		assert.Equal(t, 1, len(hook.Entries), "test: %s", k)
+		assert.Equal(t, tc.status, hook.LastEntry().Data["http.status"], "test: %s", k)
+		assert.Equal(t, len(tc.body), hook.LastEntry().Data["http.bytes"], "test: %s", k)
+	}
+}
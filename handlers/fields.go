@@ -0,0 +1,120 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/graze/golang-service/log"
+)
+
+// contextKey is a private type for the keys this package stores in a request context
+type contextKey int
+
+const (
+	// fieldsKey stores the requestFields for a request
+	fieldsKey contextKey = iota
+	// queueStartKey stores the time a request started waiting to be handled
+	queueStartKey
+	// requestIDKey stores the id of the request
+	requestIDKey
+	// operationKey stores the name of the operation handling the request
+	operationKey
+	// ownerKey stores the team owning the route handling the request
+	ownerKey
+)
+
+// requestFields is a mutable store of log fields attached to a request context
+//
+// As a request context can only be replaced further down the handler chain, the outer logging handlers can not see
+// anything the inner handlers add. This store is created by the outermost handler and shared with all the others
+type requestFields struct {
+	sync.Mutex
+	fields       log.KV
+	decompressed *countingReader
+	owner        string
+	// overridden are the names of fields that were replaced by a different value
+	overridden map[string]bool
+	// logOverridden is set when the overridden fields should be logged
+	logOverridden bool
+}
+
+// withRequestFields returns req with a requestFields store in its context, reusing any existing store
+func withRequestFields(req *http.Request) *http.Request {
+	if _, ok := req.Context().Value(fieldsKey).(*requestFields); ok {
+		return req
+	}
+	return req.WithContext(context.WithValue(req.Context(), fieldsKey, &requestFields{fields: log.KV{}, overridden: map[string]bool{}}))
+}
+
+// addLogFields adds fields to the request's store to be written by the logging handlers
+//
+// A field with the same name as one already stored replaces it, so the last write wins. Nothing is stored if the
+// request was not passed through a logging handler
+func addLogFields(req *http.Request, fields log.KV) {
+	store, ok := req.Context().Value(fieldsKey).(*requestFields)
+	if !ok {
+		return
+	}
+	store.Lock()
+	defer store.Unlock()
+	for k, v := range fields {
+		if old, ok := store.fields[k]; ok && !reflect.DeepEqual(old, v) {
+			store.overridden[k] = true
+		}
+		store.fields[k] = v
+	}
+}
+
+// OverriddenFields returns the names of the log fields added for req that were replaced by a later write with a
+// different value, in name order, such as when two middlewares both set `http.user`. It is for debugging conflicting
+// middleware, and returns nil if nothing was replaced or the request was not passed through a logging handler
+func OverriddenFields(req *http.Request) []string {
+	store, ok := req.Context().Value(fieldsKey).(*requestFields)
+	if !ok {
+		return nil
+	}
+	store.Lock()
+	defer store.Unlock()
+	return sortedKeys(store.overridden)
+}
+
+// sortedKeys returns the keys of set in order, or nil if it is empty
+func sortedKeys(set map[string]bool) []string {
+	if len(set) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// logFields returns a copy of the fields added to the request's store
+func logFields(req *http.Request) log.KV {
+	fields := log.KV{}
+	store, ok := req.Context().Value(fieldsKey).(*requestFields)
+	if !ok {
+		return fields
+	}
+	store.Lock()
+	defer store.Unlock()
+	for k, v := range store.fields {
+		fields[k] = v
+	}
+	return fields
+}
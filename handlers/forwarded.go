@@ -0,0 +1,162 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ForwardedOption changes how the forwarded headers are normalized
+type ForwardedOption func(*forwardedHandler)
+
+// WithForwardedHeader also sets the RFC 7239 `Forwarded` header from the normalized values
+func WithForwardedHeader() ForwardedOption {
+	return func(h *forwardedHandler) {
+		h.forwarded = true
+	}
+}
+
+type forwardedHandler struct {
+	trusted   []*net.IPNet
+	forwarded bool
+	handler   http.Handler
+}
+
+// isTrusted returns true if ip is in one of the trusted proxy ranges
+func (h *forwardedHandler) isTrusted(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range h.trusted {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// ServeHTTP replaces the forwarded headers of req with the client ip, scheme and host before calling the handler
+func (h *forwardedHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	ip, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		ip = req.RemoteAddr
+	}
+	proto := "http"
+	if req.TLS != nil {
+		proto = "https"
+	}
+	host := req.Host
+
+	if h.isTrusted(ip) {
+		hops := forwardedValues(req.Header, "X-Forwarded-For")
+		// the client is the last hop that is not a trusted proxy, or the first hop if they are all trusted
+		client := len(hops) - 1
+		for client > 0 && h.isTrusted(hops[client]) {
+			client--
+		}
+		if client >= 0 {
+			ip = hops[client]
+		}
+		// each trusted proxy after the client adds a value, so the value for the client is as far from the end
+		if p := strings.ToLower(forwardedHop(req.Header, "X-Forwarded-Proto", len(hops)-1-client)); p == "http" || p == "https" {
+			proto = p
+		}
+		if fh := forwardedHop(req.Header, "X-Forwarded-Host", len(hops)-1-client); fh != "" {
+			host = fh
+		}
+	}
+
+	out := new(http.Request)
+	*out = *req
+	out.Header = make(http.Header, len(req.Header)+4)
+	for k, v := range req.Header {
+		out.Header[k] = v
+	}
+	out.Header.Set("X-Forwarded-For", ip)
+	out.Header.Set("X-Forwarded-Proto", proto)
+	out.Header.Set("X-Forwarded-Host", host)
+	if h.forwarded {
+		out.Header.Set("Forwarded", forwardedValue(ip, proto, host))
+	} else {
+		out.Header.Del("Forwarded")
+	}
+	h.handler.ServeHTTP(w, out)
+}
+
+// forwardedValues returns the values of a comma separated header from every line of the header
+func forwardedValues(header http.Header, name string) []string {
+	var values []string
+	for _, line := range header[name] {
+		for _, value := range strings.Split(line, ",") {
+			values = append(values, strings.TrimSpace(value))
+		}
+	}
+	return values
+}
+
+// forwardedHop returns the value of a comma separated header that is after hops from the end, which was added by the
+// proxy that received the request from the client. If there are fewer values than hops, because not every proxy adds
+// one, the first value is used
+func forwardedHop(header http.Header, name string, hops int) string {
+	values := forwardedValues(header, name)
+	if len(values) == 0 {
+		return ""
+	}
+	i := len(values) - 1 - hops
+	if i < 0 {
+		i = 0
+	}
+	return values[i]
+}
+
+// forwardedValue returns an RFC 7239 `Forwarded` header value, quoting ipv6 addresses
+func forwardedValue(ip, proto, host string) string {
+	if strings.Contains(ip, ":") {
+		ip = fmt.Sprintf(`"[%s]"`, ip)
+	}
+	return fmt.Sprintf("for=%s;proto=%s;host=%q", ip, proto, host)
+}
+
+// ForwardedHeaders returns a middleware that normalizes the `X-Forwarded-For`, `X-Forwarded-Proto` and
+// `X-Forwarded-Host` headers of each request to a single canonical value, so handlers (such as the request loggers)
+// can rely on them
+//
+// If the request was made by a proxy in the trusted ip ranges, `X-Forwarded-For` becomes the last ip that is not a
+// trusted proxy (as auth.TrustedProxyIP), and the scheme and host are the values added by the proxy that received the
+// request from that ip, counting one value for each trusted proxy from the end, so values sent by the client are
+// ignored. Otherwise the headers are replaced with the address, scheme and host of the connection, as they can be set
+// by the client. Any `Forwarded` header is removed, unless WithForwardedHeader is used to set it. It panics if a range
+// is invalid
+//
+// Usage:
+//  forwarded := handlers.ForwardedHeaders([]string{"10.0.0.0/8"}, handlers.WithForwardedHeader())
+//  http.ListenAndServe(":1123", forwarded(handlers.StructuredHandler(r)))
+func ForwardedHeaders(trusted []string, opts ...ForwardedOption) func(h http.Handler) http.Handler {
+	networks := make([]*net.IPNet, 0, len(trusted))
+	for _, cidr := range trusted {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return func(h http.Handler) http.Handler {
+		handler := &forwardedHandler{trusted: networks, handler: h}
+		for _, opt := range opts {
+			opt(handler)
+		}
+		return handler
+	}
+}
@@ -0,0 +1,163 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Sirupsen/logrus/hooks/test"
+	"github.com/graze/golang-service/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForwardedHeaders(t *testing.T) {
+	cases := map[string]struct {
+		remote  string
+		tls     bool
+		headers map[string]string
+		ip      string
+		proto   string
+		host    string
+	}{
+		"direct": {
+			"203.0.113.5:1234", false, map[string]string{}, "203.0.113.5", "http", "example.com",
+		},
+		"direct over tls": {
+			"203.0.113.5:1234", true, map[string]string{}, "203.0.113.5", "https", "example.com",
+		},
+		"spoofed by an untrusted client": {
+			"203.0.113.5:1234", false,
+			map[string]string{"X-Forwarded-For": "1.2.3.4", "X-Forwarded-Proto": "https", "X-Forwarded-Host": "evil.com"},
+			"203.0.113.5", "http", "example.com",
+		},
+		"single trusted proxy": {
+			"10.0.0.1:1234", false,
+			map[string]string{"X-Forwarded-For": "198.51.100.7", "X-Forwarded-Proto": "https", "X-Forwarded-Host": "api.example.com"},
+			"198.51.100.7", "https", "api.example.com",
+		},
+		"chain of trusted proxies": {
+			"10.0.0.1:1234", false,
+			map[string]string{"X-Forwarded-For": "198.51.100.7, 10.0.0.3, 10.0.0.2", "X-Forwarded-Proto": "HTTPS, http", "X-Forwarded-Host": "api.example.com, internal"},
+			"198.51.100.7", "https", "api.example.com",
+		},
+		"spoofed host before the proxy's value": {
+			"10.0.0.1:1234", false,
+			map[string]string{"X-Forwarded-For": "198.51.100.7", "X-Forwarded-Proto": "http, https", "X-Forwarded-Host": "evil.example, api.example.com"},
+			"198.51.100.7", "https", "api.example.com",
+		},
+		"spoofed host before a chain": {
+			"10.0.0.1:1234", false,
+			map[string]string{"X-Forwarded-For": "198.51.100.7, 10.0.0.2", "X-Forwarded-Host": "evil.example, api.example.com, internal"},
+			"198.51.100.7", "http", "api.example.com",
+		},
+		"spoofed ip before the chain": {
+			"10.0.0.1:1234", false,
+			map[string]string{"X-Forwarded-For": "1.2.3.4, 198.51.100.7, 10.0.0.2"},
+			"198.51.100.7", "http", "example.com",
+		},
+		"ipv6 client": {
+			"10.0.0.1:1234", false,
+			map[string]string{"X-Forwarded-For": "2001:db8::1, 10.0.0.2"},
+			"2001:db8::1", "http", "example.com",
+		},
+		"invalid proto": {
+			"10.0.0.1:1234", true,
+			map[string]string{"X-Forwarded-For": "198.51.100.7", "X-Forwarded-Proto": "gopher"},
+			"198.51.100.7", "https", "example.com",
+		},
+		"only trusted proxies": {
+			"10.0.0.1:1234", false,
+			map[string]string{"X-Forwarded-For": "10.0.0.3, 10.0.0.2"},
+			"10.0.0.3", "http", "example.com",
+		},
+	}
+
+	for k, tc := range cases {
+		var got *http.Request
+		handler := ForwardedHeaders([]string{"10.0.0.0/8"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = r
+		}))
+		req := newRequest("GET", "http://example.com/path")
+		req.RemoteAddr = tc.remote
+		if tc.tls {
+			req.TLS = &tls.ConnectionState{}
+		}
+		for name, value := range tc.headers {
+			req.Header.Set(name, value)
+		}
+		req.Header.Set("Forwarded", "for=1.2.3.4")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		assert.Equal(t, []string{tc.ip}, got.Header["X-Forwarded-For"], "test: %s", k)
+		assert.Equal(t, []string{tc.proto}, got.Header["X-Forwarded-Proto"], "test: %s", k)
+		assert.Equal(t, []string{tc.host}, got.Header["X-Forwarded-Host"], "test: %s", k)
+		assert.Equal(t, "", got.Header.Get("Forwarded"), "test: %s", k)
+		assert.Equal(t, "for=1.2.3.4", req.Header.Get("Forwarded"), "test: %s the original request is not changed", k)
+	}
+}
+
+func TestForwardedHeadersMultipleLines(t *testing.T) {
+	var got *http.Request
+	handler := ForwardedHeaders([]string{"10.0.0.0/8"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+	}))
+
+	req := newRequest("GET", "http://example.com/path")
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header["X-Forwarded-For"] = []string{"1.2.3.4", "198.51.100.7"}
+	req.Header["X-Forwarded-Host"] = []string{"evil.example", "api.example.com"}
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, []string{"198.51.100.7"}, got.Header["X-Forwarded-For"], "every header line is read")
+	assert.Equal(t, []string{"api.example.com"}, got.Header["X-Forwarded-Host"], "the client's host is ignored")
+}
+
+func TestForwardedHeadersRFC7239(t *testing.T) {
+	var forwarded string
+	handler := ForwardedHeaders([]string{"10.0.0.0/8"}, WithForwardedHeader())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = r.Header.Get("Forwarded")
+	}))
+
+	req := newRequest("GET", "http://example.com/path")
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "198.51.100.7, 10.0.0.2")
+	req.Header.Set("X-Forwarded-Proto", "https")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, `for=198.51.100.7;proto=https;host="example.com"`, forwarded)
+
+	req.Header.Set("X-Forwarded-For", "2001:db8::1")
+	req.Header.Set("X-Forwarded-Host", "example.com:8443")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, `for="[2001:db8::1]";proto=https;host="example.com:8443"`, forwarded)
+}
+
+func TestForwardedHeadersLogging(t *testing.T) {
+	logger := log.New("", "", "")
+	hook := test.NewLocal(logger.Logger)
+	handler := ForwardedHeaders([]string{"10.0.0.0/8"})(StructuredLogHandler(logger, okHandler))
+
+	req := newRequest("GET", "http://example.com/path")
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "1.2.3.4, 198.51.100.7, 10.0.0.2")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, 1, len(hook.Entries))
+	assert.Equal(t, "198.51.100.7", hook.LastEntry().Data["http.user"], "the logger sees the normalized client ip")
+}
+
+func TestForwardedHeadersInvalidRange(t *testing.T) {
+	assert.Panics(t, func() {
+		ForwardedHeaders([]string{"not a range"})
+	})
+}
@@ -0,0 +1,48 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/graze/golang-service/log"
+)
+
+const (
+	// DefaultGeoCountryHeader is the header Cloudflare sets with the country of the client
+	DefaultGeoCountryHeader = "CF-IPCountry"
+	// fallbackGeoCountryHeader is used when the configured header is not set
+	fallbackGeoCountryHeader = "X-Geo-Country"
+)
+
+// WithGeoCountry logs the country of the client from a header set by a CDN as `geo.country`
+//
+// header defaults to DefaultGeoCountryHeader if empty, falling back to `X-Geo-Country` when it is not set on the
+// request. Nothing is logged if neither header is set
+//
+// Usage:
+//  loggedRouter := handlers.StructuredHandler(r, handlers.WithGeoCountry(""))
+func WithGeoCountry(header string) StructuredOption {
+	if header == "" {
+		header = DefaultGeoCountryHeader
+	}
+	return func(h *structuredHandler) {
+		h.fields = append(h.fields, func(req *http.Request) log.KV {
+			for _, name := range []string{header, fallbackGeoCountryHeader} {
+				if country := strings.TrimSpace(req.Header.Get(name)); country != "" {
+					return log.KV{"geo.country": country}
+				}
+			}
+			return log.KV{}
+		})
+	}
+}
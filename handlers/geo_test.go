@@ -0,0 +1,52 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Sirupsen/logrus/hooks/test"
+	"github.com/graze/golang-service/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithGeoCountry(t *testing.T) {
+	cases := map[string]struct {
+		header   string
+		headers  map[string]string
+		expected interface{}
+	}{
+		"cloudflare":      {"", map[string]string{"CF-IPCountry": "GB"}, "GB"},
+		"fallback":        {"", map[string]string{"X-Geo-Country": "FR"}, "FR"},
+		"prefers default": {"", map[string]string{"CF-IPCountry": "GB", "X-Geo-Country": "FR"}, "GB"},
+		"custom header":   {"CloudFront-Viewer-Country", map[string]string{"CloudFront-Viewer-Country": "DE", "CF-IPCountry": "GB"}, "DE"},
+		"absent":          {"", map[string]string{}, nil},
+	}
+
+	for k, tc := range cases {
+		logger := log.New("", "", "")
+		hook := test.NewLocal(logger.Logger)
+
+		req := newRequest("GET", "http://example.com")
+		for h, v := range tc.headers {
+			req.Header.Set(h, v)
+		}
+		StructuredLogHandler(logger, okHandler, WithGeoCountry(tc.header)).ServeHTTP(httptest.NewRecorder(), req)
+
+		assert.Equal(t, 1, len(hook.Entries), "test: %s", k)
+		country, ok := hook.LastEntry().Data["geo.country"]
+		assert.Equal(t, tc.expected != nil, ok, "test: %s", k)
+		if ok {
+			assert.Equal(t, tc.expected, country, "test: %s", k)
+		}
+	}
+}
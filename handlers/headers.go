@@ -0,0 +1,88 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/graze/golang-service/log"
+)
+
+// WithHeaderCount logs the number of request headers as `http.header_count`
+//
+// If threshold is positive, requests with more headers than threshold are logged at warning level to surface
+// requests with abnormally many headers
+//
+// Usage:
+//  loggedRouter := handlers.StructuredHandler(r, handlers.WithHeaderCount(50))
+func WithHeaderCount(threshold int) StructuredOption {
+	return func(h *structuredHandler) {
+		h.fields = append(h.fields, func(req *http.Request) log.KV {
+			return log.KV{"http.header_count": len(req.Header)}
+		})
+		if threshold > 0 {
+			h.levels = append(h.levels, func(req *http.Request, status int) logrus.Level {
+				if len(req.Header) > threshold {
+					return log.WarnLevel
+				}
+				return log.InfoLevel
+			})
+		}
+	}
+}
+
+// DefaultDeniedHeaders are the request headers that are never logged by WithHeaders
+var DefaultDeniedHeaders = []string{"Authorization", "Cookie"}
+
+// WithHeaders logs the values of the listed request headers as `http.header.<name>` with a lower case name
+//
+// Multiple values for a header are joined with `, `. Headers that are not on the request are not logged. Headers in
+// DefaultDeniedHeaders or added with WithDeniedHeaders are never logged, even if they are listed here
+//
+// Usage:
+//  loggedRouter := handlers.StructuredHandler(r, handlers.WithHeaders("Accept", "X-Client-Version"))
+func WithHeaders(names ...string) StructuredOption {
+	return func(h *structuredHandler) {
+		for _, name := range names {
+			h.headers = append(h.headers, http.CanonicalHeaderKey(name))
+		}
+	}
+}
+
+// WithDeniedHeaders adds request headers to DefaultDeniedHeaders that will never be logged, even if they are passed to
+// WithHeaders
+//
+// Usage:
+//  loggedRouter := handlers.StructuredHandler(r,
+//      handlers.WithHeaders("X-Api-Key", "Accept"),
+//      handlers.WithDeniedHeaders("X-Api-Key"))
+func WithDeniedHeaders(names ...string) StructuredOption {
+	return func(h *structuredHandler) {
+		for _, name := range names {
+			h.denied[http.CanonicalHeaderKey(name)] = true
+		}
+	}
+}
+
+// headerFields returns the log fields for the headers to log that are not denied
+func (h structuredHandler) headerFields(req *http.Request) log.KV {
+	fields := log.KV{}
+	for _, name := range h.headers {
+		values, ok := req.Header[name]
+		if !ok || h.denied[name] {
+			continue
+		}
+		fields["http.header."+strings.ToLower(name)] = strings.Join(values, ", ")
+	}
+	return fields
+}
@@ -0,0 +1,130 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/Sirupsen/logrus/hooks/test"
+	"github.com/graze/golang-service/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithHeaderCount(t *testing.T) {
+	cases := map[string]struct {
+		threshold int
+		headers   []string
+		level     logrus.Level
+	}{
+		"several headers": {
+			0,
+			[]string{"Accept", "User-Agent", "X-One", "X-Two"},
+			log.InfoLevel,
+		},
+		"under the threshold": {
+			4,
+			[]string{"Accept", "User-Agent", "X-One", "X-Two"},
+			log.InfoLevel,
+		},
+		"over the threshold": {
+			3,
+			[]string{"Accept", "User-Agent", "X-One", "X-Two"},
+			log.WarnLevel,
+		},
+		"no headers": {
+			3,
+			[]string{},
+			log.InfoLevel,
+		},
+	}
+
+	for k, tc := range cases {
+		logger := log.New("", "", "")
+		hook := test.NewLocal(logger.Logger)
+
+		req := newRequest("GET", "http://example.com")
+		for _, h := range tc.headers {
+			req.Header.Set(h, "value")
+		}
+		StructuredLogHandler(logger, okHandler, WithHeaderCount(tc.threshold)).ServeHTTP(httptest.NewRecorder(), req)
+
+		assert.Equal(t, 1, len(hook.Entries), "test: %s", k)
+		assert.Equal(t, len(tc.headers), hook.LastEntry().Data["http.header_count"], "test: %s", k)
+		assert.Equal(t, tc.level, hook.LastEntry().Level, "test: %s", k)
+	}
+}
+
+func TestHeaderCountIsOptIn(t *testing.T) {
+	logger := log.New("", "", "")
+	hook := test.NewLocal(logger.Logger)
+
+	StructuredLogHandler(logger, okHandler).ServeHTTP(httptest.NewRecorder(), newRequest("GET", "http://example.com"))
+
+	assert.Equal(t, 1, len(hook.Entries))
+	assert.NotContains(t, hook.LastEntry().Data, "http.header_count")
+}
+
+func TestWithHeaders(t *testing.T) {
+	cases := map[string]struct {
+		opts     []StructuredOption
+		expected log.KV
+		missing  []string
+	}{
+		"selected headers": {
+			[]StructuredOption{WithHeaders("Accept", "x-client-version")},
+			log.KV{"http.header.accept": "text/html, application/json", "http.header.x-client-version": "1.2"},
+			[]string{"http.header.authorization", "http.header.cookie", "http.header.x-api-key", "http.header.x-missing"},
+		},
+		"default denied headers": {
+			[]StructuredOption{WithHeaders("Accept", "Authorization", "cookie")},
+			log.KV{"http.header.accept": "text/html, application/json"},
+			[]string{"http.header.authorization", "http.header.cookie"},
+		},
+		"added denied headers": {
+			[]StructuredOption{WithHeaders("Accept", "X-Api-Key", "Authorization"), WithDeniedHeaders("x-api-key")},
+			log.KV{"http.header.accept": "text/html, application/json"},
+			[]string{"http.header.authorization", "http.header.x-api-key"},
+		},
+		"denied before logging": {
+			[]StructuredOption{WithDeniedHeaders("Accept"), WithHeaders("Accept", "X-Client-Version")},
+			log.KV{"http.header.x-client-version": "1.2"},
+			[]string{"http.header.accept"},
+		},
+	}
+
+	for k, tc := range cases {
+		logger := log.New("", "", "")
+		hook := test.NewLocal(logger.Logger)
+
+		req := newRequest("GET", "http://example.com")
+		req.Header.Add("Accept", "text/html")
+		req.Header.Add("Accept", "application/json")
+		req.Header.Set("X-Client-Version", "1.2")
+		req.Header.Set("Authorization", "Bearer secret-token")
+		req.Header.Set("Cookie", "session=secret-session")
+		req.Header.Set("X-Api-Key", "secret-key")
+		StructuredLogHandler(logger, okHandler, tc.opts...).ServeHTTP(httptest.NewRecorder(), req)
+
+		assert.Equal(t, 1, len(hook.Entries), "test: %s", k)
+		for key, value := range tc.expected {
+			assert.Equal(t, value, hook.LastEntry().Data[key], "test: %s", k)
+		}
+		for _, key := range tc.missing {
+			assert.NotContains(t, hook.LastEntry().Data, key, "test: %s", k)
+		}
+		for key, value := range hook.LastEntry().Data {
+			assert.NotContains(t, fmt.Sprintf("%v", value), "secret", "test: %s field: %s", k, key)
+		}
+	}
+}
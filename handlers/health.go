@@ -0,0 +1,133 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/graze/golang-service/log"
+)
+
+// healthCheck is a named dependency check run by the readiness probe
+type healthCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// Health serves separate liveness and readiness probes, such as for Kubernetes
+//
+// The liveness probe reports that the process is up. The readiness probe reports that the service can handle requests:
+// it is not ready until SetReady(true) is called once started, and stops being ready when SetReady(false) is called
+// at the start of a graceful shutdown. While ready, each registered check is run and any failure reports not ready
+type Health struct {
+	ready int32
+
+	mu     sync.RWMutex
+	checks []healthCheck
+}
+
+// NewHealth returns a Health that is not ready until SetReady(true) is called
+func NewHealth() *Health {
+	return &Health{}
+}
+
+// AddCheck registers a dependency check to run for the readiness probe, the check should return an error if the
+// dependency is not healthy and should respect the request context
+//
+// Usage:
+//  health.AddCheck("database", func(ctx context.Context) error {
+//      return db.PingContext(ctx)
+//  })
+func (h *Health) AddCheck(name string, check func(ctx context.Context) error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checks = append(h.checks, healthCheck{name, check})
+}
+
+// SetReady sets if the service is ready to handle requests
+func (h *Health) SetReady(ready bool) {
+	var v int32
+	if ready {
+		v = 1
+	}
+	atomic.StoreInt32(&h.ready, v)
+}
+
+// Ready returns if the service has been set as ready
+func (h *Health) Ready() bool {
+	return atomic.LoadInt32(&h.ready) == 1
+}
+
+// Livez returns a handler for the liveness probe that always responds with 200 (OK)
+func (h *Health) Livez() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintln(w, "ok")
+	})
+}
+
+// Readyz returns a handler for the readiness probe that responds with 200 (OK) when the service is ready and all the
+// checks pass, otherwise it responds with 503 (Service Unavailable) listing the failed checks
+func (h *Health) Readyz() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if !h.Ready() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintln(w, "not ready")
+			return
+		}
+
+		h.mu.RLock()
+		checks := h.checks
+		h.mu.RUnlock()
+
+		failed := []string{}
+		for _, c := range checks {
+			if err := c.check(req.Context()); err != nil {
+				log.Ctx(req.Context()).With(log.KV{
+					"tag":   "health_check_failed",
+					"check": c.name,
+				}).Warnf("health check: %s failed: %s", c.name, err)
+				failed = append(failed, fmt.Sprintf("%s: %s", c.name, err))
+			}
+		}
+		if len(failed) > 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			for _, f := range failed {
+				fmt.Fprintln(w, f)
+			}
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+}
+
+// Handler returns a handler serving the liveness probe on `/livez` and the readiness probe on `/readyz`
+//
+// Usage:
+//  health := handlers.NewHealth()
+//  health.AddCheck("database", dbCheck)
+//  http.Handle("/", health.Handler())
+//  health.SetReady(true)
+//
+//  // on shutdown
+//  health.SetReady(false)
+//  server.Shutdown(ctx)
+func (h *Health) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/livez", h.Livez())
+	mux.Handle("/readyz", h.Readyz())
+	return mux
+}
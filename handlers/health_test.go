@@ -0,0 +1,93 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealth(t *testing.T) {
+	passing := func(ctx context.Context) error { return nil }
+	failing := func(ctx context.Context) error { return errors.New("connection refused") }
+
+	cases := map[string]struct {
+		ready  bool
+		checks map[string]func(ctx context.Context) error
+		livez  int
+		readyz int
+		body   string
+	}{
+		"ready": {
+			true,
+			map[string]func(ctx context.Context) error{"database": passing},
+			http.StatusOK,
+			http.StatusOK,
+			"ok\n",
+		},
+		"starting up": {
+			false,
+			map[string]func(ctx context.Context) error{"database": passing},
+			http.StatusOK,
+			http.StatusServiceUnavailable,
+			"not ready\n",
+		},
+		"failing dependency": {
+			true,
+			map[string]func(ctx context.Context) error{"database": failing},
+			http.StatusOK,
+			http.StatusServiceUnavailable,
+			"database: connection refused\n",
+		},
+	}
+
+	for k, tc := range cases {
+		health := NewHealth()
+		for name, check := range tc.checks {
+			health.AddCheck(name, check)
+		}
+		health.SetReady(tc.ready)
+		handler := health.Handler()
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, newRequest("GET", "http://example.com/livez"))
+		assert.Equal(t, tc.livez, rec.Code, "test: %s", k)
+
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, newRequest("GET", "http://example.com/readyz"))
+		assert.Equal(t, tc.readyz, rec.Code, "test: %s", k)
+		assert.Equal(t, tc.body, rec.Body.String(), "test: %s", k)
+	}
+}
+
+func TestHealthNotReadyDuringShutdown(t *testing.T) {
+	health := NewHealth()
+	health.SetReady(true)
+
+	rec := httptest.NewRecorder()
+	health.Readyz().ServeHTTP(rec, newRequest("GET", "http://example.com/readyz"))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	health.SetReady(false)
+
+	rec = httptest.NewRecorder()
+	health.Readyz().ServeHTTP(rec, newRequest("GET", "http://example.com/readyz"))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	rec = httptest.NewRecorder()
+	health.Livez().ServeHTTP(rec, newRequest("GET", "http://example.com/livez"))
+	assert.Equal(t, http.StatusOK, rec.Code, "still alive while shutting down")
+}
@@ -0,0 +1,100 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// hopByHopHeaders are only meant for a single connection and should not be passed on by a proxy
+//
+// See: https://tools.ietf.org/html/rfc7230#section-6.1
+var hopByHopHeaders = []string{"Connection", "Keep-Alive", "Transfer-Encoding", "Upgrade"}
+
+// removeHopByHop removes the hop-by-hop headers, and any headers listed in the Connection header, from header
+func removeHopByHop(header http.Header) {
+	for _, value := range header["Connection"] {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				header.Del(name)
+			}
+		}
+	}
+	for _, name := range hopByHopHeaders {
+		header.Del(name)
+	}
+}
+
+// hopByHopWriter removes the hop-by-hop headers before the response headers are written
+type hopByHopWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *hopByHopWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		removeHopByHop(w.Header())
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *hopByHopWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *hopByHopWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *hopByHopWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("the response writer does not support hijacking")
+	}
+	return h.Hijack()
+}
+
+type stripHopByHopHandler struct {
+	handler http.Handler
+}
+
+// ServeHTTP removes the hop-by-hop headers from the response
+func (h stripHopByHopHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	writer := &hopByHopWriter{ResponseWriter: w}
+	h.handler.ServeHTTP(writer, req)
+	if !writer.wroteHeader {
+		removeHopByHop(w.Header())
+	}
+}
+
+// StripHopByHop returns a http.Handler that removes the hop-by-hop headers (`Connection`, `Keep-Alive`,
+// `Transfer-Encoding`, `Upgrade` and any headers named in `Connection`) from the response of h, such as when proxying
+// an upstream response
+//
+// Usage:
+//  proxy := httputil.NewSingleHostReverseProxy(upstream)
+//  http.ListenAndServe(":1123", handlers.StripHopByHop(proxy))
+func StripHopByHop(h http.Handler) http.Handler {
+	return stripHopByHopHandler{h}
+}
@@ -0,0 +1,72 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStripHopByHop(t *testing.T) {
+	setHeaders := func(w http.ResponseWriter) {
+		w.Header().Set("Connection", "keep-alive, X-Upstream-Hop")
+		w.Header().Set("Keep-Alive", "timeout=5")
+		w.Header().Set("Transfer-Encoding", "chunked")
+		w.Header().Set("Upgrade", "h2c")
+		w.Header().Set("X-Upstream-Hop", "1")
+		w.Header().Set("Content-Type", "text/plain")
+	}
+
+	cases := map[string]struct {
+		handler http.Handler
+		status  int
+		body    string
+	}{
+		"write": {
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				setHeaders(w)
+				w.Write([]byte("ok\n"))
+			}),
+			http.StatusOK,
+			"ok\n",
+		},
+		"write header": {
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				setHeaders(w)
+				w.WriteHeader(http.StatusCreated)
+			}),
+			http.StatusCreated,
+			"",
+		},
+		"nothing written": {
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				setHeaders(w)
+			}),
+			http.StatusOK,
+			"",
+		},
+	}
+
+	for k, tc := range cases {
+		rec := httptest.NewRecorder()
+		StripHopByHop(tc.handler).ServeHTTP(rec, newRequest("GET", "http://example.com"))
+
+		assert.Equal(t, tc.status, rec.Code, "test: %s", k)
+		assert.Equal(t, tc.body, rec.Body.String(), "test: %s", k)
+		for _, h := range []string{"Connection", "Keep-Alive", "Transfer-Encoding", "Upgrade", "X-Upstream-Hop"} {
+			assert.NotContains(t, rec.Header(), h, "test: %s", k)
+		}
+		assert.Equal(t, "text/plain", rec.Header().Get("Content-Type"), "test: %s", k)
+	}
+}
@@ -0,0 +1,98 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/graze/golang-service/handlers/failure"
+	"github.com/graze/golang-service/log"
+)
+
+// HostNotAllowedError is returned when the Host header of a request is not in the allowed list
+type HostNotAllowedError struct {
+	Host string
+}
+
+func (e *HostNotAllowedError) Error() string {
+	return fmt.Sprintf("host: %q is not allowed", e.Host)
+}
+
+type allowedHostsHandler struct {
+	hosts    map[string]bool
+	suffixes []string
+	onError  failure.Handler
+	handler  http.Handler
+}
+
+// allowed returns true if the canonical host matches one of the hosts or a wildcard suffix
+func (h allowedHostsHandler) allowed(host string) bool {
+	if h.hosts[host] {
+		return true
+	}
+	for _, suffix := range h.suffixes {
+		if strings.HasSuffix(host, suffix) && len(host) > len(suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ServeHTTP rejects requests with a Host header that is not allowed
+func (h allowedHostsHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if !h.allowed(canonicalHost(req.Host)) {
+		log.Ctx(req.Context()).With(log.KV{
+			"tag":         "host_not_allowed",
+			"http.method": req.Method,
+			"http.host":   req.Host,
+			"http.status": http.StatusBadRequest,
+		}).Warnf("host: %q is not allowed", req.Host)
+		h.onError.Handle(w, req, &HostNotAllowedError{req.Host}, http.StatusBadRequest)
+		return
+	}
+	h.handler.ServeHTTP(w, req)
+}
+
+// canonicalHost lower cases host and removes any port and trailing dot
+func canonicalHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// AllowedHosts returns a middleware that only allows requests with a Host header in hosts
+//
+// Hosts are compared without the port and ignoring case. A host starting with `*.` allows any subdomain of the rest of
+// the host, but not the host itself. Other requests are logged at warning level using the global logger and onError is
+// called with a *HostNotAllowedError and a status of 400
+//
+// Usage:
+//  allowed := handlers.AllowedHosts(failure.HandlerFunc(onError), "example.com", "*.example.com")
+//  http.ListenAndServe(":1123", allowed(handlers.StructuredHandler(r)))
+func AllowedHosts(onError failure.Handler, hosts ...string) func(h http.Handler) http.Handler {
+	exact := make(map[string]bool, len(hosts))
+	var suffixes []string
+	for _, host := range hosts {
+		host = canonicalHost(host)
+		if strings.HasPrefix(host, "*.") {
+			suffixes = append(suffixes, host[1:])
+		} else {
+			exact[host] = true
+		}
+	}
+	return func(h http.Handler) http.Handler {
+		return allowedHostsHandler{exact, suffixes, onError, h}
+	}
+}
@@ -0,0 +1,66 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/graze/golang-service/handlers/failure"
+	"github.com/graze/golang-service/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllowedHosts(t *testing.T) {
+	cases := map[string]struct {
+		host   string
+		status int
+	}{
+		"allowed":               {"example.com", http.StatusOK},
+		"allowed with port":     {"example.com:8080", http.StatusOK},
+		"allowed case":          {"EXAMPLE.com", http.StatusOK},
+		"allowed trailing dot":  {"example.com.", http.StatusOK},
+		"disallowed":            {"evil.com", http.StatusBadRequest},
+		"disallowed suffix":     {"evilexample.com", http.StatusBadRequest},
+		"wildcard":              {"api.service.io", http.StatusOK},
+		"nested wildcard":       {"v1.api.service.io", http.StatusOK},
+		"wildcard with port":    {"api.service.io:443", http.StatusOK},
+		"wildcard without host": {"service.io", http.StatusBadRequest},
+		"empty":                 {"", http.StatusBadRequest},
+	}
+
+	hook, restore := globalHook()
+	defer restore()
+	onError := failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, status int) {
+		assert.IsType(t, &HostNotAllowedError{}, err)
+		w.WriteHeader(status)
+	})
+	handler := AllowedHosts(onError, "example.com", "*.service.io")(okHandler)
+
+	for k, tc := range cases {
+		hook.Reset()
+		req := newRequest("GET", "http://example.com/path")
+		req.Host = tc.host
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, tc.status, rec.Code, "test: %s", k)
+		if tc.status == http.StatusOK {
+			assert.Equal(t, 0, len(hook.Entries), "test: %s", k)
+		} else {
+			assert.Equal(t, 1, len(hook.Entries), "test: %s", k)
+			assert.Equal(t, log.WarnLevel, hook.LastEntry().Level, "test: %s", k)
+			assert.Equal(t, "host_not_allowed", hook.LastEntry().Data["tag"], "test: %s", k)
+			assert.Equal(t, tc.host, hook.LastEntry().Data["http.host"], "test: %s", k)
+		}
+	}
+}
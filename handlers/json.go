@@ -0,0 +1,206 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/graze/golang-service/handlers/failure"
+	"github.com/graze/golang-service/log"
+)
+
+// DefaultMaxJSONBytes is the largest json body read by RequireJSON when no size is given
+const DefaultMaxJSONBytes = 1 << 20
+
+// InvalidJSONError is returned when a request declares a JSON body that is not well formed
+type InvalidJSONError struct {
+	err error
+}
+
+func (e *InvalidJSONError) Error() string {
+	return fmt.Sprintf("request body is not valid json: %s", e.err.Error())
+}
+
+// JSONTooLargeError is returned when a request declares a JSON body that is larger than the maximum size
+type JSONTooLargeError struct {
+	max int64
+}
+
+func (e *JSONTooLargeError) Error() string {
+	return fmt.Sprintf("request body is larger than %d bytes", e.max)
+}
+
+// MaxBytes returns the largest body that is allowed
+func (e *JSONTooLargeError) MaxBytes() int64 {
+	return e.max
+}
+
+// jsonOptions are the options shared by the RequireJSON and ValidateSchema middleware
+type jsonOptions struct {
+	maxBytes int64
+}
+
+// JSONOption changes the behaviour of the RequireJSON and ValidateSchema middleware
+type JSONOption func(o *jsonOptions)
+
+// WithMaxJSONBytes sets the largest body that is read (default: DefaultMaxJSONBytes), larger bodies are rejected
+func WithMaxJSONBytes(n int64) JSONOption {
+	return func(o *jsonOptions) {
+		if n > 0 {
+			o.maxBytes = n
+		}
+	}
+}
+
+// newJSONOptions returns the defaults changed by opts
+func newJSONOptions(opts []JSONOption) jsonOptions {
+	o := jsonOptions{maxBytes: DefaultMaxJSONBytes}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+type requireJSONHandler struct {
+	jsonOptions
+	onError failure.Handler
+	handler http.Handler
+}
+
+// ServeHTTP checks the body of any json request is well formed before calling the handler
+func (h requireJSONHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Body == nil || !isJSONRequest(req) {
+		h.handler.ServeHTTP(w, req)
+		return
+	}
+
+	buf := &bytes.Buffer{}
+	err := checkJSON(io.TeeReader(&limitedReader{Reader: req.Body, n: h.maxBytes}, buf))
+	req.Body = readCloser{buf, req.Body}
+	if err == errTooLarge {
+		addLogFields(req, log.KV{"http.json_too_large": true})
+		h.onError.Handle(w, req, &JSONTooLargeError{h.maxBytes}, http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		addLogFields(req, log.KV{"http.json_invalid": true})
+		h.onError.Handle(w, req, &InvalidJSONError{err}, http.StatusBadRequest)
+		return
+	}
+	h.handler.ServeHTTP(w, req)
+}
+
+// readCloser reads from a replacement reader, and closes the original body
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// errTooLarge is returned by a limitedReader once more than its maximum has been read
+var errTooLarge = errors.New("body is too large")
+
+// limitedReader reads up to n bytes, unlike io.LimitReader it returns an error if there are more rather than stopping
+type limitedReader struct {
+	io.Reader
+	n int64
+	// err is returned once more than n bytes have been read (default: errTooLarge)
+	err  error
+	over bool
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	if r.over {
+		return 0, r.tooLarge()
+	}
+	if int64(len(p)) > r.n+1 {
+		p = p[:r.n+1]
+	}
+	n, err := r.Reader.Read(p)
+	if int64(n) > r.n {
+		r.over = true
+		return int(r.n), r.tooLarge()
+	}
+	r.n -= int64(n)
+	return n, err
+}
+
+// tooLarge returns the error for reading more than n bytes
+func (r *limitedReader) tooLarge() error {
+	if r.err != nil {
+		return r.err
+	}
+	return errTooLarge
+}
+
+// isJSONRequest returns if the request declares its body as json
+func isJSONRequest(req *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	return err == nil && mediaType == "application/json"
+}
+
+// checkJSON reads r a token at a time and returns an error if it is not a single well formed json value
+//
+// An empty body is allowed as there is nothing to validate
+func checkJSON(r io.Reader) error {
+	dec := json.NewDecoder(r)
+	depth := 0
+	values := 0
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			if depth > 0 {
+				return io.ErrUnexpectedEOF
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if values > 0 {
+			return errors.New("unexpected data after the top-level value")
+		}
+		if delim, ok := tok.(json.Delim); ok {
+			switch delim {
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+			}
+		}
+		if depth == 0 {
+			values++
+		}
+	}
+}
+
+// RequireJSON returns a middleware that checks requests with a `Content-Type` of `application/json` contain a well
+// formed json body
+//
+// The body is read a token at a time so it is not decoded in to memory. If it is not valid, onError is called with an
+// *InvalidJSONError and a status of 400 and `http.json_invalid=true` is logged. The body is kept so it can still be read
+// by the handler afterwards, so bodies larger than DefaultMaxJSONBytes (or WithMaxJSONBytes) are not read any further
+// and onError is called with a *JSONTooLargeError and a status of 413, logging `http.json_too_large=true`. Requests
+// with any other content type are passed straight through
+//
+// Usage:
+//  requireJSON := handlers.RequireJSON(failure.HandlerFunc(onError), handlers.WithMaxJSONBytes(64*1024))
+//  http.ListenAndServe(":1123", handlers.StructuredHandler(requireJSON(r)))
+func RequireJSON(onError failure.Handler, opts ...JSONOption) func(h http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return requireJSONHandler{newJSONOptions(opts), onError, h}
+	}
+}
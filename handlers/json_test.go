@@ -0,0 +1,175 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Sirupsen/logrus/hooks/test"
+	"github.com/graze/golang-service/handlers/failure"
+	"github.com/graze/golang-service/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func bodyRequest(method, url, contentType, body string) *http.Request {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		panic(err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return req
+}
+
+func TestRequireJSON(t *testing.T) {
+	cases := map[string]struct {
+		request *http.Request
+		status  int
+		body    string
+		invalid bool
+	}{
+		"valid object": {
+			bodyRequest("POST", "http://example.com", "application/json", `{"key":["value",1,{"a":null}]}`),
+			http.StatusOK,
+			`{"key":["value",1,{"a":null}]}`,
+			false,
+		},
+		"valid with charset": {
+			bodyRequest("POST", "http://example.com", "application/json; charset=utf-8", `"string"`),
+			http.StatusOK,
+			`"string"`,
+			false,
+		},
+		"empty body": {
+			bodyRequest("POST", "http://example.com", "application/json", ``),
+			http.StatusOK,
+			``,
+			false,
+		},
+		"invalid json": {
+			bodyRequest("POST", "http://example.com", "application/json", `{"key":}`),
+			http.StatusBadRequest,
+			"",
+			true,
+		},
+		"truncated json": {
+			bodyRequest("POST", "http://example.com", "application/json", `{"key":[1,2`),
+			http.StatusBadRequest,
+			"",
+			true,
+		},
+		"multiple values": {
+			bodyRequest("POST", "http://example.com", "application/json", `{}{}`),
+			http.StatusBadRequest,
+			"",
+			true,
+		},
+		"not json content type": {
+			bodyRequest("POST", "http://example.com", "text/plain", `{"key":}`),
+			http.StatusOK,
+			`{"key":}`,
+			false,
+		},
+	}
+
+	logger := log.New("", "", "")
+	hook := test.NewLocal(logger.Logger)
+
+	for k, tc := range cases {
+		hook.Reset()
+		var body string
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, err := ioutil.ReadAll(r.Body)
+			assert.Nil(t, err, "test: %s", k)
+			body = string(b)
+		})
+		onError := failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, status int) {
+			assert.IsType(t, &InvalidJSONError{}, err, "test: %s", k)
+			w.WriteHeader(status)
+		})
+
+		rec := httptest.NewRecorder()
+		StructuredLogHandler(logger, RequireJSON(onError)(h)).ServeHTTP(rec, tc.request)
+
+		assert.Equal(t, tc.status, rec.Code, "test: %s", k)
+		assert.Equal(t, tc.body, body, "test: %s", k)
+		assert.Equal(t, 1, len(hook.Entries), "test: %s", k)
+		if tc.invalid {
+			assert.Equal(t, true, hook.LastEntry().Data["http.json_invalid"], "test: %s", k)
+		} else {
+			assert.NotContains(t, hook.LastEntry().Data, "http.json_invalid", "test: %s", k)
+		}
+	}
+}
+
+func TestRequireJSONMaxBytes(t *testing.T) {
+	cases := map[string]struct {
+		body    string
+		status  int
+		tooBig  bool
+		invalid bool
+	}{
+		"under the limit":       {`[1,2,3]`, http.StatusOK, false, false},
+		"at the limit":          {`[1,2,3,4]`, http.StatusOK, false, false},
+		"over the limit":        {`[1,2,3,4,5]`, http.StatusRequestEntityTooLarge, true, false},
+		"trailing whitespace":   {`[1,2,3,4]  `, http.StatusRequestEntityTooLarge, true, false},
+		"invalid before limit":  {`[1,}` + strings.Repeat(" ", 100), http.StatusBadRequest, false, true},
+		"large valid json body": {`[` + strings.Repeat(`1,`, 1000) + `1]`, http.StatusRequestEntityTooLarge, true, false},
+	}
+
+	logger := log.New("", "", "")
+	hook := test.NewLocal(logger.Logger)
+
+	for k, tc := range cases {
+		hook.Reset()
+		var body string
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, err := ioutil.ReadAll(r.Body)
+			assert.Nil(t, err, "test: %s", k)
+			body = string(b)
+		})
+		var handled error
+		onError := failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, status int) {
+			handled = err
+			w.WriteHeader(status)
+		})
+
+		rec := httptest.NewRecorder()
+		req := bodyRequest("POST", "http://example.com", "application/json", tc.body)
+		StructuredLogHandler(logger, RequireJSON(onError, WithMaxJSONBytes(9))(h)).ServeHTTP(rec, req)
+
+		assert.Equal(t, tc.status, rec.Code, "test: %s", k)
+		if tc.status == http.StatusOK {
+			assert.Equal(t, tc.body, body, "test: %s the whole body is passed on", k)
+		}
+		if tc.tooBig {
+			if assert.IsType(t, &JSONTooLargeError{}, handled, "test: %s", k) {
+				assert.Equal(t, int64(9), handled.(*JSONTooLargeError).MaxBytes(), "test: %s", k)
+			}
+			assert.Equal(t, true, hook.LastEntry().Data["http.json_too_large"], "test: %s", k)
+		} else {
+			assert.NotContains(t, hook.LastEntry().Data, "http.json_too_large", "test: %s", k)
+		}
+		if tc.invalid {
+			assert.IsType(t, &InvalidJSONError{}, handled, "test: %s", k)
+		}
+	}
+
+	handler := RequireJSON(nil)(okHandler).(requireJSONHandler)
+	assert.Equal(t, int64(DefaultMaxJSONBytes), handler.maxBytes)
+	handler = RequireJSON(nil, WithMaxJSONBytes(0))(okHandler).(requireJSONHandler)
+	assert.Equal(t, int64(DefaultMaxJSONBytes), handler.maxBytes, "a limit that is not positive is ignored")
+}
@@ -0,0 +1,146 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/graze/golang-service/handlers/failure"
+	"github.com/graze/golang-service/log"
+)
+
+const (
+	// DefaultMaxURLLength is the maximum length of a request uri used by MaxURLLength when no limit is given
+	DefaultMaxURLLength = 8192
+	// DefaultMaxQueryParams is the maximum number of query parameters used by MaxQueryParams when no limit is given
+	DefaultMaxQueryParams = 100
+)
+
+// URITooLongError is returned when the request uri is longer than the allowed limit
+type URITooLongError struct {
+	Length, Max int
+}
+
+func (e *URITooLongError) Error() string {
+	return fmt.Sprintf("request uri length: %d is longer than the maximum: %d", e.Length, e.Max)
+}
+
+// TooManyQueryParamsError is returned when the request has more query parameters than the allowed limit
+type TooManyQueryParamsError struct {
+	Count, Max int
+}
+
+func (e *TooManyQueryParamsError) Error() string {
+	return fmt.Sprintf("request has %d query parameters, more than the maximum: %d", e.Count, e.Max)
+}
+
+type maxURLLengthHandler struct {
+	max     int
+	onError failure.Handler
+	handler http.Handler
+}
+
+// ServeHTTP rejects requests with a uri longer than the maximum
+func (h maxURLLengthHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	length := len(parseURI(req, *req.URL))
+	if length > h.max {
+		log.Ctx(req.Context()).With(log.KV{
+			"tag":             "uri_too_long",
+			"http.method":     req.Method,
+			"http.uri_length": length,
+			"http.status":     http.StatusRequestURITooLong,
+		}).Warnf("request uri length: %d is longer than the maximum: %d", length, h.max)
+		h.onError.Handle(w, req, &URITooLongError{length, h.max}, http.StatusRequestURITooLong)
+		return
+	}
+	h.handler.ServeHTTP(w, req)
+}
+
+// MaxURLLength returns a middleware that rejects requests with a uri longer than n characters
+//
+// onError is called with a *URITooLongError and a status of 414. If n is not positive, DefaultMaxURLLength is used.
+// The rejection is logged using the global logger without the uri, so it should be placed outside the logging and
+// metrics handlers to stop oversized uris reaching them
+//
+// Usage:
+//  maxLength := handlers.MaxURLLength(2048, failure.HandlerFunc(onError))
+//  http.ListenAndServe(":1123", maxLength(handlers.StructuredHandler(r)))
+func MaxURLLength(n int, onError failure.Handler) func(h http.Handler) http.Handler {
+	if n <= 0 {
+		n = DefaultMaxURLLength
+	}
+	return func(h http.Handler) http.Handler {
+		return maxURLLengthHandler{n, onError, h}
+	}
+}
+
+type maxQueryParamsHandler struct {
+	max     int
+	onError failure.Handler
+	handler http.Handler
+}
+
+// ServeHTTP rejects requests with more query parameters than the maximum
+func (h maxQueryParamsHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	count := countQueryParams(req.URL.RawQuery)
+	if count > h.max {
+		log.Ctx(req.Context()).With(log.KV{
+			"tag":               "too_many_query_params",
+			"http.method":       req.Method,
+			"http.path":         req.URL.Path,
+			"http.query_params": count,
+			"http.status":       http.StatusBadRequest,
+		}).Warnf("request has %d query parameters, more than the maximum: %d", count, h.max)
+		h.onError.Handle(w, req, &TooManyQueryParamsError{count, h.max}, http.StatusBadRequest)
+		return
+	}
+	h.handler.ServeHTTP(w, req)
+}
+
+// countQueryParams returns the number of parameters in query, counting each repeated key, without decoding them
+func countQueryParams(query string) int {
+	count := 0
+	for len(query) > 0 {
+		end := strings.IndexAny(query, "&;")
+		if end < 0 {
+			end = len(query)
+		}
+		if end > 0 {
+			count++
+		}
+		if end == len(query) {
+			break
+		}
+		query = query[end+1:]
+	}
+	return count
+}
+
+// MaxQueryParams returns a middleware that rejects requests with more than n query parameters, counting each value of
+// a repeated parameter
+//
+// onError is called with a *TooManyQueryParamsError and a status of 400. If n is not positive, DefaultMaxQueryParams is
+// used. The parameters are counted without being parsed, and the rejection is logged using the global logger without
+// the query, so it should be placed outside the logging and metrics handlers to stop oversized queries reaching them
+//
+// Usage:
+//  maxParams := handlers.MaxQueryParams(50, failure.HandlerFunc(onError))
+//  http.ListenAndServe(":1123", maxParams(handlers.StructuredHandler(r)))
+func MaxQueryParams(n int, onError failure.Handler) func(h http.Handler) http.Handler {
+	if n <= 0 {
+		n = DefaultMaxQueryParams
+	}
+	return func(h http.Handler) http.Handler {
+		return maxQueryParamsHandler{n, onError, h}
+	}
+}
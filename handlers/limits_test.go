@@ -0,0 +1,161 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/Sirupsen/logrus/hooks/test"
+	"github.com/graze/golang-service/handlers/failure"
+	"github.com/graze/golang-service/log"
+	"github.com/stretchr/testify/assert"
+)
+
+// globalHook adds a test hook to the global logger and discards its output, the returned func restores the hooks and
+// output of the global logger
+func globalHook() (*test.Hook, func()) {
+	logger := log.With(log.KV{}).(*log.LoggerEntry).Logger
+	hooks, out := logger.Hooks, logger.Out
+	logger.Hooks = make(logrus.LevelHooks, len(hooks))
+	for level, levelHooks := range hooks {
+		logger.Hooks[level] = append([]logrus.Hook(nil), levelHooks...)
+	}
+
+	hook := &test.Hook{}
+	log.SetOutput(ioutil.Discard)
+	log.AddHook(hook)
+	return hook, func() {
+		logger.Hooks = hooks
+		log.SetOutput(out)
+	}
+}
+
+func TestMaxURLLength(t *testing.T) {
+	cases := map[string]struct {
+		max    int
+		url    string
+		status int
+	}{
+		"under the limit": {
+			20,
+			"http://example.com/path?query=1",
+			http.StatusOK,
+		},
+		"at the limit": {
+			len("/path?query=1"),
+			"http://example.com/path?query=1",
+			http.StatusOK,
+		},
+		"over the limit": {
+			10,
+			"http://example.com/path?query=1",
+			http.StatusRequestURITooLong,
+		},
+		"default limit": {
+			0,
+			"http://example.com/" + strings.Repeat("a", DefaultMaxURLLength),
+			http.StatusRequestURITooLong,
+		},
+	}
+
+	hook, restore := globalHook()
+	defer restore()
+	onError := failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, status int) {
+		assert.IsType(t, &URITooLongError{}, err)
+		w.WriteHeader(status)
+	})
+
+	for k, tc := range cases {
+		hook.Reset()
+		rec := httptest.NewRecorder()
+		MaxURLLength(tc.max, onError)(okHandler).ServeHTTP(rec, newRequest("GET", tc.url))
+
+		assert.Equal(t, tc.status, rec.Code, "test: %s", k)
+		if tc.status == http.StatusOK {
+			assert.Equal(t, 0, len(hook.Entries), "test: %s", k)
+		} else {
+			assert.Equal(t, 1, len(hook.Entries), "test: %s", k)
+			assert.Equal(t, log.WarnLevel, hook.LastEntry().Level, "test: %s", k)
+			assert.Equal(t, "uri_too_long", hook.LastEntry().Data["tag"], "test: %s", k)
+		}
+	}
+}
+
+func TestMaxQueryParams(t *testing.T) {
+	cases := map[string]struct {
+		max    int
+		url    string
+		status int
+	}{
+		"no query": {
+			2,
+			"http://example.com/path",
+			http.StatusOK,
+		},
+		"under the limit": {
+			3,
+			"http://example.com/path?a=1&b=2",
+			http.StatusOK,
+		},
+		"at the limit": {
+			3,
+			"http://example.com/path?a=1&b=2&a=3",
+			http.StatusOK,
+		},
+		"empty parameters are not counted": {
+			2,
+			"http://example.com/path?a=1&&;b=2&",
+			http.StatusOK,
+		},
+		"over the limit": {
+			2,
+			"http://example.com/path?a=1&b=2&a=3",
+			http.StatusBadRequest,
+		},
+		"semicolon separated": {
+			2,
+			"http://example.com/path?a=1;b=2;c=3",
+			http.StatusBadRequest,
+		},
+		"default limit": {
+			0,
+			"http://example.com/path?" + strings.Repeat("a=1&", DefaultMaxQueryParams+1),
+			http.StatusBadRequest,
+		},
+	}
+
+	hook, restore := globalHook()
+	defer restore()
+	onError := failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, status int) {
+		assert.IsType(t, &TooManyQueryParamsError{}, err)
+		w.WriteHeader(status)
+	})
+
+	for k, tc := range cases {
+		hook.Reset()
+		rec := httptest.NewRecorder()
+		MaxQueryParams(tc.max, onError)(okHandler).ServeHTTP(rec, newRequest("GET", tc.url))
+
+		assert.Equal(t, tc.status, rec.Code, "test: %s", k)
+		if tc.status == http.StatusOK {
+			assert.Equal(t, 0, len(hook.Entries), "test: %s", k)
+		} else {
+			assert.Equal(t, 1, len(hook.Entries), "test: %s", k)
+			assert.Equal(t, log.WarnLevel, hook.LastEntry().Level, "test: %s", k)
+			assert.Equal(t, "too_many_query_params", hook.LastEntry().Data["tag"], "test: %s", k)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/graze/golang-service/handlers/failure"
+	"github.com/graze/golang-service/log"
+)
+
+// MethodNotAllowedError is returned when the method of a request is not in the allowed list
+type MethodNotAllowedError struct {
+	Method  string
+	Allowed []string
+}
+
+func (e *MethodNotAllowedError) Error() string {
+	return fmt.Sprintf("method: %s is not allowed, expecting one of: %s", e.Method, strings.Join(e.Allowed, ", "))
+}
+
+type allowMethodsHandler struct {
+	methods map[string]bool
+	allow   []string
+	onError failure.Handler
+	handler http.Handler
+}
+
+// ServeHTTP rejects requests with a method that is not allowed
+func (h allowMethodsHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if !h.methods[req.Method] {
+		log.Ctx(req.Context()).With(log.KV{
+			"tag":         "method_not_allowed",
+			"http.method": req.Method,
+			"http.status": http.StatusMethodNotAllowed,
+		}).Warnf("method: %s is not allowed", req.Method)
+		w.Header().Set("Allow", strings.Join(h.allow, ", "))
+		h.onError.Handle(w, req, &MethodNotAllowedError{req.Method, h.allow}, http.StatusMethodNotAllowed)
+		return
+	}
+	h.handler.ServeHTTP(w, req)
+}
+
+// AllowMethods returns a middleware that only allows requests with a method in methods, such as for a read only
+// service
+//
+// Other requests are logged at warning level using the global logger, and onError is called with a
+// *MethodNotAllowedError and a status of 405 after setting the `Allow` header. Preflight `OPTIONS` requests are only
+// passed to the handler (such as a CORS handler) if `OPTIONS` is in methods, otherwise they are rejected
+//
+// Usage:
+//  readOnly := handlers.AllowMethods(failure.HandlerFunc(onError), "GET", "HEAD", "OPTIONS")
+//  http.ListenAndServe(":1123", handlers.StructuredHandler(readOnly(r)))
+func AllowMethods(onError failure.Handler, methods ...string) func(h http.Handler) http.Handler {
+	allowed := make(map[string]bool, len(methods))
+	allow := make([]string, 0, len(methods))
+	for _, m := range methods {
+		m = strings.ToUpper(m)
+		if !allowed[m] {
+			allowed[m] = true
+			allow = append(allow, m)
+		}
+	}
+	return func(h http.Handler) http.Handler {
+		return allowMethodsHandler{allowed, allow, onError, h}
+	}
+}
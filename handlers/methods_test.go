@@ -0,0 +1,59 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/graze/golang-service/handlers/failure"
+	"github.com/graze/golang-service/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllowMethods(t *testing.T) {
+	cases := map[string]struct {
+		methods []string
+		method  string
+		status  int
+		allow   string
+	}{
+		"get":                 {[]string{"GET", "HEAD"}, "GET", http.StatusOK, ""},
+		"head":                {[]string{"GET", "HEAD"}, "HEAD", http.StatusOK, ""},
+		"post":                {[]string{"GET", "HEAD"}, "POST", http.StatusMethodNotAllowed, "GET, HEAD"},
+		"delete":              {[]string{"GET", "HEAD"}, "DELETE", http.StatusMethodNotAllowed, "GET, HEAD"},
+		"options not allowed": {[]string{"GET", "HEAD"}, "OPTIONS", http.StatusMethodNotAllowed, "GET, HEAD"},
+		"options allowed":     {[]string{"get", "head", "options"}, "OPTIONS", http.StatusOK, ""},
+	}
+
+	hook, restore := globalHook()
+	defer restore()
+	for k, tc := range cases {
+		hook.Reset()
+		onError := failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, status int) {
+			assert.IsType(t, &MethodNotAllowedError{}, err, "test: %s", k)
+			w.WriteHeader(status)
+		})
+		rec := httptest.NewRecorder()
+		AllowMethods(onError, tc.methods...)(okHandler).ServeHTTP(rec, newRequest(tc.method, "http://example.com/path"))
+
+		assert.Equal(t, tc.status, rec.Code, "test: %s", k)
+		assert.Equal(t, tc.allow, rec.Header().Get("Allow"), "test: %s", k)
+		if tc.status == http.StatusOK {
+			assert.Equal(t, 0, len(hook.Entries), "test: %s", k)
+		} else {
+			assert.Equal(t, 1, len(hook.Entries), "test: %s", k)
+			assert.Equal(t, log.WarnLevel, hook.LastEntry().Level, "test: %s", k)
+			assert.Equal(t, "method_not_allowed", hook.LastEntry().Data["tag"], "test: %s", k)
+		}
+	}
+}
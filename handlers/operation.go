@@ -0,0 +1,47 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/graze/golang-service/log"
+)
+
+type namedHandler struct {
+	name    string
+	handler http.Handler
+}
+
+// ServeHTTP stores the operation name in the request context and log fields
+func (h namedHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	addLogFields(req, log.KV{"http.operation": h.name})
+	h.handler.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), operationKey, h.name)))
+}
+
+// Named returns a http.Handler that records h as the operation `name`, to group requests by what they do rather
+// than their path
+//
+// The name is logged by the structured log handler as `http.operation` and can be read with Operation
+//
+// Usage:
+//  r.Handle("/users", handlers.Named("CreateUser", createUser)).Methods("POST")
+//  http.ListenAndServe(":1123", handlers.StructuredHandler(r))
+func Named(name string, h http.Handler) http.Handler {
+	return namedHandler{name, h}
+}
+
+// Operation returns the name of the operation set by Named, and false if it is not set
+func Operation(req *http.Request) (string, bool) {
+	name, ok := req.Context().Value(operationKey).(string)
+	return name, ok
+}
@@ -0,0 +1,44 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Sirupsen/logrus/hooks/test"
+	"github.com/graze/golang-service/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNamed(t *testing.T) {
+	logger := log.New("", "", "")
+	hook := test.NewLocal(logger.Logger)
+
+	var name string
+	var ok bool
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, ok = Operation(r)
+	})
+
+	StructuredLogHandler(logger, Named("CreateUser", h)).ServeHTTP(httptest.NewRecorder(), newRequest("POST", "http://example.com/users"))
+	assert.True(t, ok)
+	assert.Equal(t, "CreateUser", name)
+	assert.Equal(t, 1, len(hook.Entries))
+	assert.Equal(t, "CreateUser", hook.LastEntry().Data["http.operation"])
+
+	hook.Reset()
+	StructuredLogHandler(logger, h).ServeHTTP(httptest.NewRecorder(), newRequest("POST", "http://example.com/users"))
+	assert.False(t, ok)
+	assert.Equal(t, 1, len(hook.Entries))
+	assert.NotContains(t, hook.LastEntry().Data, "http.operation")
+}
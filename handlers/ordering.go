@@ -0,0 +1,133 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/graze/golang-service/handlers/auth"
+	"github.com/graze/golang-service/handlers/failure"
+	"github.com/graze/golang-service/log"
+)
+
+// SequenceHeader is the request header containing the client's sequence number
+const SequenceHeader = "X-Sequence"
+
+type (
+	// InvalidSequenceError is returned when the sequence header is not a positive number
+	InvalidSequenceError struct {
+		Value string
+	}
+	// OutOfOrderError is returned when a request has a sequence number that is not after the last one seen for its
+	// client, because it was sent out of order or replayed
+	OutOfOrderError struct {
+		Sequence uint64
+		Last     uint64
+	}
+)
+
+func (e *InvalidSequenceError) Error() string {
+	return fmt.Sprintf("sequence: %q is not a positive number", e.Value)
+}
+
+func (e *OutOfOrderError) Error() string {
+	return fmt.Sprintf("sequence: %d is not after the last sequence: %d", e.Sequence, e.Last)
+}
+
+// SequenceStore records the last sequence number seen for each client
+type SequenceStore interface {
+	// Advance sets the last sequence of client to seq if it is after the current one, returning the current one and
+	// false if it is not. It must be safe to call concurrently
+	Advance(client string, seq uint64) (last uint64, ok bool)
+}
+
+// MemorySequenceStore is a SequenceStore that keeps the sequences in memory, so it is only suitable for a single
+// instance of a service
+type MemorySequenceStore struct {
+	mu   sync.Mutex
+	last map[string]uint64
+}
+
+// Advance sets the last sequence of client to seq if it is after the current one
+func (s *MemorySequenceStore) Advance(client string, seq uint64) (uint64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	last := s.last[client]
+	if seq <= last {
+		return last, false
+	}
+	s.last[client] = seq
+	return last, true
+}
+
+// NewMemorySequenceStore returns an empty MemorySequenceStore
+func NewMemorySequenceStore() *MemorySequenceStore {
+	return &MemorySequenceStore{last: make(map[string]uint64)}
+}
+
+type orderingHandler struct {
+	store   SequenceStore
+	client  func(user interface{}) string
+	onError failure.Handler
+	handler http.Handler
+}
+
+// ServeHTTP rejects requests with a sequence that is not after the last one seen for the client
+func (h orderingHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	value := req.Header.Get(SequenceHeader)
+	user := auth.GetUser(req)
+	if value == "" || user == nil {
+		h.handler.ServeHTTP(w, req)
+		return
+	}
+	client := h.client(user)
+	if client == "" {
+		h.handler.ServeHTTP(w, req)
+		return
+	}
+
+	seq, err := strconv.ParseUint(value, 10, 64)
+	if err != nil || seq == 0 {
+		h.onError.Handle(w, req, &InvalidSequenceError{value}, http.StatusBadRequest)
+		return
+	}
+	if last, ok := h.store.Advance(client, seq); !ok {
+		addLogFields(req, log.KV{"http.out_of_order": true})
+		h.onError.Handle(w, req, &OutOfOrderError{seq, last}, http.StatusConflict)
+		return
+	}
+	h.handler.ServeHTTP(w, req)
+}
+
+// RequireSequence returns a middleware that rejects requests from a client with a sequence number in the `X-Sequence`
+// header that is not greater than the last one it sent, so requests from stateful clients are handled in order and
+// at most once
+//
+// client returns the id of the user returned by auth.GetUser, so the middleware must be placed after (inside) the auth
+// handler. Requests without a sequence, a user or a client id are passed straight through. Out of order and replayed
+// requests log `http.out_of_order=true` and onError is called with an *OutOfOrderError and a status of 409. A sequence
+// that is not a positive number calls onError with an *InvalidSequenceError and a status of 400
+//
+// The sequence is recorded before the request is handled, so a request that fails must be retried with a new sequence
+//
+// Usage:
+//  ordered := handlers.RequireSequence(handlers.NewMemorySequenceStore(), func(user interface{}) string {
+//      return user.(*account.User).ID
+//  }, failure.HandlerFunc(onError))
+//  http.Handle("/", handlers.StructuredHandler(keyAuth.Then(ordered(r))))
+func RequireSequence(store SequenceStore, client func(user interface{}) string, onError failure.Handler) func(h http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return orderingHandler{store, client, onError, h}
+	}
+}
@@ -0,0 +1,136 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Sirupsen/logrus/hooks/test"
+	"github.com/graze/golang-service/handlers/auth"
+	"github.com/graze/golang-service/handlers/failure"
+	"github.com/graze/golang-service/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireSequence(t *testing.T) {
+	type request struct {
+		key      string
+		sequence string
+		status   int
+	}
+	cases := map[string]struct {
+		requests []request
+	}{
+		"in order": {[]request{
+			{"alice", "1", http.StatusOK},
+			{"alice", "2", http.StatusOK},
+			{"alice", "5", http.StatusOK},
+		}},
+		"out of order": {[]request{
+			{"alice", "1", http.StatusOK},
+			{"alice", "3", http.StatusOK},
+			{"alice", "2", http.StatusConflict},
+			{"alice", "4", http.StatusOK},
+		}},
+		"replayed": {[]request{
+			{"alice", "1", http.StatusOK},
+			{"alice", "1", http.StatusConflict},
+		}},
+		"per client": {[]request{
+			{"alice", "5", http.StatusOK},
+			{"bob", "1", http.StatusOK},
+			{"alice", "1", http.StatusConflict},
+		}},
+		"invalid": {[]request{
+			{"alice", "first", http.StatusBadRequest},
+			{"alice", "0", http.StatusBadRequest},
+			{"alice", "-1", http.StatusBadRequest},
+		}},
+		"no sequence": {[]request{
+			{"alice", "", http.StatusOK},
+			{"alice", "", http.StatusOK},
+		}},
+		"anonymous": {[]request{
+			{"", "1", http.StatusOK},
+			{"", "1", http.StatusOK},
+		}},
+	}
+
+	finder := auth.FinderFunc(func(key interface{}, r *http.Request) (interface{}, error) {
+		return key, nil
+	})
+	onError := failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, status int) {
+		w.WriteHeader(status)
+	})
+	keyAuth := auth.NewXAPIKey(finder, onError)
+
+	for k, tc := range cases {
+		logger := log.New("", "", "")
+		hook := test.NewLocal(logger.Logger)
+		ordered := RequireSequence(NewMemorySequenceStore(), func(user interface{}) string {
+			return user.(string)
+		}, onError)
+		handler := StructuredLogHandler(logger, ordered(okHandler))
+		authenticated := StructuredLogHandler(logger, keyAuth.Then(ordered(okHandler)))
+
+		for i, r := range tc.requests {
+			req := newRequest("POST", "http://example.com/basket")
+			if r.sequence != "" {
+				req.Header.Set(SequenceHeader, r.sequence)
+			}
+			h := handler
+			if r.key != "" {
+				req.Header.Set("X-Api-Key", r.key)
+				h = authenticated
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			msg := fmt.Sprintf("test: %s request: %d", k, i)
+			assert.Equal(t, r.status, rec.Code, msg)
+			if r.status == http.StatusConflict {
+				assert.Equal(t, true, hook.LastEntry().Data["http.out_of_order"], msg)
+			} else {
+				assert.NotContains(t, hook.LastEntry().Data, "http.out_of_order", msg)
+			}
+		}
+	}
+}
+
+func TestRequireSequenceError(t *testing.T) {
+	var handled error
+	onError := failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, status int) {
+		handled = err
+		w.WriteHeader(status)
+	})
+	finder := auth.FinderFunc(func(key interface{}, r *http.Request) (interface{}, error) {
+		return key, nil
+	})
+	ordered := RequireSequence(NewMemorySequenceStore(), func(user interface{}) string {
+		return user.(string)
+	}, onError)
+	handler := auth.NewXAPIKey(finder, onError).Then(ordered(okHandler))
+
+	for _, seq := range []string{"7", "3"} {
+		req := newRequest("POST", "http://example.com/basket")
+		req.Header.Set("X-Api-Key", "alice")
+		req.Header.Set(SequenceHeader, seq)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if assert.IsType(t, &OutOfOrderError{}, handled) {
+		assert.Equal(t, uint64(3), handled.(*OutOfOrderError).Sequence)
+		assert.Equal(t, uint64(7), handled.(*OutOfOrderError).Last)
+	}
+}
@@ -0,0 +1,66 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/graze/golang-service/log"
+)
+
+// WithOverriddenFields adds a `log.overridden` field to entries where a field was written more than once with different
+// values, listing the names of the fields in order, to debug middleware that set the same field
+//
+// The last value written is always the one logged. The fields the structured handler writes itself (such as
+// `http.user`) replace any added by middleware with the same name
+//
+// Usage:
+//  loggedRouter := handlers.StructuredHandler(r, handlers.WithOverriddenFields())
+func WithOverriddenFields() StructuredOption {
+	return func(h *structuredHandler) {
+		h.overridden = true
+	}
+}
+
+// logOverridden marks the overridden fields of req to be logged
+func logOverridden(req *http.Request) {
+	if store, ok := req.Context().Value(fieldsKey).(*requestFields); ok {
+		store.Lock()
+		defer store.Unlock()
+		store.logOverridden = true
+	}
+}
+
+// overriddenField returns the names of the fields of req that were overridden, including by fields, joined by commas.
+// An empty string is returned if there are none, or they are not to be logged
+func overriddenField(req *http.Request, stored, fields log.KV) string {
+	store, ok := req.Context().Value(fieldsKey).(*requestFields)
+	if !ok {
+		return ""
+	}
+	store.Lock()
+	defer store.Unlock()
+	if !store.logOverridden {
+		return ""
+	}
+	overridden := make(map[string]bool, len(store.overridden))
+	for k := range store.overridden {
+		overridden[k] = true
+	}
+	for k, v := range fields {
+		if old, ok := stored[k]; ok && !reflect.DeepEqual(old, v) {
+			overridden[k] = true
+		}
+	}
+	return strings.Join(sortedKeys(overridden), ",")
+}
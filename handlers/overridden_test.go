@@ -0,0 +1,71 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Sirupsen/logrus/hooks/test"
+	"github.com/graze/golang-service/log"
+	"github.com/stretchr/testify/assert"
+)
+
+// fieldLayer is a middleware that adds fields to the request log
+func fieldLayer(fields log.KV, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		addLogFields(req, fields)
+		h.ServeHTTP(w, req)
+	})
+}
+
+func TestOverriddenFields(t *testing.T) {
+	var overridden []string
+	handler := fieldLayer(log.KV{"app.user": "outer", "app.role": "admin", "app.id": 1},
+		fieldLayer(log.KV{"app.user": "inner", "app.role": "admin"},
+			http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				addLogFields(req, log.KV{"app.id": 2})
+				overridden = OverriddenFields(req)
+			})))
+
+	logger := log.New("", "", "")
+	hook := test.NewLocal(logger.Logger)
+	for i := 0; i < 10; i++ {
+		StructuredLogHandler(logger, handler).ServeHTTP(httptest.NewRecorder(), newRequest("GET", "http://example.com"))
+
+		assert.Equal(t, "inner", hook.LastEntry().Data["app.user"], "the last write wins")
+		assert.Equal(t, 2, hook.LastEntry().Data["app.id"])
+		assert.Equal(t, []string{"app.id", "app.user"}, overridden, "fields written again with the same value are not overridden")
+		assert.NotContains(t, hook.LastEntry().Data, "log.overridden")
+	}
+
+	assert.Nil(t, OverriddenFields(newRequest("GET", "http://example.com")))
+}
+
+func TestStructuredLoggingWithOverriddenFields(t *testing.T) {
+	logger := log.New("", "", "")
+	hook := test.NewLocal(logger.Logger)
+
+	handler := StructuredLogHandler(logger, fieldLayer(log.KV{"app.user": "outer", "http.user": "alice"},
+		fieldLayer(log.KV{"app.user": "inner"}, okHandler)), WithOverriddenFields())
+	handler.ServeHTTP(httptest.NewRecorder(), newRequest("GET", "http://example.com"))
+
+	assert.Equal(t, 1, len(hook.Entries))
+	assert.Equal(t, "inner", hook.LastEntry().Data["app.user"])
+	assert.Equal(t, "", hook.LastEntry().Data["http.user"], "the fields of the structured handler are written last")
+	assert.Equal(t, "app.user,http.user", hook.LastEntry().Data["log.overridden"])
+
+	handler = StructuredLogHandler(logger, fieldLayer(log.KV{"app.user": "outer"}, okHandler), WithOverriddenFields())
+	handler.ServeHTTP(httptest.NewRecorder(), newRequest("GET", "http://example.com"))
+	assert.Equal(t, 2, len(hook.Entries))
+	assert.NotContains(t, hook.LastEntry().Data, "log.overridden")
+}
@@ -0,0 +1,76 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"context"
+	"net/http"
+)
+
+type ownedHandler struct {
+	team    string
+	handler http.Handler
+}
+
+// ServeHTTP stores the owning team in the request context and the request's store for the outer handlers
+func (h ownedHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if store, ok := req.Context().Value(fieldsKey).(*requestFields); ok {
+		store.Lock()
+		store.owner = h.team
+		store.Unlock()
+	}
+	h.handler.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), ownerKey, h.team)))
+}
+
+// Owned returns a http.Handler that records `team` as the owner of h, so metrics can be attributed to the team
+//
+// The owner is added as a `team:` tag by the statsd handler with WithTeamTag, and can be read with Owner
+//
+// Usage:
+//  r.Handle("/payments", handlers.Owned("payments", payments))
+//  http.ListenAndServe(":1123", handlers.StatsdIoHandler(client, r, handlers.WithTeamTag()))
+func Owned(team string, h http.Handler) http.Handler {
+	return ownedHandler{team, h}
+}
+
+// Owner returns the team set by Owned, and false if it is not set
+func Owner(req *http.Request) (string, bool) {
+	team, ok := req.Context().Value(ownerKey).(string)
+	return team, ok
+}
+
+// requestOwner returns the team recorded by Owned in the request's store
+func requestOwner(req *http.Request) string {
+	store, ok := req.Context().Value(fieldsKey).(*requestFields)
+	if !ok {
+		return ""
+	}
+	store.Lock()
+	defer store.Unlock()
+	return store.owner
+}
+
+// WithTeamTag adds a `team:` tag to the statsd metrics with the team set by Owned, or `team:unknown` if the route is
+// not owned
+//
+// Usage:
+//  loggedRouter := handlers.StatsdIoHandler(client, r, handlers.WithTeamTag())
+func WithTeamTag() StatsdOption {
+	return func(h *statsdHandler) {
+		h.tags = append(h.tags, func(req *http.Request) []string {
+			team := requestOwner(req)
+			if team == "" {
+				team = "unknown"
+			}
+			return []string{"team:" + team}
+		})
+	}
+}
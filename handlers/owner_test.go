@@ -0,0 +1,66 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/DataDog/datadog-go/statsd"
+	"github.com/graze/golang-service/nettest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatsdTeamTag(t *testing.T) {
+	cases := map[string]struct {
+		handler  http.Handler
+		expected string
+	}{
+		"owned":   {Owned("payments", okHandler), "team:payments"},
+		"unowned": {okHandler, "team:unknown"},
+	}
+
+	done := make(chan string)
+	addr, sock, srvWg := nettest.CreateServer(t, "udp", "localhost:", done)
+	defer srvWg.Wait()
+	defer os.Remove(addr.String())
+	defer sock.Close()
+
+	client, err := statsd.New(addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for k, tc := range cases {
+		StatsdIoHandler(client, tc.handler, WithTeamTag()).ServeHTTP(httptest.NewRecorder(), newRequest("GET", "http://example.com"))
+
+		tags := "endpoint:/,statusCode:200,method:GET,protocol:HTTP/1.1," + tc.expected
+		assert.Regexp(t, `^request\.response_time:[0-9.]+\|ms\|#`+regexp.QuoteMeta(tags)+`$`, <-done, "test: %s", k)
+		assert.Equal(t, "request.count:1|c|#"+tags, <-done, "test: %s", k)
+	}
+}
+
+func TestOwner(t *testing.T) {
+	var team string
+	var ok bool
+	Owned("payments", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		team, ok = Owner(r)
+	})).ServeHTTP(httptest.NewRecorder(), newRequest("GET", "http://example.com"))
+
+	assert.True(t, ok)
+	assert.Equal(t, "payments", team)
+
+	_, ok = Owner(newRequest("GET", "http://example.com"))
+	assert.False(t, ok)
+}
@@ -0,0 +1,52 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+type queueStartHandler struct {
+	handler http.Handler
+}
+
+// ServeHTTP records the current time as the start of the request's queue time
+func (h queueStartHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	h.handler.ServeHTTP(w, WithQueueStart(req, time.Now().UTC()))
+}
+
+// QueueStartHandler records the time a request was received so the logging handlers can report how long it waited
+// before being handled
+//
+// It should be the outermost handler, with anything that can hold a request up (such as a connection limiter)
+// between it and the logging handlers. The statsd handler reports the wait as `request.queue_time`, and the structured
+// log handler logs the times as `ts.accept` and `ts.start`
+//
+// Usage:
+//  r := mux.NewRouter()
+//  loggedRouter := handlers.QueueStartHandler(limiter(handlers.StatsdHandler(r)))
+//  http.ListenAndServe(":1123", loggedRouter)
+func QueueStartHandler(h http.Handler) http.Handler {
+	return queueStartHandler{h}
+}
+
+// WithQueueStart returns req with `start` stored as the time it started waiting to be handled
+func WithQueueStart(req *http.Request, start time.Time) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), queueStartKey, start))
+}
+
+// QueueStart returns the time the request started waiting to be handled, and false if it was not recorded
+func QueueStart(req *http.Request) (time.Time, bool) {
+	start, ok := req.Context().Value(queueStartKey).(time.Time)
+	return start, ok
+}
@@ -0,0 +1,102 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/graze/golang-service/handlers/failure"
+	"github.com/graze/golang-service/log"
+)
+
+// PanicError is passed to the error handler when a panic is recovered
+//
+// The message does not contain the panic value unless IncludePanicValue is used, so it is safe to show to a client
+type PanicError struct {
+	value        interface{}
+	includeValue bool
+}
+
+func (e *PanicError) Error() string {
+	if e.includeValue {
+		return fmt.Sprintf("internal server error: %v", e.value)
+	}
+	return "internal server error"
+}
+
+type recoverHandler struct {
+	onError      failure.Handler
+	includeValue bool
+	handler      http.Handler
+}
+
+// RecoverOption changes the behaviour of the Recover middleware
+type RecoverOption func(h *recoverHandler)
+
+// IncludePanicValue includes the panic value in the error passed to the error handler when include is true
+//
+// This should not be used in production as the value can leak internal details to the client
+//
+// Usage:
+//  recoverer := handlers.Recover(onError, handlers.IncludePanicValue(env != "live"))
+func IncludePanicValue(include bool) RecoverOption {
+	return func(h *recoverHandler) {
+		h.includeValue = include
+	}
+}
+
+// ServeHTTP recovers from any panic in the handler, logs it and calls the error handler if the response has not been
+// started. http.ErrAbortHandler is panicked again so the server aborts the response
+func (h recoverHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	logger := MakeLogger(w)
+	defer func() {
+		if e := recover(); e != nil {
+			if isAbort(e) {
+				panic(e)
+			}
+			log.Ctx(req.Context()).With(log.KV{
+				"tag":    "critical_error",
+				"stack":  string(debug.Stack()),
+				"status": http.StatusInternalServerError,
+				"panic":  fmt.Sprintf("%v", e),
+			}).Error("panic occurred")
+			if logger.Status() == 0 {
+				h.onError.Handle(w, req, &PanicError{e, h.includeValue}, http.StatusInternalServerError)
+			}
+		}
+	}()
+	h.handler.ServeHTTP(logger, req)
+}
+
+// Recover returns a middleware that recovers from panics, logs the stack trace at the error level and calls onError
+// with a *PanicError and a status of 500
+//
+// onError is not called if the handler had already written the status, as the response can not be changed. A panic
+// with http.ErrAbortHandler is not recovered, so the server aborts the response as intended
+//
+// Usage:
+//  onError := failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, status int) {
+//      w.WriteHeader(status)
+//      w.Write([]byte(err.Error()))
+//  })
+//  recoverer := handlers.Recover(onError)
+//  http.ListenAndServe(":1123", handlers.StructuredHandler(recoverer(r)))
+func Recover(onError failure.Handler, opts ...RecoverOption) func(h http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		handler := &recoverHandler{onError: onError, handler: h}
+		for _, opt := range opts {
+			opt(handler)
+		}
+		return *handler
+	}
+}
@@ -0,0 +1,20 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+// +build go1.8
+
+package handlers
+
+import "net/http"
+
+// isAbort returns true if the panic value is http.ErrAbortHandler, which is used to abort a response on purpose
+func isAbort(e interface{}) bool {
+	return e == http.ErrAbortHandler
+}
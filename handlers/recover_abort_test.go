@@ -0,0 +1,41 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+// +build go1.8
+
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/graze/golang-service/handlers/failure"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecoverAbortHandler(t *testing.T) {
+	hook, restore := globalHook()
+	defer restore()
+
+	called := false
+	onError := failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, status int) {
+		called = true
+	})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		panic(http.ErrAbortHandler)
+	})
+
+	assert.Panics(t, func() {
+		Recover(onError)(handler).ServeHTTP(httptest.NewRecorder(), newRequest("GET", "http://example.com"))
+	}, "http.ErrAbortHandler is panicked again")
+	assert.False(t, called)
+	assert.Equal(t, 0, len(hook.Entries), "aborting is not logged as an error")
+}
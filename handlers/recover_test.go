@@ -0,0 +1,99 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/graze/golang-service/handlers/failure"
+	"github.com/graze/golang-service/log"
+	"github.com/stretchr/testify/assert"
+)
+
+var panicHandler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+	panic("secret database password")
+})
+
+var echoRecoverer = failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, status int) {
+	w.WriteHeader(status)
+	w.Write([]byte(err.Error()))
+})
+
+func TestRecover(t *testing.T) {
+	cases := map[string]struct {
+		opts []RecoverOption
+		body string
+	}{
+		"sanitised": {
+			[]RecoverOption{},
+			"internal server error",
+		},
+		"include value in development": {
+			[]RecoverOption{IncludePanicValue(true)},
+			"internal server error: secret database password",
+		},
+		"exclude value in production": {
+			[]RecoverOption{IncludePanicValue(false)},
+			"internal server error",
+		},
+	}
+
+	hook, restore := globalHook()
+	defer restore()
+
+	for k, tc := range cases {
+		hook.Reset()
+		rec := httptest.NewRecorder()
+		Recover(echoRecoverer, tc.opts...)(panicHandler).ServeHTTP(rec, newRequest("GET", "http://example.com"))
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code, "test: %s", k)
+		assert.Equal(t, tc.body, rec.Body.String(), "test: %s", k)
+		assert.Equal(t, 1, len(hook.Entries), "test: %s", k)
+		assert.Equal(t, log.ErrorLevel, hook.LastEntry().Level, "test: %s", k)
+		assert.Equal(t, "critical_error", hook.LastEntry().Data["tag"], "test: %s", k)
+		assert.Equal(t, "secret database password", hook.LastEntry().Data["panic"], "test: %s", k)
+		assert.Contains(t, hook.LastEntry().Data["stack"], "recover.go", "test: %s", k)
+	}
+}
+
+func TestRecoverWithoutAPanic(t *testing.T) {
+	rec := httptest.NewRecorder()
+	Recover(echoRecoverer)(okHandler).ServeHTTP(rec, newRequest("GET", "http://example.com"))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "ok\n", rec.Body.String())
+}
+
+func TestRecoverAfterTheStatusIsWritten(t *testing.T) {
+	hook, restore := globalHook()
+	defer restore()
+
+	called := false
+	onError := failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, status int) {
+		called = true
+		w.WriteHeader(status)
+	})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("partial"))
+		panic("lost connection")
+	})
+	rec := httptest.NewRecorder()
+	Recover(onError)(handler).ServeHTTP(rec, newRequest("GET", "http://example.com"))
+
+	assert.False(t, called, "the error handler is not called once the status is written")
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "partial", rec.Body.String())
+	assert.Equal(t, 1, len(hook.Entries), "the panic is still logged")
+	assert.Equal(t, "critical_error", hook.LastEntry().Data["tag"])
+}
@@ -16,6 +16,7 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -25,12 +26,30 @@ import (
 // LogServeHTTP creates a LoggingResponseWriter from `w` if applicable and calls `caller` with the request status, size,
 // time and duration
 func LogServeHTTP(w http.ResponseWriter, req *http.Request, handler http.Handler, caller func(w LoggingResponseWriter, req *http.Request, url url.URL, ts time.Time, dur time.Duration, status, size int)) {
-	t := time.Now().UTC()
-	mt := monotime.Now()
+	logServeHTTP(w, req, handler, nil, caller)
+}
+
+// logServeHTTP is LogServeHTTP using now for the time and duration, if now is nil the current time and a monotonic
+// clock for the duration are used
+func logServeHTTP(w http.ResponseWriter, req *http.Request, handler http.Handler, now func() time.Time, caller func(w LoggingResponseWriter, req *http.Request, url url.URL, ts time.Time, dur time.Duration, status, size int)) {
+	var t time.Time
+	var mt uint64
+	if now != nil {
+		t = now()
+	} else {
+		t = time.Now().UTC()
+		mt = monotime.Now()
+	}
 	logger := MakeLogger(w)
 	url := *req.URL
+	req = withRequestFields(req)
 	handler.ServeHTTP(logger, req)
-	dur := time.Duration(monotime.Now() - mt)
+	var dur time.Duration
+	if now != nil {
+		dur = now().Sub(t)
+	} else {
+		dur = time.Duration(monotime.Now() - mt)
+	}
 	caller(logger, req, url, t, dur, logger.Status(), logger.Size())
 }
 
@@ -42,7 +61,7 @@ func MakeLogger(w http.ResponseWriter) LoggingResponseWriter {
 		return logResponse
 	}
 
-	var logger LoggingResponseWriter = &responseLogger{w: w}
+	var logger detailedResponseWriter = &responseLogger{w: w}
 	if _, ok := w.(http.Hijacker); ok {
 		logger = &hijackLogger{responseLogger{w: w}}
 	}
@@ -65,12 +84,50 @@ type LoggingResponseWriter interface {
 	Size() int
 }
 
+// firstByteWriter is implemented by a LoggingResponseWriter that records when the response was started
+type firstByteWriter interface {
+	// FirstByte returns the time the status or first byte was written, or the zero time if nothing has been written
+	FirstByte() time.Time
+}
+
+// cacheControlWriter is implemented by a LoggingResponseWriter that records the Cache-Control header of the response
+type cacheControlWriter interface {
+	// CacheControl returns the Cache-Control header of the response when the status was written
+	CacheControl() string
+}
+
+// locationWriter is implemented by a LoggingResponseWriter that records the Location header of the response
+type locationWriter interface {
+	// Location returns the Location header of the response when the status was written
+	Location() string
+}
+
+// writeErrorWriter is implemented by a LoggingResponseWriter that records errors writing the response
+type writeErrorWriter interface {
+	// WriteError returns the first error from writing the response, such as when the client has gone away
+	WriteError() error
+}
+
+// detailedResponseWriter is a LoggingResponseWriter that records all of the optional details of the response, so they
+// are kept when it is wrapped
+type detailedResponseWriter interface {
+	LoggingResponseWriter
+	firstByteWriter
+	cacheControlWriter
+	locationWriter
+	writeErrorWriter
+}
+
 // responseLogger is wrapper of http.ResponseWriter that keeps track of its HTTP
 // status code and body size
 type responseLogger struct {
-	w      http.ResponseWriter
-	status int
-	size   int
+	w            http.ResponseWriter
+	status       int
+	size         int
+	firstByte    time.Time
+	cacheControl string
+	location     string
+	writeErr     error
 }
 
 func (l *responseLogger) Header() http.Header {
@@ -78,16 +135,29 @@ func (l *responseLogger) Header() http.Header {
 }
 
 func (l *responseLogger) Write(b []byte) (int, error) {
+	if l.firstByte.IsZero() {
+		l.firstByte = time.Now().UTC()
+	}
 	if l.status == 0 {
 		// The status will be StatusOK if WriteHeader has not been called yet
 		l.status = http.StatusOK
+		l.captureHeaders()
 	}
 	size, err := l.w.Write(b)
 	l.size += size
+	if err != nil && l.writeErr == nil {
+		l.writeErr = err
+	}
 	return size, err
 }
 
 func (l *responseLogger) WriteHeader(s int) {
+	if l.firstByte.IsZero() {
+		l.firstByte = time.Now().UTC()
+	}
+	if l.status == 0 {
+		l.captureHeaders()
+	}
 	l.w.WriteHeader(s)
 	l.status = s
 }
@@ -100,6 +170,28 @@ func (l *responseLogger) Size() int {
 	return l.size
 }
 
+func (l *responseLogger) FirstByte() time.Time {
+	return l.firstByte
+}
+
+// captureHeaders stores the response headers to log, as they can be changed after the status has been written
+func (l *responseLogger) captureHeaders() {
+	l.cacheControl = l.w.Header().Get("Cache-Control")
+	l.location = l.w.Header().Get("Location")
+}
+
+func (l *responseLogger) CacheControl() string {
+	return l.cacheControl
+}
+
+func (l *responseLogger) Location() string {
+	return l.location
+}
+
+func (l *responseLogger) WriteError() error {
+	return l.writeErr
+}
+
 func (l *responseLogger) Flush() {
 	f, ok := l.w.(http.Flusher)
 	if ok {
@@ -123,12 +215,12 @@ func (l *hijackLogger) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 }
 
 type closeNotifyWriter struct {
-	LoggingResponseWriter
+	detailedResponseWriter
 	http.CloseNotifier
 }
 
 type hijackCloseNotifier struct {
-	LoggingResponseWriter
+	detailedResponseWriter
 	http.Hijacker
 	http.CloseNotifier
 }
@@ -198,3 +290,99 @@ func getUserIP(req *http.Request) (net.IP, error) {
 	}
 	return userIP, nil
 }
+
+// protocolKind returns the class of traffic for a request: `websocket` for a websocket upgrade, `grpc-web` for a
+// gRPC-Web content type, or `rest` for everything else
+func protocolKind(req *http.Request) string {
+	if strings.EqualFold(req.Header.Get("Upgrade"), "websocket") {
+		return "websocket"
+	}
+	if strings.HasPrefix(strings.ToLower(req.Header.Get("Content-Type")), "application/grpc-web") {
+		return "grpc-web"
+	}
+	return "rest"
+}
+
+// hostPort splits req.Host into the hostname and port, inferring the port from the scheme if it is not supplied
+func hostPort(req *http.Request) (string, string) {
+	if host, port, err := net.SplitHostPort(req.Host); err == nil {
+		return host, port
+	}
+	host := strings.TrimSuffix(strings.TrimPrefix(req.Host, "["), "]")
+	if req.TLS != nil || req.URL.Scheme == "https" {
+		return host, "443"
+	}
+	return host, "80"
+}
+
+// keepAlive returns false if the connection will be closed after the request, from `req.Close`, a `Connection: close`
+// header, or a HTTP/1.0 request without a `Connection: keep-alive` header
+func keepAlive(req *http.Request) bool {
+	if req.Close {
+		return false
+	}
+	keep := req.ProtoAtLeast(1, 1)
+	for _, value := range req.Header["Connection"] {
+		for _, v := range strings.Split(value, ",") {
+			v = strings.TrimSpace(v)
+			switch {
+			case strings.EqualFold(v, "close"):
+				return false
+			case strings.EqualFold(v, "keep-alive"):
+				keep = true
+			}
+		}
+	}
+	return keep
+}
+
+// language returns the language tag with the highest quality in the Accept-Language header of req, the first is used
+// if several have the same quality. An empty string is returned if there is no header, or it is malformed
+func language(req *http.Request) string {
+	best, bestQ := "", 0.0
+	for _, value := range req.Header["Accept-Language"] {
+		for _, item := range strings.Split(value, ",") {
+			parts := strings.Split(item, ";")
+			tag := strings.TrimSpace(parts[0])
+			if tag == "" {
+				continue
+			}
+			if tag != "*" && !validLanguageTag(tag) {
+				return ""
+			}
+			q := 1.0
+			for _, param := range parts[1:] {
+				param = strings.TrimSpace(param)
+				if !strings.HasPrefix(param, "q=") {
+					continue
+				}
+				value, err := strconv.ParseFloat(param[2:], 64)
+				if err != nil || value < 0 || value > 1 {
+					return ""
+				}
+				q = value
+			}
+			if tag != "*" && q > bestQ {
+				best, bestQ = tag, q
+			}
+		}
+	}
+	return best
+}
+
+// validLanguageTag returns true if tag is made of subtags of 1 to 8 letters or digits, separated by hyphens, with a
+// first subtag of only letters
+func validLanguageTag(tag string) bool {
+	for i, subtag := range strings.Split(tag, "-") {
+		if len(subtag) < 1 || len(subtag) > 8 {
+			return false
+		}
+		for _, c := range subtag {
+			letter := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+			if !letter && (i == 0 || c < '0' || c > '9') {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -1,9 +1,12 @@
 package handlers
 
 import (
+	"bufio"
+	"crypto/tls"
 	"net"
 	"net/http"
 	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -126,3 +129,145 @@ func TestGetUserIP(t *testing.T) {
 		assert.Equal(t, tc.expected, ip, "test %s", k)
 	}
 }
+
+func TestProtocolKind(t *testing.T) {
+	cases := map[string]struct {
+		headers  map[string]string
+		expected string
+	}{
+		"rest": {
+			map[string]string{"Content-Type": "application/json"},
+			"rest",
+		},
+		"no headers": {
+			map[string]string{},
+			"rest",
+		},
+		"websocket": {
+			map[string]string{"Connection": "Upgrade", "Upgrade": "WebSocket"},
+			"websocket",
+		},
+		"other upgrade": {
+			map[string]string{"Connection": "Upgrade", "Upgrade": "h2c"},
+			"rest",
+		},
+		"grpc-web": {
+			map[string]string{"Content-Type": "application/grpc-web"},
+			"grpc-web",
+		},
+		"grpc-web with encoding": {
+			map[string]string{"Content-Type": "application/grpc-web-text+proto"},
+			"grpc-web",
+		},
+	}
+
+	for k, tc := range cases {
+		req := newRequest("POST", "http://example.com")
+		for h, v := range tc.headers {
+			req.Header.Set(h, v)
+		}
+		assert.Equal(t, tc.expected, protocolKind(req), "test %s", k)
+	}
+}
+
+func TestHostPort(t *testing.T) {
+	tlsRequest := newRequest("GET", "http://example.com")
+	tlsRequest.TLS = &tls.ConnectionState{}
+
+	cases := map[string]struct {
+		req      *http.Request
+		hostname string
+		port     string
+	}{
+		"host with port": {
+			newRequest("GET", "http://example.com:8080/path"),
+			"example.com",
+			"8080",
+		},
+		"host without port": {
+			newRequest("GET", "http://example.com/path"),
+			"example.com",
+			"80",
+		},
+		"https without port": {
+			newRequest("GET", "https://example.com/path"),
+			"example.com",
+			"443",
+		},
+		"tls without port": {
+			tlsRequest,
+			"example.com",
+			"443",
+		},
+		"ipv6 with port": {
+			newRequest("GET", "http://[::1]:8080/path"),
+			"::1",
+			"8080",
+		},
+		"ipv6 without port": {
+			newRequest("GET", "http://[::1]/path"),
+			"::1",
+			"80",
+		},
+	}
+
+	for k, tc := range cases {
+		hostname, port := hostPort(tc.req)
+		assert.Equal(t, tc.hostname, hostname, "test %s", k)
+		assert.Equal(t, tc.port, port, "test %s", k)
+	}
+}
+
+func TestKeepAlive(t *testing.T) {
+	closeRequest, err := http.ReadRequest(bufio.NewReader(strings.NewReader("GET / HTTP/1.1\r\nHost: example.com\r\nConnection: close\r\n\r\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	http10Request, err := http.ReadRequest(bufio.NewReader(strings.NewReader("GET / HTTP/1.0\r\nHost: example.com\r\n\r\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	headerRequest := newRequest("GET", "http://example.com")
+	headerRequest.Header.Set("Connection", "Upgrade, Close")
+	http10Plain := newRequest("GET", "http://example.com")
+	http10Plain.Proto, http10Plain.ProtoMajor, http10Plain.ProtoMinor = "HTTP/1.0", 1, 0
+	http10KeepAlive, err := http.ReadRequest(bufio.NewReader(strings.NewReader(
+		"GET / HTTP/1.0\r\nHost: example.com\r\nConnection: Keep-Alive\r\n\r\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		req      *http.Request
+		expected bool
+	}{
+		"default": {
+			newRequest("GET", "http://example.com"),
+			true,
+		},
+		"connection close": {
+			closeRequest,
+			false,
+		},
+		"http 1.0": {
+			http10Request,
+			false,
+		},
+		"close header": {
+			headerRequest,
+			false,
+		},
+		"http 1.0 without keep-alive": {
+			http10Plain,
+			false,
+		},
+		"http 1.0 with keep-alive": {
+			http10KeepAlive,
+			true,
+		},
+	}
+
+	for k, tc := range cases {
+		assert.Equal(t, tc.expected, keepAlive(tc.req), "test %s", k)
+	}
+}
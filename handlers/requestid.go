@@ -0,0 +1,76 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"context"
+	"net/http"
+)
+
+// DefaultRequestIDHeader is the header used to send the request id on outbound requests
+const DefaultRequestIDHeader = "X-Request-Id"
+
+// WithRequestID returns a copy of ctx with the request id set
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestID returns the request id stored in ctx, and false if there isn't one
+func RequestID(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey).(string)
+	return id, ok && id != ""
+}
+
+// requestIDTransport adds the request id from the context of outbound requests as a header
+type requestIDTransport struct {
+	header string
+	next   http.RoundTripper
+}
+
+// RoundTrip sets the request id header on a copy of req if the context has a request id and the header is not set
+func (t requestIDTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	id, ok := RequestID(req.Context())
+	if !ok || req.Header.Get(t.header) != "" {
+		return t.next.RoundTrip(req)
+	}
+
+	// a RoundTripper must not modify the request, so copy it and its headers
+	out := new(http.Request)
+	*out = *req
+	out.Header = make(http.Header, len(req.Header)+1)
+	for k, v := range req.Header {
+		out.Header[k] = v
+	}
+	out.Header.Set(t.header, id)
+	return t.next.RoundTrip(out)
+}
+
+// RequestIDTransport returns a http.RoundTripper that sends the request id from the context of each outbound request
+// in header (default: DefaultRequestIDHeader), using next (default: http.DefaultTransport) to make the request
+//
+// The request id is set by the LoggingContextHandler, and the outbound request must use the inbound request's context
+//
+// Usage:
+//  client := &http.Client{Transport: handlers.RequestIDTransport("", nil)}
+//  r.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+//      out, _ := http.NewRequest("GET", "http://other.service/path", nil)
+//      resp, err := client.Do(out.WithContext(r.Context()))
+//  })
+//  http.ListenAndServe(":1123", handlers.LoggingContextHandler(log.With(log.KV{}), r))
+func RequestIDTransport(header string, next http.RoundTripper) http.RoundTripper {
+	if header == "" {
+		header = DefaultRequestIDHeader
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return requestIDTransport{header, next}
+}
@@ -0,0 +1,92 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/graze/golang-service/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestIDTransport(t *testing.T) {
+	var received http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header
+	}))
+	defer server.Close()
+
+	cases := map[string]struct {
+		ctx      context.Context
+		header   string
+		headers  map[string]string
+		expected map[string]string
+	}{
+		"injects the request id": {
+			WithRequestID(context.Background(), "some-id"),
+			"",
+			map[string]string{},
+			map[string]string{"X-Request-Id": "some-id"},
+		},
+		"custom header": {
+			WithRequestID(context.Background(), "some-id"),
+			"X-Correlation-Id",
+			map[string]string{},
+			map[string]string{"X-Correlation-Id": "some-id", "X-Request-Id": ""},
+		},
+		"no request id": {
+			context.Background(),
+			"",
+			map[string]string{},
+			map[string]string{"X-Request-Id": ""},
+		},
+		"does not replace an existing header": {
+			WithRequestID(context.Background(), "some-id"),
+			"",
+			map[string]string{"X-Request-Id": "other-id"},
+			map[string]string{"X-Request-Id": "other-id"},
+		},
+	}
+
+	for k, tc := range cases {
+		received = nil
+		client := &http.Client{Transport: RequestIDTransport(tc.header, nil)}
+		req := newRequest("GET", server.URL)
+		for h, v := range tc.headers {
+			req.Header.Set(h, v)
+		}
+		resp, err := client.Do(req.WithContext(tc.ctx))
+		assert.Nil(t, err, "test: %s", k)
+		resp.Body.Close()
+
+		for h, v := range tc.expected {
+			assert.Equal(t, v, received.Get(h), "test: %s", k)
+		}
+		assert.Equal(t, len(tc.headers), len(req.Header), "test: %s - the original request is not modified", k)
+	}
+}
+
+func TestLoggingContextHandlerSetsTheRequestID(t *testing.T) {
+	var id string
+	var ok bool
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, ok = RequestID(r.Context())
+		assert.Equal(t, id, log.Ctx(r.Context()).Fields()["transaction"])
+	})
+
+	LoggingContextHandler(log.With(log.KV{}), h).ServeHTTP(httptest.NewRecorder(), newRequest("GET", "http://example.com"))
+
+	assert.True(t, ok)
+	assert.Regexp(t, `(?:[0-9a-z]+-){4}[0-9a-z]+`, id)
+}
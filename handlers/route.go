@@ -0,0 +1,41 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/graze/golang-service/log"
+)
+
+type routeMatchedHandler struct {
+	handler http.Handler
+}
+
+// ServeHTTP marks the request as matching a route in its log fields
+func (h routeMatchedHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	addLogFields(req, log.KV{"http.route_matched": true})
+	h.handler.ServeHTTP(w, req)
+}
+
+// RouteMatched returns a http.Handler that marks requests for h as matching a route, so requests that fell through to
+// the router's not found or catch-all handler can be found in the logs
+//
+// The structured log handler logs `http.route_matched=true` for marked requests, and `http.route_matched=false` for
+// the rest
+//
+// Usage:
+//  r.Handle("/users", handlers.RouteMatched(users))
+//  r.NotFoundHandler = notFound
+//  http.ListenAndServe(":1123", handlers.StructuredHandler(r))
+func RouteMatched(h http.Handler) http.Handler {
+	return routeMatchedHandler{h}
+}
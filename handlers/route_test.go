@@ -0,0 +1,62 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Sirupsen/logrus/hooks/test"
+	"github.com/graze/golang-service/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouteMatched(t *testing.T) {
+	cases := map[string]struct {
+		path    string
+		status  int
+		matched bool
+	}{
+		"matched":   {"/users", http.StatusOK, true},
+		"unmatched": {"/nowhere", http.StatusNotFound, false},
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/users", RouteMatched(okHandler))
+	mux.Handle("/", http.NotFoundHandler())
+
+	for k, tc := range cases {
+		logger := log.New("", "", "")
+		hook := test.NewLocal(logger.Logger)
+		handler := StructuredLogHandler(logger, mux, WithOverriddenFields())
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, newRequest("GET", "http://example.com"+tc.path))
+
+		assert.Equal(t, tc.status, rec.Code, "test: %s", k)
+		if assert.Equal(t, 1, len(hook.Entries), "test: %s", k) {
+			assert.Equal(t, tc.matched, hook.LastEntry().Data["http.route_matched"], "test: %s", k)
+			assert.NotContains(t, hook.LastEntry().Data, "log.overridden", "test: %s", k)
+		}
+	}
+}
+
+func TestRouteMatchedDefault(t *testing.T) {
+	logger := log.New("", "", "")
+	hook := test.NewLocal(logger.Logger)
+
+	StructuredLogHandler(logger, http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), newRequest("GET", "http://example.com/nowhere"))
+	assert.Equal(t, false, hook.LastEntry().Data["http.route_matched"], "unmarked requests are logged as not matched")
+
+	StructuredLogHandler(logger, RouteMatched(okHandler)).ServeHTTP(httptest.NewRecorder(), newRequest("GET", "http://example.com/users"))
+	assert.Equal(t, true, hook.LastEntry().Data["http.route_matched"])
+}
@@ -0,0 +1,68 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"strings"
+	"sync/atomic"
+)
+
+// sampleRule logs 1 in every n requests with a path matching pattern
+type sampleRule struct {
+	pattern string
+	n       uint64
+	count   uint64
+}
+
+// matches returns true if path matches the rule's pattern, a pattern ending in `*` matches any path with that prefix
+func (r *sampleRule) matches(path string) bool {
+	if strings.HasSuffix(r.pattern, "*") {
+		return strings.HasPrefix(path, strings.TrimSuffix(r.pattern, "*"))
+	}
+	return path == r.pattern
+}
+
+// sample returns true if this request should be logged, the first of every n requests is logged
+func (r *sampleRule) sample() bool {
+	return (atomic.AddUint64(&r.count, 1)-1)%r.n == 0
+}
+
+// WithSampleRate only logs 1 in every n requests with a path matching pattern
+//
+// A pattern ending in `*` matches any path starting with the rest of the pattern. The first matching rule is used and
+// paths without a matching rule are always logged. Responses with a 5xx status are always logged
+//
+// Usage:
+//  loggedRouter := handlers.StructuredHandler(r,
+//      handlers.WithSampleRate("/admin/*", 1),
+//      handlers.WithSampleRate("/search", 100))
+func WithSampleRate(pattern string, n int) StructuredOption {
+	if n < 1 {
+		n = 1
+	}
+	rule := &sampleRule{pattern: pattern, n: uint64(n)}
+	return func(h *structuredHandler) {
+		h.samples = append(h.samples, rule)
+	}
+}
+
+// sampled returns true if a request for path with the response status should be logged
+func (h structuredHandler) sampled(path string, status int) bool {
+	if status >= 500 {
+		return true
+	}
+	for _, rule := range h.samples {
+		if rule.matches(path) {
+			return rule.sample()
+		}
+	}
+	return true
+}
@@ -0,0 +1,54 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Sirupsen/logrus/hooks/test"
+	"github.com/graze/golang-service/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithSampleRate(t *testing.T) {
+	cases := map[string]struct {
+		path     string
+		status   int
+		expected int
+	}{
+		"sampled path":         {"/search", http.StatusOK, 2},
+		"prefix path":          {"/admin/users", http.StatusOK, 10},
+		"unmatched path":       {"/other", http.StatusOK, 10},
+		"sampled client error": {"/search", http.StatusNotFound, 2},
+		"sampled server error": {"/search", http.StatusBadGateway, 10},
+	}
+
+	for k, tc := range cases {
+		logger := log.New("", "", "")
+		hook := test.NewLocal(logger.Logger)
+
+		status := tc.status
+		handler := StructuredLogHandler(logger, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(status)
+		}),
+			WithSampleRate("/admin/*", 1),
+			WithSampleRate("/search", 5),
+		)
+
+		for i := 0; i < 10; i++ {
+			handler.ServeHTTP(httptest.NewRecorder(), newRequest("GET", "http://example.com"+tc.path))
+		}
+
+		assert.Equal(t, tc.expected, len(hook.Entries), "test: %s", k)
+	}
+}
@@ -0,0 +1,462 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/graze/golang-service/handlers/failure"
+	"github.com/graze/golang-service/log"
+)
+
+// SchemaViolation is a single way a value did not match a JSON Schema
+type SchemaViolation struct {
+	// Path is the location of the value in the document, such as `$.items[0].name`
+	Path string
+	// Message describes why the value did not match
+	Message string
+}
+
+// SchemaError is returned when a request body does not match a JSON Schema
+type SchemaError struct {
+	Violations []SchemaViolation
+}
+
+func (e *SchemaError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		messages[i] = fmt.Sprintf("%s: %s", v.Path, v.Message)
+	}
+	return fmt.Sprintf("request body does not match the schema: %s", strings.Join(messages, ", "))
+}
+
+// JSONSchema is a compiled JSON Schema
+//
+// It supports the validation keywords: type, enum, properties, required, additionalProperties, items, minItems,
+// maxItems, minimum, maximum, minLength, maxLength and pattern. Annotations (such as title and description) and
+// extensions starting with `x-` are ignored, any other keyword is a compile error rather than being skipped
+type JSONSchema struct {
+	types                []string
+	enum                 []interface{}
+	properties           map[string]*JSONSchema
+	required             []string
+	additionalProperties *JSONSchema
+	noAdditional         bool
+	items                *JSONSchema
+	minItems, maxItems   *int
+	minimum, maximum     *float64
+	minLength, maxLength *int
+	pattern              *regexp.Regexp
+}
+
+// CompileJSONSchema parses a JSON Schema document, returning an error if it is not valid or uses a keyword in an
+// unsupported way
+func CompileJSONSchema(schema []byte) (*JSONSchema, error) {
+	var doc interface{}
+	if err := json.Unmarshal(schema, &doc); err != nil {
+		return nil, fmt.Errorf("schema is not valid json: %s", err.Error())
+	}
+	return compileSchema(doc, "$")
+}
+
+// MustCompileJSONSchema is CompileJSONSchema that panics if the schema is not valid, for schemas defined in code
+func MustCompileJSONSchema(schema []byte) *JSONSchema {
+	s, err := CompileJSONSchema(schema)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// schemaKeywords are the keywords allowed in a schema, true for the validation keywords and false for the annotations
+// that are ignored
+var schemaKeywords = map[string]bool{
+	"type": true, "enum": true, "properties": true, "required": true, "additionalProperties": true, "items": true,
+	"minItems": true, "maxItems": true, "minimum": true, "maximum": true, "minLength": true, "maxLength": true,
+	"pattern": true,
+
+	"$schema": false, "$id": false, "id": false, "$comment": false, "title": false, "description": false,
+	"default": false, "examples": false, "readOnly": false, "writeOnly": false, "deprecated": false,
+}
+
+// schemaTypes are the values allowed for the type keyword
+var schemaTypes = map[string]bool{
+	"null": true, "boolean": true, "object": true, "array": true, "number": true, "integer": true, "string": true,
+}
+
+// compileSchema compiles the schema doc found at path
+func compileSchema(doc interface{}, path string) (*JSONSchema, error) {
+	if b, ok := doc.(bool); ok {
+		if b {
+			return &JSONSchema{}, nil
+		}
+		return &JSONSchema{enum: []interface{}{}}, nil
+	}
+	m, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: a schema must be an object or boolean", path)
+	}
+	keywords := make([]string, 0, len(m))
+	for keyword := range m {
+		keywords = append(keywords, keyword)
+	}
+	sort.Strings(keywords)
+	for _, keyword := range keywords {
+		if _, ok := schemaKeywords[keyword]; !ok && !strings.HasPrefix(keyword, "x-") {
+			return nil, fmt.Errorf("%s.%s: unsupported keyword", path, keyword)
+		}
+	}
+	s := &JSONSchema{}
+
+	switch t := m["type"].(type) {
+	case nil:
+	case string:
+		s.types = []string{t}
+	case []interface{}:
+		for _, v := range t {
+			name, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s.type: must be a string or an array of strings", path)
+			}
+			s.types = append(s.types, name)
+		}
+	default:
+		return nil, fmt.Errorf("%s.type: must be a string or an array of strings", path)
+	}
+	for _, t := range s.types {
+		if !schemaTypes[t] {
+			return nil, fmt.Errorf("%s.type: unknown type: %s", path, t)
+		}
+	}
+
+	if enum, ok := m["enum"]; ok {
+		values, ok := enum.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s.enum: must be an array", path)
+		}
+		s.enum = values
+	}
+
+	if props, ok := m["properties"]; ok {
+		pm, ok := props.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s.properties: must be an object", path)
+		}
+		s.properties = make(map[string]*JSONSchema, len(pm))
+		for name, prop := range pm {
+			compiled, err := compileSchema(prop, path+".properties."+name)
+			if err != nil {
+				return nil, err
+			}
+			s.properties[name] = compiled
+		}
+	}
+
+	if required, ok := m["required"]; ok {
+		names, ok := required.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s.required: must be an array of strings", path)
+		}
+		for _, v := range names {
+			name, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s.required: must be an array of strings", path)
+			}
+			s.required = append(s.required, name)
+		}
+	}
+
+	switch additional := m["additionalProperties"].(type) {
+	case nil:
+	case bool:
+		s.noAdditional = !additional
+	default:
+		compiled, err := compileSchema(additional, path+".additionalProperties")
+		if err != nil {
+			return nil, err
+		}
+		s.additionalProperties = compiled
+	}
+
+	if items, ok := m["items"]; ok {
+		compiled, err := compileSchema(items, path+".items")
+		if err != nil {
+			return nil, err
+		}
+		s.items = compiled
+	}
+
+	var err error
+	if s.minItems, err = schemaInt(m, "minItems", path); err != nil {
+		return nil, err
+	}
+	if s.maxItems, err = schemaInt(m, "maxItems", path); err != nil {
+		return nil, err
+	}
+	if s.minLength, err = schemaInt(m, "minLength", path); err != nil {
+		return nil, err
+	}
+	if s.maxLength, err = schemaInt(m, "maxLength", path); err != nil {
+		return nil, err
+	}
+	if s.minimum, err = schemaNumber(m, "minimum", path); err != nil {
+		return nil, err
+	}
+	if s.maximum, err = schemaNumber(m, "maximum", path); err != nil {
+		return nil, err
+	}
+
+	if pattern, ok := m["pattern"]; ok {
+		expr, ok := pattern.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s.pattern: must be a string", path)
+		}
+		if s.pattern, err = regexp.Compile(expr); err != nil {
+			return nil, fmt.Errorf("%s.pattern: %s", path, err.Error())
+		}
+	}
+	return s, nil
+}
+
+// schemaNumber returns the number for keyword in m, or nil if it is not set
+func schemaNumber(m map[string]interface{}, keyword, path string) (*float64, error) {
+	v, ok := m[keyword]
+	if !ok {
+		return nil, nil
+	}
+	n, ok := v.(float64)
+	if !ok {
+		return nil, fmt.Errorf("%s.%s: must be a number", path, keyword)
+	}
+	return &n, nil
+}
+
+// schemaInt returns the non negative integer for keyword in m, or nil if it is not set
+func schemaInt(m map[string]interface{}, keyword, path string) (*int, error) {
+	n, err := schemaNumber(m, keyword, path)
+	if err != nil || n == nil {
+		return nil, err
+	}
+	if *n < 0 || *n != math.Trunc(*n) {
+		return nil, fmt.Errorf("%s.%s: must be a non negative integer", path, keyword)
+	}
+	i := int(*n)
+	return &i, nil
+}
+
+// Validate returns the ways value (decoded by encoding/json) does not match the schema, or nil if it matches
+func (s *JSONSchema) Validate(value interface{}) []SchemaViolation {
+	var violations []SchemaViolation
+	s.validate(value, "$", &violations)
+	return violations
+}
+
+// validate adds the ways value at path does not match the schema to violations
+func (s *JSONSchema) validate(value interface{}, path string, violations *[]SchemaViolation) {
+	add := func(format string, args ...interface{}) {
+		*violations = append(*violations, SchemaViolation{path, fmt.Sprintf(format, args...)})
+	}
+
+	if len(s.types) > 0 && !s.matchesType(value) {
+		add("expected %s, got %s", strings.Join(s.types, " or "), jsonType(value))
+		return
+	}
+	if s.enum != nil && !inEnum(s.enum, value) {
+		if len(s.enum) == 0 {
+			add("no value is allowed")
+		} else {
+			add("value is not one of the allowed values")
+		}
+		return
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, name := range s.required {
+			if _, ok := v[name]; !ok {
+				add("missing required property: %s", name)
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if prop, ok := s.properties[name]; ok {
+				prop.validate(v[name], path+"."+name, violations)
+			} else if s.noAdditional {
+				add("unexpected property: %s", name)
+			} else if s.additionalProperties != nil {
+				s.additionalProperties.validate(v[name], path+"."+name, violations)
+			}
+		}
+	case []interface{}:
+		if s.minItems != nil && len(v) < *s.minItems {
+			add("expected at least %d items, got %d", *s.minItems, len(v))
+		}
+		if s.maxItems != nil && len(v) > *s.maxItems {
+			add("expected at most %d items, got %d", *s.maxItems, len(v))
+		}
+		if s.items != nil {
+			for i, item := range v {
+				s.items.validate(item, fmt.Sprintf("%s[%d]", path, i), violations)
+			}
+		}
+	case float64:
+		if s.minimum != nil && v < *s.minimum {
+			add("expected a minimum of %v, got %v", *s.minimum, v)
+		}
+		if s.maximum != nil && v > *s.maximum {
+			add("expected a maximum of %v, got %v", *s.maximum, v)
+		}
+	case string:
+		length := utf8.RuneCountInString(v)
+		if s.minLength != nil && length < *s.minLength {
+			add("expected a minimum length of %d, got %d", *s.minLength, length)
+		}
+		if s.maxLength != nil && length > *s.maxLength {
+			add("expected a maximum length of %d, got %d", *s.maxLength, length)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			add("does not match the pattern: %s", s.pattern.String())
+		}
+	}
+}
+
+// matchesType returns true if value is one of the types of the schema
+func (s *JSONSchema) matchesType(value interface{}) bool {
+	actual := jsonType(value)
+	for _, t := range s.types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonType returns the JSON Schema type of a value decoded by encoding/json, numbers without a fraction are integers
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	}
+	return "unknown"
+}
+
+// inEnum returns true if value is equal to one of the enum values
+func inEnum(enum []interface{}, value interface{}) bool {
+	for _, e := range enum {
+		if reflect.DeepEqual(e, value) {
+			return true
+		}
+	}
+	return false
+}
+
+type validateSchemaHandler struct {
+	jsonOptions
+	schema  *JSONSchema
+	onError failure.Handler
+	handler http.Handler
+}
+
+// ServeHTTP checks the body of any json request matches the schema before calling the handler
+func (h validateSchemaHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Body == nil || (!hasBody(req) && !isJSONRequest(req)) {
+		h.handler.ServeHTTP(w, req)
+		return
+	}
+	// a body of any other type is rejected, so the schema can not be skipped by changing the content type
+	if !isJSONRequest(req) {
+		contentType := req.Header.Get("Content-Type")
+		log.Ctx(req.Context()).With(log.KV{
+			"tag":               "unsupported_content_type",
+			"http.method":       req.Method,
+			"http.content_type": contentType,
+			"http.status":       http.StatusUnsupportedMediaType,
+		}).Warnf("content type: %q is not supported", contentType)
+		h.onError.Handle(w, req, &UnsupportedContentTypeError{contentType}, http.StatusUnsupportedMediaType)
+		return
+	}
+
+	data, err := ioutil.ReadAll(&limitedReader{Reader: req.Body, n: h.maxBytes})
+	req.Body = readCloser{bytes.NewReader(data), req.Body}
+	if err == errTooLarge {
+		addLogFields(req, log.KV{"http.json_too_large": true})
+		h.onError.Handle(w, req, &JSONTooLargeError{h.maxBytes}, http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		h.onError.Handle(w, req, err, http.StatusBadRequest)
+		return
+	}
+	var value interface{}
+	if err = checkJSON(bytes.NewReader(data)); err == nil && len(bytes.TrimSpace(data)) > 0 {
+		err = json.Unmarshal(data, &value)
+	}
+	if err != nil {
+		addLogFields(req, log.KV{"http.json_invalid": true})
+		h.onError.Handle(w, req, &InvalidJSONError{err}, http.StatusBadRequest)
+		return
+	}
+
+	if violations := h.schema.Validate(value); len(violations) > 0 {
+		addLogFields(req, log.KV{"http.schema_invalid": true})
+		h.onError.Handle(w, req, &SchemaError{violations}, http.StatusUnprocessableEntity)
+		return
+	}
+	h.handler.ServeHTTP(w, req)
+}
+
+// ValidateSchema returns a middleware that checks requests with a body have a `Content-Type` of `application/json` and
+// a body matching schema
+//
+// A body with any other content type, or none, is rejected by calling onError with an *UnsupportedContentTypeError and
+// a status of 415, so the schema can not be skipped by changing or leaving out the content type. If the body is not well formed json,
+// onError is called with an *InvalidJSONError and a status of 400. If it does not match the schema, onError is called
+// with a *SchemaError listing each violation and a status of 422, and `http.schema_invalid=true` is logged. An empty
+// json body is validated as null. The body can still be read by the handler afterwards. Bodies larger than
+// DefaultMaxJSONBytes (or WithMaxJSONBytes) are rejected as in RequireJSON. Requests without a body are passed straight
+// through
+//
+// Usage:
+//  schema := handlers.MustCompileJSONSchema([]byte(`{"type": "object", "required": ["name"]}`))
+//  validate := handlers.ValidateSchema(schema, failure.HandlerFunc(onError), handlers.WithMaxJSONBytes(64*1024))
+//  http.ListenAndServe(":1123", handlers.StructuredHandler(validate(r)))
+func ValidateSchema(schema *JSONSchema, onError failure.Handler, opts ...JSONOption) func(h http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return validateSchemaHandler{newJSONOptions(opts), schema, onError, h}
+	}
+}
@@ -0,0 +1,250 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Sirupsen/logrus/hooks/test"
+	"github.com/graze/golang-service/handlers/failure"
+	"github.com/graze/golang-service/log"
+	"github.com/stretchr/testify/assert"
+)
+
+const orderSchema = `{
+	"$schema": "http://json-schema.org/draft-04/schema#",
+	"title": "order",
+	"type": "object",
+	"required": ["id", "items"],
+	"additionalProperties": false,
+	"properties": {
+		"id": {"type": "integer", "minimum": 1},
+		"status": {"enum": ["open", "closed"]},
+		"note": {"type": ["string", "null"], "maxLength": 10},
+		"email": {"type": "string", "pattern": "^[^@]+@[^@]+$"},
+		"items": {
+			"type": "array",
+			"minItems": 1,
+			"items": {
+				"type": "object",
+				"required": ["sku"],
+				"properties": {
+					"sku": {"type": "string", "minLength": 3},
+					"quantity": {"type": "number", "maximum": 100}
+				}
+			}
+		}
+	}
+}`
+
+func TestValidateSchema(t *testing.T) {
+	cases := map[string]struct {
+		contentType string
+		body        string
+		status      int
+		violations  []SchemaViolation
+	}{
+		"valid": {
+			"application/json", `{"id": 1, "status": "open", "note": null, "items": [{"sku": "abc", "quantity": 2.5}]}`,
+			http.StatusOK, nil,
+		},
+		"missing required": {
+			"application/json", `{"items": [{"sku": "abc"}]}`,
+			http.StatusUnprocessableEntity, []SchemaViolation{{"$", "missing required property: id"}},
+		},
+		"wrong type": {
+			"application/json", `{"id": "1", "items": [{"sku": "abc"}]}`,
+			http.StatusUnprocessableEntity, []SchemaViolation{{"$.id", "expected integer, got string"}},
+		},
+		"not an integer": {
+			"application/json", `{"id": 1.5, "items": [{"sku": "abc"}]}`,
+			http.StatusUnprocessableEntity, []SchemaViolation{{"$.id", "expected integer, got number"}},
+		},
+		"nested violations": {
+			"application/json", `{"id": 0, "items": [{"sku": "ab", "quantity": 101}, {}]}`,
+			http.StatusUnprocessableEntity, []SchemaViolation{
+				{"$.id", "expected a minimum of 1, got 0"},
+				{"$.items[0].quantity", "expected a maximum of 100, got 101"},
+				{"$.items[0].sku", "expected a minimum length of 3, got 2"},
+				{"$.items[1]", "missing required property: sku"},
+			},
+		},
+		"enum, pattern and length": {
+			"application/json", `{"id": 1, "status": "lost", "note": "far too long a note", "email": "nope", "items": [{"sku": "abc"}]}`,
+			http.StatusUnprocessableEntity, []SchemaViolation{
+				{"$.email", "does not match the pattern: ^[^@]+@[^@]+$"},
+				{"$.note", "expected a maximum length of 10, got 19"},
+				{"$.status", "value is not one of the allowed values"},
+			},
+		},
+		"additional property": {
+			"application/json", `{"id": 1, "items": [{"sku": "abc"}], "extra": true}`,
+			http.StatusUnprocessableEntity, []SchemaViolation{{"$", "unexpected property: extra"}},
+		},
+		"empty array": {
+			"application/json", `{"id": 1, "items": []}`,
+			http.StatusUnprocessableEntity, []SchemaViolation{{"$.items", "expected at least 1 items, got 0"}},
+		},
+		"empty body": {
+			"application/json", ``,
+			http.StatusUnprocessableEntity, []SchemaViolation{{"$", "expected object, got null"}},
+		},
+		"invalid json": {
+			"application/json", `{"id": 1,`,
+			http.StatusBadRequest, nil,
+		},
+		"trailing data": {
+			"application/json", `{"id": 1, "items": [{"sku": "abc"}]} {}`,
+			http.StatusBadRequest, nil,
+		},
+		"not json": {
+			"text/plain", `hello`,
+			http.StatusUnsupportedMediaType, nil,
+		},
+		"invalid order as text": {
+			"text/plain", `{"items": []}`,
+			http.StatusUnsupportedMediaType, nil,
+		},
+		"invalid order without a content type": {
+			"", `{"items": []}`,
+			http.StatusUnsupportedMediaType, nil,
+		},
+		"no body": {
+			"", ``,
+			http.StatusOK, nil,
+		},
+	}
+
+	schema, err := CompileJSONSchema([]byte(orderSchema))
+	assert.Nil(t, err)
+
+	for k, tc := range cases {
+		var handled error
+		onError := failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, status int) {
+			handled = err
+			w.WriteHeader(status)
+		})
+		var body string
+		handler := ValidateSchema(schema, onError)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, _ := ioutil.ReadAll(r.Body)
+			body = string(b)
+		}))
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, bodyRequest("POST", "http://example.com/orders", tc.contentType, tc.body))
+
+		assert.Equal(t, tc.status, rec.Code, "test: %s", k)
+		switch tc.status {
+		case http.StatusOK:
+			assert.Equal(t, tc.body, body, "test: %s the body can be read by the handler", k)
+		case http.StatusBadRequest:
+			assert.IsType(t, &InvalidJSONError{}, handled, "test: %s", k)
+		case http.StatusUnsupportedMediaType:
+			assert.IsType(t, &UnsupportedContentTypeError{}, handled, "test: %s", k)
+			assert.Equal(t, "", body, "test: %s the handler is not called", k)
+		default:
+			if assert.IsType(t, &SchemaError{}, handled, "test: %s", k) {
+				assert.Equal(t, tc.violations, handled.(*SchemaError).Violations, "test: %s", k)
+			}
+		}
+	}
+}
+
+func TestValidateSchemaLogging(t *testing.T) {
+	logger := log.New("", "", "")
+	hook := test.NewLocal(logger.Logger)
+	onError := failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, status int) {
+		w.WriteHeader(status)
+	})
+	handler := StructuredLogHandler(logger, ValidateSchema(MustCompileJSONSchema([]byte(orderSchema)), onError)(okHandler))
+
+	handler.ServeHTTP(httptest.NewRecorder(), bodyRequest("POST", "http://example.com/orders", "application/json", `{}`))
+
+	assert.Equal(t, 1, len(hook.Entries))
+	assert.Equal(t, http.StatusUnprocessableEntity, hook.LastEntry().Data["http.status"])
+	assert.Equal(t, true, hook.LastEntry().Data["http.schema_invalid"])
+}
+
+func TestValidateSchemaMaxBytes(t *testing.T) {
+	logger := log.New("", "", "")
+	hook := test.NewLocal(logger.Logger)
+	var handled error
+	onError := failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, status int) {
+		handled = err
+		w.WriteHeader(status)
+	})
+	schema := MustCompileJSONSchema([]byte(`{"type": "array"}`))
+	handler := StructuredLogHandler(logger, ValidateSchema(schema, onError, WithMaxJSONBytes(9))(okHandler))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, bodyRequest("POST", "http://example.com/orders", "application/json", `[1,2,3,4]`))
+	assert.Equal(t, http.StatusOK, rec.Code, "a body at the limit is validated")
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, bodyRequest("POST", "http://example.com/orders", "application/json", `[1,2,3,4,5]`))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	if assert.IsType(t, &JSONTooLargeError{}, handled) {
+		assert.Equal(t, int64(9), handled.(*JSONTooLargeError).MaxBytes())
+	}
+	assert.Equal(t, true, hook.LastEntry().Data["http.json_too_large"])
+	assert.NotContains(t, hook.LastEntry().Data, "http.schema_invalid")
+}
+
+func TestCompileJSONSchemaErrors(t *testing.T) {
+	cases := map[string]string{
+		"invalid json":         `{`,
+		"not a schema":         `"string"`,
+		"unknown type":         `{"type": "date"}`,
+		"invalid type":         `{"type": 1}`,
+		"invalid enum":         `{"enum": "a"}`,
+		"invalid properties":   `{"properties": []}`,
+		"invalid required":     `{"required": [1]}`,
+		"invalid nested":       `{"properties": {"a": {"type": "date"}}}`,
+		"invalid items":        `{"items": 1}`,
+		"negative length":      `{"minLength": -1}`,
+		"fractional length":    `{"maxItems": 1.5}`,
+		"invalid minimum":      `{"minimum": "1"}`,
+		"invalid pattern":      `{"pattern": "("}`,
+		"invalid pattern type": `{"pattern": 1}`,
+		"all of":               `{"allOf": [{"type": "string"}]}`,
+		"any of":               `{"anyOf": [{"type": "string"}]}`,
+		"one of":               `{"oneOf": [{"type": "string"}]}`,
+		"ref":                  `{"$ref": "#/definitions/a"}`,
+		"format":               `{"type": "string", "format": "email"}`,
+		"exclusive minimum":    `{"exclusiveMinimum": 1}`,
+		"min properties":       `{"minProperties": 1}`,
+		"nested unsupported":   `{"properties": {"a": {"items": {"uniqueItems": true}}}}`,
+	}
+
+	for k, schema := range cases {
+		_, err := CompileJSONSchema([]byte(schema))
+		assert.NotNil(t, err, "test: %s", k)
+	}
+	assert.Panics(t, func() { MustCompileJSONSchema([]byte(`{`)) })
+
+	_, err := CompileJSONSchema([]byte(`{"properties": {"email": {"type": "string", "format": "email"}}}`))
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "$.properties.email.format: unsupported keyword", err.Error())
+	}
+	_, err = CompileJSONSchema([]byte(`{"title": "a", "description": "b", "default": 1, "x-internal": true}`))
+	assert.Nil(t, err, "annotations and extensions are ignored")
+}
+
+func TestJSONSchemaBooleans(t *testing.T) {
+	schema := MustCompileJSONSchema([]byte(`{"properties": {"any": true, "none": false}, "additionalProperties": {"type": "string"}}`))
+
+	assert.Nil(t, schema.Validate(map[string]interface{}{"any": 1.0, "other": "a"}))
+	assert.Equal(t, []SchemaViolation{{"$.none", "no value is allowed"}, {"$.other", "expected string, got integer"}},
+		schema.Validate(map[string]interface{}{"none": 1.0, "other": 2.0}))
+}
@@ -0,0 +1,39 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"sync/atomic"
+
+	"github.com/graze/golang-service/log"
+)
+
+// logSequence is the sequence number of the last entry written by a structured handler in this process
+var logSequence uint64
+
+// WithSequence adds a `seq` field to each entry, a number that increases by 1 for every entry written by the structured
+// handlers in the process, to order entries with the same timestamp. It starts again from 1 when the process restarts
+//
+// Usage:
+//  loggedRouter := handlers.StructuredHandler(r, handlers.WithSequence())
+func WithSequence() StructuredOption {
+	return func(h *structuredHandler) {
+		h.sequence = true
+	}
+}
+
+// withSequence adds the next sequence number to logger if the handler has WithSequence set
+func (h structuredHandler) withSequence(logger log.FieldLogger) log.FieldLogger {
+	if !h.sequence {
+		return logger
+	}
+	return logger.With(log.KV{"seq": atomic.AddUint64(&logSequence, 1)})
+}
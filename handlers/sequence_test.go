@@ -0,0 +1,53 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Sirupsen/logrus/hooks/test"
+	"github.com/graze/golang-service/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithSequence(t *testing.T) {
+	logger := log.New("", "", "")
+	hook := test.NewLocal(logger.Logger)
+	handler := StructuredLogHandler(logger, okHandler, WithSequence())
+
+	var last uint64
+	for i := 0; i < 5; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), newRequest("GET", "http://example.com"))
+		seq, ok := hook.LastEntry().Data["seq"].(uint64)
+		assert.True(t, ok, "request: %d", i)
+		assert.True(t, seq > last, "request: %d seq: %d is after: %d", i, seq, last)
+		if i > 0 {
+			assert.Equal(t, last+1, seq, "request: %d", i)
+		}
+		last = seq
+	}
+
+	hook.Reset()
+	StructuredLogHandler(logger, okHandler).ServeHTTP(httptest.NewRecorder(), newRequest("GET", "http://example.com"))
+	assert.NotContains(t, hook.LastEntry().Data, "seq", "the sequence is optional")
+}
+
+func TestWithSequenceStarted(t *testing.T) {
+	logger := log.New("", "", "")
+	hook := test.NewLocal(logger.Logger)
+	StructuredLogHandler(logger, okHandler, WithSequence(), WithRequestStarted()).
+		ServeHTTP(httptest.NewRecorder(), newRequest("GET", "http://example.com"))
+
+	assert.Equal(t, 2, len(hook.Entries))
+	started, handled := hook.Entries[0].Data["seq"].(uint64), hook.Entries[1].Data["seq"].(uint64)
+	assert.Equal(t, started+1, handled, "the started and handled lines are numbered in order")
+}
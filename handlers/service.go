@@ -0,0 +1,141 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/DataDog/datadog-go/statsd"
+	"github.com/graze/golang-service/handlers/failure"
+	"github.com/graze/golang-service/log"
+)
+
+// service is the configuration of each layer of the middleware stack built by Service
+type service struct {
+	logger      log.FieldLogger
+	logOpts     []StructuredOption
+	client      *statsd.Client
+	statsdOpts  []StatsdOption
+	onError     failure.Handler
+	recoverOpts []RecoverOption
+	cors        func(h http.Handler) http.Handler
+	auth        func(h http.Handler) http.Handler
+	middleware  []func(h http.Handler) http.Handler
+}
+
+// ServiceOption configures a layer of the middleware stack built by Service
+type ServiceOption func(s *service)
+
+// WithServiceLogger sets the logger used for the logging context and structured request logs, the default is the
+// global logger with the field `module=request.handler`
+func WithServiceLogger(logger log.FieldLogger) ServiceOption {
+	return func(s *service) {
+		s.logger = logger
+	}
+}
+
+// WithServiceLogging adds options to the structured request logger
+func WithServiceLogging(opts ...StructuredOption) ServiceOption {
+	return func(s *service) {
+		s.logOpts = append(s.logOpts, opts...)
+	}
+}
+
+// WithServiceStatsd sends request metrics to client with opts, statsd is not used unless this is set
+func WithServiceStatsd(client *statsd.Client, opts ...StatsdOption) ServiceOption {
+	return func(s *service) {
+		s.client = client
+		s.statsdOpts = append(s.statsdOpts, opts...)
+	}
+}
+
+// WithServiceRecover sets the failure.Handler called when a panic is recovered, the default writes the status text. A
+// nil onError does not recover from panics
+func WithServiceRecover(onError failure.Handler, opts ...RecoverOption) ServiceOption {
+	return func(s *service) {
+		s.onError = onError
+		s.recoverOpts = append(s.recoverOpts, opts...)
+	}
+}
+
+// WithServiceCORS sets the middleware that handles CORS, it is called before authentication so preflight requests do
+// not need credentials
+func WithServiceCORS(cors func(h http.Handler) http.Handler) ServiceOption {
+	return func(s *service) {
+		s.cors = cors
+	}
+}
+
+// WithServiceAuth sets the middleware that authenticates requests, such as `auth.APIKey.Then`
+func WithServiceAuth(auth func(h http.Handler) http.Handler) ServiceOption {
+	return func(s *service) {
+		s.auth = auth
+	}
+}
+
+// WithServiceMiddleware adds middleware between authentication and the handler, the first middleware is the outermost
+func WithServiceMiddleware(middleware ...func(h http.Handler) http.Handler) ServiceOption {
+	return func(s *service) {
+		s.middleware = append(s.middleware, middleware...)
+	}
+}
+
+// statusText writes the status text of the status as the response
+var statusText = failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, status int) {
+	http.Error(w, http.StatusText(status), status)
+})
+
+// Service returns h wrapped in the standard middleware stack for a service, in the order each request passes through:
+//
+//  LoggingContextHandler - adds the request id and request fields to the logging context
+//  StructuredLogHandler  - logs each request, including requests rejected or recovered by the layers below
+//  StatsdIoHandler       - sends request metrics, if WithServiceStatsd is used
+//  Recover               - recovers from panics in the layers below, responding with a 500
+//  CORS                  - if WithServiceCORS is used
+//  Auth                  - if WithServiceAuth is used
+//  Middleware            - added with WithServiceMiddleware
+//
+// Each layer can be configured with the options, and the existing middleware can be used directly for a different
+// stack
+//
+// Usage:
+//  keyAuth := auth.NewAPIKey("Graze", finder, failure.HandlerFunc(onError))
+//  http.ListenAndServe(":1123", handlers.Service(r,
+//      handlers.WithServiceStatsd(client),
+//      handlers.WithServiceAuth(keyAuth.Then),
+//      handlers.WithServiceRecover(failure.HandlerFunc(onError), handlers.IncludePanicValue(env != "live"))))
+func Service(h http.Handler, opts ...ServiceOption) http.Handler {
+	s := &service{
+		logger:  log.With(log.KV{"module": "request.handler"}),
+		onError: statusText,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	for i := len(s.middleware) - 1; i >= 0; i-- {
+		h = s.middleware[i](h)
+	}
+	if s.auth != nil {
+		h = s.auth(h)
+	}
+	if s.cors != nil {
+		h = s.cors(h)
+	}
+	if s.onError != nil {
+		h = Recover(s.onError, s.recoverOpts...)(h)
+	}
+	if s.client != nil {
+		h = StatsdIoHandler(s.client, h, s.statsdOpts...)
+	}
+	h = StructuredLogHandler(s.logger, h, s.logOpts...)
+	return LoggingContextHandler(s.logger, h)
+}
@@ -0,0 +1,136 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/DataDog/datadog-go/statsd"
+	"github.com/Sirupsen/logrus/hooks/test"
+	"github.com/graze/golang-service/log"
+	"github.com/graze/golang-service/nettest"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordLayer returns middleware that records name when a request passes through it
+func recordLayer(name string, order *[]string) func(h http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*order = append(*order, name)
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestServiceOrder(t *testing.T) {
+	var order []string
+	logger := log.New("", "", "")
+	hook := test.NewLocal(logger.Logger)
+
+	handler := Service(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, ok := RequestID(r.Context())
+		assert.True(t, ok, "the request id is set before the handler")
+		order = append(order, "handler")
+		w.Write([]byte("ok\n"))
+	}),
+		WithServiceLogger(logger),
+		WithServiceAuth(recordLayer("auth", &order)),
+		WithServiceCORS(recordLayer("cors", &order)),
+		WithServiceMiddleware(recordLayer("first", &order), recordLayer("second", &order)),
+	)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, newRequest("GET", "http://example.com"))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []string{"cors", "auth", "first", "second", "handler"}, order)
+	assert.Equal(t, 1, len(hook.Entries))
+	assert.Equal(t, "request_handled", hook.LastEntry().Data["tag"])
+	assert.NotEmpty(t, hook.LastEntry().Data["transaction"], "the request is logged with the logging context")
+}
+
+func TestServiceBehaviour(t *testing.T) {
+	// rejects requests without credentials, unless they are a preflight request handled by cors
+	auth := func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") == "" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+	cors := func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "OPTIONS" {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+
+	cases := map[string]struct {
+		method  string
+		auth    string
+		handler http.Handler
+		status  int
+	}{
+		"authenticated":   {"GET", "Graze key", okHandler, http.StatusOK},
+		"unauthenticated": {"GET", "", okHandler, http.StatusUnauthorized},
+		"preflight":       {"OPTIONS", "", okHandler, http.StatusNoContent},
+		"panic":           {"GET", "Graze key", panicHandler, http.StatusInternalServerError},
+	}
+
+	_, restore := globalHook()
+	defer restore()
+	for k, tc := range cases {
+		logger := log.New("", "", "")
+		hook := test.NewLocal(logger.Logger)
+
+		req := newRequest(tc.method, "http://example.com")
+		if tc.auth != "" {
+			req.Header.Set("Authorization", tc.auth)
+		}
+		rec := httptest.NewRecorder()
+		Service(tc.handler, WithServiceLogger(logger), WithServiceAuth(auth), WithServiceCORS(cors)).ServeHTTP(rec, req)
+
+		assert.Equal(t, tc.status, rec.Code, "test: %s", k)
+		assert.Equal(t, 1, len(hook.Entries), "test: %s", k)
+		assert.Equal(t, tc.status, hook.LastEntry().Data["http.status"], "test: %s: the response is logged", k)
+	}
+}
+
+func TestServiceStatsd(t *testing.T) {
+	done := make(chan string)
+	addr, sock, srvWg := nettest.CreateServer(t, "udp", "localhost:", done)
+	defer srvWg.Wait()
+	defer os.Remove(addr.String())
+	defer sock.Close()
+
+	client, err := statsd.New(addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, restore := globalHook()
+	defer restore()
+	Service(panicHandler, WithServiceStatsd(client)).ServeHTTP(httptest.NewRecorder(), newRequest("GET", "http://example.com"))
+
+	assert.Regexp(t, `^request\.response_time:[0-9.]+\|ms\|#endpoint:/,statusCode:500,`, <-done, "recovered panics are measured")
+	// read the other metrics so the server can be closed
+	go func() {
+		for range done {
+		}
+	}()
+}
@@ -0,0 +1,190 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// flightRecorder buffers the response of the handler so it can be written to every waiting request
+type flightRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *flightRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *flightRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+}
+
+func (r *flightRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.body.Write(b)
+}
+
+// writeTo writes the buffered response to w
+func (r *flightRecorder) writeTo(w http.ResponseWriter) {
+	for k, v := range r.header {
+		w.Header()[k] = append([]string(nil), v...)
+	}
+	status := r.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	w.Write(r.body.Bytes())
+}
+
+// flight is a single in-flight call of the handler
+type flight struct {
+	wg       sync.WaitGroup
+	response *flightRecorder
+}
+
+type singleFlightHandler struct {
+	handler     http.Handler
+	key         func(req *http.Request) string
+	methods     map[string]bool
+	credentials bool
+
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+// SingleFlightOption changes the behaviour of the SingleFlight handler
+type SingleFlightOption func(h *singleFlightHandler)
+
+// WithFlightKey sets the function that returns the key identifying duplicate requests, the default is the method, path,
+// query, `Accept` and `Accept-Encoding` headers of the request. The key must include any header the response varies on
+func WithFlightKey(key func(req *http.Request) string) SingleFlightOption {
+	return func(h *singleFlightHandler) {
+		h.key = key
+	}
+}
+
+// WithFlightMethods sets the request methods that are coalesced, the default is GET and HEAD. Only idempotent methods
+// should be used
+func WithFlightMethods(methods ...string) SingleFlightOption {
+	return func(h *singleFlightHandler) {
+		h.methods = make(map[string]bool, len(methods))
+		for _, m := range methods {
+			h.methods[m] = true
+		}
+	}
+}
+
+// WithFlightCredentials also coalesces requests with an `Authorization` or `Cookie` header, which are handled separately
+// by default so one user's response is not sent to another. The key must include the identity of the user, such as
+// the user from auth.GetUser when the handler is inside the auth handler
+func WithFlightCredentials() SingleFlightOption {
+	return func(h *singleFlightHandler) {
+		h.credentials = true
+	}
+}
+
+// defaultFlightKey returns the method, path, query, `Accept` and `Accept-Encoding` headers of req, so requests
+// negotiating a different representation or encoding (such as a gzip response from Compress) are not shared
+func defaultFlightKey(req *http.Request) string {
+	return req.Method + " " + req.URL.RequestURI() +
+		"\nAccept: " + strings.Join(req.Header["Accept"], ", ") +
+		"\nAccept-Encoding: " + strings.Join(req.Header["Accept-Encoding"], ", ")
+}
+
+// hasCredentials returns true if req has credentials that could make its response specific to a user
+func hasCredentials(req *http.Request) bool {
+	return req.Header.Get("Authorization") != "" || req.Header.Get("Cookie") != ""
+}
+
+// ServeHTTP calls the handler once for all concurrent requests with the same key, writing the same response to each
+func (h *singleFlightHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if !h.methods[req.Method] || (!h.credentials && hasCredentials(req)) {
+		h.handler.ServeHTTP(w, req)
+		return
+	}
+
+	key := h.key(req)
+	h.mu.Lock()
+	if f, ok := h.flights[key]; ok {
+		h.mu.Unlock()
+		f.wg.Wait()
+		if f.response == nil {
+			// the handler panicked, so handle this request separately
+			h.handler.ServeHTTP(w, req)
+			return
+		}
+		f.response.writeTo(w)
+		return
+	}
+	f := &flight{}
+	f.wg.Add(1)
+	h.flights[key] = f
+	h.mu.Unlock()
+
+	land := func() {
+		h.mu.Lock()
+		delete(h.flights, key)
+		h.mu.Unlock()
+		f.wg.Done()
+	}
+	defer func() {
+		// the handler panicked, so release the duplicates to be handled separately
+		if f.response == nil {
+			land()
+		}
+	}()
+
+	rec := &flightRecorder{header: http.Header{}}
+	h.handler.ServeHTTP(rec, req)
+	f.response = rec
+	// the duplicates are released before writing, so they do not wait for a slow client
+	land()
+	rec.writeTo(w)
+}
+
+// SingleFlight returns a middleware that coalesces concurrent duplicate requests, so only one call of the handler is
+// in flight for each key and the duplicates receive the same buffered response
+//
+// Responses are only shared while the call is in flight, they are not cached. By default only GET and HEAD requests
+// are coalesced by method, path, query, `Accept` and `Accept-Encoding`, a key set with WithFlightKey must include any
+// other header the response varies on. Requests with an `Authorization` or `Cookie` header are handled separately,
+// as the whole response (including any `Set-Cookie` header) is shared, unless WithFlightCredentials is used. As the
+// response is buffered, the handler can not flush or hijack the connection
+//
+// Usage:
+//  singleFlight := handlers.SingleFlight(handlers.WithFlightKey(func(r *http.Request) string {
+//      return r.Method + " " + r.URL.Path
+//  }))
+//  http.ListenAndServe(":1123", handlers.StructuredHandler(singleFlight(r)))
+func SingleFlight(opts ...SingleFlightOption) func(h http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		handler := &singleFlightHandler{
+			handler: h,
+			key:     defaultFlightKey,
+			methods: map[string]bool{"GET": true, "HEAD": true},
+			flights: map[string]*flight{},
+		}
+		for _, opt := range opts {
+			opt(handler)
+		}
+		return handler
+	}
+}
@@ -0,0 +1,216 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// arrivals wraps h to count the requests that have reached it
+func arrivals(h http.Handler) (http.Handler, *int32) {
+	var arrived int32
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&arrived, 1)
+		h.ServeHTTP(w, r)
+	}), &arrived
+}
+
+// waitForArrivals waits until n requests have arrived, and a little longer for them to join the flight
+func waitForArrivals(t *testing.T, arrived *int32, n int32) {
+	for i := 0; i < 1000; i++ {
+		if atomic.LoadInt32(arrived) == n {
+			time.Sleep(20 * time.Millisecond)
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d requests", n)
+}
+
+func TestSingleFlight(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	handler := SingleFlight()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		<-release
+		w.Header().Set("X-Call", fmt.Sprintf("%d", n))
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("expensive\n"))
+	}))
+
+	counted, arrived := arrivals(handler)
+
+	const requests = 5
+	recs := make([]*httptest.ResponseRecorder, requests)
+	wg := sync.WaitGroup{}
+	for i := 0; i < requests; i++ {
+		recs[i] = httptest.NewRecorder()
+		wg.Add(1)
+		go func(rec *httptest.ResponseRecorder) {
+			defer wg.Done()
+			counted.ServeHTTP(rec, newRequest("GET", "http://example.com/report"))
+		}(recs[i])
+	}
+	waitForArrivals(t, arrived, requests)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	for i, rec := range recs {
+		assert.Equal(t, http.StatusCreated, rec.Code, "request: %d", i)
+		assert.Equal(t, "1", rec.Header().Get("X-Call"), "request: %d", i)
+		assert.Equal(t, "expensive\n", rec.Body.String(), "request: %d", i)
+	}
+
+	rec := httptest.NewRecorder()
+	release = make(chan struct{})
+	close(release)
+	handler.ServeHTTP(rec, newRequest("GET", "http://example.com/report"))
+	assert.Equal(t, "2", rec.Header().Get("X-Call"), "the response is not cached after the flight")
+}
+
+func TestSingleFlightSkipsNonIdempotentMethods(t *testing.T) {
+	var calls int32
+	handler := SingleFlight()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Write([]byte("ok\n"))
+	}))
+
+	for i := 0; i < 3; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), newRequest("POST", "http://example.com/report"))
+	}
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	assert.Empty(t, handler.(*singleFlightHandler).flights)
+}
+
+func TestSingleFlightKey(t *testing.T) {
+	h := SingleFlight(WithFlightKey(func(r *http.Request) string {
+		return r.URL.RequestURI()
+	}), WithFlightMethods("GET"))(okHandler).(*singleFlightHandler)
+
+	assert.Equal(t, "/report?page=2", h.key(newRequest("GET", "http://example.com/report?page=2")))
+	assert.False(t, h.methods["HEAD"])
+}
+
+func TestDefaultFlightKey(t *testing.T) {
+	plain := newRequest("GET", "http://example.com/report?page=2")
+	gzip := newRequest("GET", "http://example.com/report?page=2")
+	gzip.Header.Set("Accept-Encoding", "gzip")
+	csv := newRequest("GET", "http://example.com/report?page=2")
+	csv.Header.Set("Accept", "text/csv")
+	other := newRequest("GET", "http://example.com/report?page=2")
+	other.Header.Set("User-Agent", "curl")
+
+	assert.Equal(t, "GET /report?page=2\nAccept: \nAccept-Encoding: ", defaultFlightKey(plain))
+	assert.Equal(t, "GET /report?page=2\nAccept: \nAccept-Encoding: gzip", defaultFlightKey(gzip))
+	assert.NotEqual(t, defaultFlightKey(plain), defaultFlightKey(csv), "the accepted type is part of the key")
+	assert.NotEqual(t, defaultFlightKey(gzip), defaultFlightKey(csv))
+	assert.Equal(t, defaultFlightKey(plain), defaultFlightKey(other), "other headers are not part of the key")
+}
+
+// blockingWriter is a http.ResponseWriter for a slow client, whose writes block until it is released
+type blockingWriter struct {
+	*httptest.ResponseRecorder
+	release chan struct{}
+}
+
+func (w *blockingWriter) Write(b []byte) (int, error) {
+	<-w.release
+	return w.ResponseRecorder.Write(b)
+}
+
+func TestSingleFlightSlowClient(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := SingleFlight()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("expensive\n"))
+	}))
+	counted, arrived := arrivals(handler)
+
+	slow := &blockingWriter{httptest.NewRecorder(), make(chan struct{})}
+	leader := make(chan struct{})
+	go func() {
+		defer close(leader)
+		counted.ServeHTTP(slow, newRequest("GET", "http://example.com/report"))
+	}()
+	<-started
+
+	dup := make(chan *httptest.ResponseRecorder)
+	go func() {
+		rec := httptest.NewRecorder()
+		counted.ServeHTTP(rec, newRequest("GET", "http://example.com/report"))
+		dup <- rec
+	}()
+	waitForArrivals(t, arrived, 2)
+	close(release)
+
+	select {
+	case rec := <-dup:
+		assert.Equal(t, "expensive\n", rec.Body.String(), "the duplicate gets the response")
+	case <-time.After(time.Second):
+		t.Fatal("the duplicate waited for the leader's slow client")
+	}
+	close(slow.release)
+	<-leader
+	assert.Equal(t, "expensive\n", slow.Body.String())
+}
+
+func TestSingleFlightCredentials(t *testing.T) {
+	cases := map[string]struct {
+		opts   []SingleFlightOption
+		header string
+		calls  int32
+	}{
+		"authorization":      {nil, "Authorization", 2},
+		"cookie":             {nil, "Cookie", 2},
+		"no credentials":     {nil, "", 1},
+		"credentials shared": {[]SingleFlightOption{WithFlightCredentials()}, "Cookie", 1},
+	}
+
+	for k, tc := range cases {
+		var calls int32
+		release := make(chan struct{})
+		handler := SingleFlight(tc.opts...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			<-release
+			w.Write([]byte("ok\n"))
+		}))
+		counted, arrived := arrivals(handler)
+
+		wg := sync.WaitGroup{}
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				req := newRequest("GET", "http://example.com/account")
+				if tc.header != "" {
+					req.Header.Set(tc.header, fmt.Sprintf("user-%d", i))
+				}
+				counted.ServeHTTP(httptest.NewRecorder(), req)
+			}(i)
+		}
+		waitForArrivals(t, arrived, 2)
+		close(release)
+		wg.Wait()
+
+		assert.Equal(t, tc.calls, atomic.LoadInt32(&calls), "test: %s", k)
+	}
+}
@@ -0,0 +1,147 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/graze/golang-service/log"
+)
+
+// countingReader counts the bytes read from the request body
+type countingReader struct {
+	io.ReadCloser
+	n   int64
+	eof int32
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	atomic.AddInt64(&r.n, int64(n))
+	if err == io.EOF {
+		atomic.StoreInt32(&r.eof, 1)
+	}
+	return n, err
+}
+
+// EOF returns true if the end of the body has been read
+func (r *countingReader) EOF() bool {
+	return atomic.LoadInt32(&r.eof) == 1
+}
+
+// Count returns the number of bytes read so far
+func (r *countingReader) Count() int64 {
+	return atomic.LoadInt64(&r.n)
+}
+
+// withCountingBody returns a copy of req with the body wrapped in a countingReader
+func withCountingBody(req *http.Request) *http.Request {
+	if req.Body == nil {
+		return req
+	}
+	out := new(http.Request)
+	*out = *req
+	out.Body = &countingReader{ReadCloser: req.Body}
+	return out
+}
+
+// requestSize returns the number of bytes read from the body of req
+func requestSize(req *http.Request) int64 {
+	if body, ok := req.Body.(*countingReader); ok {
+		return body.Count()
+	}
+	return 0
+}
+
+// bodyDrained returns true if the body of req was read to the end, or it has no body
+func bodyDrained(req *http.Request) bool {
+	if !hasBody(req) {
+		return true
+	}
+	if body, ok := req.Body.(*countingReader); ok {
+		return body.EOF()
+	}
+	return false
+}
+
+// setDecompressedBody stores the decompressed body of the request so the logging handlers can report its size
+func setDecompressedBody(req *http.Request, body *countingReader) {
+	store, ok := req.Context().Value(fieldsKey).(*requestFields)
+	if !ok {
+		return
+	}
+	store.Lock()
+	defer store.Unlock()
+	store.decompressed = body
+}
+
+// decompressedSize returns the number of decompressed bytes read from the body of req, or false if the body was not
+// decompressed
+func decompressedSize(req *http.Request) (int64, bool) {
+	store, ok := req.Context().Value(fieldsKey).(*requestFields)
+	if !ok {
+		return 0, false
+	}
+	store.Lock()
+	defer store.Unlock()
+	if store.decompressed == nil {
+		return 0, false
+	}
+	return store.decompressed.Count(), true
+}
+
+// WithRequestBodySizes logs the number of bytes read from the request body as `http.request_wire_bytes`, and after
+// any decompression by the Decompress handler as `http.request_bytes`. Without decompression they are equal
+//
+// Usage:
+//  decompress := handlers.Decompress(failure.HandlerFunc(onError))
+//  loggedRouter := handlers.StructuredHandler(decompress(r), handlers.WithRequestBodySizes())
+func WithRequestBodySizes() StructuredOption {
+	return func(h *structuredHandler) {
+		h.countBody = true
+		h.fields = append(h.fields, func(req *http.Request) log.KV {
+			wire := requestSize(req)
+			decompressed, ok := decompressedSize(req)
+			if !ok {
+				decompressed = wire
+			}
+			return log.KV{"http.request_wire_bytes": wire, "http.request_bytes": decompressed}
+		})
+	}
+}
+
+// WithSizeHistograms sends the number of bytes read from the request body and written to the response as the
+// `request.request_size` and `request.response_size` histograms, with the same tags as the other metrics
+//
+// Usage:
+//  loggedRouter := handlers.StatsdIoHandler(client, r, handlers.WithSizeHistograms())
+func WithSizeHistograms() StatsdOption {
+	return func(h *statsdHandler) {
+		h.sizes = true
+	}
+}
+
+// WithBodyDrained logs if the handler read the request body to the end as `http.body_drained`. Bodies that are not
+// drained can stop the connection being kept alive, so this flags handlers that leak connections. Requests without a
+// body are logged as drained
+//
+// Usage:
+//  loggedRouter := handlers.StructuredHandler(r, handlers.WithBodyDrained())
+func WithBodyDrained() StructuredOption {
+	return func(h *structuredHandler) {
+		h.countBody = true
+		h.fields = append(h.fields, func(req *http.Request) log.KV {
+			return log.KV{"http.body_drained": bodyDrained(req)}
+		})
+	}
+}
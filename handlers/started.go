@@ -0,0 +1,51 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/graze/golang-service/log"
+	uuid "github.com/satori/go.uuid"
+)
+
+// WithRequestStarted also logs a line with the tag `request_started` when each request starts, so requests that never
+// complete can be found
+//
+// Both lines have the request id as the `transaction` field. If the LoggingContextHandler has not set a request id,
+// one is created
+//
+// Usage:
+//  loggedRouter := handlers.StructuredHandler(r, handlers.WithRequestStarted())
+func WithRequestStarted() StructuredOption {
+	return func(h *structuredHandler) {
+		h.started = true
+	}
+}
+
+// logStarted writes the `request_started` line for req, returning req with a request id if it did not have one
+func (h structuredHandler) logStarted(req *http.Request) *http.Request {
+	if _, ok := RequestID(req.Context()); !ok {
+		id := uuid.NewV4().String()
+		req = req.WithContext(h.logger.AppendContext(WithRequestID(req.Context(), id), log.KV{"transaction": id}))
+	}
+	url := *req.URL
+	uri := parseURI(req, url)
+	h.withSequence(h.logger.Ctx(req.Context())).With(log.KV{
+		"tag":           "request_started",
+		"http.method":   req.Method,
+		"http.protocol": req.Proto,
+		"http.uri":      uri,
+		"http.path":     uriPath(req, url),
+		"http.host":     req.Host,
+	}).Infof("%s %s %s started", req.Method, uri, req.Proto)
+	return req
+}
@@ -0,0 +1,71 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Sirupsen/logrus/hooks/test"
+	"github.com/graze/golang-service/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithRequestStarted(t *testing.T) {
+	cases := map[string]struct {
+		context bool
+	}{
+		"with a logging context":    {true},
+		"without a logging context": {false},
+	}
+
+	for k, tc := range cases {
+		logger := log.New("", "", "")
+		hook := test.NewLocal(logger.Logger)
+
+		var requestID string
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestID, _ = RequestID(r.Context())
+			assert.Equal(t, 1, len(hook.Entries), "test: %s: the start is logged before the request is handled", k)
+			w.Write([]byte("ok\n"))
+		})
+		var handler http.Handler = StructuredLogHandler(logger, h, WithRequestStarted())
+		if tc.context {
+			handler = LoggingContextHandler(logger, handler)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), newRequest("GET", "http://example.com/path?q=1"))
+
+		if !assert.Equal(t, 2, len(hook.Entries), "test: %s", k) {
+			continue
+		}
+		started, handled := hook.Entries[0], hook.Entries[1]
+		assert.Equal(t, "request_started", started.Data["tag"], "test: %s", k)
+		assert.Equal(t, "GET /path?q=1 HTTP/1.1 started", started.Message, "test: %s", k)
+		assert.Equal(t, "GET", started.Data["http.method"], "test: %s", k)
+		assert.Equal(t, "/path", started.Data["http.path"], "test: %s", k)
+		assert.Equal(t, "request_handled", handled.Data["tag"], "test: %s", k)
+
+		assert.NotEmpty(t, requestID, "test: %s", k)
+		assert.Equal(t, requestID, started.Data["transaction"], "test: %s", k)
+		assert.Equal(t, requestID, handled.Data["transaction"], "test: %s", k)
+	}
+}
+
+func TestRequestStartedIsOptIn(t *testing.T) {
+	logger := log.New("", "", "")
+	hook := test.NewLocal(logger.Logger)
+
+	StructuredLogHandler(logger, okHandler).ServeHTTP(httptest.NewRecorder(), newRequest("GET", "http://example.com"))
+
+	assert.Equal(t, 1, len(hook.Entries))
+	assert.Equal(t, "request_handled", hook.LastEntry().Data["tag"])
+}
@@ -21,33 +21,145 @@ import (
 )
 
 type statsdHandler struct {
-	statsd  *statsd.Client
-	handler http.Handler
+	clients    []*statsd.Client
+	handler    http.Handler
+	tags       []func(req *http.Request) []string
+	sizes      bool
+	now        func() time.Time
+	countRate  float64
+	counts     *countAggregator
+	processing bool
+	classes    bool
 }
 
+// StatsdOption changes the behaviour of a statsd handler
+type StatsdOption func(h *statsdHandler)
+
 // ServeHTTP does the actual handling of HTTP requests by wrapping the request in a logger
 func (h statsdHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	LogServeHTTP(w, req, h.handler, h.writeLog)
+	if h.sizes {
+		req = withCountingBody(req)
+	}
+	logServeHTTP(w, req, h.handler, h.now, h.writeLog)
 }
 
 // writeLog writes the log do the statsd client from a statsdHandler
 func (h statsdHandler) writeLog(w LoggingResponseWriter, req *http.Request, url url.URL, ts time.Time, dur time.Duration, status, size int) {
-	writeStatsdLog(h.statsd, req, url, ts, dur, status, size)
+	var extra []string
+	for _, tags := range h.tags {
+		extra = append(extra, tags(req)...)
+	}
+	tags := statsdTags(req, url, status, extra...)
+	countRate := h.countRate
+	if h.counts != nil {
+		h.counts.add(tags)
+		countRate = 0
+	}
+	// a failure writing to one client does not stop the others being written to
+	for _, client := range h.clients {
+		writeStatsdMetrics(client, req, ts, dur, tags, countRate)
+		if class := statusClass(status); h.classes && class != "" {
+			client.Incr("request."+class, nil, 1)
+		}
+		if h.sizes {
+			client.Histogram("request.request_size", float64(requestSize(req)), tags, 1)
+			client.Histogram("request.response_size", float64(size), tags, 1)
+		}
+		if h.processing {
+			client.Timing("request.processing_time", processingTime(w, ts, dur), tags, 1)
+		}
+	}
+}
+
+// processingTime returns the time until the status or first byte was written, or dur if nothing was written or w does
+// not record it
+func processingTime(w LoggingResponseWriter, ts time.Time, dur time.Duration) time.Duration {
+	fb, ok := w.(firstByteWriter)
+	if !ok || fb.FirstByte().IsZero() {
+		return dur
+	}
+	firstByte := fb.FirstByte()
+	if processing := firstByte.Sub(ts); processing < dur {
+		if processing < 0 {
+			return 0
+		}
+		return processing
+	}
+	return dur
 }
 
 // writeStatsdLog send the response time and a counter for each request to statsd
-func writeStatsdLog(w *statsd.Client, req *http.Request, url url.URL, ts time.Time, dur time.Duration, status, size int) {
-	uri := uriPath(req, url)
+//
+// extra tags are added after the standard tags
+func writeStatsdLog(w *statsd.Client, req *http.Request, url url.URL, ts time.Time, dur time.Duration, status, size int, extra ...string) {
+	writeStatsdMetrics(w, req, ts, dur, statsdTags(req, url, status, extra...), 1)
+}
+
+// writeStatsdMetrics sends the response time, count and queue time metrics for a request with tags
+//
+// countRate is the sample rate of the count metric, the count is not sent if it is 0
+func writeStatsdMetrics(w *statsd.Client, req *http.Request, ts time.Time, dur time.Duration, tags []string, countRate float64) {
+	w.Timing("request.response_time", dur, tags, 1)
+	if countRate > 0 {
+		w.Incr("request.count", tags, countRate)
+	}
+	if start, ok := QueueStart(req); ok {
+		w.Timing("request.queue_time", ts.Sub(start), tags, 1)
+	}
+}
 
-	tags := []string{
-		"endpoint:" + uri,
+// WithStatsdClients also writes the same metrics to each of clients, such as when moving to a new statsd backend
+//
+// Usage:
+//  loggedRouter := handlers.StatsdIoHandler(oldClient, r, handlers.WithStatsdClients(newClient))
+func WithStatsdClients(clients ...*statsd.Client) StatsdOption {
+	return func(h *statsdHandler) {
+		h.clients = append(h.clients, clients...)
+	}
+}
+
+// statsdTags returns the standard tags for a request followed by the extra tags
+func statsdTags(req *http.Request, url url.URL, status int, extra ...string) []string {
+	return append([]string{
+		"endpoint:" + uriPath(req, url),
 		"statusCode:" + strconv.Itoa(status),
 		"method:" + req.Method,
 		"protocol:" + req.Proto,
+	}, extra...)
+}
+
+// WithStatsdClock uses now to get the time and duration of each request rather than the system clocks, so tests can
+// control the timing
+//
+// Usage:
+//  loggedRouter := handlers.StatsdIoHandler(client, r, handlers.WithStatsdClock(clock.Now))
+func WithStatsdClock(now func() time.Time) StatsdOption {
+	return func(h *statsdHandler) {
+		h.now = now
+	}
+}
+
+// WithProcessingTime sends the time until the handler wrote the status or first byte of the response as
+// `request.processing_time`, or the full duration if the handler did not write anything. Unlike
+// `request.response_time` it does not include the time spent sending the response body to a slow client
+//
+// The time the first byte is written is taken from the system clock, so this should not be used with WithStatsdClock
+//
+// Usage:
+//  loggedRouter := handlers.StatsdIoHandler(client, r, handlers.WithProcessingTime())
+func WithProcessingTime() StatsdOption {
+	return func(h *statsdHandler) {
+		h.processing = true
 	}
+}
 
-	w.Timing("request.response_time", dur, tags, 1)
-	w.Incr("request.count", tags, 1)
+// Close stops sending the counts in the background when WithCountGauge is used, sending the counts for the current
+// interval first. It does nothing otherwise
+func (h statsdHandler) Close() error {
+	if h.counts != nil {
+		h.counts.close()
+	}
+	return nil
 }
 
 // StatsdIoHandler returns a http.Handler that wraps h and logs request to statsd
@@ -62,8 +174,15 @@ func writeStatsdLog(w *statsd.Client, req *http.Request, url url.URL, ts time.Ti
 //  loggedRouter := handlers.StatsdHandler(c, r)
 //  http.ListenAndServe(":1123", loggedRouter)
 //
-func StatsdIoHandler(out *statsd.Client, h http.Handler) http.Handler {
-	return statsdHandler{out, h}
+func StatsdIoHandler(out *statsd.Client, h http.Handler, opts ...StatsdOption) http.Handler {
+	handler := &statsdHandler{clients: []*statsd.Client{out}, handler: h, countRate: 1}
+	for _, opt := range opts {
+		opt(handler)
+	}
+	if handler.counts != nil {
+		handler.counts.start(handler.clients)
+	}
+	return *handler
 }
 
 // NewStatsdHandler returns a handlers.StatsdHandler to write request and response informtion to statsd
@@ -75,12 +194,12 @@ func StatsdIoHandler(out *statsd.Client, h http.Handler) http.Handler {
 // 	})
 // 	loggedRouter := handlers.NewStatsdHandler(c)
 // 	http.ListenAndServe(":1123", loggedRouter)
-func NewStatsdHandler(c metrics.StatsdClientConf) func(h http.Handler) http.Handler {
+func NewStatsdHandler(c metrics.StatsdClientConf, opts ...StatsdOption) func(h http.Handler) http.Handler {
 	return func(h http.Handler) http.Handler {
 		client, err := metrics.GetStatsd(c)
 		if err != nil {
 			panic(err)
 		}
-		return StatsdIoHandler(client, h)
+		return StatsdIoHandler(client, h, opts...)
 	}
 }
@@ -11,11 +11,14 @@
 package handlers
 
 import (
+	"io/ioutil"
 	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"regexp"
+	"strconv"
 	"testing"
 	"time"
 
@@ -152,3 +155,178 @@ func TestStatsdHandler(t *testing.T) {
 		}
 	}
 }
+
+func TestStatsdQueueTime(t *testing.T) {
+	done := make(chan string)
+	addr, sock, srvWg := nettest.CreateServer(t, "udp", "localhost:", done)
+	defer srvWg.Wait()
+	defer os.Remove(addr.String())
+	defer sock.Close()
+
+	client, err := statsd.New(addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := time.Now().UTC()
+	req := WithQueueStart(newRequest("GET", "http://example.com"), ts.Add(-getDuration(t, "0.05s")))
+
+	writeStatsdLog(client, req, *req.URL, ts, getDuration(t, "0.302s"), http.StatusOK, 100)
+
+	assert.Equal(t, "request.response_time:302.000000|ms|#endpoint:/,statusCode:200,method:GET,protocol:HTTP/1.1", <-done)
+	assert.Equal(t, "request.count:1|c|#endpoint:/,statusCode:200,method:GET,protocol:HTTP/1.1", <-done)
+	assert.Equal(t, "request.queue_time:50.000000|ms|#endpoint:/,statusCode:200,method:GET,protocol:HTTP/1.1", <-done)
+}
+
+func TestQueueStartHandler(t *testing.T) {
+	var start time.Time
+	var ok bool
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start, ok = QueueStart(r)
+	})
+
+	before := time.Now().UTC()
+	QueueStartHandler(h).ServeHTTP(httptest.NewRecorder(), newRequest("GET", "http://example.com"))
+
+	assert.True(t, ok)
+	assert.False(t, start.Before(before))
+}
+
+func TestStatsdMultipleClients(t *testing.T) {
+	clients := []*statsd.Client{}
+	received := []chan string{}
+	for i := 0; i < 2; i++ {
+		done := make(chan string)
+		addr, sock, srvWg := nettest.CreateServer(t, "udp", "localhost:", done)
+		defer srvWg.Wait()
+		defer os.Remove(addr.String())
+		defer sock.Close()
+
+		client, err := statsd.New(addr.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		clients = append(clients, client)
+		received = append(received, done)
+	}
+
+	// a closed client fails to write, which should not stop the others
+	closed, err := statsd.New("localhost:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed.Close()
+
+	handler := StatsdIoHandler(clients[0], okHandler, WithStatsdClients(closed, clients[1]))
+	handler.ServeHTTP(httptest.NewRecorder(), newRequest("GET", "http://example.com"))
+
+	for i, done := range received {
+		assert.Regexp(t, `^request\.response_time:[0-9.]+\|ms\|#endpoint:/,statusCode:200,method:GET,protocol:HTTP/1\.1$`, <-done, "client: %d", i)
+		assert.Equal(t, "request.count:1|c|#endpoint:/,statusCode:200,method:GET,protocol:HTTP/1.1", <-done, "client: %d", i)
+	}
+}
+
+func TestStatsdSizeHistograms(t *testing.T) {
+	done := make(chan string)
+	addr, sock, srvWg := nettest.CreateServer(t, "udp", "localhost:", done)
+	defer srvWg.Wait()
+	defer os.Remove(addr.String())
+	defer sock.Close()
+
+	client, err := statsd.New(addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+		w.Write([]byte("some response"))
+	})
+	handler := StatsdIoHandler(client, h, WithSizeHistograms())
+	handler.ServeHTTP(httptest.NewRecorder(), bodyRequest("POST", "http://example.com/path", "application/json", `{"key":"value"}`))
+
+	tags := "#endpoint:/path,statusCode:200,method:POST,protocol:HTTP/1.1"
+	assert.Regexp(t, `^request\.response_time:[0-9.]+\|ms\|`+tags+`$`, <-done)
+	assert.Equal(t, "request.count:1|c|"+tags, <-done)
+	assert.Equal(t, "request.request_size:15.000000|h|"+tags, <-done)
+	assert.Equal(t, "request.response_size:13.000000|h|"+tags, <-done)
+}
+
+func TestStatsdClock(t *testing.T) {
+	done := make(chan string)
+	addr, sock, srvWg := nettest.CreateServer(t, "udp", "localhost:", done)
+	defer srvWg.Wait()
+	defer os.Remove(addr.String())
+	defer sock.Close()
+
+	client, err := statsd.New(addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// each call to the clock moves it forward by 302ms
+	now := time.Date(2016, 10, 28, 10, 51, 31, 0, time.UTC)
+	clock := func() time.Time {
+		now = now.Add(getDuration(t, "0.302s"))
+		return now
+	}
+
+	handler := StatsdIoHandler(client, okHandler, WithStatsdClock(clock))
+	handler.ServeHTTP(httptest.NewRecorder(), newRequest("GET", "http://example.com"))
+
+	assert.Equal(t, "request.response_time:302.000000|ms|#endpoint:/,statusCode:200,method:GET,protocol:HTTP/1.1", <-done)
+	assert.Equal(t, "request.count:1|c|#endpoint:/,statusCode:200,method:GET,protocol:HTTP/1.1", <-done)
+}
+
+func TestStatsdProcessingTime(t *testing.T) {
+	done := make(chan string)
+	addr, sock, srvWg := nettest.CreateServer(t, "udp", "localhost:", done)
+	defer srvWg.Wait()
+	defer os.Remove(addr.String())
+	defer sock.Close()
+
+	client, err := statsd.New(addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a streaming response where sending the body is slow
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("first chunk"))
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte("second chunk"))
+	})
+	handler := StatsdIoHandler(client, h, WithProcessingTime())
+	handler.ServeHTTP(httptest.NewRecorder(), newRequest("GET", "http://example.com"))
+
+	tags := "|ms|#endpoint:/,statusCode:200,method:GET,protocol:HTTP/1.1"
+	response := regexp.MustCompile(`^request\.response_time:([0-9.]+)` + regexp.QuoteMeta(tags) + `$`).FindStringSubmatch(<-done)
+	assert.Equal(t, "request.count:1|c|#endpoint:/,statusCode:200,method:GET,protocol:HTTP/1.1", <-done)
+	processing := regexp.MustCompile(`^request\.processing_time:([0-9.]+)` + regexp.QuoteMeta(tags) + `$`).FindStringSubmatch(<-done)
+	if !assert.Equal(t, 2, len(response)) || !assert.Equal(t, 2, len(processing)) {
+		return
+	}
+
+	responseTime, _ := strconv.ParseFloat(response[1], 64)
+	processingTime, _ := strconv.ParseFloat(processing[1], 64)
+	assert.True(t, responseTime >= 50, "the response time includes writing the body: %f", responseTime)
+	assert.True(t, processingTime < 50, "the processing time stops at the first byte: %f", processingTime)
+}
+
+func TestProcessingTime(t *testing.T) {
+	ts := time.Date(2016, 10, 28, 10, 51, 31, 0, time.UTC)
+	cases := map[string]struct {
+		firstByte time.Time
+		expected  time.Duration
+	}{
+		"first byte":         {ts.Add(20 * time.Millisecond), 20 * time.Millisecond},
+		"nothing written":    {time.Time{}, 100 * time.Millisecond},
+		"after the duration": {ts.Add(time.Second), 100 * time.Millisecond},
+		"before the start":   {ts.Add(-time.Second), 0},
+	}
+
+	for k, tc := range cases {
+		w := &responseLogger{w: httptest.NewRecorder(), firstByte: tc.firstByte}
+		assert.Equal(t, tc.expected, processingTime(w, ts, 100*time.Millisecond), "test: %s", k)
+	}
+}
@@ -15,22 +15,63 @@ import (
 	"net/url"
 	"time"
 
+	"github.com/Sirupsen/logrus"
+	"github.com/graze/golang-service/handlers/auth"
 	"github.com/graze/golang-service/log"
 )
 
 type structuredHandler struct {
-	logger  log.FieldLogger
-	handler http.Handler
+	logger     log.FieldLogger
+	handler    http.Handler
+	fields     []func(req *http.Request) log.KV
+	samples    []*sampleRule
+	levels     []func(req *http.Request, status int) logrus.Level
+	countBody  bool
+	headers    []string
+	denied     map[string]bool
+	started    bool
+	sequence   bool
+	overridden bool
 }
 
+// StructuredOption changes the behaviour of a structured log handler
+type StructuredOption func(h *structuredHandler)
+
 // ServeHTTP does the actual handling of HTTP requests by wrapping the request in a logger
 func (h structuredHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if h.countBody {
+		req = withCountingBody(req)
+	}
+	req = auth.Track(req)
+	if h.started {
+		req = h.logStarted(req)
+	}
 	LogServeHTTP(w, req, h.handler, h.writeLog)
 }
 
 // writeLog writes a log entry to structuredHandler's logger
 func (h structuredHandler) writeLog(w LoggingResponseWriter, req *http.Request, url url.URL, ts time.Time, dur time.Duration, status, size int) {
-	writeStructuredLog(w, h.logger.Ctx(req.Context()), req, url, ts, dur, status, size)
+	if !h.sampled(url.Path, status) {
+		return
+	}
+	if h.overridden {
+		logOverridden(req)
+	}
+	logger := h.withSequence(h.logger.Ctx(req.Context()))
+	for _, fields := range h.fields {
+		logger = logger.With(fields(req))
+	}
+	if len(h.headers) > 0 {
+		logger = logger.With(h.headerFields(req))
+	}
+	level := log.InfoLevel
+	for _, levelFor := range h.levels {
+		// lower levels are more severe
+		if l := levelFor(req, status); l < level {
+			level = l
+		}
+	}
+	writeStructuredLogAt(w, logger, level, req, url, ts, dur, status, size)
 }
 
 // writeStructuredLog writes a log entry for req to logger in a structured format for json/logfmt
@@ -38,27 +79,109 @@ func (h structuredHandler) writeLog(w LoggingResponseWriter, req *http.Request,
 // dur is the time taken by the server to generate the response
 // status and size are used to provide response HTTP status and size
 func writeStructuredLog(w LoggingResponseWriter, logger log.FieldLogger, req *http.Request, url url.URL, ts time.Time, dur time.Duration, status, size int) {
+	writeStructuredLogAt(w, logger, log.InfoLevel, req, url, ts, dur, status, size)
+}
+
+// writeStructuredLogAt writes the log entry from writeStructuredLog at level
+func writeStructuredLogAt(w LoggingResponseWriter, logger log.FieldLogger, level logrus.Level, req *http.Request, url url.URL, ts time.Time, dur time.Duration, status, size int) {
 	uri := parseURI(req, url)
 	ip := ""
 	if userIP, err := getUserIP(req); err == nil {
 		ip = userIP.String()
 	}
 
-	logger.With(log.KV{
-		"tag":             "request_handled",
-		"http.method":     req.Method,
-		"http.protocol":   req.Proto,
-		"http.uri":        uri,
-		"http.path":       uriPath(req, url),
-		"http.host":       req.Host,
-		"http.status":     status,
-		"http.bytes":      size,
-		"http.user":       ip,
-		"http.ref":        req.Referer(),
-		"http.user-agent": req.Header.Get("User-Agent"),
-		"dur":             dur.Seconds(),
-		"http.time":       ts.Format(time.RFC3339Nano),
-	}).Infof("%s %s %s", req.Method, uri, req.Proto)
+	hostname, port := hostPort(req)
+
+	fields := log.KV{
+		"tag":                "request_handled",
+		"http.method":        req.Method,
+		"http.protocol":      req.Proto,
+		"http.protocol_kind": protocolKind(req),
+		"http.keep_alive":    keepAlive(req),
+		"http.uri":           uri,
+		"http.path":          uriPath(req, url),
+		"http.host":          req.Host,
+		"http.hostname":      hostname,
+		"http.port":          port,
+		"http.status":        status,
+		"http.bytes":         size,
+		"http.user":          ip,
+		"http.ref":           req.Referer(),
+		"http.user-agent":    req.Header.Get("User-Agent"),
+		"dur":                dur.Seconds(),
+		"http.time":          ts.Format(time.RFC3339Nano),
+	}
+	if fb, ok := w.(firstByteWriter); ok && !fb.FirstByte().IsZero() {
+		fields["http.ttfb"] = fb.FirstByte().Sub(ts).Seconds()
+		process := processingTime(w, ts, dur)
+		fields["dur.process"] = process.Seconds()
+		fields["dur.write"] = (dur - process).Seconds()
+	}
+	if lang := language(req); lang != "" {
+		fields["http.language"] = lang
+	}
+	if cc, ok := w.(cacheControlWriter); ok && cc.CacheControl() != "" {
+		fields["http.cache_control"] = cc.CacheControl()
+	}
+	if lw, ok := w.(locationWriter); ok && lw.Location() != "" && status >= 300 && status < 400 {
+		fields["http.location"] = lw.Location()
+	}
+	if accept, ok := QueueStart(req); ok {
+		fields["ts.accept"] = accept.UTC().Format(time.RFC3339Nano)
+		fields["ts.start"] = ts.Format(time.RFC3339Nano)
+		wait := ts.Sub(accept)
+		if wait < 0 {
+			wait = 0
+		}
+		fields["dur.wait"] = wait.Seconds()
+	}
+	if scheme, provider := auth.GetScheme(req); scheme != "" {
+		fields["auth.scheme"] = scheme
+		if provider != "" {
+			fields["auth.provider"] = provider
+		}
+	}
+	if remaining, limited, ok := auth.GetRateLimit(req); ok {
+		fields["ratelimit.remaining"] = remaining
+		fields["ratelimit.limited"] = limited
+		fields["ratelimit.bucket"] = auth.GetRateLimitBucket(req)
+	}
+	if req.TLS != nil {
+		fields["tls.resumed"] = req.TLS.DidResume
+		if req.TLS.ServerName != "" {
+			fields["tls.server_name"] = req.TLS.ServerName
+		}
+		if req.TLS.NegotiatedProtocol != "" {
+			fields["tls.alpn"] = req.TLS.NegotiatedProtocol
+		}
+	}
+
+	if ew, ok := w.(writeErrorWriter); ok && ew.WriteError() != nil {
+		fields["http.write_error"] = ew.WriteError().Error()
+		if level > log.WarnLevel {
+			level = log.WarnLevel
+		}
+	}
+
+	stored := logFields(req)
+	if _, ok := stored["http.route_matched"]; !ok {
+		fields["http.route_matched"] = false
+	}
+	if overridden := overriddenField(req, stored, fields); overridden != "" {
+		fields["log.overridden"] = overridden
+	}
+
+	entry := logger.With(stored).With(fields)
+	switch level {
+	case log.PanicLevel, log.FatalLevel, log.ErrorLevel:
+		entry.Errorf("%s %s %s", req.Method, uri, req.Proto)
+	case log.WarnLevel:
+		entry.Warnf("%s %s %s", req.Method, uri, req.Proto)
+	case log.DebugLevel:
+		entry.Debugf("%s %s %s", req.Method, uri, req.Proto)
+	default:
+		entry.Infof("%s %s %s", req.Method, uri, req.Proto)
+	}
 }
 
 // StructuredLogHandler returns a http.Handler that wraps h and logs request to out in
@@ -76,8 +199,15 @@ func writeStructuredLog(w LoggingResponseWriter, logger log.FieldLogger, req *ht
 //		logger.With(log.KV{"module":"request.handler"})
 //		, r)
 //  http.ListenAndServe(":1123", loggedRouter)
-func StructuredLogHandler(logger log.FieldLogger, h http.Handler) http.Handler {
-	return structuredHandler{logger, h}
+func StructuredLogHandler(logger log.FieldLogger, h http.Handler, opts ...StructuredOption) http.Handler {
+	handler := &structuredHandler{logger: logger, handler: h, denied: map[string]bool{}}
+	for _, name := range DefaultDeniedHeaders {
+		handler.denied[http.CanonicalHeaderKey(name)] = true
+	}
+	for _, opt := range opts {
+		opt(handler)
+	}
+	return *handler
 }
 
 // StructuredHandler returns an opinionated structuredHandler using the standard logger
@@ -92,9 +222,9 @@ func StructuredLogHandler(logger log.FieldLogger, h http.Handler) http.Handler {
 //  loggedRouter := handlers.StructuredHandler(r)
 //  http.ListenAndServe(":1123", loggedRouter)
 //
-func StructuredHandler(h http.Handler) http.Handler {
+func StructuredHandler(h http.Handler, opts ...StructuredOption) http.Handler {
 	logger := log.With(log.KV{
 		"module": "request.handler",
 	})
-	return structuredHandler{logger, h}
+	return StructuredLogHandler(logger, h, opts...)
 }
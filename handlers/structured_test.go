@@ -11,6 +11,11 @@
 package handlers
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -18,6 +23,8 @@ import (
 	"time"
 
 	"github.com/Sirupsen/logrus/hooks/test"
+	"github.com/graze/golang-service/handlers/auth"
+	"github.com/graze/golang-service/handlers/failure"
 	"github.com/graze/golang-service/log"
 	"github.com/stretchr/testify/assert"
 )
@@ -35,6 +42,9 @@ func TestStructuredLogging(t *testing.T) {
 	referrerRequest := newRequest("GET", "http://example.com/test")
 	referrerRequest.Header.Add("Referer", "http://google.com")
 
+	tlsRequest := newRequest("GET", "https://example.com")
+	tlsRequest.TLS = &tls.ConnectionState{ServerName: "api.example.com"}
+
 	cases := map[string]struct {
 		request   *http.Request
 		timestamp time.Time
@@ -50,21 +60,25 @@ func TestStructuredLogging(t *testing.T) {
 			100,
 			"GET / HTTP/1.1",
 			map[string]interface{}{
-				"module":          "request.handler",
-				"tag":             "request_handled",
-				"http.method":     "GET",
-				"http.protocol":   "HTTP/1.1",
-				"http.uri":        "/",
-				"http.path":       "/",
-				"http.host":       "example.com",
-				"http.status":     200,
-				"http.bytes":      100,
-				"dur":             0.302,
-				"http.time":       now.Format(time.RFC3339Nano),
-				"http.ref":        "",
-				"http.user":       "",
-				"http.user-agent": "",
-				"transaction":     "test-123",
+				"module":             "request.handler",
+				"tag":                "request_handled",
+				"http.method":        "GET",
+				"http.protocol":      "HTTP/1.1",
+				"http.protocol_kind": "rest",
+				"http.keep_alive":    true,
+				"http.uri":           "/",
+				"http.path":          "/",
+				"http.host":          "example.com",
+				"http.hostname":      "example.com",
+				"http.port":          "80",
+				"http.status":        200,
+				"http.bytes":         100,
+				"dur":                0.302,
+				"http.time":          now.Format(time.RFC3339Nano),
+				"http.ref":           "",
+				"http.user":          "",
+				"http.user-agent":    "",
+				"transaction":        "test-123",
 			},
 		},
 		"post path": {
@@ -137,6 +151,8 @@ func TestStructuredLogging(t *testing.T) {
 				"http.uri":        "www.example.com:443",
 				"http.path":       "www.example.com:443",
 				"http.host":       "www.example.com:443",
+				"http.hostname":   "www.example.com",
+				"http.port":       "443",
 				"http.status":     200,
 				"http.bytes":      400,
 				"dur":             0.927,
@@ -218,6 +234,32 @@ func TestStructuredLogging(t *testing.T) {
 				"transaction":     "test-123",
 			},
 		},
+		"handles tls server name": {
+			tlsRequest,
+			now,
+			getDuration(t, "0.019s"),
+			600,
+			"GET / HTTP/1.1",
+			map[string]interface{}{
+				"module":          "request.handler",
+				"tag":             "request_handled",
+				"http.method":     "GET",
+				"http.protocol":   "HTTP/1.1",
+				"http.uri":        "/",
+				"http.path":       "/",
+				"http.host":       "example.com",
+				"http.status":     200,
+				"http.bytes":      600,
+				"dur":             0.019,
+				"http.time":       now.Format(time.RFC3339Nano),
+				"http.ref":        "",
+				"http.user":       "",
+				"http.user-agent": "",
+				"tls.server_name": "api.example.com",
+				"tls.resumed":     false,
+				"transaction":     "test-123",
+			},
+		},
 	}
 
 	logger := log.New("", "", "").With(log.KV{"transaction": "test-123"})
@@ -246,3 +288,528 @@ func TestStructuredLogging(t *testing.T) {
 		}
 	}
 }
+
+func TestStructuredLoggingOmitsServerNameWithoutTLS(t *testing.T) {
+	logger := log.New("", "", "")
+	hook := test.NewLocal(logger.Logger)
+
+	req := newRequest("GET", "http://example.com")
+	writeStructuredLog(&responseLogger{w: httptest.NewRecorder()}, logger, req, *req.URL, time.Now(), 0, http.StatusOK, 0)
+
+	assert.Equal(t, 1, len(hook.Entries))
+	assert.NotContains(t, hook.LastEntry().Data, "tls.server_name")
+	assert.NotContains(t, hook.LastEntry().Data, "tls.resumed")
+	assert.NotContains(t, hook.LastEntry().Data, "tls.alpn")
+}
+
+func TestStructuredLoggingALPN(t *testing.T) {
+	logger := log.New("", "", "")
+	hook := test.NewLocal(logger.Logger)
+
+	cases := map[string]struct {
+		protocol string
+		proto    string
+	}{
+		"http/2":               {"h2", "HTTP/2.0"},
+		"http/1.1":             {"http/1.1", "HTTP/1.1"},
+		"no protocol selected": {"", "HTTP/1.1"},
+	}
+
+	for k, tc := range cases {
+		hook.Reset()
+		req := newRequest("GET", "https://example.com")
+		req.Proto = tc.proto
+		req.TLS = &tls.ConnectionState{NegotiatedProtocol: tc.protocol, NegotiatedProtocolIsMutual: tc.protocol != ""}
+		writeStructuredLog(&responseLogger{w: httptest.NewRecorder()}, logger, req, *req.URL, time.Now(), 0, http.StatusOK, 0)
+
+		assert.Equal(t, 1, len(hook.Entries), "test: %s", k)
+		assert.Equal(t, tc.proto, hook.LastEntry().Data["http.protocol"], "test: %s", k)
+		if tc.protocol == "" {
+			assert.NotContains(t, hook.LastEntry().Data, "tls.alpn", "test: %s", k)
+		} else {
+			assert.Equal(t, tc.protocol, hook.LastEntry().Data["tls.alpn"], "test: %s", k)
+		}
+	}
+}
+
+func TestStructuredLoggingLanguage(t *testing.T) {
+	logger := log.New("", "", "")
+	hook := test.NewLocal(logger.Logger)
+
+	cases := map[string]struct {
+		header   string
+		language string
+	}{
+		"weighted":        {"en;q=0.5, fr-CH, de;q=0.9", "fr-CH"},
+		"highest q":       {"en-GB;q=0.8, de;q=0.9, *;q=1", "de"},
+		"first on a tie":  {"es, pt", "es"},
+		"single":          {"en-GB", "en-GB"},
+		"only wildcard":   {"*", ""},
+		"not acceptable":  {"en;q=0", ""},
+		"no header":       {"", ""},
+		"invalid q":       {"en;q=high", ""},
+		"q out of range":  {"en;q=2", ""},
+		"invalid tag":     {"en_GB", ""},
+		"long subtag":     {"englishlanguage", ""},
+		"numeric primary": {"123", ""},
+	}
+
+	for k, tc := range cases {
+		hook.Reset()
+		req := newRequest("GET", "http://example.com")
+		if tc.header != "" {
+			req.Header.Set("Accept-Language", tc.header)
+		}
+		writeStructuredLog(&responseLogger{w: httptest.NewRecorder()}, logger, req, *req.URL, time.Now(), 0, http.StatusOK, 0)
+
+		assert.Equal(t, 1, len(hook.Entries), "test: %s", k)
+		if tc.language == "" {
+			assert.NotContains(t, hook.LastEntry().Data, "http.language", "test: %s", k)
+		} else {
+			assert.Equal(t, tc.language, hook.LastEntry().Data["http.language"], "test: %s", k)
+		}
+	}
+}
+
+// brokenWriter is a http.ResponseWriter where every write fails, as when the client has disconnected
+type brokenWriter struct {
+	*httptest.ResponseRecorder
+	writes int
+}
+
+func (w *brokenWriter) Write(b []byte) (int, error) {
+	w.writes++
+	return 0, fmt.Errorf("write tcp: broken pipe %d", w.writes)
+}
+
+func TestStructuredLoggingWriteError(t *testing.T) {
+	logger := log.New("", "", "")
+	hook := test.NewLocal(logger.Logger)
+
+	handler := StructuredLogHandler(logger, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("first"))
+		w.Write([]byte("second"))
+	}))
+	handler.ServeHTTP(&brokenWriter{ResponseRecorder: httptest.NewRecorder()}, newRequest("GET", "http://example.com"))
+
+	assert.Equal(t, 1, len(hook.Entries))
+	assert.Equal(t, log.WarnLevel, hook.LastEntry().Level)
+	assert.Equal(t, "write tcp: broken pipe 1", hook.LastEntry().Data["http.write_error"], "the first error is logged")
+
+	handler = StructuredLogHandler(logger, okHandler)
+	handler.ServeHTTP(httptest.NewRecorder(), newRequest("GET", "http://example.com"))
+	assert.Equal(t, 2, len(hook.Entries))
+	assert.Equal(t, log.InfoLevel, hook.LastEntry().Level)
+	assert.NotContains(t, hook.LastEntry().Data, "http.write_error")
+}
+
+func TestStructuredLoggingTLSResumed(t *testing.T) {
+	logger := log.New("", "", "")
+	hook := test.NewLocal(logger.Logger)
+
+	for _, resumed := range []bool{true, false} {
+		req := newRequest("GET", "https://example.com")
+		req.TLS = &tls.ConnectionState{DidResume: resumed}
+		writeStructuredLog(&responseLogger{w: httptest.NewRecorder()}, logger, req, *req.URL, time.Now(), 0, http.StatusOK, 0)
+
+		assert.Equal(t, resumed, hook.LastEntry().Data["tls.resumed"], "resumed: %t", resumed)
+		assert.NotContains(t, hook.LastEntry().Data, "tls.server_name", "resumed: %t", resumed)
+	}
+}
+
+func TestStructuredLoggingTimeToFirstByte(t *testing.T) {
+	logger := log.New("", "", "")
+	hook := test.NewLocal(logger.Logger)
+
+	slowStart := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte("first"))
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte("second"))
+	})
+	StructuredLogHandler(logger, slowStart).ServeHTTP(httptest.NewRecorder(), newRequest("GET", "http://example.com"))
+
+	assert.Equal(t, 1, len(hook.Entries))
+	ttfb, ok := hook.LastEntry().Data["http.ttfb"].(float64)
+	assert.True(t, ok)
+	assert.True(t, ttfb >= 0.05, "ttfb: %f", ttfb)
+	assert.True(t, ttfb < hook.LastEntry().Data["dur"].(float64), "ttfb: %f is before the end of the response", ttfb)
+
+	hook.Reset()
+	empty := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	StructuredLogHandler(logger, empty).ServeHTTP(httptest.NewRecorder(), newRequest("GET", "http://example.com"))
+
+	assert.Equal(t, 1, len(hook.Entries))
+	assert.NotContains(t, hook.LastEntry().Data, "http.ttfb")
+}
+
+// minimalResponseWriter is a LoggingResponseWriter that only implements the required methods
+type minimalResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (w minimalResponseWriter) Flush()      {}
+func (w minimalResponseWriter) Status() int { return http.StatusOK }
+func (w minimalResponseWriter) Size() int   { return 0 }
+
+// closeNotifyRecorder is a ResponseRecorder that is also a http.CloseNotifier
+type closeNotifyRecorder struct {
+	*httptest.ResponseRecorder
+}
+
+func (r closeNotifyRecorder) CloseNotify() <-chan bool {
+	return make(chan bool)
+}
+
+func TestStructuredLoggingOptionalDetails(t *testing.T) {
+	logger := log.New("", "", "")
+	hook := test.NewLocal(logger.Logger)
+
+	redirect := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-cache")
+		http.Redirect(w, r, "/other", http.StatusFound)
+	})
+	StructuredLogHandler(logger, redirect).ServeHTTP(closeNotifyRecorder{httptest.NewRecorder()}, newRequest("GET", "http://example.com"))
+
+	assert.Equal(t, 1, len(hook.Entries))
+	assert.Contains(t, hook.LastEntry().Data, "http.ttfb", "the details are kept when the writer is wrapped")
+	assert.Equal(t, "no-cache", hook.LastEntry().Data["http.cache_control"])
+	assert.Equal(t, "/other", hook.LastEntry().Data["http.location"])
+
+	hook.Reset()
+	StructuredLogHandler(logger, redirect).ServeHTTP(minimalResponseWriter{httptest.NewRecorder()}, newRequest("GET", "http://example.com"))
+
+	assert.Equal(t, 1, len(hook.Entries), "a LoggingResponseWriter without the details can still be logged")
+	assert.NotContains(t, hook.LastEntry().Data, "http.ttfb")
+	assert.NotContains(t, hook.LastEntry().Data, "http.cache_control")
+	assert.NotContains(t, hook.LastEntry().Data, "http.location")
+	assert.Equal(t, time.Second, processingTime(minimalResponseWriter{}, time.Now(), time.Second))
+}
+
+func TestStructuredLoggingAcceptTime(t *testing.T) {
+	logger := log.New("", "", "")
+	hook := test.NewLocal(logger.Logger)
+
+	accept := time.Now().UTC().Add(-50 * time.Millisecond)
+	req := WithQueueStart(newRequest("GET", "http://example.com"), accept)
+	StructuredLogHandler(logger, okHandler).ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, 1, len(hook.Entries))
+	assert.Equal(t, accept.Format(time.RFC3339Nano), hook.LastEntry().Data["ts.accept"])
+	start, err := time.Parse(time.RFC3339Nano, hook.LastEntry().Data["ts.start"].(string))
+	assert.Nil(t, err)
+	assert.True(t, start.Sub(accept) >= 50*time.Millisecond, "queue: %s", start.Sub(accept))
+
+	hook.Reset()
+	StructuredLogHandler(logger, okHandler).ServeHTTP(httptest.NewRecorder(), newRequest("GET", "http://example.com"))
+
+	assert.Equal(t, 1, len(hook.Entries))
+	assert.NotContains(t, hook.LastEntry().Data, "ts.accept")
+	assert.NotContains(t, hook.LastEntry().Data, "ts.start")
+}
+
+func TestStructuredLoggingPhases(t *testing.T) {
+	logger := log.New("", "", "")
+	hook := test.NewLocal(logger.Logger)
+
+	accept := time.Date(2016, 10, 1, 12, 0, 0, 0, time.UTC)
+	ts := accept.Add(20 * time.Millisecond)
+	req := WithQueueStart(newRequest("GET", "http://example.com"), accept)
+	w := &responseLogger{w: httptest.NewRecorder(), firstByte: ts.Add(30 * time.Millisecond)}
+	writeStructuredLog(w, logger, req, *req.URL, ts, 100*time.Millisecond, http.StatusOK, 10)
+
+	assert.Equal(t, 1, len(hook.Entries))
+	data := hook.LastEntry().Data
+	assert.InDelta(t, 0.02, data["dur.wait"], 0.0001)
+	assert.InDelta(t, 0.03, data["dur.process"], 0.0001)
+	assert.InDelta(t, 0.07, data["dur.write"], 0.0001)
+	assert.InDelta(t, data["dur"].(float64), data["dur.process"].(float64)+data["dur.write"].(float64), 0.0001,
+		"process and write sum to the handling time")
+	assert.InDelta(t, 0.12, data["dur.wait"].(float64)+data["dur.process"].(float64)+data["dur.write"].(float64), 0.0001,
+		"the phases sum to the total time since the request was accepted")
+
+	hook.Reset()
+	w = &responseLogger{w: httptest.NewRecorder()}
+	writeStructuredLog(w, logger, newRequest("GET", "http://example.com"), *req.URL, ts, 100*time.Millisecond, http.StatusOK, 0)
+
+	assert.Equal(t, 1, len(hook.Entries))
+	for _, field := range []string{"dur.wait", "dur.process", "dur.write"} {
+		assert.NotContains(t, hook.LastEntry().Data, field, "%s is omitted without its inputs", field)
+	}
+}
+
+func TestStructuredLoggingPhasesSumToTotal(t *testing.T) {
+	logger := log.New("", "", "")
+	hook := test.NewLocal(logger.Logger)
+
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte("first"))
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte("second"))
+	})
+	req := WithQueueStart(newRequest("GET", "http://example.com"), time.Now().UTC().Add(-10*time.Millisecond))
+	StructuredLogHandler(logger, slow).ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, 1, len(hook.Entries))
+	data := hook.LastEntry().Data
+	assert.True(t, data["dur.wait"].(float64) >= 0.01, "wait: %v", data["dur.wait"])
+	assert.True(t, data["dur.process"].(float64) >= 0.02, "process: %v", data["dur.process"])
+	assert.True(t, data["dur.write"].(float64) > 0, "write: %v", data["dur.write"])
+	assert.InDelta(t, data["dur"].(float64), data["dur.process"].(float64)+data["dur.write"].(float64), 0.0001)
+}
+
+func TestStructuredLoggingCacheControl(t *testing.T) {
+	cases := map[string]struct {
+		handler  http.Handler
+		expected interface{}
+	}{
+		"write header": {
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Cache-Control", "no-store")
+				w.WriteHeader(http.StatusOK)
+			}),
+			"no-store",
+		},
+		"write": {
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Cache-Control", "public, max-age=60")
+				w.Write([]byte("ok\n"))
+			}),
+			"public, max-age=60",
+		},
+		"set after the status": {
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				w.Header().Set("Cache-Control", "no-store")
+			}),
+			nil,
+		},
+		"unset": {okHandler, nil},
+	}
+
+	for k, tc := range cases {
+		logger := log.New("", "", "")
+		hook := test.NewLocal(logger.Logger)
+
+		StructuredLogHandler(logger, tc.handler).ServeHTTP(httptest.NewRecorder(), newRequest("GET", "http://example.com"))
+
+		assert.Equal(t, 1, len(hook.Entries), "test: %s", k)
+		cacheControl, ok := hook.LastEntry().Data["http.cache_control"]
+		assert.Equal(t, tc.expected != nil, ok, "test: %s", k)
+		if ok {
+			assert.Equal(t, tc.expected, cacheControl, "test: %s", k)
+		}
+	}
+}
+
+func TestWithBodyDrained(t *testing.T) {
+	cases := map[string]struct {
+		request *http.Request
+		handler http.Handler
+		drained bool
+	}{
+		"read all": {
+			bodyRequest("POST", "http://example.com", "text/plain", "some body"),
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ioutil.ReadAll(r.Body)
+			}),
+			true,
+		},
+		"read part": {
+			bodyRequest("POST", "http://example.com", "text/plain", "some body"),
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				r.Body.Read(make([]byte, 4))
+			}),
+			false,
+		},
+		"not read": {
+			bodyRequest("POST", "http://example.com", "text/plain", "some body"),
+			okHandler,
+			false,
+		},
+		"no body": {
+			newRequest("GET", "http://example.com"),
+			okHandler,
+			true,
+		},
+	}
+
+	for k, tc := range cases {
+		logger := log.New("", "", "")
+		hook := test.NewLocal(logger.Logger)
+
+		StructuredLogHandler(logger, tc.handler, WithBodyDrained()).ServeHTTP(httptest.NewRecorder(), tc.request)
+
+		assert.Equal(t, 1, len(hook.Entries), "test: %s", k)
+		assert.Equal(t, tc.drained, hook.LastEntry().Data["http.body_drained"], "test: %s", k)
+	}
+}
+
+func TestStructuredLoggingLocation(t *testing.T) {
+	cases := map[string]struct {
+		handler  http.Handler
+		expected interface{}
+	}{
+		"redirect": {
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Redirect(w, r, "https://example.com/login?next=%2F", http.StatusFound)
+			}),
+			"https://example.com/login?next=%2F",
+		},
+		"created": {
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Location", "/users/1")
+				w.WriteHeader(http.StatusCreated)
+			}),
+			nil,
+		},
+		"set after the status": {
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusFound)
+				w.Header().Set("Location", "/login")
+			}),
+			nil,
+		},
+		"not a redirect": {okHandler, nil},
+	}
+
+	for k, tc := range cases {
+		logger := log.New("", "", "")
+		hook := test.NewLocal(logger.Logger)
+
+		StructuredLogHandler(logger, tc.handler).ServeHTTP(httptest.NewRecorder(), newRequest("GET", "http://example.com"))
+
+		assert.Equal(t, 1, len(hook.Entries), "test: %s", k)
+		location, ok := hook.LastEntry().Data["http.location"]
+		assert.Equal(t, tc.expected != nil, ok, "test: %s", k)
+		if ok {
+			assert.Equal(t, tc.expected, location, "test: %s", k)
+		}
+	}
+}
+
+func TestStructuredLoggingAuthScheme(t *testing.T) {
+	finder := auth.FinderFunc(func(creds interface{}, r *http.Request) (interface{}, error) {
+		return "user", nil
+	})
+	onError := failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, status int) {
+		w.WriteHeader(status)
+	})
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "client.example.com"}}
+
+	cases := map[string]struct {
+		handler  http.Handler
+		header   string
+		value    string
+		tls      *tls.ConnectionState
+		scheme   interface{}
+		provider interface{}
+	}{
+		"api key": {
+			auth.NewAPIKey("Graze", finder, onError).Then(okHandler),
+			"Authorization", "Graze key", nil,
+			"apikey", "Graze",
+		},
+		"bearer": {
+			auth.NewAPIKey("Bearer", finder, onError).Then(okHandler),
+			"Authorization", "Bearer token", nil,
+			"apikey", "Bearer",
+		},
+		"x api key": {
+			auth.NewXAPIKey(finder, onError).Then(okHandler),
+			"X-Api-Key", "key", nil,
+			"x-api-key", nil,
+		},
+		"client cert": {
+			auth.NewClientCert(&auth.ClientCertFinder{CommonNames: []string{"client.example.com"}}, onError).Then(okHandler),
+			"", "", &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}, VerifiedChains: [][]*x509.Certificate{{cert}}},
+			"client_cert", nil,
+		},
+		"rejected": {
+			auth.NewAPIKey("Graze", finder, onError).Then(okHandler),
+			"Authorization", "Other key", nil,
+			nil, nil,
+		},
+		"anonymous": {okHandler, "", "", nil, nil, nil},
+	}
+
+	for k, tc := range cases {
+		logger := log.New("", "", "")
+		hook := test.NewLocal(logger.Logger)
+
+		req := newRequest("GET", "http://example.com")
+		if tc.header != "" {
+			req.Header.Set(tc.header, tc.value)
+		}
+		req.TLS = tc.tls
+		StructuredLogHandler(logger, tc.handler).ServeHTTP(httptest.NewRecorder(), req)
+
+		assert.Equal(t, 1, len(hook.Entries), "test: %s", k)
+		for field, expected := range map[string]interface{}{"auth.scheme": tc.scheme, "auth.provider": tc.provider} {
+			value, ok := hook.LastEntry().Data[field]
+			assert.Equal(t, expected != nil, ok, "test: %s field: %s", k, field)
+			if ok {
+				assert.Equal(t, expected, value, "test: %s field: %s", k, field)
+			}
+		}
+	}
+}
+
+type limitedUser struct{}
+
+func (u *limitedUser) RateLimit() (float64, int) {
+	return 1, 2
+}
+
+func TestStructuredLoggingRateLimit(t *testing.T) {
+	finder := auth.NewKeyRateLimiter(auth.FinderFunc(func(creds interface{}, r *http.Request) (interface{}, error) {
+		if creds == "limited" {
+			return &limitedUser{}, nil
+		}
+		return "user", nil
+	}), time.Hour)
+	keyAuth := auth.NewAPIKey("Graze", finder, failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, status int) {
+		w.WriteHeader(status)
+	}))
+
+	cases := []struct {
+		key       string
+		status    int
+		remaining interface{}
+		limited   interface{}
+	}{
+		{"limited", http.StatusOK, 1, false},
+		{"limited", http.StatusOK, 0, false},
+		{"limited", http.StatusTooManyRequests, 0, true},
+		{"unlimited", http.StatusOK, nil, nil},
+		{"", http.StatusUnauthorized, nil, nil},
+	}
+
+	for i, tc := range cases {
+		logger := log.New("", "", "")
+		hook := test.NewLocal(logger.Logger)
+
+		req := newRequest("GET", "http://example.com")
+		if tc.key != "" {
+			req.Header.Set("Authorization", "Graze "+tc.key)
+		}
+		rec := httptest.NewRecorder()
+		StructuredLogHandler(logger, keyAuth.Then(okHandler)).ServeHTTP(rec, req)
+
+		assert.Equal(t, tc.status, rec.Code, "request: %d", i)
+		assert.Equal(t, 1, len(hook.Entries), "request: %d", i)
+		var bucket interface{}
+		if tc.limited != nil {
+			bucket = "key"
+		}
+		fields := map[string]interface{}{"ratelimit.remaining": tc.remaining, "ratelimit.limited": tc.limited, "ratelimit.bucket": bucket}
+		for field, expected := range fields {
+			value, ok := hook.LastEntry().Data[field]
+			assert.Equal(t, expected != nil, ok, "request: %d field: %s", i, field)
+			if ok {
+				assert.Equal(t, expected, value, "request: %d field: %s", i, field)
+			}
+		}
+	}
+}
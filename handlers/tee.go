@@ -0,0 +1,252 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/graze/golang-service/log"
+)
+
+// DefaultTeeRedactFields are the JSON and form fields that are always redacted from the copies of request bodies
+var DefaultTeeRedactFields = []string{"password", "token", "secret", "authorization"}
+
+// redacted replaces the value of a redacted field
+const redacted = "[REDACTED]"
+
+// teeBuffer is the number of copies that can be waiting to be written to the sink before copies are dropped
+const teeBuffer = 100
+
+// teeReader is a request body that keeps a copy of the first max bytes read
+type teeReader struct {
+	io.ReadCloser
+	max   int
+	copy  bytes.Buffer
+	over  bool
+	eof   bool
+	total int
+}
+
+func (r *teeReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.total += n
+	if !r.over {
+		if r.total > r.max {
+			r.over = true
+			r.copy.Reset()
+		} else {
+			r.copy.Write(p[:n])
+		}
+	}
+	if err == io.EOF {
+		r.eof = true
+	}
+	return n, err
+}
+
+// BodyTee sends a sampled copy of request bodies to a sink without affecting the handler
+type BodyTee struct {
+	sink   io.Writer
+	rate   uint64
+	max    int
+	fields map[string]bool
+
+	n      uint64
+	mu     sync.RWMutex
+	closed bool
+	copies chan teeCopy
+	done   chan struct{}
+}
+
+// teeCopy is a copy of a request body waiting to be redacted and written to the sink
+type teeCopy struct {
+	contentType string
+	body        []byte
+}
+
+// BodyTeeOption changes the behaviour of a BodyTee
+type BodyTeeOption func(t *BodyTee)
+
+// WithTeeSampleRate only copies 1 in every n request bodies, the default copies every body
+func WithTeeSampleRate(n int) BodyTeeOption {
+	return func(t *BodyTee) {
+		if n < 1 {
+			n = 1
+		}
+		t.rate = uint64(n)
+	}
+}
+
+// WithTeeMaxBytes sets the largest body that is copied (default: 64KB), larger bodies are not copied as they can not
+// be redacted once they are cut short
+func WithTeeMaxBytes(n int) BodyTeeOption {
+	return func(t *BodyTee) {
+		t.max = n
+	}
+}
+
+// WithTeeRedactFields adds fields to DefaultTeeRedactFields that are redacted from the copies of request bodies
+func WithTeeRedactFields(fields ...string) BodyTeeOption {
+	return func(t *BodyTee) {
+		for _, field := range fields {
+			t.fields[strings.ToLower(field)] = true
+		}
+	}
+}
+
+// Handler returns a http.Handler that copies the request body read by h, sending it to the sink after h has returned
+//
+// Only bodies that h reads to the end are copied
+func (t *BodyTee) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !hasBody(req) || atomic.AddUint64(&t.n, 1)%t.rate != 0 {
+			h.ServeHTTP(w, req)
+			return
+		}
+		body := &teeReader{ReadCloser: req.Body, max: t.max}
+		out := new(http.Request)
+		*out = *req
+		out.Body = body
+		h.ServeHTTP(w, out)
+
+		if body.eof && !body.over {
+			t.send(teeCopy{req.Header.Get("Content-Type"), body.copy.Bytes()})
+		}
+	})
+}
+
+// send queues a copy to be written to the sink, dropping it if the queue is full or the tee is closed
+func (t *BodyTee) send(c teeCopy) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.closed {
+		return
+	}
+	select {
+	case t.copies <- c:
+	default:
+		log.With(log.KV{"tag": "body_tee_dropped"}).Warnf("the body tee buffer is full, dropping a request body")
+	}
+}
+
+// Close stops copying request bodies and waits for the queued copies to be written to the sink
+func (t *BodyTee) Close() {
+	t.mu.Lock()
+	if !t.closed {
+		t.closed = true
+		close(t.copies)
+	}
+	t.mu.Unlock()
+	<-t.done
+}
+
+// run redacts and writes the queued copies to the sink until Close is called
+func (t *BodyTee) run() {
+	defer close(t.done)
+	for c := range t.copies {
+		body, err := t.redact(c.contentType, c.body)
+		if err != nil {
+			log.With(log.KV{"tag": "body_tee_failed"}).Err(err).Warnf("failed to redact a request body, dropping it")
+			continue
+		}
+		if _, err := t.sink.Write(body); err != nil {
+			log.With(log.KV{"tag": "body_tee_failed"}).Err(err).Warnf("failed to write a request body to the sink")
+		}
+	}
+}
+
+// redact returns body with the values of the redacted fields replaced for JSON and form bodies. Other bodies are
+// returned unchanged
+func (t *BodyTee) redact(contentType string, body []byte) ([]byte, error) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		var value interface{}
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		if err := decoder.Decode(&value); err != nil {
+			return nil, fmt.Errorf("invalid json body: %v", err)
+		}
+		return json.Marshal(t.redactJSON(value))
+	case mediaType == "application/x-www-form-urlencoded":
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return nil, fmt.Errorf("invalid form body: %v", err)
+		}
+		for k, v := range values {
+			if t.fields[strings.ToLower(k)] {
+				for i := range v {
+					v[i] = redacted
+				}
+			}
+		}
+		return []byte(values.Encode()), nil
+	}
+	return body, nil
+}
+
+// redactJSON replaces the values of redacted fields in any object within value
+func (t *BodyTee) redactJSON(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			if t.fields[strings.ToLower(k)] {
+				v[k] = redacted
+			} else {
+				v[k] = t.redactJSON(child)
+			}
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = t.redactJSON(child)
+		}
+	}
+	return value
+}
+
+// NewBodyTee returns a BodyTee that writes copies of request bodies to sink on a background goroutine, so the handler
+// is not slowed down by the sink. Each body is written with a single call to Write, copies are dropped if the sink can
+// not keep up
+//
+// The values of the fields in DefaultTeeRedactFields are replaced with `[REDACTED]` in JSON and form bodies, other
+// bodies are copied unchanged
+//
+// Usage:
+//  tee := handlers.NewBodyTee(sink, handlers.WithTeeSampleRate(100), handlers.WithTeeRedactFields("card_number"))
+//  defer tee.Close()
+//  http.ListenAndServe(":1123", tee.Handler(r))
+func NewBodyTee(sink io.Writer, opts ...BodyTeeOption) *BodyTee {
+	t := &BodyTee{
+		sink:   sink,
+		rate:   1,
+		max:    64 * 1024,
+		fields: make(map[string]bool, len(DefaultTeeRedactFields)),
+		copies: make(chan teeCopy, teeBuffer),
+		done:   make(chan struct{}),
+	}
+	for _, field := range DefaultTeeRedactFields {
+		t.fields[field] = true
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	go t.run()
+	return t
+}
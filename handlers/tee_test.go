@@ -0,0 +1,128 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingSink records each write
+type recordingSink struct {
+	mu     sync.Mutex
+	writes []string
+}
+
+func (s *recordingSink) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writes = append(s.writes, string(p))
+	return len(p), nil
+}
+
+// echoHandler writes the request body to the response
+var echoHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	io.Copy(w, r.Body)
+})
+
+func TestBodyTee(t *testing.T) {
+	cases := map[string]struct {
+		opts        []BodyTeeOption
+		handler     http.Handler
+		contentType string
+		body        string
+		expected    []string
+	}{
+		"json": {
+			nil,
+			echoHandler,
+			"application/json",
+			`{"name":"Jo","password":"hunter2","cards":[{"Token":"tok_1","last4":"4242"}]}`,
+			[]string{`{"cards":[{"Token":"[REDACTED]","last4":"4242"}],"name":"Jo","password":"[REDACTED]"}`},
+		},
+		"extra redacted fields": {
+			[]BodyTeeOption{WithTeeRedactFields("Name")},
+			echoHandler,
+			"application/json; charset=utf-8",
+			`{"name":"Jo","age":30}`,
+			[]string{`{"age":30,"name":"[REDACTED]"}`},
+		},
+		"form": {
+			nil,
+			echoHandler,
+			"application/x-www-form-urlencoded",
+			"user=jo&password=hunter2",
+			[]string{"password=%5BREDACTED%5D&user=jo"},
+		},
+		"other content types": {
+			nil,
+			echoHandler,
+			"text/plain",
+			"password=hunter2",
+			[]string{"password=hunter2"},
+		},
+		"invalid json is dropped": {
+			nil,
+			echoHandler,
+			"application/json",
+			`{"password":"hunter2"`,
+			nil,
+		},
+		"over the max size": {
+			[]BodyTeeOption{WithTeeMaxBytes(8)},
+			echoHandler,
+			"text/plain",
+			"a longer body",
+			nil,
+		},
+		"not read to the end": {
+			nil,
+			okHandler,
+			"text/plain",
+			"unread body",
+			nil,
+		},
+	}
+
+	for k, tc := range cases {
+		sink := &recordingSink{}
+		tee := NewBodyTee(sink, tc.opts...)
+		tee.Handler(tc.handler).ServeHTTP(httptest.NewRecorder(), bodyRequest("POST", "http://example.com", tc.contentType, tc.body))
+		tee.Close()
+
+		assert.Equal(t, tc.expected, sink.writes, "test: %s", k)
+	}
+}
+
+func TestBodyTeeSampleRate(t *testing.T) {
+	sink := &recordingSink{}
+	tee := NewBodyTee(sink, WithTeeSampleRate(3))
+	handler := tee.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		assert.Nil(t, err)
+		w.Write(body)
+	}))
+
+	for _, body := range []string{"one", "two", "three", "four", "five", "six", "seven"} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, bodyRequest("POST", "http://example.com", "text/plain", body))
+		assert.Equal(t, body, rec.Body.String(), "sampled and not sampled requests read the full body")
+	}
+	tee.Close()
+
+	assert.Equal(t, []string{"three", "six"}, sink.writes)
+}
@@ -0,0 +1,52 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/graze/golang-service/handlers/auth"
+)
+
+// WithTenantTag adds a `tenant:` tag to the statsd metrics using the user stored by the auth handlers
+//
+// extract returns the tenant (or user id) for the user returned by auth.GetUser. Requests without a user, or where
+// extract returns an empty string, are tagged `tenant:anonymous`. To limit the number of distinct tags, a list of
+// allowed tenants can be given and any other tenant is tagged `tenant:other`
+//
+// The statsd handler must be placed after (inside) the auth handler to see the user
+//
+// Usage:
+//  tenant := handlers.WithTenantTag(func(user interface{}) string {
+//      return user.(*account.User).Tenant
+//  })
+//  http.Handle("/", keyAuth.Then(handlers.StatsdIoHandler(client, r, tenant)))
+func WithTenantTag(extract func(user interface{}) string, allowed ...string) StatsdOption {
+	allowList := make(map[string]bool, len(allowed))
+	for _, tenant := range allowed {
+		allowList[tenant] = true
+	}
+	return func(h *statsdHandler) {
+		h.tags = append(h.tags, func(req *http.Request) []string {
+			tenant := ""
+			if user := auth.GetUser(req); user != nil {
+				tenant = extract(user)
+			}
+			switch {
+			case tenant == "":
+				tenant = "anonymous"
+			case len(allowList) > 0 && !allowList[tenant]:
+				tenant = "other"
+			}
+			return []string{"tenant:" + tenant}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/DataDog/datadog-go/statsd"
+	"github.com/graze/golang-service/handlers/auth"
+	"github.com/graze/golang-service/handlers/failure"
+	"github.com/graze/golang-service/nettest"
+	"github.com/stretchr/testify/assert"
+)
+
+type tenantUser struct {
+	tenant string
+}
+
+func TestStatsdTenantTag(t *testing.T) {
+	cases := map[string]struct {
+		key      string
+		expected string
+	}{
+		"authenticated": {"acme-key", "tenant:acme"},
+		"not allowed":   {"other-key", "tenant:other"},
+		"anonymous":     {"", "tenant:anonymous"},
+	}
+
+	done := make(chan string)
+	addr, sock, srvWg := nettest.CreateServer(t, "udp", "localhost:", done)
+	defer srvWg.Wait()
+	defer os.Remove(addr.String())
+	defer sock.Close()
+
+	client, err := statsd.New(addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	finder := auth.FinderFunc(func(key interface{}, r *http.Request) (interface{}, error) {
+		switch key {
+		case "acme-key":
+			return &tenantUser{"acme"}, nil
+		case "other-key":
+			return &tenantUser{"globex"}, nil
+		}
+		return nil, fmt.Errorf("unknown key")
+	})
+	tenant := WithTenantTag(func(user interface{}) string {
+		return user.(*tenantUser).tenant
+	}, "acme")
+	metrics := StatsdIoHandler(client, okHandler, tenant)
+
+	keyAuth := auth.NewXAPIKey(finder, failure.HandlerFunc(func(w http.ResponseWriter, r *http.Request, err error, status int) {
+		t.Errorf("onError handler called. Err: %s", err)
+	}))
+	authenticated := keyAuth.Then(metrics)
+
+	for k, tc := range cases {
+		req := newRequest("GET", "http://example.com")
+		handler := metrics
+		if tc.key != "" {
+			req.Header.Set("X-Api-Key", tc.key)
+			handler = authenticated
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		tags := "endpoint:/,statusCode:200,method:GET,protocol:HTTP/1.1," + tc.expected
+		assert.Regexp(t, `^request\.response_time:[0-9.]+\|ms\|#`+regexp.QuoteMeta(tags)+`$`, <-done, "test: %s", k)
+		assert.Equal(t, "request.count:1|c|#"+tags, <-done, "test: %s", k)
+	}
+}
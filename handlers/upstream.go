@@ -0,0 +1,142 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/graze/golang-service/log"
+)
+
+// UpstreamTiming records how long it took to establish the connection for an outbound request
+//
+// A phase is zero if it did not happen, such as when an existing connection was reused
+type UpstreamTiming struct {
+	mu                               sync.Mutex
+	dnsStart, connectStart, tlsStart time.Time
+	dns, connect, tls                time.Duration
+}
+
+// DNS returns the time taken to look up the host
+func (t *UpstreamTiming) DNS() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.dns
+}
+
+// Connect returns the time taken to open the TCP connection
+func (t *UpstreamTiming) Connect() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.connect
+}
+
+// TLS returns the time taken by the TLS handshake
+func (t *UpstreamTiming) TLS() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.tls
+}
+
+// start records the start of a phase
+func (t *UpstreamTiming) start(start *time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	*start = time.Now()
+}
+
+// done records the duration of a phase, if it was started
+func (t *UpstreamTiming) done(start *time.Time, dur *time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !start.IsZero() {
+		*dur = time.Since(*start)
+	}
+}
+
+// fields returns the phases that happened as log fields in seconds
+func (t *UpstreamTiming) fields() log.KV {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fields := log.KV{}
+	if t.dns > 0 {
+		fields["upstream.dns"] = t.dns.Seconds()
+	}
+	if t.connect > 0 {
+		fields["upstream.connect"] = t.connect.Seconds()
+	}
+	if t.tls > 0 {
+		fields["upstream.tls"] = t.tls.Seconds()
+	}
+	return fields
+}
+
+// TraceUpstream returns a copy of the outbound request req with a httptrace.ClientTrace that records how long the
+// DNS lookup, TCP connect and TLS handshake took. The TLS handshake is only recorded from go 1.8
+//
+// Usage:
+//  out, timing := handlers.TraceUpstream(out)
+//  resp, err := client.Do(out)
+//  fmt.Println(timing.DNS(), timing.Connect(), timing.TLS())
+func TraceUpstream(req *http.Request) (*http.Request, *UpstreamTiming) {
+	timing := &UpstreamTiming{}
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			timing.start(&timing.dnsStart)
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			timing.done(&timing.dnsStart, &timing.dns)
+		},
+		ConnectStart: func(network, addr string) {
+			timing.start(&timing.connectStart)
+		},
+		ConnectDone: func(network, addr string, err error) {
+			timing.done(&timing.connectStart, &timing.connect)
+		},
+	}
+	traceTLS(trace, timing)
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace)), timing
+}
+
+// upstreamTimingTransport traces the connection of outbound requests and adds the timings to the inbound request log
+type upstreamTimingTransport struct {
+	next http.RoundTripper
+}
+
+// RoundTrip makes the request with a trace, and adds the timings to the log fields of the request context
+func (t upstreamTimingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	out, timing := TraceUpstream(req)
+	resp, err := t.next.RoundTrip(out)
+	addLogFields(req, timing.fields())
+	return resp, err
+}
+
+// UpstreamTimingTransport returns a http.RoundTripper that records how long it took to establish the connection for
+// each outbound request, using next (default: http.DefaultTransport) to make the request
+//
+// The DNS lookup, TCP connect and TLS handshake are logged as `upstream.dns`, `upstream.connect` and `upstream.tls` in
+// seconds by the logging handlers, when the outbound request uses the inbound request's context (as
+// httputil.ReverseProxy does). Phases that did not happen, such as when a connection is reused, are not logged. If
+// several outbound requests are made, the timings of the last new connection are logged
+//
+// Usage:
+//  proxy := httputil.NewSingleHostReverseProxy(upstream)
+//  proxy.Transport = handlers.UpstreamTimingTransport(nil)
+//  http.ListenAndServe(":1123", handlers.StructuredHandler(proxy))
+func UpstreamTimingTransport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return upstreamTimingTransport{next}
+}
@@ -0,0 +1,88 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package handlers
+
+import (
+	"crypto/tls"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/Sirupsen/logrus/hooks/test"
+	"github.com/graze/golang-service/log"
+	"github.com/stretchr/testify/assert"
+)
+
+// localhostURL returns the url of server using the localhost name, so the host is looked up
+func localhostURL(t *testing.T, server *httptest.Server) string {
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, port, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u.Host = "localhost:" + port
+	return u.String()
+}
+
+func TestUpstreamTimingTransport(t *testing.T) {
+	upstream := httptest.NewTLSServer(okHandler)
+	defer upstream.Close()
+
+	logger := log.New("", "", "")
+	hook := test.NewLocal(logger.Logger)
+	client := &http.Client{Transport: UpstreamTimingTransport(&http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	})}
+	handler := StructuredLogHandler(logger, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		out, _ := http.NewRequest("GET", localhostURL(t, upstream), nil)
+		resp, err := client.Do(out.WithContext(r.Context()))
+		if assert.Nil(t, err) {
+			// the body is drained so the connection can be reused
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), newRequest("GET", "http://example.com/proxy"))
+	assert.Equal(t, 1, len(hook.Entries))
+	for _, field := range []string{"upstream.dns", "upstream.connect", "upstream.tls"} {
+		if assert.Contains(t, hook.LastEntry().Data, field) {
+			assert.True(t, hook.LastEntry().Data[field].(float64) > 0, "%s is recorded", field)
+		}
+	}
+
+	handler.ServeHTTP(httptest.NewRecorder(), newRequest("GET", "http://example.com/proxy"))
+	assert.Equal(t, 2, len(hook.Entries))
+	assert.NotContains(t, hook.LastEntry().Data, "upstream.connect", "a reused connection is not logged")
+}
+
+func TestTraceUpstream(t *testing.T) {
+	upstream := httptest.NewServer(okHandler)
+	defer upstream.Close()
+
+	out, _ := http.NewRequest("GET", localhostURL(t, upstream), nil)
+	out, timing := TraceUpstream(out)
+	resp, err := (&http.Transport{}).RoundTrip(out)
+	if assert.Nil(t, err) {
+		resp.Body.Close()
+	}
+
+	assert.True(t, timing.DNS() > 0)
+	assert.True(t, timing.Connect() > 0)
+	assert.Equal(t, int64(0), int64(timing.TLS()), "there is no handshake without TLS")
+}
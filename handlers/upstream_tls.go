@@ -0,0 +1,28 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+// +build go1.8
+
+package handlers
+
+import (
+	"crypto/tls"
+	"net/http/httptrace"
+)
+
+// traceTLS records the duration of the TLS handshake in timing
+func traceTLS(trace *httptrace.ClientTrace, timing *UpstreamTiming) {
+	trace.TLSHandshakeStart = func() {
+		timing.start(&timing.tlsStart)
+	}
+	trace.TLSHandshakeDone = func(tls.ConnectionState, error) {
+		timing.done(&timing.tlsStart, &timing.tls)
+	}
+}
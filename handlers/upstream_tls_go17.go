@@ -0,0 +1,18 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+// +build !go1.8
+
+package handlers
+
+import "net/http/httptrace"
+
+// traceTLS does nothing, as the TLS handshake can not be traced before go 1.8
+func traceTLS(trace *httptrace.ClientTrace, timing *UpstreamTiming) {}
@@ -0,0 +1,137 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package log
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultBufferSize is the size used by NewBufferedWriter when the size is not positive
+	DefaultBufferSize = 64 * 1024
+	// DefaultFlushInterval is the interval used by NewBufferedWriter when the interval is not positive
+	DefaultFlushInterval = time.Second
+)
+
+// BufferedWriter buffers log output to reduce the number of writes, flushing complete lines to the wrapped writer when
+// the buffer is full and on an interval
+//
+// Only complete lines are flushed, so a line is never split between writes
+type BufferedWriter struct {
+	w    io.Writer
+	size int
+
+	mu     sync.Mutex
+	buf    bytes.Buffer
+	closed bool
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// Write adds p to the buffer, flushing the complete lines if the buffer is full
+func (b *BufferedWriter) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return b.w.Write(p)
+	}
+	n, _ := b.buf.Write(p)
+	if b.buf.Len() >= b.size {
+		if err := b.flushLines(); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// Flush writes the complete lines in the buffer to the wrapped writer
+func (b *BufferedWriter) Flush() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.flushLines()
+}
+
+// flushLines writes the buffer up to the last new line, the lock must be held
+func (b *BufferedWriter) flushLines() error {
+	end := bytes.LastIndexByte(b.buf.Bytes(), '\n')
+	if end < 0 {
+		return nil
+	}
+	_, err := b.w.Write(b.buf.Next(end + 1))
+	return err
+}
+
+// Close stops the interval flush and writes everything in the buffer to the wrapped writer, including an incomplete
+// last line. Writes after Close are not buffered
+//
+// Call Close when the service shuts down so the buffered lines are not lost
+func (b *BufferedWriter) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	close(b.stop)
+	b.mu.Unlock()
+	<-b.done
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.buf.Len() == 0 {
+		return nil
+	}
+	_, err := b.w.Write(b.buf.Next(b.buf.Len()))
+	return err
+}
+
+// run flushes the buffer every interval until Close is called
+func (b *BufferedWriter) run(interval time.Duration) {
+	defer close(b.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			b.Flush()
+		case <-b.stop:
+			return
+		}
+	}
+}
+
+// NewBufferedWriter returns a BufferedWriter that writes to w when more than size bytes are buffered, or every interval
+//
+// DefaultBufferSize is used if size is not positive, and DefaultFlushInterval if interval is not positive
+//
+// Usage:
+//  out := log.NewBufferedWriter(file, 64*1024, time.Second)
+//  defer out.Close()
+//  log.SetOutput(out)
+func NewBufferedWriter(w io.Writer, size int, interval time.Duration) *BufferedWriter {
+	if size <= 0 {
+		size = DefaultBufferSize
+	}
+	if interval <= 0 {
+		interval = DefaultFlushInterval
+	}
+	b := &BufferedWriter{
+		w:    w,
+		size: size,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go b.run(interval)
+	return b
+}
@@ -0,0 +1,114 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package log
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingWriter records each write
+type recordingWriter struct {
+	mu     sync.Mutex
+	writes []string
+}
+
+func (w *recordingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.writes = append(w.writes, string(p))
+	return len(p), nil
+}
+
+func (w *recordingWriter) Writes() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]string(nil), w.writes...)
+}
+
+func TestBufferedWriter(t *testing.T) {
+	out := &recordingWriter{}
+	w := NewBufferedWriter(out, 1024, time.Hour)
+
+	logger := New("", "", "")
+	logger.SetFormatter(&CompactFormatter{Fields: []string{"n"}})
+	logger.SetOutput(w)
+	logger.With(KV{"n": 1}).Info("")
+	logger.With(KV{"n": 2}).Info("")
+	assert.Empty(t, out.Writes(), "lines are buffered")
+
+	w.Write([]byte("partial"))
+	assert.Nil(t, w.Flush())
+	assert.Equal(t, []string{"1\n2\n"}, out.Writes(), "buffered lines are written in a single write without the partial line")
+
+	w.Write([]byte(" line\n"))
+	assert.Nil(t, w.Close())
+	assert.Equal(t, []string{"1\n2\n", "partial line\n"}, out.Writes())
+
+	w.Write([]byte("after close\n"))
+	assert.Equal(t, "after close\n", out.Writes()[2], "writes after closing are not buffered")
+	assert.Nil(t, w.Close())
+}
+
+func TestBufferedWriterFlushesWhenFull(t *testing.T) {
+	out := &recordingWriter{}
+	w := NewBufferedWriter(out, 10, time.Hour)
+	defer w.Close()
+
+	w.Write([]byte("12345\n"))
+	assert.Empty(t, out.Writes())
+	w.Write([]byte("67890\nabc"))
+	assert.Equal(t, []string{"12345\n67890\n"}, out.Writes(), "the incomplete line is kept")
+}
+
+func TestBufferedWriterFlushesOnInterval(t *testing.T) {
+	var out bytes.Buffer
+	written := make(chan struct{})
+	w := NewBufferedWriter(writerFunc(func(p []byte) (int, error) {
+		out.Write(p)
+		close(written)
+		return len(p), nil
+	}), 1024, time.Millisecond)
+
+	w.Write([]byte("line\n"))
+	select {
+	case <-written:
+	case <-time.After(time.Second):
+		t.Fatal("the buffer was not flushed")
+	}
+	w.Close()
+	assert.Equal(t, "line\n", out.String())
+}
+
+func TestBufferedWriterDefaults(t *testing.T) {
+	out := &recordingWriter{}
+	w := NewBufferedWriter(out, 0, 0)
+	assert.Equal(t, DefaultBufferSize, w.size, "the default size is used if the size is not positive")
+
+	w.Write([]byte("line\n"))
+	assert.Empty(t, out.Writes(), "lines are still buffered")
+	assert.Nil(t, w.Close())
+	assert.Equal(t, []string{"line\n"}, out.Writes())
+
+	w = NewBufferedWriter(out, -1, -time.Second)
+	assert.Equal(t, DefaultBufferSize, w.size)
+	assert.Nil(t, w.Close(), "a negative interval does not stop the writer")
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
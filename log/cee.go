@@ -0,0 +1,81 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package log
+
+import (
+	"github.com/Sirupsen/logrus"
+)
+
+// ceePrefix is the cookie that rsyslog's mmjsonparse looks for at the start of a message
+const ceePrefix = "@cee: "
+
+// CEEFormatter formats an entry as JSON prefixed with `@cee: ` so rsyslog can parse the structured fields
+type CEEFormatter struct {
+	logrus.JSONFormatter
+}
+
+// Format writes the entry as `@cee: {json}`
+func (f *CEEFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	serialized, err := f.JSONFormatter.Format(entry)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(ceePrefix), serialized...), nil
+}
+
+// SyslogWriter is the set of methods used from a *syslog.Writer to write at each priority
+type SyslogWriter interface {
+	Crit(m string) error
+	Err(m string) error
+	Warning(m string) error
+	Info(m string) error
+	Debug(m string) error
+}
+
+// SyslogHook writes each entry to syslog using its own formatter, leaving the logger's output untouched
+//
+// Usage:
+//  hook, err := log.NewSyslogHook("udp", "localhost:514", "my-service")
+//  if err == nil {
+//      log.AddHook(hook)
+//  }
+type SyslogHook struct {
+	Writer    SyslogWriter
+	Formatter logrus.Formatter
+}
+
+// Fire formats the entry and writes it to syslog at a priority matching the entry's level
+func (h *SyslogHook) Fire(entry *logrus.Entry) error {
+	line, err := h.Formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+
+	switch entry.Level {
+	case logrus.PanicLevel, logrus.FatalLevel:
+		return h.Writer.Crit(string(line))
+	case logrus.ErrorLevel:
+		return h.Writer.Err(string(line))
+	case logrus.WarnLevel:
+		return h.Writer.Warning(string(line))
+	case logrus.InfoLevel:
+		return h.Writer.Info(string(line))
+	case logrus.DebugLevel:
+		return h.Writer.Debug(string(line))
+	default:
+		return nil
+	}
+}
+
+// Levels returns all the levels as every entry is sent to syslog
+func (h *SyslogHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
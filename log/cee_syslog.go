@@ -0,0 +1,26 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+// +build !windows,!nacl,!plan9
+
+package log
+
+import "log/syslog"
+
+// NewSyslogHook connects to a syslog daemon and returns a hook that writes entries to it in CEE format
+//
+// See syslog.Dial for the network and raddr options. An empty network connects to the local syslog server
+func NewSyslogHook(network, raddr, tag string) (*SyslogHook, error) {
+	w, err := syslog.Dial(network, raddr, syslog.LOG_INFO|syslog.LOG_USER, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &SyslogHook{w, &CEEFormatter{}}, nil
+}
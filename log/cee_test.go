@@ -0,0 +1,74 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+type syslogMock struct {
+	lines map[string][]string
+}
+
+func (s *syslogMock) write(priority, m string) error {
+	s.lines[priority] = append(s.lines[priority], m)
+	return nil
+}
+
+func (s *syslogMock) Crit(m string) error    { return s.write("crit", m) }
+func (s *syslogMock) Err(m string) error     { return s.write("err", m) }
+func (s *syslogMock) Warning(m string) error { return s.write("warning", m) }
+func (s *syslogMock) Info(m string) error    { return s.write("info", m) }
+func (s *syslogMock) Debug(m string) error   { return s.write("debug", m) }
+
+func TestCEEFormatter(t *testing.T) {
+	logger := New("", "", "")
+	buf := &bytes.Buffer{}
+	logger.SetOutput(buf)
+	logger.SetFormatter(&CEEFormatter{})
+
+	logger.With(KV{"key": "value"}).Info("message")
+
+	line := buf.String()
+	assert.True(t, strings.HasPrefix(line, "@cee: {"), "line: %s", line)
+
+	fields := map[string]interface{}{}
+	assert.Nil(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "@cee: ")), &fields))
+	assert.Equal(t, "value", fields["key"])
+	assert.Equal(t, "message", fields["msg"])
+}
+
+func TestSyslogHookOnlyPrefixesTheSyslogOutput(t *testing.T) {
+	logger := New("", "", "")
+	buf := &bytes.Buffer{}
+	logger.SetOutput(buf)
+	logger.SetFormatter(&logrus.JSONFormatter{})
+	mock := &syslogMock{map[string][]string{}}
+	logger.AddHook(&SyslogHook{mock, &CEEFormatter{}})
+
+	logger.With(KV{"key": "value"}).Info("message")
+	logger.Error("error")
+
+	assert.True(t, strings.HasPrefix(buf.String(), "{"), "stdout: %s", buf.String())
+	assert.NotContains(t, buf.String(), "@cee:")
+
+	assert.Equal(t, 1, len(mock.lines["info"]))
+	assert.True(t, strings.HasPrefix(mock.lines["info"][0], "@cee: {"))
+	assert.Contains(t, mock.lines["info"][0], `"key":"value"`)
+	assert.Equal(t, 1, len(mock.lines["err"]))
+	assert.True(t, strings.HasPrefix(mock.lines["err"][0], "@cee: {"))
+}
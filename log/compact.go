@@ -0,0 +1,75 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// DefaultCompactFields are the fields written by the CompactFormatter, for the request entries of the structured handler
+var DefaultCompactFields = []string{"http.method", "http.path", "http.status", "dur"}
+
+// CompactFormatter writes only the values of a list of fields separated by spaces, for sinks with a small line size
+// limit, such as: `GET /path 200 12ms`
+//
+// Any other fields are dropped. The `dur` field (in seconds) is written in milliseconds. Entries without any of the
+// fields are written as the message
+//
+// Usage:
+//  logger := log.New("", "", "")
+//  logger.SetFormatter(&log.CompactFormatter{MaxLength: 128})
+type CompactFormatter struct {
+	// Fields are the fields to write in order (default: DefaultCompactFields)
+	Fields []string
+	// MaxLength truncates lines longer than this many bytes, 0 does not truncate
+	MaxLength int
+}
+
+// Format writes the values of the fields as a single line
+func (f *CompactFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	fields := f.Fields
+	if len(fields) == 0 {
+		fields = DefaultCompactFields
+	}
+
+	values := make([]string, 0, len(fields))
+	for _, k := range fields {
+		v, ok := entry.Data[k]
+		if !ok {
+			continue
+		}
+		if secs, ok := v.(float64); ok && k == "dur" {
+			values = append(values, fmt.Sprintf("%dms", int64(time.Duration(secs*float64(time.Second))/time.Millisecond)))
+			continue
+		}
+		values = append(values, fmt.Sprintf("%v", v))
+	}
+
+	line := strings.Join(values, " ")
+	if len(values) == 0 {
+		line = entry.Message
+	}
+	// keep each entry on a single line
+	line = strings.Replace(line, "\n", " ", -1)
+	if f.MaxLength > 0 && len(line) > f.MaxLength {
+		line = line[:f.MaxLength]
+	}
+
+	b := &bytes.Buffer{}
+	b.WriteString(line)
+	b.WriteByte('\n')
+	return b.Bytes(), nil
+}
@@ -0,0 +1,71 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package log
+
+import (
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompactFormatter(t *testing.T) {
+	request := logrus.Fields{
+		"http.method":     "GET",
+		"http.path":       "/path",
+		"http.status":     200,
+		"dur":             0.0125,
+		"http.user-agent": "some user agent",
+		"module":          "request.handler",
+	}
+
+	cases := map[string]struct {
+		formatter *CompactFormatter
+		fields    logrus.Fields
+		message   string
+		expected  string
+	}{
+		"default fields": {
+			&CompactFormatter{},
+			request,
+			"GET /path HTTP/1.1",
+			"GET /path 200 12ms\n",
+		},
+		"configured fields": {
+			&CompactFormatter{Fields: []string{"http.status", "module", "missing"}},
+			request,
+			"GET /path HTTP/1.1",
+			"200 request.handler\n",
+		},
+		"truncated": {
+			&CompactFormatter{MaxLength: 9},
+			request,
+			"GET /path HTTP/1.1",
+			"GET /path\n",
+		},
+		"no fields": {
+			&CompactFormatter{},
+			logrus.Fields{"key": "value"},
+			"some\nmessage",
+			"some message\n",
+		},
+	}
+
+	for k, tc := range cases {
+		entry := logrus.NewEntry(logrus.New())
+		entry.Data = tc.fields
+		entry.Message = tc.message
+
+		b, err := tc.formatter.Format(entry)
+		assert.Nil(t, err, "test: %s", k)
+		assert.Equal(t, tc.expected, string(b), "test: %s", k)
+	}
+}
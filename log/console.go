@@ -0,0 +1,140 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+)
+
+const (
+	// DefaultConsoleTimeFormat is the format of the time written by the ConsoleFormatter
+	DefaultConsoleTimeFormat = "15:04:05.000"
+	// consoleMessageWidth is the width the message is padded to, so the fields of consecutive lines line up
+	consoleMessageWidth = 40
+)
+
+// ANSI color codes for each level
+const (
+	colorRed    = 31
+	colorYellow = 33
+	colorBlue   = 36
+	colorGray   = 37
+)
+
+// ConsoleFormatter writes each entry as an aligned, human readable line for local development:
+//  INFO    10:51:32.123 Received request                         method=GET path=/path
+//
+// The level and field names are colored when the output is a terminal, unless DisableColors is set or the `NO_COLOR`
+// environment variable is not empty
+//
+// Usage:
+//  log.SetFormatter(&log.ConsoleFormatter{})
+type ConsoleFormatter struct {
+	// TimeFormat is the format of the time (default: DefaultConsoleTimeFormat)
+	TimeFormat string
+	// DisableColors writes plain output, even to a terminal
+	DisableColors bool
+
+	// terminal returns true if the output is a terminal (default: logrus.IsTerminal)
+	terminal func() bool
+}
+
+// Format writes the level, time, message and then the fields of the entry sorted by name
+func (f *ConsoleFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	format := f.TimeFormat
+	if format == "" {
+		format = DefaultConsoleTimeFormat
+	}
+	color := 0
+	if f.colored() {
+		color = levelColor(entry.Level)
+	}
+
+	keys := make([]string, 0, len(entry.Data))
+	for k := range entry.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	b := &bytes.Buffer{}
+	// pad to the longest level name, warning
+	b.WriteString(paint(fmt.Sprintf("%-7s", strings.ToUpper(entry.Level.String())), color))
+	b.WriteByte(' ')
+	b.WriteString(entry.Time.Format(format))
+	b.WriteByte(' ')
+	// keep each entry on a single line
+	message := strings.Replace(entry.Message, "\n", " ", -1)
+	if len(keys) > 0 {
+		message = fmt.Sprintf("%-*s", consoleMessageWidth, message)
+	}
+	b.WriteString(message)
+	for _, k := range keys {
+		b.WriteByte(' ')
+		b.WriteString(paint(k, color))
+		b.WriteByte('=')
+		b.WriteString(consoleValue(entry.Data[k]))
+	}
+	b.WriteByte('\n')
+	return b.Bytes(), nil
+}
+
+// colored returns true if the output should use colors
+func (f *ConsoleFormatter) colored() bool {
+	if f.DisableColors || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	if f.terminal != nil {
+		return f.terminal()
+	}
+	return logrus.IsTerminal()
+}
+
+// levelColor returns the ANSI color code for level
+func levelColor(level logrus.Level) int {
+	switch level {
+	case logrus.DebugLevel:
+		return colorGray
+	case logrus.WarnLevel:
+		return colorYellow
+	case logrus.ErrorLevel, logrus.FatalLevel, logrus.PanicLevel:
+		return colorRed
+	}
+	return colorBlue
+}
+
+// paint wraps s in the ANSI escape codes for color, or returns s if color is 0
+func paint(s string, color int) string {
+	if color == 0 {
+		return s
+	}
+	return fmt.Sprintf("\x1b[%dm%s\x1b[0m", color, s)
+}
+
+// consoleValue formats v, quoting it if it is empty or contains spaces, quotes or `=`
+func consoleValue(v interface{}) string {
+	var s string
+	switch v := v.(type) {
+	case error:
+		s = v.Error()
+	default:
+		s = fmt.Sprintf("%v", v)
+	}
+	if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+		return fmt.Sprintf("%q", s)
+	}
+	return s
+}
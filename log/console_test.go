@@ -0,0 +1,105 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package log
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func notTTY() bool { return false }
+func isTTY() bool  { return true }
+
+func TestConsoleFormatter(t *testing.T) {
+	ts := time.Date(2016, 10, 28, 10, 51, 32, 123000000, time.UTC)
+
+	cases := map[string]struct {
+		formatter *ConsoleFormatter
+		level     logrus.Level
+		fields    logrus.Fields
+		message   string
+		expected  string
+	}{
+		"fields": {
+			&ConsoleFormatter{terminal: notTTY},
+			logrus.InfoLevel,
+			logrus.Fields{"path": "/path", "method": "GET", "status": 200},
+			"Received request",
+			"INFO    10:51:32.123 Received request                         method=GET path=/path status=200\n",
+		},
+		"quoted values": {
+			&ConsoleFormatter{terminal: notTTY},
+			logrus.WarnLevel,
+			logrus.Fields{"agent": "some agent", "empty": "", "error": errors.New("failed")},
+			"slow",
+			"WARNING 10:51:32.123 slow                                     agent=\"some agent\" empty=\"\" error=failed\n",
+		},
+		"no fields": {
+			&ConsoleFormatter{terminal: notTTY},
+			logrus.ErrorLevel,
+			logrus.Fields{},
+			"some\nmessage",
+			"ERROR   10:51:32.123 some message\n",
+		},
+		"time format": {
+			&ConsoleFormatter{TimeFormat: time.RFC3339, terminal: notTTY},
+			logrus.DebugLevel,
+			logrus.Fields{},
+			"message",
+			"DEBUG   2016-10-28T10:51:32Z message\n",
+		},
+		"colored": {
+			&ConsoleFormatter{terminal: isTTY},
+			logrus.ErrorLevel,
+			logrus.Fields{"key": "value"},
+			"failed",
+			"\x1b[31mERROR  \x1b[0m 10:51:32.123 failed                                   \x1b[31mkey\x1b[0m=value\n",
+		},
+		"colors disabled": {
+			&ConsoleFormatter{DisableColors: true, terminal: isTTY},
+			logrus.InfoLevel,
+			logrus.Fields{},
+			"message",
+			"INFO    10:51:32.123 message\n",
+		},
+	}
+
+	for k, tc := range cases {
+		entry := logrus.NewEntry(logrus.New())
+		entry.Data = tc.fields
+		entry.Level = tc.level
+		entry.Message = tc.message
+		entry.Time = ts
+
+		b, err := tc.formatter.Format(entry)
+		assert.Nil(t, err, "test: %s", k)
+		assert.Equal(t, tc.expected, string(b), "test: %s", k)
+	}
+}
+
+func TestConsoleFormatterNoColorEnv(t *testing.T) {
+	os.Setenv("NO_COLOR", "1")
+	defer os.Unsetenv("NO_COLOR")
+
+	entry := logrus.NewEntry(logrus.New())
+	entry.Level = logrus.InfoLevel
+	entry.Message = "message"
+	entry.Time = time.Date(2016, 10, 28, 10, 51, 32, 0, time.UTC)
+
+	b, err := (&ConsoleFormatter{terminal: isTTY}).Format(entry)
+	assert.Nil(t, err)
+	assert.Equal(t, "INFO    10:51:32.000 message\n", string(b))
+}
@@ -107,6 +107,8 @@ func (c *LoggerEntry) AppendContext(ctx context.Context, fields KV) context.Cont
 }
 
 // With creates a new LoggerEntry and adds the fields to it
+//
+// A field with the same name as an existing field replaces it, so the last value written wins
 func (c *LoggerEntry) With(fields KV) FieldLogger {
 	// type conversion of same type without refection
 	data := make(logrus.Fields, len(fields))
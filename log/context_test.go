@@ -55,6 +55,16 @@ func TestMergeContext(t *testing.T) {
 	assert.Equal(t, KV{"test": 1, "test2": 2}, logger.With(logger2.Fields()).Fields())
 }
 
+func TestWithLastWriteWins(t *testing.T) {
+	logger := New("", "", "").With(KV{"user": "first", "app": "service"})
+
+	for i := 0; i < 10; i++ {
+		fields := logger.With(KV{"user": "second"}).With(KV{"user": "third"}).Fields()
+		assert.Equal(t, KV{"user": "third", "app": "service"}, fields)
+	}
+	assert.Equal(t, KV{"user": "first", "app": "service"}, logger.Fields(), "the original logger is not changed")
+}
+
 func testImplements(t *testing.T) {
 	logger := New("", "", "")
 	assert.Implements(t, (*Logger)(nil), logger)
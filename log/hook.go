@@ -0,0 +1,122 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package log
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// Hook is sent each log entry at the levels it was added for, such as an adapter to an error tracking service
+type Hook interface {
+	Fire(level logrus.Level, message string, fields KV) error
+}
+
+// HookFunc converts a function to a Hook
+type HookFunc func(level logrus.Level, message string, fields KV) error
+
+// Fire calls the function
+func (f HookFunc) Fire(level logrus.Level, message string, fields KV) error {
+	return f(level, message, fields)
+}
+
+// hookEntry is a copy of a log entry waiting to be sent to a Hook
+type hookEntry struct {
+	level   logrus.Level
+	message string
+	fields  KV
+}
+
+// AsyncHook is a logrus.Hook that sends entries to a Hook on a background goroutine, so a slow Hook does not block
+// the code doing the logging
+//
+// If the buffer is full the entry is dropped rather than waiting. Errors returned by the Hook are written to stderr
+type AsyncHook struct {
+	hook    Hook
+	levels  []logrus.Level
+	entries chan hookEntry
+	done    chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// Fire queues a copy of the entry to be sent to the Hook
+func (h *AsyncHook) Fire(entry *logrus.Entry) error {
+	fields := make(KV, len(entry.Data))
+	for k, v := range entry.Data {
+		fields[k] = v
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if h.closed {
+		return fmt.Errorf("the hook is closed")
+	}
+	select {
+	case h.entries <- hookEntry{entry.Level, entry.Message, fields}:
+		return nil
+	default:
+		return fmt.Errorf("the hook buffer is full, dropping entry: %s", entry.Message)
+	}
+}
+
+// Levels returns the levels the Hook is sent entries for
+func (h *AsyncHook) Levels() []logrus.Level {
+	return h.levels
+}
+
+// Close stops accepting entries and waits for the queued entries to be sent to the Hook
+func (h *AsyncHook) Close() {
+	h.mu.Lock()
+	if !h.closed {
+		h.closed = true
+		close(h.entries)
+	}
+	h.mu.Unlock()
+	<-h.done
+}
+
+// run sends the queued entries to the Hook until Close is called
+func (h *AsyncHook) run() {
+	defer close(h.done)
+	for entry := range h.entries {
+		if err := h.hook.Fire(entry.level, entry.message, entry.fields); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to fire hook: %v\n", err)
+		}
+	}
+}
+
+// NewAsyncHook returns an AsyncHook that sends entries at levels (default: error and above) to hook, holding up to
+// buffer entries while waiting to be sent
+//
+// Usage:
+//  hook := log.NewAsyncHook(log.HookFunc(func(level logrus.Level, message string, fields log.KV) error {
+//      return tracker.Send(message, fields)
+//  }), 100, log.ErrorLevel)
+//  defer hook.Close()
+//  log.AddHook(hook)
+func NewAsyncHook(hook Hook, buffer int, levels ...logrus.Level) *AsyncHook {
+	if len(levels) == 0 {
+		levels = []logrus.Level{PanicLevel, FatalLevel, ErrorLevel}
+	}
+	h := &AsyncHook{
+		hook:    hook,
+		levels:  levels,
+		entries: make(chan hookEntry, buffer),
+		done:    make(chan struct{}),
+	}
+	go h.run()
+	return h
+}
@@ -0,0 +1,73 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package log
+
+import (
+	"io/ioutil"
+	"sync"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingHook records each entry it is sent
+type recordingHook struct {
+	mu       sync.Mutex
+	levels   []logrus.Level
+	messages []string
+	fields   []KV
+}
+
+func (h *recordingHook) Fire(level logrus.Level, message string, fields KV) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.levels = append(h.levels, level)
+	h.messages = append(h.messages, message)
+	h.fields = append(h.fields, fields)
+	return nil
+}
+
+func TestAsyncHookFiresOnError(t *testing.T) {
+	logger := New("", "", "")
+	logger.SetOutput(ioutil.Discard)
+	recorder := &recordingHook{}
+	hook := NewAsyncHook(recorder, 10)
+	logger.AddHook(hook)
+
+	logger.With(KV{"key": "value"}).Info("info")
+	logger.With(KV{"key": "value"}).Warn("warning")
+	logger.With(KV{"key": "value"}).Error("error")
+	hook.Close()
+
+	assert.Equal(t, []logrus.Level{ErrorLevel}, recorder.levels)
+	assert.Equal(t, []string{"error"}, recorder.messages)
+	assert.Equal(t, "value", recorder.fields[0]["key"])
+
+	assert.NotNil(t, hook.Fire(logrus.NewEntry(logrus.New())), "entries are rejected after closing")
+}
+
+func TestAsyncHookDoesNotBlock(t *testing.T) {
+	release := make(chan struct{})
+	hook := NewAsyncHook(HookFunc(func(level logrus.Level, message string, fields KV) error {
+		<-release
+		return nil
+	}), 1, InfoLevel)
+	defer hook.Close()
+	defer close(release)
+
+	entry := logrus.NewEntry(logrus.New())
+	// the hook is blocked, so once the buffer is full entries are dropped rather than blocking
+	assert.Nil(t, hook.Fire(entry))
+	for hook.Fire(entry) == nil {
+	}
+	assert.Equal(t, []logrus.Level{InfoLevel}, hook.Levels())
+}
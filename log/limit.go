@@ -0,0 +1,87 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package log
+
+import (
+	"sort"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// DefaultMaxFields is the number of fields kept by a FieldLimitHook if no maximum is given
+const DefaultMaxFields = 100
+
+// DefaultKeptFields are the fields a FieldLimitHook keeps before any others
+var DefaultKeptFields = []string{"app", "env", "module", "tag", logrus.ErrorKey}
+
+// FieldLimitHook caps the number of fields in each entry, to protect the log pipeline from an entry with a runaway
+// number of fields
+//
+// Entries with more than Max fields keep the Keep fields, then the rest in name order up to Max, and have
+// `fields_truncated=true` added
+type FieldLimitHook struct {
+	// Max is the number of fields to keep
+	Max int
+	// Keep are the fields to keep before any others (default: DefaultKeptFields)
+	Keep []string
+}
+
+// Fire drops the fields of entry beyond the maximum
+func (h *FieldLimitHook) Fire(entry *logrus.Entry) error {
+	if len(entry.Data) <= h.Max {
+		return nil
+	}
+	keep := h.Keep
+	if keep == nil {
+		keep = DefaultKeptFields
+	}
+
+	kept := make(logrus.Fields, h.Max+1)
+	for _, k := range keep {
+		if v, ok := entry.Data[k]; ok && len(kept) < h.Max {
+			kept[k] = v
+		}
+	}
+	names := make([]string, 0, len(entry.Data))
+	for k := range entry.Data {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		if len(kept) >= h.Max {
+			break
+		}
+		kept[k] = entry.Data[k]
+	}
+	kept["fields_truncated"] = true
+	entry.Data = kept
+	return nil
+}
+
+// Levels returns all the levels
+func (h *FieldLimitHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// NewFieldLimitHook returns a FieldLimitHook that keeps at most max fields in each entry, if max is 0 DefaultMaxFields
+// is used
+//
+// Hooks are fired in the order they are added, so add it after any hooks that add fields
+//
+// Usage:
+//  log.AddHook(log.NewProcessHook())
+//  log.AddHook(log.NewFieldLimitHook(0))
+func NewFieldLimitHook(max int) *FieldLimitHook {
+	if max <= 0 {
+		max = DefaultMaxFields
+	}
+	return &FieldLimitHook{Max: max}
+}
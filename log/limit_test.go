@@ -0,0 +1,55 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package log
+
+import (
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/Sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFieldLimitHook(t *testing.T) {
+	logger := New("app", "", "")
+	logger.SetOutput(ioutil.Discard)
+	logger.AddHook(NewFieldLimitHook(5))
+	hook := test.NewLocal(logger.Logger)
+
+	fields := KV{"tag": "runaway"}
+	for i := 0; i < 200; i++ {
+		fields[fmt.Sprintf("field.%03d", i)] = i
+	}
+	logger.With(fields).Info("too many fields")
+
+	assert.Equal(t, 1, len(hook.Entries))
+	data := hook.LastEntry().Data
+	assert.Equal(t, 6, len(data), "5 fields and the truncated flag")
+	assert.Equal(t, true, data["fields_truncated"])
+	assert.Equal(t, "app", data["app"], "kept fields are kept first")
+	assert.Equal(t, "runaway", data["tag"], "kept fields are kept first")
+	for _, k := range []string{"field.000", "field.001", "field.002"} {
+		assert.Contains(t, data, k, "the rest are kept in name order")
+	}
+	assert.NotContains(t, data, "field.003")
+
+	logger.With(KV{"tag": "small", "key": "value"}).Info("few fields")
+	assert.Equal(t, KV{"app": "app", "tag": "small", "key": "value"}, KV(hook.LastEntry().Data), "entries under the limit are unchanged")
+
+	logger.With(KV{"a": 1, "b": 2, "c": 3, "d": 4}).Info("exactly the limit")
+	assert.NotContains(t, hook.LastEntry().Data, "fields_truncated")
+}
+
+func TestNewFieldLimitHookDefault(t *testing.T) {
+	assert.Equal(t, DefaultMaxFields, NewFieldLimitHook(0).Max)
+	assert.Equal(t, 20, NewFieldLimitHook(20).Max)
+}
@@ -0,0 +1,86 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// DefaultTimestampKey is the key the NDJSONFormatter writes the timestamp as
+const DefaultTimestampKey = "@timestamp"
+
+// NDJSONFormatter formats each entry as a single line of JSON terminated by `\n` with a configurable timestamp key
+//
+// Usage:
+//  log.SetFormatter(&log.NDJSONFormatter{TimeField: "http.time"})
+type NDJSONFormatter struct {
+	// TimestampKey is the key the timestamp is written as (default: DefaultTimestampKey)
+	TimestampKey string
+	// TimestampFormat is the format of the timestamp (default: time.RFC3339Nano)
+	TimestampFormat string
+	// TimeField is an optional field holding an RFC3339 time to use as the timestamp instead of the time the entry was
+	// logged, such as the `http.time` field from the structured handler. The field is removed from the output
+	TimeField string
+}
+
+// Format writes the entry as a single line of JSON
+func (f *NDJSONFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	key := f.TimestampKey
+	if key == "" {
+		key = DefaultTimestampKey
+	}
+	format := f.TimestampFormat
+	if format == "" {
+		format = time.RFC3339Nano
+	}
+
+	ts := entry.Time
+	data := make(logrus.Fields, len(entry.Data)+3)
+	for k, v := range entry.Data {
+		if k == f.TimeField && f.TimeField != "" {
+			if s, ok := v.(string); ok {
+				if parsed, err := time.Parse(time.RFC3339Nano, s); err == nil {
+					ts = parsed
+					continue
+				}
+			}
+		}
+		switch v := v.(type) {
+		case error:
+			// errors are otherwise written as {} by encoding/json
+			data[k] = v.Error()
+		default:
+			data[k] = v
+		}
+	}
+
+	// keep any fields that clash with the standard keys
+	for _, k := range []string{key, "msg", "level"} {
+		if v, ok := data[k]; ok {
+			data["fields."+k] = v
+		}
+	}
+
+	data[key] = ts.Format(format)
+	data["msg"] = entry.Message
+	data["level"] = entry.Level.String()
+
+	// encoding/json escapes any new lines within strings so the output is always a single line
+	serialized, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal fields to JSON: %v", err)
+	}
+	return append(serialized, '\n'), nil
+}
@@ -0,0 +1,85 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNDJSONFormatter(t *testing.T) {
+	requestTime := time.Date(2016, 10, 28, 10, 51, 31, 542424381, time.UTC)
+
+	cases := map[string]struct {
+		formatter *NDJSONFormatter
+		fields    KV
+		expected  map[string]interface{}
+		missing   []string
+	}{
+		"default key": {
+			&NDJSONFormatter{},
+			KV{"key": "value"},
+			map[string]interface{}{"key": "value", "msg": "multi\nline", "level": "info"},
+			[]string{"time"},
+		},
+		"custom key": {
+			&NDJSONFormatter{TimestampKey: "ts"},
+			KV{},
+			map[string]interface{}{"msg": "multi\nline"},
+			[]string{"@timestamp", "time"},
+		},
+		"time field": {
+			&NDJSONFormatter{TimeField: "http.time"},
+			KV{"http.time": requestTime.Format(time.RFC3339Nano)},
+			map[string]interface{}{"@timestamp": "2016-10-28T10:51:31.542424381Z"},
+			[]string{"http.time"},
+		},
+		"clashing field": {
+			&NDJSONFormatter{},
+			KV{"@timestamp": "other", "level": "other"},
+			map[string]interface{}{"fields.@timestamp": "other", "fields.level": "other", "level": "info"},
+			[]string{},
+		},
+	}
+
+	for k, tc := range cases {
+		logger := New("", "", "")
+		buf := &bytes.Buffer{}
+		logger.SetOutput(buf)
+		logger.SetFormatter(tc.formatter)
+
+		logger.With(tc.fields).Info("multi\nline")
+
+		line := buf.String()
+		assert.Equal(t, 1, strings.Count(line, "\n"), "test: %s - single line", k)
+		assert.True(t, strings.HasSuffix(line, "}\n"), "test: %s - terminated by a new line", k)
+
+		fields := map[string]interface{}{}
+		assert.Nil(t, json.Unmarshal([]byte(line), &fields), "test: %s", k)
+		key := tc.formatter.TimestampKey
+		if key == "" {
+			key = DefaultTimestampKey
+		}
+		_, err := time.Parse(time.RFC3339, fields[key].(string))
+		assert.Nil(t, err, "test: %s - timestamp is RFC3339", k)
+		for f, v := range tc.expected {
+			assert.Equal(t, v, fields[f], "test: %s - field: %s", k, f)
+		}
+		for _, f := range tc.missing {
+			assert.NotContains(t, fields, f, "test: %s - field: %s", k, f)
+		}
+	}
+}
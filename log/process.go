@@ -0,0 +1,53 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package log
+
+import (
+	"os"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// processStart is recorded when the package is initialised, as close to the start of the process as possible
+var processStart = time.Now()
+
+// ProcessHook adds the `process.pid` and `process.uptime` (in seconds) fields to every entry, to identify which
+// process instance wrote a log line across restarts
+type ProcessHook struct {
+	pid   int
+	start time.Time
+	now   func() time.Time
+}
+
+// Fire adds the process fields to the entry
+func (h *ProcessHook) Fire(entry *logrus.Entry) error {
+	entry.Data["process.pid"] = h.pid
+	entry.Data["process.uptime"] = h.now().Sub(h.start).Seconds()
+	return nil
+}
+
+// Levels returns all the levels
+func (h *ProcessHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// NewProcessHook returns a ProcessHook for the current process
+//
+// Usage:
+//  log.AddHook(log.NewProcessHook())
+func NewProcessHook() *ProcessHook {
+	return &ProcessHook{
+		pid:   os.Getpid(),
+		start: processStart,
+		now:   time.Now,
+	}
+}
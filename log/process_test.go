@@ -0,0 +1,41 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package log
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessHook(t *testing.T) {
+	logger := New("", "", "")
+	logger.SetOutput(ioutil.Discard)
+	process := NewProcessHook()
+	now := process.start.Add(5 * time.Second)
+	process.now = func() time.Time { return now }
+	logger.AddHook(process)
+	hook := test.NewLocal(logger.Logger)
+
+	logger.Info("first")
+	assert.Equal(t, os.Getpid(), hook.LastEntry().Data["process.pid"])
+	assert.Equal(t, 5.0, hook.LastEntry().Data["process.uptime"])
+
+	now = now.Add(1500 * time.Millisecond)
+	logger.With(KV{"key": "value"}).Warn("second")
+	assert.Equal(t, os.Getpid(), hook.LastEntry().Data["process.pid"])
+	assert.Equal(t, 6.5, hook.LastEntry().Data["process.uptime"], "uptime increases")
+	assert.Equal(t, "value", hook.LastEntry().Data["key"])
+}
@@ -30,5 +30,13 @@ Example:
 Usage:
     client, _ := GetStatsdFromEnv()
     client.Incr("metric", []string{"tag","tag2"}, 1)
+
+Connections
+
+Send the number of open connections on a listener as the server.connections gauge
+
+Usage:
+    l, _ := net.Listen("tcp", ":1123")
+    http.Serve(metrics.NewConnectionListener(l, client), r)
 */
 package metrics
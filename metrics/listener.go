@@ -0,0 +1,78 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package metrics
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"github.com/DataDog/datadog-go/statsd"
+)
+
+// ConnectionListener is a net.Listener that sends the number of open connections to statsd as `server.connections`
+// each time a connection is accepted or closed
+type ConnectionListener struct {
+	net.Listener
+	client *statsd.Client
+	tags   []string
+	open   int64
+}
+
+// Accept waits for the next connection and sends the new number of open connections
+func (l *ConnectionListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	l.gauge(atomic.AddInt64(&l.open, 1))
+	return &countedConn{Conn: conn, listener: l}, nil
+}
+
+// Connections returns the number of connections that have been accepted and not closed
+func (l *ConnectionListener) Connections() int64 {
+	return atomic.LoadInt64(&l.open)
+}
+
+func (l *ConnectionListener) gauge(n int64) {
+	l.client.Gauge("server.connections", float64(n), l.tags, 1)
+}
+
+// countedConn is a net.Conn that reduces the number of open connections on its listener once it is closed
+type countedConn struct {
+	net.Conn
+	listener *ConnectionListener
+	once     sync.Once
+}
+
+// Close closes the connection and sends the new number of open connections the first time it is called
+func (c *countedConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(func() {
+		c.listener.gauge(atomic.AddInt64(&c.listener.open, -1))
+	})
+	return err
+}
+
+// NewConnectionListener wraps l to send the number of open connections to statsd as the `server.connections` gauge
+//
+// Unlike request metrics this includes idle keep-alive connections and connections that have not sent a full request,
+// such as slow clients holding connections open
+//
+// Usage:
+//  l, err := net.Listen("tcp", ":1123")
+//  if err != nil {
+//      return err
+//  }
+//  http.Serve(metrics.NewConnectionListener(l, client, "service:api"), r)
+func NewConnectionListener(l net.Listener, client *statsd.Client, tags ...string) *ConnectionListener {
+	return &ConnectionListener{Listener: l, client: client, tags: tags}
+}
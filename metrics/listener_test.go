@@ -0,0 +1,69 @@
+// This file is part of graze/golang-service
+//
+// Copyright (c) 2016 Nature Delivered Ltd. <https://www.graze.com>
+//
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+//
+// license: https://github.com/graze/golang-service/blob/master/LICENSE
+// link:    https://github.com/graze/golang-service
+
+package metrics
+
+import (
+	"net"
+	"os"
+	"testing"
+
+	"github.com/DataDog/datadog-go/statsd"
+	"github.com/graze/golang-service/nettest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnectionListener(t *testing.T) {
+	done := make(chan string)
+	addr, sock, srvWg := nettest.CreateServer(t, "udp", "localhost:", done)
+	defer srvWg.Wait()
+	defer os.Remove(addr.String())
+	defer sock.Close()
+
+	client, err := statsd.New(addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener := NewConnectionListener(l, client, "service:test")
+	defer listener.Close()
+
+	var conns []net.Conn
+	for i := 1; i <= 2; i++ {
+		client, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer client.Close()
+		conn, err := listener.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		conns = append(conns, conn)
+		assert.Equal(t, int64(i), listener.Connections())
+	}
+	assert.Equal(t, "server.connections:1.000000|g|#service:test", <-done)
+	assert.Equal(t, "server.connections:2.000000|g|#service:test", <-done)
+
+	assert.Nil(t, conns[0].Close())
+	assert.Equal(t, "server.connections:1.000000|g|#service:test", <-done)
+	assert.Equal(t, int64(1), listener.Connections())
+
+	conns[0].Close()
+	assert.Equal(t, int64(1), listener.Connections(), "closing a connection twice is only counted once")
+
+	assert.Nil(t, conns[1].Close())
+	assert.Equal(t, "server.connections:0.000000|g|#service:test", <-done)
+	assert.Equal(t, int64(0), listener.Connections())
+}